@@ -0,0 +1,17 @@
+// Command anonymize replaces the IP addresses, host names, user names
+// and other identifying values of the sample events of packages. See
+// cmd/docs-template-update/README.md for its options.
+package main
+
+import (
+	"os"
+
+	"github.com/kgeller/go-examples/internal/doctools"
+)
+
+func main() {
+	shutdownTracing := doctools.SetupTracing("anonymize")
+	defer shutdownTracing()
+
+	doctools.RunAnonymize(os.Args[1:])
+}
@@ -0,0 +1,16 @@
+// Command changelog writes the changelog entry of a package change with an LLM.
+// See cmd/docs-template-update/README.md for its options.
+package main
+
+import (
+	"os"
+
+	"github.com/kgeller/go-examples/internal/doctools"
+)
+
+func main() {
+	shutdownTracing := doctools.SetupTracing("changelog")
+	defer shutdownTracing()
+
+	doctools.RunChangelog(os.Args[1:])
+}
@@ -0,0 +1,9 @@
+// Command docs-template-update migrates the readmes of Elastic integration
+// packages to the new documentation template with an LLM.
+package main
+
+import "github.com/kgeller/go-examples/internal/doctools"
+
+func main() {
+	doctools.Main()
+}
@@ -0,0 +1,16 @@
+// Command drift reports how far the readmes of packages are behind the
+// readme template. See cmd/docs-template-update/README.md for its options.
+package main
+
+import (
+	"os"
+
+	"github.com/kgeller/go-examples/internal/doctools"
+)
+
+func main() {
+	shutdownTracing := doctools.SetupTracing("drift")
+	defer shutdownTracing()
+
+	doctools.RunDrift(os.Args[1:])
+}
@@ -0,0 +1,16 @@
+// Command ecs-mappings reports the ECS mappings of the fields of a
+// package. See cmd/docs-template-update/README.md for its options.
+package main
+
+import (
+	"os"
+
+	"github.com/kgeller/go-examples/internal/doctools"
+)
+
+func main() {
+	shutdownTracing := doctools.SetupTracing("ecs-mappings")
+	defer shutdownTracing()
+
+	doctools.RunECSMappings(os.Args[1:])
+}
@@ -0,0 +1,16 @@
+// Command fields renders the exported fields tables of the data streams of a
+// package. See cmd/docs-template-update/README.md for its options.
+package main
+
+import (
+	"os"
+
+	"github.com/kgeller/go-examples/internal/doctools"
+)
+
+func main() {
+	shutdownTracing := doctools.SetupTracing("fields")
+	defer shutdownTracing()
+
+	doctools.RunFields(os.Args[1:])
+}
@@ -0,0 +1,16 @@
+// Command qa indexes the docs of packages and answers questions about
+// them with an LLM. See cmd/docs-template-update/README.md for its options.
+package main
+
+import (
+	"os"
+
+	"github.com/kgeller/go-examples/internal/doctools"
+)
+
+func main() {
+	shutdownTracing := doctools.SetupTracing("qa")
+	defer shutdownTracing()
+
+	doctools.RunQA(os.Args[1:])
+}
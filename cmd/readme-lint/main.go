@@ -0,0 +1,17 @@
+// Command readme-lint checks migrated readmes against the template, the
+// terminology rules and the readability limits. See
+// cmd/docs-template-update/README.md for its options.
+package main
+
+import (
+	"os"
+
+	"github.com/kgeller/go-examples/internal/doctools"
+)
+
+func main() {
+	shutdownTracing := doctools.SetupTracing("readme-lint")
+	defer shutdownTracing()
+
+	doctools.RunReadmeLint(os.Args[1:])
+}
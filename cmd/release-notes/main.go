@@ -0,0 +1,16 @@
+// Command release-notes writes the release notes of a package version
+// with an LLM. See cmd/docs-template-update/README.md for its options.
+package main
+
+import (
+	"os"
+
+	"github.com/kgeller/go-examples/internal/doctools"
+)
+
+func main() {
+	shutdownTracing := doctools.SetupTracing("release-notes")
+	defer shutdownTracing()
+
+	doctools.RunReleaseNotes(os.Args[1:])
+}
@@ -0,0 +1,319 @@
+package doctools
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// collectionPrompt is added to the readme prompt with the collection details
+// read from the agent templates of the package
+const collectionPrompt = `
+
+Use these collection details, read from the Elastic Agent templates of the package, to describe in the How it works section how the data is collected: the inputs, the endpoints or files they read and how often they poll. Values in {{ }} are set by the user when adding the integration.
+%s`
+
+// streamCollection describes how an agent template collects data
+type streamCollection struct {
+	// DataStream is empty for the templates of input packages.
+	DataStream string `json:"data_stream,omitempty"`
+	// Template is the path of the template in the package.
+	Template  string   `json:"template"`
+	Input     string   `json:"input"`
+	Endpoints []string `json:"endpoints,omitempty"`
+	Intervals []string `json:"intervals,omitempty"`
+	// TLS reports whether the template configures SSL/TLS.
+	TLS bool `json:"tls,omitempty"`
+}
+
+// Settings of agent templates that describe where and how often data is
+// collected, matched on the last part of their key
+var (
+	endpointKeys = []string{"url", "urls", "hosts", "host", "paths", "listen_address", "listen_port", "queue_url", "bucket_arn", "endpoint", "topics", "resource.url", "request.url"}
+	intervalKeys = []string{"interval", "period", "scan_frequency", "bucket_list_interval", "sqs.wait_time"}
+)
+
+var (
+	templateKeyPattern  = regexp.MustCompile(`^(\s*)(?:-\s+)?([A-Za-z0-9_.\-]+):\s*(.*)$`)
+	templateItemPattern = regexp.MustCompile(`^\s*-\s+(.*)$`)
+	templateEachPattern = regexp.MustCompile(`^\s*\{\{~?#each\s+([A-Za-z0-9_.]+)`)
+	templateVarPattern  = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.]+)\s*\}\}`)
+)
+
+// dataStreamManifest is the part of a data stream's manifest.yml describing
+// its type and streams
+type dataStreamManifest struct {
+	// Type is the type of the data stream, such as logs or metrics.
+	Type    string `yaml:"type"`
+	Streams []struct {
+		Input        string        `yaml:"input"`
+		TemplatePath string        `yaml:"template_path"`
+		Vars         []manifestVar `yaml:"vars"`
+	} `yaml:"streams"`
+}
+
+// plainDefault formats the default value of a setting for the prompt
+func plainDefault(value any) string {
+	if items, ok := value.([]any); ok {
+		values := make([]string, len(items))
+		for i, item := range items {
+			values[i] = plainDefault(item)
+		}
+		return strings.Join(values, ", ")
+	}
+	return fmt.Sprint(value)
+}
+
+// varDefaults returns the default values of settings by name
+func varDefaults(vars ...[]manifestVar) map[string]string {
+	defaults := make(map[string]string)
+	for _, list := range vars {
+		for _, v := range list {
+			if v.Default != nil && !v.Secret {
+				defaults[v.Name] = plainDefault(v.Default)
+			}
+		}
+	}
+	return defaults
+}
+
+// setupDefaults returns the defaults of the package, policy template and
+// input settings, the agent templates of every data stream can use them
+func setupDefaults(setup *packageSetup) map[string]string {
+	if setup == nil {
+		return map[string]string{}
+	}
+	vars := [][]manifestVar{setup.Vars}
+	for _, pt := range setup.PolicyTemplates {
+		vars = append(vars, pt.Vars)
+		for _, in := range pt.Inputs {
+			vars = append(vars, in.Vars)
+		}
+	}
+	return varDefaults(vars...)
+}
+
+// matchesKey reports whether a dotted template key ends with one of keys
+func matchesKey(key string, keys []string) bool {
+	return slices.ContainsFunc(keys, func(k string) bool {
+		return key == k || strings.HasSuffix(key, "."+k)
+	})
+}
+
+// parseStreamTemplate extracts the endpoints, intervals and TLS settings of
+// an agent template. Variables are replaced with their defaults where known.
+func parseStreamTemplate(data []byte, defaults map[string]string) streamCollection {
+	var c streamCollection
+	type entry struct {
+		indent int
+		key    string
+	}
+	var (
+		parents []entry
+		each    []string
+		listKey string
+	)
+	resolve := func(value string) string {
+		return templateVarPattern.ReplaceAllStringFunc(value, func(match string) string {
+			name := templateVarPattern.FindStringSubmatch(match)[1]
+			if name == "this" && len(each) > 0 {
+				name = each[len(each)-1]
+			}
+			if d, ok := defaults[name]; ok {
+				return d
+			}
+			return "{{" + name + "}}"
+		})
+	}
+	add := func(key, value string) {
+		value = strings.Trim(resolve(strings.TrimSpace(value)), `"'`)
+		if value == "" || value == "|" || value == ">" {
+			return
+		}
+		switch {
+		case matchesKey(key, endpointKeys):
+			c.Endpoints = append(c.Endpoints, key+": "+value)
+		case matchesKey(key, intervalKeys):
+			c.Intervals = append(c.Intervals, key+": "+value)
+		}
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if m := templateEachPattern.FindStringSubmatch(line); m != nil {
+			each = append(each, m[1])
+			continue
+		}
+		if strings.HasPrefix(trimmed, "{{/each") || strings.HasPrefix(trimmed, "{{~/each") {
+			if len(each) > 0 {
+				each = each[:len(each)-1]
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "{{") && strings.HasSuffix(trimmed, "}}") && !strings.Contains(trimmed, ":") {
+			// Other block helpers such as {{#if}} do not change the keys.
+			continue
+		}
+
+		if m := templateKeyPattern.FindStringSubmatch(line); m != nil {
+			indent := len(m[1])
+			if strings.HasPrefix(strings.TrimSpace(line), "-") {
+				indent += strings.Index(line[len(m[1]):], m[2])
+			}
+			for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+				parents = parents[:len(parents)-1]
+			}
+			key := m[2]
+			if len(parents) > 0 {
+				key = parents[len(parents)-1].key + "." + key
+			}
+			parents = append(parents, entry{indent, key})
+			if strings.HasPrefix(key, "ssl") || strings.Contains(key, ".ssl") {
+				c.TLS = true
+			}
+			listKey = ""
+			if m[3] == "" {
+				listKey = key
+			} else {
+				add(key, m[3])
+			}
+			continue
+		}
+		if m := templateItemPattern.FindStringSubmatch(line); m != nil && listKey != "" {
+			add(listKey, m[1])
+		}
+	}
+	return c
+}
+
+// readCollection reads how a package collects data from the agent templates
+// of its data streams, or of the package itself for input packages
+func readCollection(pkgPath string, req migrateRequest) ([]streamCollection, error) {
+	defaults := setupDefaults(req.Setup)
+	if req.PackageType == packageTypeInput {
+		collection, err := readTemplates(pkgPath, "", filepath.Join("agent", "input"), nil, defaults)
+		if err != nil {
+			return nil, err
+		}
+		setPackageInput(collection, req.Setup)
+		return collection, nil
+	}
+
+	var collection []streamCollection
+	for _, ds := range req.DataStreams {
+		dsPath := filepath.Join("data_stream", ds)
+		var manifest dataStreamManifest
+		if data, err := pkgFS.ReadFile(filepath.Join(pkgPath, dsPath, "manifest.yml")); err == nil {
+			if err := yaml.Unmarshal(data, &manifest); err != nil {
+				return nil, fmt.Errorf("failed to parse %s manifest: %w", ds, err)
+			}
+		}
+		templates, err := readTemplates(pkgPath, ds, filepath.Join(dsPath, "agent", "stream"), &manifest, defaults)
+		if err != nil {
+			return nil, err
+		}
+		collection = append(collection, templates...)
+	}
+	return collection, nil
+}
+
+// setPackageInput names the input of the templates of an input package after
+// its policy template, their file names do not
+func setPackageInput(collection []streamCollection, setup *packageSetup) {
+	if setup == nil || len(setup.PolicyTemplates) != 1 || setup.PolicyTemplates[0].Input == "" {
+		return
+	}
+	for i := range collection {
+		collection[i].Input = setup.PolicyTemplates[0].Input
+	}
+}
+
+// readTemplates parses the agent templates in dir, relative to the package.
+// The streams of the data stream manifest give the inputs of the templates
+// and the defaults of their settings.
+func readTemplates(pkgPath, dataStream, dir string, manifest *dataStreamManifest, defaults map[string]string) ([]streamCollection, error) {
+	entries, err := pkgFS.ReadDir(filepath.Join(pkgPath, dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var collection []streamCollection
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".yml.hbs") {
+			continue
+		}
+		data, err := pkgFS.ReadFile(filepath.Join(pkgPath, dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		collection = append(collection, streamTemplateCollection(dataStream, path.Join(filepath.ToSlash(dir), e.Name()), data, manifest, defaults))
+	}
+	return collection, nil
+}
+
+// streamTemplateCollection parses the agent template at templatePath with the
+// defaults of its stream in manifest
+func streamTemplateCollection(dataStream, templatePath string, data []byte, manifest *dataStreamManifest, defaults map[string]string) streamCollection {
+	name := path.Base(templatePath)
+	input := strings.TrimSuffix(name, ".yml.hbs")
+	streamDefaults := defaults
+	if manifest != nil {
+		for _, s := range manifest.Streams {
+			tp := s.TemplatePath
+			if tp == "" {
+				tp = "stream.yml.hbs"
+			}
+			if tp != name {
+				continue
+			}
+			if s.Input != "" {
+				input = s.Input
+			}
+			streamDefaults = make(map[string]string, len(defaults))
+			for k, v := range defaults {
+				streamDefaults[k] = v
+			}
+			for k, v := range varDefaults(s.Vars) {
+				streamDefaults[k] = v
+			}
+		}
+	}
+
+	c := parseStreamTemplate(data, streamDefaults)
+	c.DataStream, c.Template, c.Input = dataStream, templatePath, input
+	return c
+}
+
+// formatCollection formats the collection details for the prompt
+func formatCollection(collection []streamCollection) string {
+	var b strings.Builder
+	for _, c := range collection {
+		if c.DataStream != "" {
+			fmt.Fprintf(&b, "- data stream %s, %s input (%s)\n", c.DataStream, c.Input, c.Template)
+		} else {
+			fmt.Fprintf(&b, "- %s input (%s)\n", c.Input, c.Template)
+		}
+		for _, e := range c.Endpoints {
+			fmt.Fprintf(&b, "  - %s (endpoint)\n", e)
+		}
+		for _, i := range c.Intervals {
+			fmt.Fprintf(&b, "  - %s (polling interval)\n", i)
+		}
+		if c.TLS {
+			b.WriteString("  - SSL/TLS can be configured\n")
+		}
+	}
+	return b.String()
+}
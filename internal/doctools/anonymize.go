@@ -0,0 +1,207 @@
+package doctools
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// pipelineTestKeys are the fields of the pipeline test files holding their
+// documents: the events of the JSON test inputs and the documents of the
+// -expected.json files
+var pipelineTestKeys = []string{"events", "expected"}
+
+// anonymizeFile is a sample event or pipeline test file of a package
+type anonymizeFile struct {
+	path string
+	data []byte
+	// documents are the parsed documents of a JSON file, nil for text files
+	// such as the .log test inputs
+	documents any
+}
+
+// RunAnonymize runs the anonymize command with the arguments args
+func RunAnonymize(args []string) {
+	fs := flag.NewFlagSet("anonymize", flag.ExitOnError)
+	pkgPath := fs.String("path", "", "Path to the package to anonymize")
+	dir := fs.String("packages", "", "Directory of the packages to anonymize")
+	check := fs.Bool("check", false, "Print the changes as a patch without writing them, and exit with 1 if there are any")
+	fs.StringVar(&samplePolicyPath, "sample-policy", "", "YAML policy file of the replacements, see the README for its settings (default randomize all the IP addresses, hostnames and account IDs)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [options] [package ...]\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	registerVerbosityFlags(fs)
+	_ = fs.Parse(args)
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	pkgs := fs.Args()
+	if *pkgPath != "" {
+		pkgs = append(pkgs, *pkgPath)
+	}
+	if *dir != "" {
+		found, err := findPackages(*dir)
+		if err != nil {
+			log.Fatalf("Error listing packages: %v", err)
+		}
+		pkgs = append(pkgs, found...)
+	}
+	if len(pkgs) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := loadSamplePolicy(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if sampleSanitizer == nil {
+		sampleSanitizer = &samplePolicy{IPs: sanitizeRandomize, Hostnames: sanitizeRandomize, AccountIDs: sanitizeRandomize}
+	}
+
+	changed := false
+	for _, p := range pkgs {
+		patch, err := anonymizePackage(p, sampleSanitizer, *check)
+		if err != nil {
+			log.Fatalf("Error anonymizing %s: %v", p, err)
+		}
+		if patch == "" {
+			continue
+		}
+		changed = true
+		if *check {
+			fmt.Print(patch)
+		}
+	}
+	if *check && changed {
+		os.Exit(1)
+	}
+}
+
+// anonymizePackage replaces the IP addresses, hostnames and account IDs of
+// the sample events and pipeline tests of a package following the policy,
+// and returns the patch of the changes, written unless check is set. The
+// hostnames and account IDs found in any of the files are replaced in all of
+// them, so a hostname of an expected document is also replaced in the log
+// line it is parsed from.
+func anonymizePackage(pkgPath string, p *samplePolicy, check bool) (string, error) {
+	paths, err := anonymizePaths(pkgPath)
+	if err != nil {
+		return "", err
+	}
+	ids := newSampleIdentifiers()
+	files := make([]anonymizeFile, 0, len(paths))
+	for _, path := range paths {
+		data, err := pkgFS.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		f := anonymizeFile{path: path, data: data}
+		if strings.HasSuffix(path, ".json") {
+			var doc any
+			if err := json.Unmarshal(data, &doc); err != nil {
+				return "", fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			f.documents = pipelineTestDocuments(doc)
+			p.collect(f.documents, ids)
+		} else {
+			p.collectText(string(data), ids)
+		}
+		files = append(files, f)
+	}
+
+	var patches strings.Builder
+	for _, f := range files {
+		var content []byte
+		var replaced int
+		if f.documents != nil {
+			content, replaced = p.rewrite(f.data, f.documents, ids)
+		} else {
+			content, replaced = p.rewriteText(f.data, ids)
+		}
+		if bytes.Equal(content, f.data) {
+			continue
+		}
+		rel, err := filepath.Rel(pkgPath, f.path)
+		if err != nil {
+			rel = f.path
+		}
+		patch, err := generatePatch(rel, string(f.data), string(content))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate patch: %w", err)
+		}
+		patches.WriteString(patch)
+		if check {
+			continue
+		}
+		if err := pkgFS.WriteFile(f.path, content, 0o644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", f.path, err)
+		}
+		logInfo("Anonymized %d values in %s", replaced, f.path)
+	}
+	return patches.String(), nil
+}
+
+// anonymizePaths returns the sample events of a package and the files of the
+// pipeline tests of its data streams, leaving out their YAML configurations
+func anonymizePaths(pkgPath string) ([]string, error) {
+	dataStreams, err := findDataStreams(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	paths := sampleEventPaths(pkgPath, dataStreams)
+	for _, ds := range dataStreams {
+		dir := filepath.Join(pkgPath, "data_stream", ds, "_dev", "test", "pipeline")
+		entries, err := pkgFS.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pipeline tests: %w", err)
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if e.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml") {
+				continue
+			}
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// pipelineTestDocuments returns the documents of a pipeline test file, or
+// doc itself for sample events, so their fields are walked without the
+// events or expected prefix
+func pipelineTestDocuments(doc any) any {
+	if m, ok := doc.(map[string]any); ok && len(m) == 1 {
+		for _, key := range pipelineTestKeys {
+			if documents, ok := m[key].([]any); ok {
+				return documents
+			}
+		}
+	}
+	return doc
+}
+
+// rewriteText returns a text file, such as a log of the pipeline tests, with
+// its IP addresses and the identifiers of ids replaced following the policy,
+// and the number of lines that changed
+func (p *samplePolicy) rewriteText(data []byte, ids *sampleIdentifiers) ([]byte, int) {
+	lines := strings.SplitAfter(string(data), "\n")
+	replaced := 0
+	for i, line := range lines {
+		if sanitized := p.replaceString("", line, ids); sanitized != line {
+			lines[i] = sanitized
+			replaced++
+		}
+	}
+	return []byte(strings.Join(lines, "")), replaced
+}
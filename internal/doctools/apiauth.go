@@ -0,0 +1,204 @@
+package doctools
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// apiTokensEnv is the environment variable holding the path of the API
+// tokens file
+const apiTokensEnv = "DOCS_TEMPLATE_UPDATE_API_TOKENS"
+
+// apiToken is a bearer token of the API with the name of its holder
+type apiToken struct {
+	name  string
+	token string
+}
+
+// apiAuth authenticates the requests to the REST and gRPC APIs and the web
+// UI of serve, with a bearer token of -api-tokens or the identity header a
+// trusted proxy sets with -identity-header
+type apiAuth struct {
+	tokens []apiToken
+	// identityHeader is the header a trusted proxy in front of the server
+	// sets to the authenticated user.
+	identityHeader string
+	// insecure serves the API without authentication, with
+	// -insecure-no-auth.
+	insecure bool
+	// csrfKey signs the CSRF tokens of the forms of the web UI, it changes
+	// on every start.
+	csrfKey []byte
+}
+
+// newAPIAuth returns the auth of serve, with the tokens of the tokens file
+// at tokensPath if not empty
+func newAPIAuth(tokensPath, identityHeader string, insecure bool) (*apiAuth, error) {
+	a := &apiAuth{identityHeader: identityHeader, insecure: insecure, csrfKey: make([]byte, 32)}
+	if _, err := rand.Read(a.csrfKey); err != nil {
+		return nil, err
+	}
+	if tokensPath != "" {
+		var err error
+		if a.tokens, err = loadAPITokens(tokensPath); err != nil {
+			return nil, fmt.Errorf("failed to load API tokens: %w", err)
+		}
+	}
+	return a, nil
+}
+
+// loadAPITokens reads a tokens file, one "<name> <token>" per line. Blank
+// lines and lines starting with # are ignored.
+func loadAPITokens(path string) ([]apiToken, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tokens []apiToken
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want \"<name> <token>\"", path, n)
+		}
+		tokens = append(tokens, apiToken{name: fields[0], token: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%s has no tokens", path)
+	}
+	return tokens, nil
+}
+
+// enabled reports whether requests are authenticated
+func (a *apiAuth) enabled() bool {
+	return len(a.tokens) > 0 || a.identityHeader != ""
+}
+
+// authenticate returns the name of the holder of a bearer token, or the
+// identity set by the trusted proxy. It fails if neither is given, unless
+// the API is served without authentication.
+func (a *apiAuth) authenticate(authorization, identity string) (string, bool) {
+	if got, ok := strings.CutPrefix(authorization, "Bearer "); ok {
+		// Every token is compared so the time taken does not tell which
+		// one matched
+		name := ""
+		for _, t := range a.tokens {
+			if subtle.ConstantTimeCompare([]byte(got), []byte(t.token)) == 1 {
+				name = t.name
+			}
+		}
+		if name != "" {
+			return name, true
+		}
+	}
+	if a.identityHeader != "" {
+		if identity = strings.TrimSpace(identity); identity != "" {
+			return identity, true
+		}
+	}
+	return "", a.insecure
+}
+
+// identity returns the authenticated user of a request
+func (a *apiAuth) identity(r *http.Request) (string, bool) {
+	identity := ""
+	if a.identityHeader != "" {
+		identity = r.Header.Get(a.identityHeader)
+	}
+	return a.authenticate(r.Header.Get("Authorization"), identity)
+}
+
+// require only passes the authenticated requests on to h. Requests
+// changing state from a page of another site are refused, as the browser
+// sends the credentials of the proxy with them.
+func (a *apiAuth) require(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := a.identity(r); !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="docs-template-update"`)
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "authentication required"})
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && crossOrigin(r) {
+			writeJSON(w, http.StatusForbidden, errorResponse{Error: "cross-origin request refused"})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// crossOrigin reports whether a request comes from a page of another site,
+// from the Sec-Fetch-Site header browsers send or else from Origin. Other
+// clients send neither.
+func crossOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "":
+	case "same-origin", "none":
+		return false
+	default:
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err != nil || u.Host != r.Host
+}
+
+// csrfToken returns the token the forms of the web UI send back for a user
+func (a *apiAuth) csrfToken(identity string) string {
+	mac := hmac.New(sha256.New, a.csrfKey)
+	mac.Write([]byte(identity))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validCSRFToken reports whether the form of a request carries the CSRF
+// token of the user
+func (a *apiAuth) validCSRFToken(r *http.Request, identity string) bool {
+	return hmac.Equal([]byte(r.FormValue("csrf_token")), []byte(a.csrfToken(identity)))
+}
+
+// streamInterceptor only passes the authenticated gRPC streams on, with the
+// same bearer token or identity in their metadata
+func (a *apiAuth) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if _, ok := a.authenticate(grpcMetadata(ss.Context(), "authorization"), grpcMetadata(ss.Context(), a.identityHeader)); !ok {
+		return status.Error(codes.Unauthenticated, "authentication required")
+	}
+	return handler(srv, ss)
+}
+
+// grpcMetadata returns the first value of a key of the incoming metadata
+func grpcMetadata(ctx context.Context, key string) string {
+	if key == "" {
+		return ""
+	}
+	values := metadata.ValueFromIncomingContext(ctx, strings.ToLower(key))
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,341 @@
+package doctools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// approvalPending is the status of a patch of the webhook bot waiting for a
+// decision with -require-approval
+const approvalPending = "pending"
+
+// approvalEvent is an entry of the audit trail of a held patch
+type approvalEvent struct {
+	Approver string `json:"approver"`
+	// Decision is accepted or rejected.
+	Decision string    `json:"decision"`
+	Comment  string    `json:"comment,omitempty"`
+	At       time.Time `json:"at"`
+	// PullRequest is the URL of the pull request opened for an accepted
+	// patch, Error why it could not be opened.
+	PullRequest string `json:"pull_request,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// heldPatch is a migration of the webhook bot held for approval before its
+// pull request is opened
+type heldPatch struct {
+	ID      string `json:"id"`
+	Repo    string `json:"repo"`
+	Base    string `json:"base"`
+	SHA     string `json:"sha"`
+	Package string `json:"package"`
+	Path    string `json:"path"`
+	// Status is pending, accepted once the pull request is opened,
+	// rejected, or failed when it could not be opened; a failed patch can
+	// be accepted again.
+	Status      string          `json:"status"`
+	Warnings    []string        `json:"warnings,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	PullRequest string          `json:"pull_request,omitempty"`
+	Audit       []approvalEvent `json:"audit"`
+
+	migration *remoteMigration
+}
+
+// approvalQueue holds the patches of the webhook bot until a reviewer
+// accepts or rejects them. Accepting a patch opens its pull request. The
+// patches and their audit trails are kept in the history database, so they
+// survive restarts.
+type approvalQueue struct {
+	mu      sync.Mutex
+	patches map[string]*heldPatch
+	order   []string
+	history *historyStore
+	// open opens the pull request of an accepted patch and returns its URL
+	open func(p *heldPatch) (string, error)
+}
+
+// newApprovalQueue returns the queue of the patches held in the history
+// database
+func newApprovalQueue(history *historyStore) (*approvalQueue, error) {
+	q := &approvalQueue{patches: make(map[string]*heldPatch), history: history}
+	patches, err := history.heldPatches()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range patches {
+		q.patches[p.ID] = p
+		q.order = append(q.order, p.ID)
+	}
+	return q, nil
+}
+
+// saveHeldPatch stores a new held patch
+func (h *historyStore) saveHeldPatch(p *heldPatch) error {
+	result, err := json.Marshal(p.migration.result)
+	if err != nil {
+		return err
+	}
+	audit, err := json.Marshal(p.Audit)
+	if err != nil {
+		return err
+	}
+	_, err = h.db.Exec(`INSERT INTO approvals (id, repo, base, sha, pkg_dir, target_path, target_exists, original, result, status, created_at, pull_request, audit) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.ID, p.Repo, p.Base, p.SHA, p.migration.pkgDir, p.migration.targetPath, p.migration.targetExists, p.migration.original, string(result),
+		p.Status, p.CreatedAt, p.PullRequest, string(audit))
+	return err
+}
+
+// updateHeldPatch stores the status and audit trail of a held patch after
+// a decision
+func (h *historyStore) updateHeldPatch(p *heldPatch) error {
+	audit, err := json.Marshal(p.Audit)
+	if err != nil {
+		return err
+	}
+	_, err = h.db.Exec(`UPDATE approvals SET status = ?, pull_request = ?, audit = ? WHERE id = ?`, p.Status, p.PullRequest, string(audit), p.ID)
+	return err
+}
+
+// heldPatches returns the held patches, the oldest first
+func (h *historyStore) heldPatches() ([]*heldPatch, error) {
+	rows, err := h.db.Query(`SELECT id, repo, base, sha, pkg_dir, target_path, target_exists, original, result, status, created_at, pull_request, audit FROM approvals ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var patches []*heldPatch
+	for rows.Next() {
+		var p heldPatch
+		var result, audit string
+		m := &remoteMigration{result: &migrateResponse{}}
+		if err := rows.Scan(&p.ID, &p.Repo, &p.Base, &p.SHA, &m.pkgDir, &m.targetPath, &m.targetExists, &m.original, &result,
+			&p.Status, &p.CreatedAt, &p.PullRequest, &audit); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(result), m.result); err != nil {
+			return nil, fmt.Errorf("invalid result of held patch %s: %w", p.ID, err)
+		}
+		if err := json.Unmarshal([]byte(audit), &p.Audit); err != nil {
+			return nil, fmt.Errorf("invalid audit trail of held patch %s: %w", p.ID, err)
+		}
+		p.Package, p.Path, p.Warnings, p.migration = path.Base(m.pkgDir), m.targetPath, m.result.Warnings, m
+		patches = append(patches, &p)
+	}
+	return patches, rows.Err()
+}
+
+// hold adds the migration of a push to the queue
+func (q *approvalQueue) hold(repo, base, sha string, m *remoteMigration) (*heldPatch, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	p := &heldPatch{
+		ID:        id,
+		Repo:      repo,
+		Base:      base,
+		SHA:       sha,
+		Package:   path.Base(m.pkgDir),
+		Path:      m.targetPath,
+		Status:    approvalPending,
+		Warnings:  m.result.Warnings,
+		CreatedAt: time.Now().UTC(),
+		Audit:     []approvalEvent{},
+		migration: m,
+	}
+	if err := q.history.saveHeldPatch(p); err != nil {
+		return nil, fmt.Errorf("failed to save the held patch: %w", err)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.patches[id] = p
+	q.order = append(q.order, id)
+	return p, nil
+}
+
+// copyPatch returns a copy of p safe to read without the lock
+func copyPatch(p *heldPatch) *heldPatch {
+	c := *p
+	c.Audit = slices.Clone(p.Audit)
+	return &c
+}
+
+// list returns the held patches, the newest first
+func (q *approvalQueue) list() []*heldPatch {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	patches := make([]*heldPatch, 0, len(q.order))
+	for _, id := range slices.Backward(q.order) {
+		patches = append(patches, copyPatch(q.patches[id]))
+	}
+	return patches
+}
+
+// get returns a held patch
+func (q *approvalQueue) get(id string) (*heldPatch, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	p, ok := q.patches[id]
+	if !ok {
+		return nil, false
+	}
+	return copyPatch(p), true
+}
+
+// decide records the decision of an approver, the authenticated user, on a
+// held patch in its audit trail. Accepting it opens its pull request.
+func (q *approvalQueue) decide(id, approver, decision, comment string) (*heldPatch, int, error) {
+	if decision != decisionAccepted && decision != decisionRejected {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid decision %q, must be %q or %q", decision, decisionAccepted, decisionRejected)
+	}
+
+	q.mu.Lock()
+	p, ok := q.patches[id]
+	if !ok {
+		q.mu.Unlock()
+		return nil, http.StatusNotFound, errors.New("patch not found")
+	}
+	if p.Status != approvalPending && p.Status != statusFailed {
+		q.mu.Unlock()
+		return nil, http.StatusConflict, fmt.Errorf("patch %s is already %s", id, p.Status)
+	}
+	// Marked as accepted while the pull request is opened, so it is only
+	// opened once
+	previous := p.Status
+	p.Status = decision
+	q.mu.Unlock()
+
+	event := approvalEvent{Approver: approver, Decision: decision, Comment: strings.TrimSpace(comment)}
+	var url string
+	var err error
+	if decision == decisionAccepted {
+		url, err = q.open(p)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	event.At = time.Now().UTC()
+	if err != nil {
+		p.Status = statusFailed
+		event.Error = err.Error()
+		log.Printf("Approvals: %s accepted %s of %s, failed to open the pull request: %v", approver, p.Package, p.Repo, err)
+	} else {
+		p.PullRequest, event.PullRequest = url, url
+		log.Printf("Approvals: %s %s %s of %s (was %s)", approver, decision, p.Package, p.Repo, previous)
+	}
+	p.Audit = append(p.Audit, event)
+	if saveErr := q.history.updateHeldPatch(p); saveErr != nil {
+		log.Printf("Approvals: failed to save the decision on %s: %v", p.ID, saveErr)
+		if err == nil {
+			return copyPatch(p), http.StatusInternalServerError, fmt.Errorf("failed to save the decision: %w", saveErr)
+		}
+	}
+	if err != nil {
+		return copyPatch(p), http.StatusBadGateway, fmt.Errorf("failed to open the pull request: %w", err)
+	}
+	return copyPatch(p), http.StatusOK, nil
+}
+
+// registerApprovalRoutes adds the endpoints and the web UI pages listing
+// the held patches and recording decisions on them to mux, for
+// authenticated requests only
+func registerApprovalRoutes(mux *http.ServeMux, q *approvalQueue, auth *apiAuth) {
+	handle := func(pattern string, h http.HandlerFunc) {
+		mux.Handle(pattern, auth.require(h))
+	}
+
+	handle("GET /v1/approvals", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, q.list())
+	})
+
+	handle("GET /v1/approvals/{id}", func(w http.ResponseWriter, r *http.Request) {
+		p, ok := q.get(r.PathValue("id"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: "patch not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	})
+
+	handle("GET /v1/approvals/{id}/patch", func(w http.ResponseWriter, r *http.Request) {
+		p, ok := q.get(r.PathValue("id"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: "patch not found"})
+			return
+		}
+		w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+		_, _ = w.Write([]byte(p.migration.result.Patch))
+	})
+
+	handle("POST /v1/approvals/{id}/decision", func(w http.ResponseWriter, r *http.Request) {
+		// Forms of other sites cannot send JSON without a preflight request
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			writeJSON(w, http.StatusUnsupportedMediaType, errorResponse{Error: "the decision must be sent as application/json"})
+			return
+		}
+		var req struct {
+			Decision string `json:"decision"`
+			Comment  string `json:"comment"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+			return
+		}
+		approver, _ := auth.identity(r)
+		p, status, err := q.decide(r.PathValue("id"), approver, req.Decision, req.Comment)
+		if err != nil {
+			writeJSON(w, status, errorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	})
+
+	handle("GET /ui/approvals", func(w http.ResponseWriter, _ *http.Request) {
+		renderUI(w, "approvals.html", map[string]any{
+			"Title":   "Approvals",
+			"Patches": q.list(),
+		})
+	})
+
+	handle("GET /ui/approvals/{id}", func(w http.ResponseWriter, r *http.Request) {
+		p, ok := q.get(r.PathValue("id"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		approver, _ := auth.identity(r)
+		renderUI(w, "approval.html", map[string]any{
+			"Title":     p.Package + " in " + p.Repo,
+			"Patch":     p,
+			"Diff":      diffLines(p.migration.result.Patch),
+			"Approver":  approver,
+			"CSRFToken": auth.csrfToken(approver),
+		})
+	})
+
+	handle("POST /ui/approvals/{id}/decision", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		approver, _ := auth.identity(r)
+		if !auth.validCSRFToken(r, approver) {
+			http.Error(w, "invalid CSRF token, reload the page", http.StatusForbidden)
+			return
+		}
+		if _, status, err := q.decide(id, approver, r.FormValue("decision"), r.FormValue("comment")); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		http.Redirect(w, r, "/ui/approvals/"+id, http.StatusSeeOther)
+	})
+}
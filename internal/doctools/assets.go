@@ -0,0 +1,253 @@
+package doctools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// kibanaAsset is a saved object shipped in the kibana/ directory of a package
+type kibanaAsset struct {
+	// Type is the saved object type, the name of its directory, e.g.
+	// dashboard.
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// assetTypeNames are the singular and plural names used to document asset
+// types, other types are listed by their directory name
+var assetTypeNames = map[string][2]string{
+	"dashboard":     {"dashboard", "dashboards"},
+	"visualization": {"visualization", "visualizations"},
+	"lens":          {"Lens visualization", "Lens visualizations"},
+	"search":        {"saved search", "saved searches"},
+	"map":           {"map", "maps"},
+	"tag":           {"tag", "tags"},
+	"security_rule": {"security rule", "security rules"},
+	"ml_module":     {"machine learning module", "machine learning modules"},
+}
+
+// parseKibanaAsset reads the metadata of a saved object. Titles and
+// descriptions are only read for dashboards, other assets are counted.
+func parseKibanaAsset(assetType, name string, data []byte) (kibanaAsset, error) {
+	asset := kibanaAsset{Type: assetType, ID: strings.TrimSuffix(name, filepath.Ext(name))}
+	if assetType != "dashboard" {
+		return asset, nil
+	}
+
+	var object struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Title       string `json:"title"`
+			Description string `json:"description"`
+		} `json:"attributes"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return asset, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if object.ID != "" {
+		asset.ID = object.ID
+	}
+	asset.Title = object.Attributes.Title
+	asset.Description = object.Attributes.Description
+	return asset, nil
+}
+
+// readKibanaAssets returns the saved objects of a package, sorted by type and
+// title
+func readKibanaAssets(pkgPath string) ([]kibanaAsset, error) {
+	dir := filepath.Join(pkgPath, "kibana")
+	types, err := pkgFS.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var assets []kibanaAsset
+	for _, t := range types {
+		if !t.IsDir() {
+			continue
+		}
+		files, err := pkgFS.ReadDir(filepath.Join(dir, t.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+				continue
+			}
+			var data []byte
+			if t.Name() == "dashboard" {
+				if data, err = pkgFS.ReadFile(filepath.Join(dir, t.Name(), f.Name())); err != nil {
+					return nil, err
+				}
+			}
+			asset, err := parseKibanaAsset(t.Name(), f.Name(), data)
+			if err != nil {
+				return nil, err
+			}
+			assets = append(assets, asset)
+		}
+	}
+	sortAssets(assets)
+	return assets, nil
+}
+
+// sortAssets orders assets by type and title
+func sortAssets(assets []kibanaAsset) {
+	sort.Slice(assets, func(i, j int) bool {
+		if assets[i].Type != assets[j].Type {
+			return assets[i].Type < assets[j].Type
+		}
+		if assets[i].Title != assets[j].Title {
+			return assets[i].Title < assets[j].Title
+		}
+		return assets[i].ID < assets[j].ID
+	})
+}
+
+// dashboardsPrompt is added to the readme prompt of packages with
+// dashboards, their section is generated
+const dashboardsPrompt = `
+
+Do not write a Dashboards section, a Reference -> Dashboards section is generated from the Kibana assets of the package and added after your response.`
+
+// screenshotsPath is where the readme links to images from, the rendered
+// readme is in docs/ next to img/
+const screenshotsPath = ".."
+
+// hasAssetsSection reports whether the readme of req gets a generated
+// Dashboards section
+func hasAssetsSection(req migrateRequest) bool {
+	return req.PackageType == packageTypeContent || len(req.Assets) > 0
+}
+
+// presentScreenshots returns the screenshots whose image is in the package
+func presentScreenshots(screenshots []screenshot, exists func(src string) bool) []screenshot {
+	var present []screenshot
+	for _, s := range screenshots {
+		if strings.HasPrefix(s.Src, "/img/") && exists(s.Src) {
+			present = append(present, s)
+		}
+	}
+	return present
+}
+
+// readScreenshots returns the screenshots of the manifest found in the img/
+// directory of a package
+func readScreenshots(pkgPath string, screenshots []screenshot) []screenshot {
+	return presentScreenshots(screenshots, func(src string) bool {
+		_, err := pkgFS.Stat(filepath.Join(pkgPath, filepath.FromSlash(src)))
+		return err == nil
+	})
+}
+
+// assetsSection documents the dashboards of a package with their
+// screenshots and counts its other assets, as a section with a heading of the
+// given level
+func assetsSection(assets []kibanaAsset, screenshots []screenshot, level int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s Dashboards\n\n", strings.Repeat("#", level))
+
+	counts := make(map[string]int)
+	var dashboards []kibanaAsset
+	for _, a := range assets {
+		if a.Type == "dashboard" {
+			dashboards = append(dashboards, a)
+		} else {
+			counts[a.Type]++
+		}
+	}
+
+	if len(dashboards) == 0 {
+		b.WriteString("This package does not include dashboards.\n")
+	} else {
+		b.WriteString("This package includes the following dashboards:\n\n")
+		for _, d := range dashboards {
+			title := d.Title
+			if title == "" {
+				title = d.ID
+			}
+			if d.Description != "" {
+				fmt.Fprintf(&b, "* **%s**: %s\n", title, strings.Join(strings.Fields(d.Description), " "))
+			} else {
+				fmt.Fprintf(&b, "* **%s**\n", title)
+			}
+		}
+	}
+
+	if len(counts) > 0 {
+		types := make([]string, 0, len(counts))
+		for t := range counts {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		parts := make([]string, len(types))
+		for i, t := range types {
+			name := t
+			if names, ok := assetTypeNames[t]; ok {
+				name = names[min(counts[t], 2)-1]
+			}
+			parts[i] = fmt.Sprintf("%d %s", counts[t], name)
+		}
+		fmt.Fprintf(&b, "\nIt also includes %s.\n", strings.Join(parts, ", "))
+	}
+
+	for _, s := range screenshots {
+		title := s.Title
+		if title == "" {
+			title = strings.TrimSuffix(path.Base(s.Src), path.Ext(s.Src))
+		}
+		fmt.Fprintf(&b, "\n![%s](%s%s)\n", title, screenshotsPath, s.Src)
+	}
+	return b.String()
+}
+
+// applyAssetsSection replaces the Dashboards section under Reference with one
+// generated from the assets and screenshots, adding the Reference section if
+// needed
+func applyAssetsSection(content string, assets []kibanaAsset, screenshots []screenshot) string {
+	return applyReferenceSection(content, "dashboards", func(level int) string {
+		return assetsSection(assets, screenshots, level)
+	})
+}
+
+// applyReferenceSection replaces the section of the given heading under
+// Reference with the one section returns for its heading level, at the end
+// of the Reference section, adding the Reference section if needed
+func applyReferenceSection(content, heading string, section func(level int) string) string {
+	lines := markdown.ParseLines(content)
+	ref := markdown.FindHeading(lines, "reference")
+	if ref < 0 {
+		return strings.TrimRight(content, "\n") + "\n\n## Reference\n\n" + section(3)
+	}
+
+	// Drop the section written by an earlier run or the model.
+	refEnd := markdown.SectionEnd(lines, ref)
+	for i := ref + 1; i < refEnd; i++ {
+		if lines[i].Level > 0 && strings.EqualFold(lines[i].Heading, heading) {
+			end := min(markdown.SectionEnd(lines, i), refEnd)
+			lines = append(lines[:i:i], lines[end:]...)
+			refEnd -= end - i
+			i--
+		}
+	}
+
+	before := strings.TrimRight(markdown.ShiftHeadings(lines[:refEnd], 0), "\n")
+	after := markdown.ShiftHeadings(lines[refEnd:], 0)
+	generated := section(min(lines[ref].Level+1, 6))
+	if after == "" {
+		return before + "\n\n" + generated
+	}
+	return before + "\n\n" + generated + "\n" + after
+}
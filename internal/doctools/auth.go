@@ -0,0 +1,87 @@
+package doctools
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+// The API key is stored in the OS keychain under this service and user
+const (
+	keyringService = "docs-template-update"
+	keyringUser    = "gemini-api-key"
+)
+
+// runAuth implements the auth subcommand
+func runAuth(args []string) {
+	if len(args) == 0 || (args[0] != "login" && args[0] != "logout") {
+		fmt.Fprintf(os.Stderr, "Usage: %s auth login|logout\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "login stores the Google Gemini API key in the OS keychain, logout removes it.\n")
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "login":
+		key, err := readAPIKey()
+		if err != nil {
+			log.Fatalf("Error reading API key: %v", err)
+		}
+		if err := keyring.Set(keyringService, keyringUser, key); err != nil {
+			log.Fatalf("Error storing API key in the keychain: %v", err)
+		}
+		fmt.Println("API key stored in the keychain")
+	case "logout":
+		err := keyring.Delete(keyringService, keyringUser)
+		if errors.Is(err, keyring.ErrNotFound) {
+			fmt.Println("No API key stored in the keychain")
+			return
+		}
+		if err != nil {
+			log.Fatalf("Error removing API key from the keychain: %v", err)
+		}
+		fmt.Println("API key removed from the keychain")
+	}
+}
+
+// readAPIKey prompts for the API key without echoing it, or reads it from
+// stdin when it is not a terminal
+func readAPIKey() (string, error) {
+	var key string
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Google Gemini API key: ")
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		key = string(b)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		key = line
+	}
+
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", errors.New("API key is empty")
+	}
+	return key, nil
+}
+
+// keychainAPIKey returns the API key stored by auth login, or an empty
+// string if there is none or the keychain is not available
+func keychainAPIKey() string {
+	key, err := keyring.Get(keyringService, keyringUser)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) && verbose {
+		log.Printf("Could not read the API key from the keychain: %v", err)
+	}
+	return key
+}
@@ -0,0 +1,171 @@
+package doctools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// defaultSubscription is the subscription of packages whose manifest has no
+// conditions.elastic.subscription
+const defaultSubscription = "basic"
+
+var (
+	// regenerateBadges is set with -regenerate-badges
+	regenerateBadges bool
+
+	// badgeURLPattern matches the addresses of badge images, such as those
+	// of shields.io
+	badgeURLPattern = regexp.MustCompile(`(?i)shields\.io|badge|\.svg(?:\?|$)`)
+	// standardBadgePattern matches the badges -regenerate-badges replaces,
+	// by their text or address
+	standardBadgePattern = regexp.MustCompile(`(?i)version|subscription`)
+)
+
+// readmeBadges returns the lines of badges at the top of a readme, before
+// its first heading or right after its title. Only lines holding nothing but
+// badge images, linked or not, are badges.
+func readmeBadges(content string) []string {
+	var badges []string
+	seen := false
+	for _, line := range markdown.ParseLines(content) {
+		text := strings.TrimSpace(line.Text)
+		switch {
+		case text == "":
+			continue
+		case line.Level == 1 && !seen:
+		case isBadgeLine(text):
+			badges = append(badges, text)
+		default:
+			return badges
+		}
+		seen = true
+	}
+	return badges
+}
+
+// isBadgeLine reports whether a line holds only badge images
+func isBadgeLine(text string) bool {
+	images := imagePattern.FindAllStringSubmatch(text, -1)
+	if len(images) == 0 || strings.TrimSpace(imagePattern.ReplaceAllString(text, "")) != "" {
+		return false
+	}
+	for _, m := range images {
+		if !badgeURLPattern.MatchString(m[1] + m[2]) {
+			return false
+		}
+	}
+	return true
+}
+
+// packageBadges returns the badges of the migrated readme of a package: those
+// of the original readme and, with -regenerate-badges, version and
+// subscription badges generated from manifest.yml in place of the original
+// ones
+func packageBadges(req migrateRequest) []string {
+	badges := readmeBadges(req.Readme)
+	if !regenerateBadges {
+		return badges
+	}
+	var kept []string
+	for _, line := range badges {
+		line = strings.TrimSpace(imagePattern.ReplaceAllStringFunc(line, func(image string) string {
+			if standardBadgePattern.MatchString(image) {
+				return ""
+			}
+			return image
+		}))
+		if line != "" {
+			kept = append(kept, strings.Join(strings.Fields(line), " "))
+		}
+	}
+	return append([]string{generatedBadges(req.Version, req.Subscription)}, kept...)
+}
+
+// generatedBadges returns the version and subscription badges of a package,
+// the version badge only if the version is known
+func generatedBadges(version, subscription string) string {
+	if subscription == "" {
+		subscription = defaultSubscription
+	}
+	color := "blue"
+	if subscription == defaultSubscription {
+		color = "green"
+	}
+	badge := fmt.Sprintf("![Subscription](https://img.shields.io/badge/subscription-%s-%s)", shieldsText(subscription), color)
+	if version == "" {
+		return badge
+	}
+	return fmt.Sprintf("![Version](https://img.shields.io/badge/version-%s-blue) %s", shieldsText(version), badge)
+}
+
+// shieldsText escapes text for a shields.io badge address, where dashes and
+// underscores separate the parts of the badge
+func shieldsText(text string) string {
+	return strings.NewReplacer("-", "--", "_", "__", " ", "%20").Replace(text)
+}
+
+// applyBadges puts the badges right after the title of a migrated readme, or
+// at its top if it has none. Lines elsewhere holding only these badges, or
+// badges -regenerate-badges replaces, are removed, so the LLM moving them
+// does not duplicate them.
+func applyBadges(content string, badges []string) string {
+	if len(badges) == 0 {
+		return content
+	}
+	keep := make(map[string]bool)
+	for _, line := range badges {
+		for _, m := range imagePattern.FindAllStringSubmatch(line, -1) {
+			keep[m[1]+m[2]] = true
+		}
+	}
+
+	var texts []string
+	title := -1
+	for _, line := range markdown.ParseLines(content) {
+		if line.Level == 1 && title < 0 {
+			title = len(texts)
+		}
+		text := strings.TrimSpace(line.Text)
+		if line.Level == 0 && isBadgeLine(text) && isKnownBadgeLine(text, keep) {
+			if len(texts) > 0 && strings.TrimSpace(texts[len(texts)-1]) == "" {
+				// Drop the blank line before it too
+				texts = texts[:len(texts)-1]
+			}
+			continue
+		}
+		texts = append(texts, line.Text)
+	}
+
+	var before, after []string
+	if title >= 0 {
+		before, after = texts[:title+1], texts[title+1:]
+	} else {
+		after = texts
+	}
+	for len(after) > 0 && strings.TrimSpace(after[0]) == "" {
+		after = after[1:]
+	}
+	var b strings.Builder
+	if len(before) > 0 {
+		b.WriteString(strings.Join(before, "\n") + "\n\n")
+	}
+	b.WriteString(strings.Join(badges, "\n") + "\n")
+	if len(after) > 0 {
+		b.WriteString("\n" + strings.Join(after, "\n"))
+	}
+	return b.String()
+}
+
+// isKnownBadgeLine reports whether every badge of a line is one of keep or,
+// with -regenerate-badges, one it replaces
+func isKnownBadgeLine(text string, keep map[string]bool) bool {
+	for _, m := range imagePattern.FindAllStringSubmatch(text, -1) {
+		if !keep[m[1]+m[2]] && !(regenerateBadges && standardBadgePattern.MatchString(m[0])) {
+			return false
+		}
+	}
+	return true
+}
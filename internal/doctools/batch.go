@@ -0,0 +1,341 @@
+package doctools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+// statusSkipped marks packages a batch run did not start because its
+// -max-duration was exceeded, or because none of their data streams changed
+// since -since
+const statusSkipped = "skipped"
+
+// batchReport is the JSON report of a batch run. The checkpoint of an
+// interrupted run uses the same format.
+type batchReport struct {
+	Model string `json:"model"`
+	// PromptVersion is the promptVersion the packages were migrated with.
+	PromptVersion string    `json:"prompt_version"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	// Interrupted reports the run was stopped before all packages were
+	// processed.
+	Interrupted bool `json:"interrupted"`
+	// DeadlineExceeded reports the run stopped scheduling packages because
+	// it ran out of time.
+	DeadlineExceeded bool `json:"deadline_exceeded"`
+	// Usage and CostUSD are the totals of the packages migrated by this run,
+	// failed ones included, resumed packages are not.
+	Usage    llmclient.Usage `json:"usage"`
+	CostUSD  float64         `json:"cost_usd"`
+	Packages []packageReport `json:"packages"`
+}
+
+// packageReport is the result of a package in a batch run
+type packageReport struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// Owner is the GitHub team owning the package, from its manifest.
+	Owner  string `json:"owner,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Stage is the pipeline stage the package failed in.
+	Stage    string   `json:"stage,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Findings classifies the warnings, style alerts, gaps and TODOs by
+	// severity.
+	Findings []finding `json:"findings,omitempty"`
+	// Edits is the edit log of -response-format operations.
+	Edits []editOperation `json:"edits,omitempty"`
+	// Consolidated lists the docs/ files merged into the readme.
+	Consolidated []string `json:"consolidated,omitempty"`
+	// Style lists the style issues of the migrated readme, with -vale.
+	Style []styleAlert `json:"style,omitempty"`
+	// Readability scores the overview and setup sections of the migrated
+	// readme.
+	Readability []sectionReadability `json:"readability,omitempty"`
+	// Gaps lists the template sections of the migrated readme that have no
+	// content.
+	Gaps []docsGap `json:"gaps,omitempty"`
+	// Todos lists the guidance and removal notes the LLM added to the
+	// migrated readme.
+	Todos []todo `json:"todos,omitempty"`
+	// FollowUpIssue is the URL of the issue opened for the TODOs.
+	FollowUpIssue string `json:"follow_up_issue,omitempty"`
+	// FollowUpTicket is the URL of the Jira ticket created for the TODOs.
+	FollowUpTicket string `json:"follow_up_ticket,omitempty"`
+	// Timings are the durations of the pipeline stages.
+	Timings []stageTiming   `json:"timings,omitempty"`
+	Usage   llmclient.Usage `json:"usage"`
+	// Backend is the provider and model that produced the readme.
+	Backend string `json:"backend,omitempty"`
+	// Candidates are the scores of the readmes of -candidates.
+	Candidates []candidateScore `json:"candidates,omitempty"`
+	// CostUSD is the sum of the costs of its calls, each estimated from the
+	// list price of the model that answered it.
+	CostUSD    float64 `json:"cost_usd"`
+	DurationMS int64   `json:"duration_ms"`
+	// Resumed reports the result was taken over from the checkpoint of an
+	// earlier, interrupted run.
+	Resumed bool `json:"resumed,omitempty"`
+}
+
+// failed reports whether any package of the run failed or was not processed
+func (r *batchReport) failed() bool {
+	for _, p := range r.Packages {
+		if p.Status != statusSucceeded {
+			return true
+		}
+	}
+	return r.incomplete()
+}
+
+// incomplete reports whether the run stopped before processing all packages
+func (r *batchReport) incomplete() bool {
+	return r.Interrupted || r.DeadlineExceeded
+}
+
+// findPackages returns the package directories directly below dir, those
+// with a manifest.yml, sorted by name
+func findPackages(dir string) ([]string, error) {
+	entries, err := pkgFS.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := pkgFS.Stat(filepath.Join(dir, e.Name(), "manifest.yml")); err == nil {
+			pkgs = append(pkgs, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(pkgs)
+	return pkgs, nil
+}
+
+// loadCheckpoint returns the packages that succeeded in the run recorded at
+// path, keyed by package path. A missing checkpoint is not an error.
+func loadCheckpoint(path string) (map[string]packageReport, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint batchReport
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	done := make(map[string]packageReport)
+	for _, p := range checkpoint.Packages {
+		if p.Status == statusSucceeded {
+			done[p.Path] = p
+		}
+	}
+	return done, nil
+}
+
+// writeReport writes a report as indented JSON, atomically so that a report
+// or checkpoint is never left half written
+func writeReport(path string, report *batchReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0o644)
+}
+
+// runBatch migrates every package below dir one after the other, printing
+// each patch as soon as it is ready. When ctx is cancelled the in-flight
+// package is abandoned without touching its readme and the remaining
+// packages are marked as cancelled. If checkpointPath is set, progress is
+// saved there after every package and packages that succeeded in an earlier
+// interrupted run are not migrated again. If maxDuration is not zero, no new
+// package is started once it elapsed; the package in flight is finished and
+// the remaining ones are marked as skipped.
+func runBatch(ctx context.Context, dir, checkpointPath string, maxDuration time.Duration) (*batchReport, error) {
+	pkgs, err := findPackages(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages: %w", err)
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no packages found in %s", dir)
+	}
+
+	var done map[string]packageReport
+	if checkpointPath != "" {
+		if done, err = loadCheckpoint(checkpointPath); err != nil {
+			return nil, err
+		}
+		if len(done) > 0 {
+			logInfo("Resuming from %s, %d packages already migrated", checkpointPath, len(done))
+		}
+	}
+
+	report := &batchReport{Model: modelName, PromptVersion: promptVersion(), StartedAt: time.Now().UTC()}
+	var deadline time.Time
+	if maxDuration > 0 {
+		deadline = report.StartedAt.Add(maxDuration)
+	}
+	for _, pkgPath := range pkgs {
+		if prev, ok := done[pkgPath]; ok {
+			prev.Resumed = true
+			report.Packages = append(report.Packages, prev)
+			continue
+		}
+
+		p := packageReport{Name: filepath.Base(pkgPath), Path: pkgPath}
+		if m, err := readManifest(pkgPath); err == nil {
+			p.Owner = m.Owner.Github
+		}
+		if ctx.Err() != nil {
+			p.Status = statusCancelled
+			report.Packages = append(report.Packages, p)
+			continue
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			if !report.DeadlineExceeded {
+				log.Printf("Run exceeded -max-duration %s, skipping the remaining packages", maxDuration)
+			}
+			report.DeadlineExceeded = true
+			p.Status = statusSkipped
+			report.Packages = append(report.Packages, p)
+			continue
+		}
+
+		if sinceRef != "" {
+			changed, err := changedDataStreams(pkgPath, sinceRef)
+			if err == nil && len(changed) == 0 {
+				logInfo("No data stream of %s changed since %s, skipping it", pkgPath, sinceRef)
+				p.Status = statusSkipped
+				report.Packages = append(report.Packages, p)
+				continue
+			}
+		}
+
+		if verbose {
+			log.Printf("Migrating %s", pkgPath)
+		}
+		started := time.Now()
+		result, err := processPackage(ctx, pkgPath)
+		p.DurationMS = time.Since(started).Milliseconds()
+		switch {
+		case err != nil && ctx.Err() != nil:
+			p.Status = statusCancelled
+		case err != nil:
+			p.Status = statusFailed
+			p.Error = redactSecrets(err.Error())
+			var se *stageError
+			if errors.As(err, &se) {
+				p.Stage = se.stage
+			}
+			log.Printf("Error processing %s: %v", pkgPath, err)
+		default:
+			p.Status = statusSucceeded
+			p.Warnings = result.Warnings
+			p.Findings = result.Findings
+			p.Edits = result.Edits
+			p.Consolidated = result.Consolidated
+			p.Style = result.Style
+			p.Readability = result.Readability
+			p.Gaps = result.Gaps
+			p.Todos = result.Todos
+			p.FollowUpIssue = result.FollowUpIssue
+			p.FollowUpTicket = result.FollowUpTicket
+			p.Timings = result.Timings
+			p.Backend = result.Backend
+			p.Candidates = result.Candidates
+			fmt.Println(result.Patch)
+		}
+		// Failed and cancelled packages still used tokens
+		if result != nil {
+			p.Usage = result.Usage
+			p.CostUSD = result.Usage.CostUSD
+		}
+		report.Usage.Add(p.Usage)
+		report.CostUSD += p.CostUSD
+		report.Packages = append(report.Packages, p)
+		if debugRuntime {
+			logRuntimeStats(pkgPath)
+		}
+
+		if checkpointPath != "" {
+			if err := writeReport(checkpointPath, report); err != nil {
+				log.Printf("Failed to write checkpoint: %v", err)
+			}
+		}
+	}
+
+	if ctx.Err() == nil {
+		checkCrossLinks(report)
+	}
+	report.FinishedAt = time.Now().UTC()
+	report.Interrupted = ctx.Err() != nil
+	if checkpointPath != "" {
+		if report.incomplete() {
+			err = writeReport(checkpointPath, report)
+		} else {
+			// A finished run has nothing to resume.
+			err = os.Remove(checkpointPath)
+			if errors.Is(err, os.ErrNotExist) {
+				err = nil
+			}
+		}
+		if err != nil {
+			log.Printf("Failed to update checkpoint: %v", err)
+		}
+	}
+	return report, nil
+}
+
+// recordBatchHistory stores a batch run in the history database at path
+func recordBatchHistory(path string, report *batchReport) error {
+	history, err := openHistory(path)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	run := runRecord{
+		ID:            id,
+		Mode:          "batch",
+		Model:         report.Model,
+		PromptVersion: report.PromptVersion,
+		StartedAt:     report.StartedAt,
+		FinishedAt:    report.FinishedAt,
+	}
+	for _, p := range report.Packages {
+		// Resumed packages were recorded by the run that migrated them.
+		if p.Resumed {
+			continue
+		}
+		run.Packages = append(run.Packages, packageRecord{
+			Name:     p.Name,
+			Owner:    p.Owner,
+			Status:   p.Status,
+			Error:    p.Error,
+			Usage:    p.Usage,
+			Duration: time.Duration(p.DurationMS) * time.Millisecond,
+			Findings: p.Warnings,
+		})
+	}
+	return history.recordRun(run)
+}
@@ -0,0 +1,212 @@
+package doctools
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+// benchmarkResult is the result of a model on a package
+type benchmarkResult struct {
+	Model   string `json:"model"`
+	Package string `json:"package"`
+	Failed  bool   `json:"failed,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Findings is the number of validation findings of the migrated readme,
+	// Gaps the number of template sections left without content.
+	Findings   int             `json:"findings"`
+	Gaps       int             `json:"gaps"`
+	DurationMS int64           `json:"duration_ms"`
+	Usage      llmclient.Usage `json:"usage"`
+	CostUSD    float64         `json:"cost_usd"`
+}
+
+// benchmarkSummary aggregates the results of a model over the packages
+type benchmarkSummary struct {
+	Model    string  `json:"model"`
+	Packages int     `json:"packages"`
+	Failed   int     `json:"failed"`
+	Findings float64 `json:"findings_per_package"`
+	Gaps     float64 `json:"gaps_per_package"`
+	// MedianMS and MaxMS are the latencies of a package migration.
+	MedianMS int64           `json:"median_ms"`
+	MaxMS    int64           `json:"max_ms"`
+	Usage    llmclient.Usage `json:"usage"`
+	CostUSD  float64         `json:"cost_usd"`
+}
+
+// benchmarkReport is the JSON report of the benchmark subcommand
+type benchmarkReport struct {
+	StartedAt time.Time          `json:"started_at"`
+	Summary   []benchmarkSummary `json:"summary"`
+	Results   []benchmarkResult  `json:"results"`
+}
+
+// runBenchmark implements the benchmark subcommand
+func runBenchmark(args []string) {
+	fs := flag.NewFlagSet("benchmark", flag.ExitOnError)
+	dir := fs.String("packages", "", "Directory of the packages to migrate with every model (required)")
+	modelsFlag := fs.String("models", "", "Comma separated models to compare, e.g. gemini-2.5-pro,gemini-2.5-flash; fake compares the fake provider (required)")
+	reportFile := fs.String("report", "", "Write the results of every package to this JSON file")
+	fs.StringVar(&fakeResponsePath, "fake-response", "", "Markdown file the fake model answers every prompt with")
+	fs.StringVar(&googleAPIKey, "api-key", "", "Google Gemini API key")
+	fs.StringVar(&apiKeyRef, "api-key-ref", "", secretRefHelp)
+	registerVerbosityFlags(fs)
+	registerNetworkFlags(fs)
+	registerTimeoutFlags(fs)
+	registerGlossaryFlags(fs)
+	registerConfigFlags(fs)
+	_ = fs.Parse(args)
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *dir == "" || *modelsFlag == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	models, err := llmclient.ParseBackends(*modelsFlag)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateTimeouts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadGlossary(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	for _, m := range models {
+		useModel(m)
+		if err := loadProvider(); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		requireAPIKey()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+
+	// Nothing is written and no follow-up issues or tickets are opened
+	dryRun = true
+	pkgs, err := findPackages(*dir)
+	if err != nil {
+		log.Fatalf("Error listing packages: %v", err)
+	}
+	report := &benchmarkReport{StartedAt: time.Now().UTC()}
+	for _, m := range models {
+		results := benchmarkPackages(ctx, m, pkgs)
+		report.Results = append(report.Results, results...)
+		report.Summary = append(report.Summary, summarizeBenchmark(m.String(), results))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	printBenchmark(report.Summary)
+	if *reportFile != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = writeFileAtomic(*reportFile, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			log.Printf("Failed to write report: %v", err)
+		}
+	}
+	if ctx.Err() != nil {
+		os.Exit(1)
+	}
+}
+
+// benchmarkPackages migrates the packages with a model. The packages are
+// left untouched, the writes of every migration are kept in memory.
+func benchmarkPackages(ctx context.Context, m llmclient.Backend, pkgs []string) []benchmarkResult {
+	useModel(m)
+	var results []benchmarkResult
+	for _, pkgPath := range pkgs {
+		if ctx.Err() != nil {
+			break
+		}
+		if verbose {
+			log.Printf("Migrating %s with %s", pkgPath, m)
+		}
+		pkgFS = newOverlayFS(osFS{})
+		r := benchmarkResult{Model: m.String(), Package: packageName(pkgPath)}
+		started := time.Now()
+		result, err := processPackage(ctx, pkgPath)
+		r.DurationMS = time.Since(started).Milliseconds()
+		// Failed packages still used tokens
+		if result != nil {
+			r.Usage = result.Usage
+			r.CostUSD = result.Usage.CostUSD
+		}
+		if err != nil {
+			r.Failed = true
+			r.Error = redactSecrets(err.Error())
+			log.Printf("Error processing %s with %s: %v", pkgPath, m, err)
+		} else {
+			r.Findings = len(result.Warnings)
+			r.Gaps = len(result.Gaps)
+		}
+		results = append(results, r)
+	}
+	pkgFS = osFS{}
+	return results
+}
+
+// summarizeBenchmark aggregates the results of a model. The findings and
+// gaps are averaged over the packages that did not fail, the usage and cost
+// are those of all of them.
+func summarizeBenchmark(model string, results []benchmarkResult) benchmarkSummary {
+	s := benchmarkSummary{Model: model, Packages: len(results)}
+	var durations []int64
+	for _, r := range results {
+		durations = append(durations, r.DurationMS)
+		s.Usage.Add(r.Usage)
+		s.CostUSD += r.CostUSD
+		if r.Failed {
+			s.Failed++
+			continue
+		}
+		s.Findings += float64(r.Findings)
+		s.Gaps += float64(r.Gaps)
+	}
+	if ok := s.Packages - s.Failed; ok > 0 {
+		s.Findings /= float64(ok)
+		s.Gaps /= float64(ok)
+	}
+	if len(durations) > 0 {
+		slices.Sort(durations)
+		s.MedianMS = durations[len(durations)/2]
+		s.MaxMS = durations[len(durations)-1]
+	}
+	return s
+}
+
+// printBenchmark prints the comparison table of the models
+func printBenchmark(summary []benchmarkSummary) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "MODEL\tPACKAGES\tFAILED\tFINDINGS/PKG\tGAPS/PKG\tMEDIAN\tMAX\tTOKENS IN\tTOKENS OUT\tCOST (USD)")
+	for _, s := range summary {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%.1f\t%s\t%s\t%d\t%d\t%.2f\n",
+			s.Model, s.Packages, s.Failed, s.Findings, s.Gaps,
+			(time.Duration(s.MedianMS) * time.Millisecond).Round(100*time.Millisecond),
+			(time.Duration(s.MaxMS) * time.Millisecond).Round(100*time.Millisecond),
+			s.Usage.PromptTokens, s.Usage.ResponseTokens, s.CostUSD)
+	}
+}
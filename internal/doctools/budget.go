@@ -0,0 +1,100 @@
+package doctools
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+const (
+	// defaultContextWindow is the context window of the Gemini models, in
+	// tokens
+	defaultContextWindow = 1048576
+	// responseTokenReserve is the part of the context window kept for the
+	// answer, the migrated readme
+	responseTokenReserve = 65536
+)
+
+// tokenBudget is the budget of -token-budget, zero for the context window of
+// the model
+var tokenBudget int
+
+// promptPart is a part of the instructions of a prompt. Optional parts are
+// context the LLM can do without, left out when the prompt does not fit the
+// token budget.
+type promptPart struct {
+	name string
+	text string
+	// drop orders the optional parts, the lowest is left out first. Zero
+	// for the parts that are always sent.
+	drop int
+}
+
+// promptBudget returns the tokens the prompt may use: -token-budget, within
+// the context window of the model of b
+func promptBudget(b llmclient.Backend) int {
+	window := backendCapabilities(b).InputTokens
+	if tokenBudget > 0 {
+		return min(tokenBudget, window)
+	}
+	return window
+}
+
+// estimateTokens estimates the tokens of text at four bytes per token, the
+// average of the English prose and markdown of readmes
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// budgetPrompt assembles the instructions of a prompt within the token
+// budget: the system instruction with the readme and the template and the
+// required parts always, then the optional parts, leaving out the lowest
+// first until the prompt fits. It returns the instructions and the names of
+// the parts left out, or an error if even the required parts do not fit.
+func budgetPrompt(backend llmclient.Backend, system string, parts []promptPart) (string, []string, error) {
+	budget := promptBudget(backend)
+	tokens := estimateTokens(system)
+	for _, p := range parts {
+		tokens += estimateTokens(p.text)
+	}
+
+	left := make(map[int]bool)
+	var dropped []string
+	for tokens > budget {
+		lowest := -1
+		for i, p := range parts {
+			if p.drop > 0 && !left[i] && (lowest < 0 || p.drop < parts[lowest].drop) {
+				lowest = i
+			}
+		}
+		if lowest < 0 {
+			return "", dropped, fmt.Errorf("prompt of about %d tokens does not fit the token budget of %d of %s, even without optional context", tokens, budget, backend.Model)
+		}
+		left[lowest] = true
+		tokens -= estimateTokens(parts[lowest].text)
+		dropped = append(dropped, parts[lowest].name)
+	}
+
+	var b strings.Builder
+	for i, p := range parts {
+		if !left[i] {
+			b.WriteString(p.text)
+		}
+	}
+	if verbose {
+		log.Printf("Prompt of about %d tokens, budget %d", tokens, budget)
+	}
+	return b.String(), dropped, nil
+}
+
+// droppedContextWarnings reports the parts of the prompt left out to fit the
+// token budget
+func droppedContextWarnings(b llmclient.Backend, dropped []string) []string {
+	warnings := make([]string, len(dropped))
+	for i, name := range dropped {
+		warnings[i] = fmt.Sprintf("left the %s out of the prompt to fit the token budget of %d tokens", name, promptBudget(b))
+	}
+	return warnings
+}
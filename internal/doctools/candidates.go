@@ -0,0 +1,147 @@
+package doctools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+// findingPenalty is what a finding takes off the score of a candidate, per
+// rank of its severity
+const findingPenalty = 5
+
+var (
+	// candidateCount is how many candidates of -candidates are generated
+	// for a readme, the best is kept
+	candidateCount = 1
+	// candidateJudge is the model of -candidate-judge
+	candidateJudge string
+	// candidateJudgeModel is the parsed -candidate-judge, nil without one
+	candidateJudgeModel *llmclient.Backend
+)
+
+// candidateScore is the score of a candidate readme of -candidates
+type candidateScore struct {
+	// Candidate numbers the candidates from 1.
+	Candidate int `json:"candidate"`
+	// Score ranks the candidates, the highest is kept.
+	Score float64 `json:"score"`
+	// Retention is the percentage of the words of the original readme the
+	// candidate kept.
+	Retention float64 `json:"retention"`
+	Findings  int     `json:"findings"`
+	// Judge are the scores of -candidate-judge, from 1 to 10.
+	Judge    map[string]float64 `json:"judge,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	Selected bool               `json:"selected,omitempty"`
+}
+
+// loadCandidates checks -candidates and parses -candidate-judge
+func loadCandidates() error {
+	candidateJudgeModel = nil
+	if candidateCount < 1 {
+		return errors.New("-candidates must be at least 1")
+	}
+	if candidateJudge == "" {
+		return nil
+	}
+	models, err := llmclient.ParseBackends(candidateJudge)
+	if err != nil {
+		return fmt.Errorf("invalid -candidate-judge: %w", err)
+	}
+	if len(models) != 1 {
+		return errors.New("-candidate-judge takes a single model")
+	}
+	if models[0].Provider == llmclient.ProviderGemini {
+		if err := checkModelAllowed(models[0].Model); err != nil {
+			return fmt.Errorf("invalid -candidate-judge: %w", err)
+		}
+	}
+	candidateJudgeModel = &models[0]
+	return nil
+}
+
+// scoreCandidate scores a migrated readme with the validators: the share of
+// the original readme it kept, less a penalty per finding by severity. With
+// -candidate-judge the mean score of the judge, out of 100, counts as much;
+// the usage of the judge is returned with the score.
+func scoreCandidate(ctx context.Context, req migrateRequest, resp *migrateResponse) (candidateScore, llmclient.Usage) {
+	score := candidateScore{
+		Retention: contentRetention(req.Readme, resp.Markdown),
+		Findings:  len(resp.Findings),
+	}
+	score.Score = score.Retention
+	for _, f := range resp.Findings {
+		score.Score -= findingPenalty * float64(severityRanks[f.Severity])
+	}
+	if candidateJudgeModel == nil {
+		return score, llmclient.Usage{}
+	}
+	judged, usage, err := judgeReadme(ctx, *candidateJudgeModel, req.Readme, resp.Markdown)
+	if err != nil {
+		// The validators still rank the candidates
+		log.Printf("Failed to judge the candidate: %v", err)
+		return score, usage
+	}
+	var mean float64
+	for _, s := range judged {
+		mean += s / float64(len(judged))
+	}
+	score.Judge = judged
+	score.Score = (score.Score + 10*mean) / 2
+	return score, usage
+}
+
+// runCandidates runs the migration pipeline once for each of -candidates,
+// scores the readmes and returns the best, with the scores of all of them.
+// The usage is that of every candidate, failed ones included, and of
+// -candidate-judge. It fails if every candidate failed, with the error of
+// the first and a response with only the usage.
+func runCandidates(ctx context.Context, req migrateRequest, progress func(stage string)) (*migrateResponse, error) {
+	if candidateCount == 1 {
+		return runPipeline(ctx, req, progress)
+	}
+	var best *migrateResponse
+	var scores []candidateScore
+	var usage llmclient.Usage
+	var firstErr error
+	selected := -1
+	for i := range candidateCount {
+		resp, err := runPipeline(ctx, req, progress)
+		// A failed candidate still returns the usage of its calls
+		if resp != nil {
+			usage.Add(resp.Usage)
+		}
+		if ctx.Err() != nil {
+			return &migrateResponse{Usage: usage, Candidates: scores}, err
+		}
+		if err != nil {
+			logInfo("Candidate %d of %d failed: %v", i+1, candidateCount, err)
+			scores = append(scores, candidateScore{Candidate: i + 1, Error: redactSecrets(err.Error())})
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		score, judged := scoreCandidate(ctx, req, resp)
+		usage.Add(judged)
+		score.Candidate = i + 1
+		if verbose {
+			log.Printf("Candidate %d of %d scored %.1f", i+1, candidateCount, score.Score)
+		}
+		if best == nil || score.Score > scores[selected].Score {
+			best, selected = resp, len(scores)
+		}
+		scores = append(scores, score)
+	}
+	if best == nil {
+		return &migrateResponse{Usage: usage, Candidates: scores}, fmt.Errorf("all %d candidates failed, the first with: %w", candidateCount, firstErr)
+	}
+	scores[selected].Selected = true
+	logInfo("Kept candidate %d of %d, scored %.1f", scores[selected].Candidate, candidateCount, scores[selected].Score)
+	best.Usage, best.Candidates = usage, scores
+	return best, nil
+}
@@ -0,0 +1,304 @@
+package doctools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+// changelogPrompt asks for the type and description of the changelog.yml
+// entry of a change
+const changelogPrompt = `I need a changelog.yml entry for a change to the Elastic integration package %q.
+
+Follow these exact guidelines:
+1. Pick the type of the change: breaking-change when users have to change their configuration, dashboards or queries, for example because fields were renamed or removed; enhancement for new features, data streams, fields, dashboards or documentation; bugfix for fixes
+2. Write the description as a single sentence in the imperative mood, ending with a period, e.g. "Add support for the audit log."
+3. Describe what changed for the users of the integration, not which files changed, and do not repeat the name of the package
+4. Keep the description under 120 characters
+
+Answer with JSON only, e.g. {"type": "enhancement", "description": "Migrate the readme to the new documentation template."}.
+
+%s`
+
+// changelogDiffBytes is how much of a diff is sent to the model, the rest is
+// cut off
+const changelogDiffBytes = 100_000
+
+// changelogTypes are the types of a changelog.yml change, with the part of
+// the version they bump: 0 for the major, 1 the minor and 2 the patch
+var changelogTypes = map[string]int{
+	"breaking-change": 0,
+	"enhancement":     1,
+	"bugfix":          2,
+}
+
+var (
+	// manifestVersionPattern matches the version of a manifest.yml, keeping
+	// its quotes
+	manifestVersionPattern = regexp.MustCompile(`(?m)^(version:[ \t]*["']?)([^"'\s#]+)`)
+	// changelogVersionPattern matches the first entry of a changelog.yml
+	changelogVersionPattern = regexp.MustCompile(`(?m)^- version:[ \t]*["']?([^"'\s#]+)`)
+	semverPattern           = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)$`)
+)
+
+// changelogChange is a change of a changelog.yml entry
+type changelogChange struct {
+	Description string `json:"description"`
+	Type        string `json:"type"`
+	Link        string `json:"link"`
+}
+
+// RunChangelog runs the changelog command with the arguments args
+func RunChangelog(args []string) {
+	fs := flag.NewFlagSet("changelog", flag.ExitOnError)
+	pkgPath := fs.String("path", "", "Path to the package to add the changelog entry to (required)")
+	diffFile := fs.String("diff", "", "File with the git diff of the change, - for standard input")
+	since := fs.String("since", "", "Describe the changes of the package since this git ref instead of a -diff file")
+	prBody := fs.String("pr-body", "", "File with the description of the pull request, - for standard input")
+	link := fs.String("link", "", "URL of the pull request of the change (required)")
+	changeType := fs.String("type", "", "Type of the change, breaking-change, enhancement or bugfix, instead of the one the model picks")
+	dryRun := fs.Bool("dry-run", false, "Print the entry and the new version without writing changelog.yml and manifest.yml")
+	fs.StringVar(&googleAPIKey, "api-key", "", "Google Gemini API key")
+	fs.StringVar(&apiKeyRef, "api-key-ref", "", secretRefHelp)
+	registerVerbosityFlags(fs)
+	registerProviderFlags(fs)
+	registerNetworkFlags(fs)
+	registerTimeoutFlags(fs)
+	_ = fs.Parse(args)
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *pkgPath == "" || *link == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *diffFile == "" && *since == "" && *prBody == "" {
+		log.Fatalf("Error: changelog needs the change, with -diff, -since or -pr-body")
+	}
+	if *diffFile != "" && *since != "" {
+		log.Fatalf("Error: -diff and -since cannot be used together")
+	}
+	if *diffFile == "-" && *prBody == "-" {
+		log.Fatalf("Error: only one of -diff and -pr-body can be read from standard input")
+	}
+	if _, ok := changelogTypes[*changeType]; *changeType != "" && !ok {
+		log.Fatalf("Error: invalid -type %q, must be breaking-change, enhancement or bugfix", *changeType)
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateTimeouts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadProvider(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	requireAPIKey()
+
+	var diff, description string
+	var err error
+	switch {
+	case *since != "":
+		if diff, err = gitOutput(*pkgPath, "diff", *since, "--", "."); err != nil {
+			log.Fatalf("Error reading the changes since %s: %v", *since, err)
+		}
+	case *diffFile != "":
+		if diff, err = readInput(*diffFile); err != nil {
+			log.Fatalf("Error reading -diff: %v", err)
+		}
+	}
+	if *prBody != "" {
+		if description, err = readInput(*prBody); err != nil {
+			log.Fatalf("Error reading -pr-body: %v", err)
+		}
+	}
+	if strings.TrimSpace(diff) == "" && strings.TrimSpace(description) == "" {
+		log.Fatalf("Error: the change is empty, nothing to describe")
+	}
+
+	change, err := draftChangelogChange(context.Background(), packageName(*pkgPath), diff, description)
+	if err != nil {
+		log.Fatalf("Error drafting the changelog entry: %v", err)
+	}
+	change.Link = *link
+	if *changeType != "" {
+		change.Type = *changeType
+	}
+	version, err := addChangelogEntry(*pkgPath, change, *dryRun)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *dryRun {
+		fmt.Print(changelogEntry(version, change))
+		return
+	}
+	logInfo("Added %s %s to the changelog of %s: %s", change.Type, version, packageName(*pkgPath), change.Description)
+}
+
+// readInput reads a file, or standard input for -
+func readInput(name string) (string, error) {
+	if name == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		return string(data), err
+	}
+	data, err := os.ReadFile(name)
+	return string(data), err
+}
+
+// draftChangelogChange asks the model for the type and description of the
+// change of a package, from its diff and the description of its pull
+// request, either of which can be empty. The link is left to the caller.
+func draftChangelogChange(ctx context.Context, pkgName, diff, description string) (changelogChange, error) {
+	var b strings.Builder
+	if description = strings.TrimSpace(description); description != "" {
+		fmt.Fprintf(&b, "# Pull request description\n%s\n\n", description)
+	}
+	if diff = strings.TrimSpace(diff); diff != "" {
+		if len(diff) > changelogDiffBytes {
+			logInfo("The diff is %d bytes, only the first %d are described", len(diff), changelogDiffBytes)
+			diff = strings.ToValidUTF8(diff[:changelogDiffBytes], "") + "\n[diff cut off]"
+		}
+		fmt.Fprintf(&b, "# Diff\n```diff\n%s\n```\n", diff)
+	}
+	types := make([]string, 0, len(changelogTypes))
+	for t := range changelogTypes {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+
+	answer, _, _, err := generateWithFailover(ctx, func(backend llmclient.Backend) (string, llmclient.Usage, error) {
+		return generateContent(ctx, backend, "", fmt.Sprintf(changelogPrompt, pkgName, b.String()), func(m *genai.GenerativeModel) {
+			m.ResponseMIMEType = "application/json"
+			m.ResponseSchema = &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"type":        {Type: genai.TypeString, Enum: types},
+					"description": {Type: genai.TypeString},
+				},
+				Required: []string{"type", "description"},
+			}
+		})
+	})
+	if err != nil {
+		return changelogChange{}, err
+	}
+	start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return changelogChange{}, errors.New("no JSON object in the answer of the model")
+	}
+	var change changelogChange
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &change); err != nil {
+		return changelogChange{}, fmt.Errorf("failed to parse the answer of the model: %w", err)
+	}
+	change.Description = strings.Join(strings.Fields(change.Description), " ")
+	if change.Description == "" {
+		return changelogChange{}, errors.New("the model gave no description")
+	}
+	if _, ok := changelogTypes[change.Type]; !ok {
+		return changelogChange{}, fmt.Errorf("the model gave the invalid type %q", change.Type)
+	}
+	return change, nil
+}
+
+// bumpVersion returns the version following version for a change of the
+// given type. Below 1.0.0 breaking changes only bump the minor version.
+// Prerelease versions are left to be bumped by hand.
+func bumpVersion(version, changeType string) (string, error) {
+	m := semverPattern.FindStringSubmatch(version)
+	if m == nil {
+		return "", fmt.Errorf("cannot bump version %q, only major.minor.patch versions are bumped, edit prereleases by hand", version)
+	}
+	parts := make([]int, 3)
+	for i := range parts {
+		parts[i], _ = strconv.Atoi(m[i+1])
+	}
+	bump := changelogTypes[changeType]
+	if bump == 0 && parts[0] == 0 {
+		bump = 1
+	}
+	parts[bump]++
+	for i := bump + 1; i < len(parts); i++ {
+		parts[i] = 0
+	}
+	return fmt.Sprintf("%d.%d.%d", parts[0], parts[1], parts[2]), nil
+}
+
+// yamlScalar returns s as a YAML scalar, quoted when needed
+func yamlScalar(s string) string {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return strconv.Quote(s)
+	}
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+// changelogEntry returns the changelog.yml entry of a version with a change
+func changelogEntry(version string, change changelogChange) string {
+	return fmt.Sprintf("- version: %q\n  changes:\n    - description: %s\n      type: %s\n      link: %s\n",
+		version, yamlScalar(change.Description), change.Type, yamlScalar(change.Link))
+}
+
+// addChangelogEntry bumps the version of the manifest.yml of a package for
+// the change and adds the change with the new version on top of its
+// changelog.yml, editing both files in place so their comments and
+// formatting are kept. The latest version of the changelog must be the
+// version of the manifest. It returns the new version, and leaves the files
+// untouched with dryRun.
+func addChangelogEntry(pkgPath string, change changelogChange, dryRun bool) (string, error) {
+	manifestPath := filepath.Join(pkgPath, "manifest.yml")
+	changelogPath := filepath.Join(pkgPath, "changelog.yml")
+	manifest, err := pkgFS.ReadFile(manifestPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read manifest.yml: %w", err)
+	}
+	changelog, err := pkgFS.ReadFile(changelogPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read changelog.yml: %w", err)
+	}
+
+	m := manifestVersionPattern.FindSubmatchIndex(manifest)
+	if m == nil {
+		return "", errors.New("no version in manifest.yml")
+	}
+	current := string(manifest[m[4]:m[5]])
+	c := changelogVersionPattern.FindSubmatchIndex(changelog)
+	if c == nil {
+		return "", errors.New("no version entry in changelog.yml")
+	}
+	if latest := string(changelog[c[2]:c[3]]); latest != current {
+		return "", fmt.Errorf("the latest version of changelog.yml, %s, is not the version of manifest.yml, %s", latest, current)
+	}
+	version, err := bumpVersion(current, change.Type)
+	if err != nil {
+		return "", err
+	}
+	if dryRun {
+		return version, nil
+	}
+
+	updatedManifest := slices.Concat(manifest[:m[4]], []byte(version), manifest[m[5]:])
+	updatedChangelog := slices.Concat(changelog[:c[0]], []byte(changelogEntry(version, change)), changelog[c[0]:])
+	if err := pkgFS.WriteFile(changelogPath, updatedChangelog, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write changelog.yml: %w", err)
+	}
+	if err := pkgFS.WriteFile(manifestPath, updatedManifest, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write manifest.yml: %w", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,135 @@
+package doctools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// excludedTemplateHeadings lists template headings the prompt instructs the
+// LLM to leave out, so they are not expected in a migrated readme
+var excludedTemplateHeadings = map[string]bool{
+	"ecs field reference": true,
+}
+
+// templateHeadings returns the section headings a readme must contain to
+// conform to the template. The title heading and headings made of template
+// placeholders are skipped since their text differs per package.
+func templateHeadings(template string) []markdown.Heading {
+	var required []markdown.Heading
+	for _, h := range markdown.ParseHeadings(template) {
+		if h.Level == 1 || strings.ContainsAny(h.Text, "{}") {
+			continue
+		}
+		if excludedTemplateHeadings[strings.ToLower(h.Text)] {
+			continue
+		}
+		required = append(required, h)
+	}
+	return required
+}
+
+// validateReadme compares readme content against the template and returns a
+// list of findings describing why the readme does not conform to it.
+// eventStreams lists the data streams that have a sample event and therefore
+// need an event placeholder.
+func validateReadme(content, template string, dataStreams, eventStreams []string) []string {
+	findings := missingSections(content, template)
+
+	if genericPlaceholder.MatchString(content) {
+		findings = append(findings, `generic "data_stream_name" placeholder left in readme`)
+	}
+
+	for _, ds := range dataStreams {
+		if !strings.Contains(content, placeholders.fields(ds)) {
+			findings = append(findings, fmt.Sprintf("missing exported fields placeholder for data stream %q", ds))
+		}
+	}
+	for _, ds := range eventStreams {
+		if !strings.Contains(content, placeholders.event(ds)) {
+			findings = append(findings, fmt.Sprintf("missing sample event placeholder for data stream %q", ds))
+		}
+	}
+
+	return findings
+}
+
+// missingSections returns a finding for every template section missing from
+// content
+func missingSections(content, template string) []string {
+	var findings []string
+	present := make(map[string]bool)
+	for _, h := range markdown.ParseHeadings(content) {
+		present[strings.ToLower(h.Text)] = true
+	}
+	for _, h := range templateHeadings(template) {
+		if !present[strings.ToLower(h.Text)] {
+			findings = append(findings, fmt.Sprintf("missing section %q", strings.Repeat("#", h.Level)+" "+h.Text))
+		}
+	}
+	return findings
+}
+
+// sampleEventStreams returns the data streams that ship a sample_event.json,
+// only those can render an event placeholder
+func sampleEventStreams(pkgPath string, dataStreams []string) []string {
+	var streams []string
+	for _, ds := range dataStreams {
+		samplePath := filepath.Join(pkgPath, "data_stream", ds, "sample_event.json")
+		if _, err := pkgFS.Stat(samplePath); err == nil {
+			streams = append(streams, ds)
+		}
+	}
+	return streams
+}
+
+// checkPackage reports whether the package docs conform to the template
+// without calling the LLM. An empty result means no migration is needed.
+func checkPackage(ctx context.Context, pkgPath string) ([]string, error) {
+	targetPath := targetReadmePath(pkgPath)
+	if outputTarget == targetDocsV3 {
+		targetPath = docsV3Path(pkgPath)
+	}
+
+	readmeContent, _, err := readMarkdown(targetPath)
+	if os.IsNotExist(err) {
+		return []string{fmt.Sprintf("%s not found, package has not been migrated", targetPath)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read readme: %w", err)
+	}
+
+	template, err := fetchTemplate(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch template: %w", err)
+	}
+	if outputTarget == targetDocsV3 {
+		return validateDocsV3(readmeContent, template), nil
+	}
+
+	req, err := packageRequest(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	// The rendered readme keeps the license header of the original one
+	if source, _, err := readMarkdown(sourceReadmePath(pkgPath)); err == nil {
+		req.LicenseHeader = licenseHeader(source)
+	}
+
+	// A split readme is validated together with its data stream pages
+	docs, err := readDataStreamDocs(pkgPath, req.DataStreams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data stream pages: %w", err)
+	}
+	readme := joinDataStreamDocs(readmeContent, req.DataStreams, docs)
+	findings := validatePackageReadme(readme, templateForPackage(template, req), req)
+	variantFindings, err := checkVariants(pkgPath, readmeContent)
+	if err != nil {
+		return nil, err
+	}
+	return append(findings, variantFindings...), nil
+}
@@ -0,0 +1,158 @@
+package doctools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// responseChunked asks for each section of the template in its own call, the
+// calls run concurrently and the readme is assembled from the answers in
+// template order, selected with -response-format
+const responseChunked = "chunked"
+
+// chunkPrompt is added to the readme prompt of each section with
+// -response-format chunked
+const chunkPrompt = `
+
+Only write the part of the migrated README for this part of the template, with its subsections, starting with %s:
+
+%s
+
+The other parts of the template are written separately: do not write them or their headings, and leave the content that belongs there to them.`
+
+// sectionConcurrency is how many sections of -response-format chunked are
+// generated at the same time, set with -section-concurrency
+var sectionConcurrency = 4
+
+// templateChunk is a part of the template generated in its own call: the
+// title and introduction before the first section, or a section with its
+// subsections
+type templateChunk struct {
+	// Heading is the heading of the section, empty for the introduction
+	Heading string
+	Text    string
+}
+
+// templateChunks splits a template at its top-level sections, the headings
+// of level 2 outside code blocks. Parts without text are left out.
+func templateChunks(template string) []templateChunk {
+	lines := markdown.ParseLines(template)
+	var chunks []templateChunk
+	start := 0
+	add := func(end int) {
+		var b strings.Builder
+		for _, l := range lines[start:end] {
+			b.WriteString(l.Text + "\n")
+		}
+		if text := strings.TrimSpace(b.String()); text != "" {
+			chunk := templateChunk{Text: text}
+			if lines[start].Level == 2 {
+				chunk.Heading = lines[start].Heading
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+	for i, l := range lines {
+		if l.Level == 2 && i > start {
+			add(i)
+			start = i
+		}
+	}
+	add(len(lines))
+	return chunks
+}
+
+// chunkStart describes where the answer for a chunk starts
+func chunkStart(chunk templateChunk) string {
+	if chunk.Heading == "" {
+		return "the title of the document"
+	}
+	return fmt.Sprintf("the heading %q", "## "+chunk.Heading)
+}
+
+// chunkAnswer returns the answer for a chunk starting with the heading of its
+// section, adding the heading if the model left it out
+func chunkAnswer(text string, chunk templateChunk) string {
+	text = strings.TrimSpace(text)
+	if chunk.Heading == "" {
+		return text
+	}
+	if lines := markdown.ParseLines(text); len(lines) > 0 && lines[0].Level > 0 && strings.EqualFold(lines[0].Heading, chunk.Heading) {
+		return text
+	}
+	return "## " + chunk.Heading + "\n\n" + text
+}
+
+// generateChunkedReadme asks the LLM for each top-level section of the
+// template in its own call, at most -section-concurrency at a time, and
+// assembles the readme from the answers in template order. An empty or
+// truncated answer for a section is asked for again like a readme, the first
+// section that fails cancels the others.
+func generateChunkedReadme(ctx context.Context, b llmclient.Backend, readmeContent, templateContent, userPrompt string) (string, llmclient.Usage, error) {
+	system := systemInstruction(readmeContent, templateContent)
+	chunks := templateChunks(templateContent)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	answers := make([]string, len(chunks))
+	usages := make([]llmclient.Usage, len(chunks))
+	errs := make([]error, len(chunks))
+	slots := make(chan struct{}, max(sectionConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			started := time.Now()
+			prompt := userPrompt + fmt.Sprintf(chunkPrompt, chunkStart(chunk), chunk.Text)
+			// The length of a section is not compared with the whole
+			// readme, only the assembled readme is
+			text, usage, err := generateReadmeText(ctx, b, system, prompt, "")
+			answers[i], usages[i], errs[i] = chunkAnswer(text, chunk), usage, err
+			if err != nil {
+				cancel()
+			}
+			logDebug("Section %d of %d generated in %s", i+1, len(chunks), time.Since(started).Round(time.Millisecond))
+		}()
+	}
+	wg.Wait()
+
+	var usage llmclient.Usage
+	for _, u := range usages {
+		usage.PromptTokens += u.PromptTokens
+		usage.ResponseTokens += u.ResponseTokens
+	}
+	// Report the section that failed rather than those it cancelled
+	failed := -1
+	for i, err := range errs {
+		if err != nil && (failed < 0 || errors.Is(errs[failed], context.Canceled) && !errors.Is(err, context.Canceled)) {
+			failed = i
+		}
+	}
+	if failed >= 0 {
+		if chunks[failed].Heading == "" {
+			return "", usage, fmt.Errorf("failed to generate the introduction: %w", errs[failed])
+		}
+		return "", usage, fmt.Errorf("failed to generate section %q: %w", chunks[failed].Heading, errs[failed])
+	}
+
+	readme := strings.Join(answers, "\n\n") + "\n"
+	if reason := degenerateReason(readme, readmeContent); reason != "" {
+		return "", usage, degenerateError{"a README assembled from the sections that is " + reason}
+	}
+	return normalizeGenerated(readme, readmeContent, templateContent), usage, nil
+}
@@ -0,0 +1,95 @@
+package doctools
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+
+	"gopkg.in/yaml.v3"
+)
+
+// toolConfig is the YAML configuration file selected with -config
+type toolConfig struct {
+	// Terms are the terminology rules checked in every migrated readme.
+	Terms []termRule `yaml:"terms"`
+	// Jira is where follow-up tickets are created, none when nil.
+	Jira *jiraConfig `yaml:"jira"`
+	// Stages are the pipeline stages to skip or replace.
+	Stages stageConfig `yaml:"stages"`
+	// Paths are the paths of the readmes in the packages.
+	Paths pathsConfig `yaml:"paths"`
+	// Sections map the headings of legacy readmes to template sections.
+	Sections []sectionMapping `yaml:"sections"`
+	// Placeholders are the placeholders of the build pipeline rendering
+	// the readmes.
+	Placeholders placeholderConfig `yaml:"placeholders"`
+	// AllowedModels are the glob patterns of the Gemini models that may be
+	// used, every model when empty.
+	AllowedModels []string `yaml:"allowed_models"`
+}
+
+var (
+	// configPath is the configuration file selected with -config
+	configPath string
+	// config is the configuration loaded from configPath
+	config toolConfig
+)
+
+// registerConfigFlags adds the configuration file flag to fs
+func registerConfigFlags(fs *flag.FlagSet) {
+	fs.StringVar(&configPath, "config", "", "YAML configuration file, see the README for its settings")
+	fs.BoolVar(&fixTerms, "fix-terms", false, "Replace the prose breaking a terminology rule of -config that has a replacement")
+	fs.StringVar(&sourceReadmeFlag, "source-readme", "", "Path of the rendered readme in the packages (default "+defaultSourceReadme+")")
+	fs.StringVar(&rulesPath, "rules", "", "YAML file of transformation rules applied to the readme before or after the LLM, see the README")
+	fs.StringVar(&targetReadmeFlag, "target-readme", "", "Path of the readme template in the packages (default "+defaultTargetReadme+")")
+}
+
+// loadConfig reads and validates the configuration file of -config
+func loadConfig() error {
+	if err := loadRules(); err != nil {
+		return err
+	}
+	if configPath == "" {
+		return applyPaths(pathsConfig{})
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	var c toolConfig
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	for i := range c.Terms {
+		if err := c.Terms[i].compile(); err != nil {
+			return fmt.Errorf("invalid term rule %d in %s: %w", i+1, configPath, err)
+		}
+	}
+	for i := range c.Sections {
+		if err := c.Sections[i].compile(); err != nil {
+			return fmt.Errorf("invalid section mapping %d in %s: %w", i+1, configPath, err)
+		}
+	}
+	if err := c.Stages.validate(); err != nil {
+		return fmt.Errorf("invalid stages in %s: %w", configPath, err)
+	}
+	if c.Jira != nil {
+		if err := c.Jira.validate(); err != nil {
+			return fmt.Errorf("invalid jira settings in %s: %w", configPath, err)
+		}
+	}
+	for _, pattern := range c.AllowedModels {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid allowed model %q in %s: %w", pattern, configPath, err)
+		}
+	}
+	if err := applyPaths(c.Paths); err != nil {
+		return fmt.Errorf("invalid paths in %s: %w", configPath, err)
+	}
+	if err := applyPlaceholders(c.Placeholders); err != nil {
+		return fmt.Errorf("invalid placeholders in %s: %w", configPath, err)
+	}
+	config = c
+	return nil
+}
@@ -0,0 +1,253 @@
+package doctools
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// How the extra docs of a package are migrated, selected with -extra-docs
+const (
+	// extraDocsMerge merges the extra docs into the readme
+	extraDocsMerge = "merge"
+	// extraDocsSeparate keeps the extra docs as pages next to the readme
+	extraDocsSeparate = "separate"
+)
+
+// extraDocsMode is the mode selected with -extra-docs
+var extraDocsMode = extraDocsMerge
+
+// docsPagesPrompt is added to the prompt of a readme whose extra docs are
+// kept as separate pages
+const docsPagesPrompt = `
+
+The docs of the package have these other pages, kept as they are next to the readme: %s. Keep the links to them, and link to them from the relevant sections of the template instead of repeating their content.`
+
+// markdownLinkPattern matches the target of inline markdown links and images
+var markdownLinkPattern = regexp.MustCompile(`\]\(([^)\s]+)((?:\s+"[^"]*")?)\)`)
+
+// validateExtraDocs rejects unknown -extra-docs modes
+func validateExtraDocs(mode, target string) error {
+	switch mode {
+	case extraDocsMerge:
+		return nil
+	case extraDocsSeparate:
+		if target == targetDocsV3 {
+			return fmt.Errorf("-extra-docs %s only applies to the readme target", extraDocsSeparate)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown -extra-docs mode %q, use %s or %s", mode, extraDocsMerge, extraDocsSeparate)
+}
+
+// findExtraDocs returns the markdown files below the docs/ directory of a
+// package besides the readme and its localized variants, sorted by path
+func findExtraDocs(pkgPath string) ([]string, error) {
+	docs, err := findMarkdown(sourceDocsDir(pkgPath), true)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(docs)
+	return docs, nil
+}
+
+// findMarkdown returns the markdown files below dir, leaving out the readme
+// and its variants at the top
+func findMarkdown(dir string, top bool) ([]string, error) {
+	entries, err := pkgFS.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var docs []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() {
+			sub, err := findMarkdown(filepath.Join(dir, name), false)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, sub...)
+			continue
+		}
+		if !strings.EqualFold(filepath.Ext(name), ".md") {
+			continue
+		}
+		if top && (strings.EqualFold(name, path.Base(sourceReadmeRel)) || variantNamePattern.MatchString(name)) {
+			continue
+		}
+		docs = append(docs, filepath.Join(dir, name))
+	}
+	return docs, nil
+}
+
+// docsPage is an extra doc of a package kept as a page next to the readme
+type docsPage struct {
+	// Rel is the path of the page relative to docs/, with slashes.
+	Rel     string
+	Content string
+}
+
+// readDocsPages reads the extra docs of a package to keep them as pages,
+// with their links to each other made relative
+func readDocsPages(pkgPath string, docs []string) ([]docsPage, error) {
+	docsDir := sourceDocsDir(pkgPath)
+	var pages []docsPage
+	for _, p := range docs {
+		content, _, err := readMarkdown(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		rel, err := filepath.Rel(docsDir, p)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, docsPage{Rel: filepath.ToSlash(rel), Content: content})
+	}
+	names := docsPageNames(pages)
+	for i := range pages {
+		pages[i].Content = rewriteDocsLinks(pages[i].Content, path.Dir(pages[i].Rel), packageName(pkgPath), names)
+	}
+	return pages, nil
+}
+
+// docsPageNames returns the paths of pages relative to docs/, with the
+// readme
+func docsPageNames(pages []docsPage) []string {
+	names := []string{path.Base(sourceReadmeRel)}
+	for _, p := range pages {
+		names = append(names, p.Rel)
+	}
+	return names
+}
+
+// docsLinkPage returns the page of docs/ a link target points to, relative
+// to docs/, and its anchor. Targets are relative to dir, the directory of the
+// linking page below docs/; links to docs/ from the package root and GitHub
+// links to the docs of the package are recognized too.
+func docsLinkPage(target, dir, pkg string, pages []string) (string, string, bool) {
+	target, anchor, _ := strings.Cut(target, "#")
+	if anchor != "" {
+		anchor = "#" + anchor
+	}
+	docsDir := path.Dir(sourceReadmeRel) + "/"
+	var candidates []string
+	if i := strings.Index(target, "/packages/"+pkg+"/"+docsDir); i >= 0 && strings.Contains(target, "://") {
+		candidates = append(candidates, target[i+len("/packages/"+pkg+"/"+docsDir):])
+	} else if !strings.Contains(target, "://") && target != "" {
+		candidates = append(candidates, path.Join(dir, target))
+		if rest, ok := strings.CutPrefix(path.Clean(target), docsDir); ok {
+			candidates = append(candidates, rest)
+		}
+		// Links from the docs directory to the package root and back
+		up := strings.Repeat("../", strings.Count(docsDir, "/"))
+		if rest, ok := strings.CutPrefix(path.Clean(path.Join(dir, target)), up+docsDir); ok {
+			candidates = append(candidates, rest)
+		}
+	}
+	for _, c := range candidates {
+		for _, p := range pages {
+			if strings.EqualFold(c, p) {
+				return p, anchor, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// rewriteDocsLinks rewrites the links of a page in dir below docs/ to the
+// other pages so that they are relative to the page, as they are once the
+// pages are rendered next to each other
+func rewriteDocsLinks(content, dir, pkg string, pages []string) string {
+	return markdownLinkPattern.ReplaceAllStringFunc(content, func(m string) string {
+		sub := markdownLinkPattern.FindStringSubmatch(m)
+		page, anchor, ok := docsLinkPage(sub[1], dir, pkg, pages)
+		if !ok {
+			return m
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(dir), filepath.FromSlash(page))
+		if err != nil {
+			return m
+		}
+		return "](" + filepath.ToSlash(rel) + anchor + sub[2] + ")"
+	})
+}
+
+// mergedDocsLinks reports the links of a migrated readme to the docs that
+// were merged into it, which no longer exist once the docs are rebuilt
+func mergedDocsLinks(content, pkg string, consolidated []string) []string {
+	docsDir := path.Dir(sourceReadmeRel)
+	var merged []string
+	for _, c := range consolidated {
+		merged = append(merged, strings.TrimPrefix(c, docsDir+"/"))
+	}
+	var warnings []string
+	for _, m := range markdownLinkPattern.FindAllStringSubmatch(content, -1) {
+		if page, _, ok := docsLinkPage(m[1], ".", pkg, merged); ok {
+			warnings = append(warnings, fmt.Sprintf("link to %s left in the readme, its content was merged into the readme", path.Join(docsDir, page)))
+		}
+	}
+	return warnings
+}
+
+// docsPagePath returns the path of a docs page in the package source
+func docsPagePath(pkgPath, rel string) string {
+	return filepath.Join(filepath.Dir(targetReadmePath(pkgPath)), filepath.FromSlash(rel))
+}
+
+// writeDocsPages writes the pages of the extra docs next to the readme and
+// adds their patches to result. Pages that exist already are left alone.
+func writeDocsPages(pkgPath string, pages []docsPage, result *migrateResponse) error {
+	for _, p := range pages {
+		target := docsPagePath(pkgPath, p.Rel)
+		if _, err := pkgFS.Stat(target); err == nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("page %s exists already, docs/%s was not copied to it", filepath.ToSlash(docsPagePath("", p.Rel)), p.Rel))
+			continue
+		}
+		if err := pkgFS.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := pkgFS.WriteFile(target, []byte(p.Content), 0o644); err != nil {
+			return fmt.Errorf("failed to write docs page: %w", err)
+		}
+		patch, err := generatePatch(docsPagePath("", p.Rel), "", p.Content)
+		if err != nil {
+			return err
+		}
+		result.Patch += patch
+	}
+	return nil
+}
+
+// consolidateDocs appends the extra docs of a package to its readme, each
+// introduced by a comment asking to merge it into the sections of the
+// template. It returns the readme and the consolidated files relative to the
+// package.
+func consolidateDocs(pkgPath, readme string, docs []string) (string, []string, error) {
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(readme, "\n"))
+	var consolidated []string
+	for _, path := range docs {
+		content, _, err := readMarkdown(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		rel, err := filepath.Rel(pkgPath, path)
+		if err != nil {
+			rel = path
+		}
+		rel = filepath.ToSlash(rel)
+
+		fmt.Fprintf(&b, "\n\n<!-- The content below comes from %s, merge it into the relevant sections of the readme instead of keeping it as a separate part, and point links to it at these sections -->\n\n", rel)
+		b.WriteString(strings.TrimRight(content, "\n"))
+		consolidated = append(consolidated, rel)
+	}
+	b.WriteString("\n")
+	return b.String(), consolidated, nil
+}
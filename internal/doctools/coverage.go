@@ -0,0 +1,125 @@
+package doctools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// Kinds of docs gaps
+const (
+	gapEmpty    = "empty"
+	gapGuidance = "guidance only"
+)
+
+// gapPriorities weighs the template sections users rely on most, sections
+// not listed take the weight of their closest listed parent, or 1
+var gapPriorities = map[string]int{
+	"overview": 3,
+	"what do i need to use this integration?":  3,
+	"how do i deploy this integration?":        3,
+	"troubleshooting":                          2,
+	"what data does this integration collect?": 2,
+}
+
+// docsGap is a template section of a migrated readme that has no content
+type docsGap struct {
+	Section string `json:"section"`
+	// Kind is empty, or guidance only when the section only holds the
+	// guidance comments the LLM adds to sections it had no content for.
+	Kind     string `json:"kind"`
+	Priority int    `json:"priority"`
+	// Guidance is the text of the guidance comments.
+	Guidance string `json:"guidance,omitempty"`
+}
+
+// coverageGaps returns the template sections of content that are empty or
+// only hold guidance comments. Sections whose parent is reported are left
+// out, as are sections missing from content.
+func coverageGaps(content, template string) []docsGap {
+	required := make(map[string]bool)
+	for _, h := range templateHeadings(template) {
+		required[strings.ToLower(h.Text)] = true
+	}
+
+	lines := markdown.ParseLines(content)
+	var gaps []docsGap
+	var walk func(sections []*markdown.Section, parentPriority int)
+	walk = func(sections []*markdown.Section, parentPriority int) {
+		for _, s := range sections {
+			name := strings.ToLower(s.Heading)
+			priority, ok := gapPriorities[name]
+			if !ok {
+				priority = parentPriority
+			}
+			if !required[name] {
+				walk(s.Children, priority)
+				continue
+			}
+
+			var text, guidance []string
+			for _, l := range lines[s.Start+1 : s.End] {
+				if l.Level == 0 {
+					text = append(text, l.Text)
+				}
+			}
+			body := strings.Join(text, "\n")
+			if markdown.HasProse(body) {
+				walk(s.Children, priority)
+				continue
+			}
+			for _, m := range markdown.CommentPattern.FindAllString(body, -1) {
+				guidance = append(guidance, commentText(m))
+			}
+
+			gap := docsGap{Section: s.Heading, Kind: gapEmpty, Priority: max(priority, 1)}
+			if len(guidance) > 0 {
+				gap.Kind = gapGuidance
+				gap.Guidance = strings.Join(guidance, " ")
+			}
+			gaps = append(gaps, gap)
+		}
+	}
+	walk(markdown.Outline(lines), 0)
+	return gaps
+}
+
+// coverageReport is a docs gap of a package in the backlog of a batch run
+type coverageReport struct {
+	Package string
+	docsGap
+}
+
+// writeCoverage writes the docs gaps of a batch run to path as a markdown
+// backlog, the highest priority gaps first
+func writeCoverage(path string, report *batchReport) error {
+	var backlog []coverageReport
+	for _, p := range report.Packages {
+		for _, g := range p.Gaps {
+			backlog = append(backlog, coverageReport{Package: p.Name, docsGap: g})
+		}
+	}
+	sort.SliceStable(backlog, func(i, j int) bool {
+		if backlog[i].Priority != backlog[j].Priority {
+			return backlog[i].Priority > backlog[j].Priority
+		}
+		return backlog[i].Package < backlog[j].Package
+	})
+
+	var b strings.Builder
+	b.WriteString("# Docs coverage\n\n")
+	if len(backlog) == 0 {
+		b.WriteString("Every template section of the migrated readmes has content.\n")
+		return writeFileAtomic(path, []byte(b.String()), 0o644)
+	}
+	fmt.Fprintf(&b, "%d template sections of the migrated readmes have no content, the highest priority first.\n\n", len(backlog))
+	b.WriteString("| Priority | Package | Section | Gap | Guidance |\n")
+	b.WriteString("|----------|---------|---------|-----|----------|\n")
+	cell := strings.NewReplacer("|", `\|`, "\n", " ")
+	for _, g := range backlog {
+		fmt.Fprintf(&b, "| %d | %s | %s | %s | %s |\n", g.Priority, g.Package, cell.Replace(g.Section), g.Kind, cell.Replace(g.Guidance))
+	}
+	return writeFileAtomic(path, []byte(b.String()), 0o644)
+}
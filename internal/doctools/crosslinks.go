@@ -0,0 +1,239 @@
+package doctools
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// fixCrossLinks is set with -fix-cross-links
+var fixCrossLinks bool
+
+var (
+	// packageDocsURLPattern matches the addresses of the docs of a package
+	// on the Elastic docs sites and captures the package name
+	packageDocsURLPattern = regexp.MustCompile(`^https?://(?:docs\.elastic\.co/(?:en/)?integrations|(?:www\.)?elastic\.co/(?:docs/reference/integrations|guide/en/integrations/current))/([a-z0-9_]+)/?$`)
+	// packageSourceURLPattern matches the addresses of the docs of a package
+	// in the integrations repository and captures the package name and the
+	// path in the package
+	packageSourceURLPattern = regexp.MustCompile(`^https?://github\.com/[^/]+/[^/]+/(?:blob|tree)/[^/]+/packages/([^/]+)/(.+)$`)
+)
+
+// packageDocs are the pages of the rendered docs of a package, by file name
+// relative to the docs directory, with the headings of their anchors
+type packageDocs map[string]map[string]string
+
+// crossLink is a link of a readme to a page of the docs of another package
+type crossLink struct {
+	pkg    string
+	page   string
+	anchor string
+}
+
+// readPackageDocs returns the markdown pages of a docs directory with the
+// headings of their anchors, the readme under the name it is rendered with.
+// Repeated headings get numbered anchors, as on GitHub.
+func readPackageDocs(dir, readme string) (packageDocs, error) {
+	entries, err := pkgFS.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	docs := make(packageDocs)
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".md") {
+			continue
+		}
+		content, _, err := readMarkdown(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		name := e.Name()
+		if strings.EqualFold(name, readme) {
+			name = path.Base(sourceReadmeRel)
+		}
+		docs[name] = markdown.Anchors(content)
+	}
+	return docs, nil
+}
+
+// resolveCrossLink returns the page of another package a link of the readme
+// of pkg points at, as rendered in docs/ of the packages directory
+func resolveCrossLink(target, pkg string) (crossLink, bool) {
+	target, anchor, _ := strings.Cut(target, "#")
+	docsDir := path.Dir(sourceReadmeRel)
+	if m := packageDocsURLPattern.FindStringSubmatch(target); m != nil {
+		return crossLink{pkg: m[1], page: path.Base(sourceReadmeRel), anchor: anchor}, m[1] != pkg
+	}
+	var rel string
+	if m := packageSourceURLPattern.FindStringSubmatch(target); m != nil {
+		rel = path.Join(m[1], m[2])
+	} else if target != "" && !strings.Contains(target, "://") && !strings.HasPrefix(target, "/") {
+		rel = path.Join(pkg, docsDir, target)
+	} else {
+		return crossLink{}, false
+	}
+	other, rest, ok := strings.Cut(rel, "/")
+	if !ok || other == pkg || other == ".." {
+		return crossLink{}, false
+	}
+	page, ok := strings.CutPrefix(rest, docsDir+"/")
+	if !ok || strings.Contains(page, "/") {
+		return crossLink{}, false
+	}
+	return crossLink{pkg: other, page: page, anchor: anchor}, true
+}
+
+// findPage returns the name of a page of docs, ignoring case
+func findPage(docs packageDocs, page string) (string, bool) {
+	for name := range docs {
+		if strings.EqualFold(name, page) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// fixedCrossLink returns where a broken link should point at in the migrated
+// docs of its package: the page and heading with the anchor, or else the
+// heading with the text the anchor had before, in the page first, or else
+// the readme
+func fixedCrossLink(l crossLink, before, after packageDocs) crossLink {
+	readme := path.Base(sourceReadmeRel)
+	page, ok := findPage(after, l.page)
+	if !ok {
+		page = readme
+	}
+	if l.anchor == "" {
+		return crossLink{pkg: l.pkg, page: page}
+	}
+	var text string
+	if p, ok := findPage(before, l.page); ok {
+		text = before[p][l.anchor]
+	}
+	pages := []string{page}
+	for _, name := range slices.Sorted(maps.Keys(after)) {
+		if name != page {
+			pages = append(pages, name)
+		}
+	}
+	for _, p := range pages {
+		if after[p][l.anchor] != "" {
+			return crossLink{pkg: l.pkg, page: p, anchor: l.anchor}
+		}
+	}
+	if text != "" {
+		for _, p := range pages {
+			for _, anchor := range slices.Sorted(maps.Keys(after[p])) {
+				if strings.EqualFold(after[p][anchor], text) {
+					return crossLink{pkg: l.pkg, page: p, anchor: anchor}
+				}
+			}
+		}
+	}
+	return crossLink{pkg: l.pkg, page: page}
+}
+
+// rewriteCrossLink returns the target of a link to l changed to point at
+// fixed, in the same form: relative, to the repository or to the docs site.
+// Pages of the docs site other than the readme are not known, links there
+// point at the readme.
+func rewriteCrossLink(target string, l, fixed crossLink) string {
+	base, _, _ := strings.Cut(target, "#")
+	if !strings.EqualFold(fixed.page, l.page) {
+		if packageDocsURLPattern.MatchString(base) {
+			return base
+		}
+		base = base[:len(base)-len(l.page)] + fixed.page
+	}
+	if fixed.anchor != "" {
+		return base + "#" + fixed.anchor
+	}
+	return base
+}
+
+// checkCrossLinks reports the links of the readmes a batch run migrated to
+// the docs of other packages of the run that break once those are rendered
+// from their migrated docs: the page is gone, merged into the readme for
+// example, or no heading has the anchor any longer. With -fix-cross-links
+// they are rewritten to the page and heading the content moved to, or to the
+// readme of the package.
+func checkCrossLinks(report *batchReport) {
+	before := make(map[string]packageDocs)
+	after := make(map[string]packageDocs)
+	readme := filepath.Base(targetReadmeRel)
+	for _, p := range report.Packages {
+		if p.Status != statusSucceeded {
+			continue
+		}
+		docs, err := readPackageDocs(filepath.Dir(targetReadmePath(p.Path)), readme)
+		if err != nil {
+			log.Printf("Skipping the links to %s: %v", p.Name, err)
+			continue
+		}
+		after[p.Name] = docs
+		if before[p.Name], err = readPackageDocs(sourceDocsDir(p.Path), path.Base(sourceReadmeRel)); err != nil && verbose {
+			log.Printf("Reading the docs of %s: %v", p.Name, err)
+		}
+	}
+	if len(after) < 2 {
+		return
+	}
+
+	for i := range report.Packages {
+		p := &report.Packages[i]
+		if after[p.Name] == nil {
+			continue
+		}
+		targetPath := targetReadmePath(p.Path)
+		content, enc, err := readMarkdown(targetPath)
+		if err != nil {
+			continue
+		}
+		var warnings []string
+		fixed := markdownLinkPattern.ReplaceAllStringFunc(content, func(m string) string {
+			sub := markdownLinkPattern.FindStringSubmatch(m)
+			l, ok := resolveCrossLink(sub[1], p.Name)
+			if !ok || after[l.pkg] == nil {
+				return m
+			}
+			var broken string
+			if page, ok := findPage(after[l.pkg], l.page); !ok {
+				broken = fmt.Sprintf("it has no page %s", l.page)
+			} else if l.anchor != "" && after[l.pkg][page][l.anchor] == "" {
+				broken = fmt.Sprintf("%s has no heading with anchor #%s", page, l.anchor)
+			} else {
+				return m
+			}
+			if !fixCrossLinks {
+				warnings = append(warnings, fmt.Sprintf("link to %s will break once %s is migrated, %s", sub[1], l.pkg, broken))
+				return m
+			}
+			target := rewriteCrossLink(sub[1], l, fixedCrossLink(l, before[l.pkg], after[l.pkg]))
+			warnings = append(warnings, fmt.Sprintf("link to %s was rewritten to %s, as %s was migrated", sub[1], target, l.pkg))
+			return "](" + target + sub[2] + ")"
+		})
+		if len(warnings) == 0 {
+			continue
+		}
+		if fixed != content {
+			if err := pkgFS.WriteFile(targetPath, enc.encode(fixed), 0o644); err != nil {
+				log.Printf("Failed to rewrite the links of %s: %v", p.Name, err)
+				continue
+			}
+		}
+		if verbose {
+			for _, w := range warnings {
+				log.Printf("%s: %s", p.Name, w)
+			}
+		}
+		p.Warnings = append(p.Warnings, warnings...)
+		p.Findings = append(p.Findings, classifyWarnings(warnings)...)
+	}
+}
@@ -0,0 +1,441 @@
+package doctools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// selectedDataStreams are the data streams selected with -data-streams, the
+// Reference sections of the other data streams are kept as they are
+var selectedDataStreams []string
+
+// parseDataStreams parses the comma separated data stream names of
+// -data-streams
+func parseDataStreams(value string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || slices.Contains(names, name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no data stream names given")
+	}
+	return names, nil
+}
+
+// selectDataStreams restricts a migration to the selected data streams of a
+// package, the others are moved to KeepDataStreams
+func selectDataStreams(req *migrateRequest, selected []string) error {
+	for _, ds := range selected {
+		if len(req.DataStreams) == 0 {
+			return errors.New("-data-streams given but the package has no data streams")
+		}
+		if !slices.Contains(req.DataStreams, ds) {
+			return fmt.Errorf("data stream %q not found, the package has %s", ds, strings.Join(req.DataStreams, ", "))
+		}
+	}
+
+	var dataStreams, sampleEvents []string
+	for _, ds := range req.DataStreams {
+		if slices.Contains(selected, ds) {
+			dataStreams = append(dataStreams, ds)
+		} else {
+			req.KeepDataStreams = append(req.KeepDataStreams, ds)
+		}
+	}
+	for _, ds := range req.SampleEvents {
+		if slices.Contains(selected, ds) {
+			sampleEvents = append(sampleEvents, ds)
+		}
+	}
+	req.DataStreams = dataStreams
+	// An empty list, not nil, still means none of them has a sample event.
+	req.SampleEvents = append([]string{}, sampleEvents...)
+	return nil
+}
+
+// keptDataStreamsPrompt is added to the readme prompt when only some of the
+// data streams are regenerated
+const keptDataStreamsPrompt = `
+
+Only regenerate the Reference content of these data streams: %s. Copy the Reference sections of these other data streams exactly as they are in the original README.md, including their placeholders: %s.`
+
+// dataStreamSection returns the lines of the section about a data stream
+// under the Reference section, or -1 if there is none
+func dataStreamSection(lines []markdown.Line, dataStream string) (int, int) {
+	ref := markdown.FindHeading(lines, "reference")
+	if ref < 0 {
+		return -1, -1
+	}
+	refEnd := markdown.SectionEnd(lines, ref)
+	want := normalizeHeading(dataStream)
+	for i := ref + 1; i < refEnd; i++ {
+		if lines[i].Level > 0 && normalizeHeading(lines[i].Heading) == want {
+			return i, min(markdown.SectionEnd(lines, i), refEnd)
+		}
+	}
+	return -1, -1
+}
+
+// restoreDataStreamSections puts back the Reference sections of the kept data
+// streams as they were in the original readme, in case the model changed
+// them. Kept data streams the migrated readme lost are reported.
+func restoreDataStreamSections(original, migrated string, kept []string) (string, []string) {
+	var warnings []string
+	originalLines := markdown.ParseLines(original)
+	for _, ds := range kept {
+		start, end := dataStreamSection(originalLines, ds)
+		if start < 0 {
+			continue
+		}
+		lines := markdown.ParseLines(migrated)
+		mStart, mEnd := dataStreamSection(lines, ds)
+		if mStart < 0 {
+			warnings = append(warnings, fmt.Sprintf("section of kept data stream %q missing from the migrated readme", ds))
+			continue
+		}
+		// The section keeps the heading level of the migrated readme.
+		section := slices.Clone(originalLines[start:end])
+		delta := lines[mStart].Level - section[0].Level
+		for i := range section {
+			if section[i].Level > 0 {
+				section[i].Level = min(max(section[i].Level+delta, 1), 6)
+			}
+		}
+		migrated = markdown.ShiftHeadings(slices.Concat(lines[:mStart], section, lines[mEnd:]), 0)
+	}
+	return migrated, warnings
+}
+
+// Orders of the data streams in the Reference section, selected with
+// -data-stream-order
+const (
+	// orderManifest keeps the order of the policy templates of the
+	// manifest
+	orderManifest = "manifest"
+	orderName     = "name"
+	// orderLogsFirst documents logs, then metrics, traces and the other
+	// types, each in manifest order
+	orderLogsFirst = "logs-first"
+)
+
+// Groupings of the data streams in the Reference section, selected with
+// -data-stream-groups
+const (
+	groupNone = "none"
+	groupType = "type"
+)
+
+var (
+	// dataStreamOrder is the order of -data-stream-order
+	dataStreamOrder = orderManifest
+	// dataStreamGroups is the grouping of -data-stream-groups
+	dataStreamGroups = groupNone
+)
+
+// dataStreamTypeRanks order the data stream types for -data-stream-order
+// logs-first, other types come last
+var dataStreamTypeRanks = map[string]int{"logs": 0, "metrics": 1, "traces": 2, "synthetics": 3}
+
+// validateDataStreamOrder rejects unknown data stream orders and groupings
+func validateDataStreamOrder(order, groups string) error {
+	switch order {
+	case orderManifest, orderName, orderLogsFirst:
+	default:
+		return fmt.Errorf("unknown data stream order %q, use %s, %s or %s", order, orderManifest, orderName, orderLogsFirst)
+	}
+	switch groups {
+	case groupNone, groupType:
+	default:
+		return fmt.Errorf("unknown data stream grouping %q, use %s or %s", groups, groupNone, groupType)
+	}
+	return nil
+}
+
+// readDataStreamTypes returns the type of each data stream, logs or metrics
+// for example, from its manifest.yml. Data streams without one are left out.
+func readDataStreamTypes(pkgPath string, dataStreams []string) (map[string]string, error) {
+	types := make(map[string]string)
+	for _, ds := range dataStreams {
+		path := filepath.Join(pkgPath, "data_stream", ds, "manifest.yml")
+		data, err := pkgFS.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var m dataStreamManifest
+		if err := yaml.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if m.Type != "" {
+			types[ds] = m.Type
+		}
+	}
+	return types, nil
+}
+
+// orderDataStreams orders data streams in manifest order for the Reference
+// section, with -data-stream-order
+func orderDataStreams(dataStreams []string, types map[string]string) []string {
+	ordered := slices.Clone(dataStreams)
+	switch dataStreamOrder {
+	case orderName:
+		slices.Sort(ordered)
+	case orderLogsFirst:
+		slices.SortStableFunc(ordered, func(a, b string) int {
+			return typeRank(types[a]) - typeRank(types[b])
+		})
+	}
+	return ordered
+}
+
+// typeRank returns the rank of a data stream type for logs-first
+func typeRank(t string) int {
+	if rank, ok := dataStreamTypeRanks[t]; ok {
+		return rank
+	}
+	return len(dataStreamTypeRanks)
+}
+
+// dataStreamGroup is a group of data streams in the Reference section
+type dataStreamGroup struct {
+	Title       string
+	DataStreams []string
+}
+
+// groupDataStreams groups ordered data streams by type with
+// -data-stream-groups type, the groups in the order of their first data
+// stream. Otherwise, there is a single group.
+func groupDataStreams(dataStreams []string, types map[string]string) []dataStreamGroup {
+	if dataStreamGroups != groupType {
+		return []dataStreamGroup{{DataStreams: dataStreams}}
+	}
+	var groups []dataStreamGroup
+	for _, ds := range dataStreams {
+		title := typeTitle(types[ds])
+		i := slices.IndexFunc(groups, func(g dataStreamGroup) bool { return g.Title == title })
+		if i < 0 {
+			groups = append(groups, dataStreamGroup{Title: title})
+			i = len(groups) - 1
+		}
+		groups[i].DataStreams = append(groups[i].DataStreams, ds)
+	}
+	return groups
+}
+
+// typeTitle returns the heading of the group of a data stream type
+func typeTitle(t string) string {
+	if t == "" {
+		return "Other"
+	}
+	return strings.ToUpper(t[:1]) + t[1:]
+}
+
+// manifestOrder orders the data streams of a package as the policy templates
+// of its manifest list them, followed by the others in name order
+func manifestOrder(dataStreams []string, setup *packageSetup) []string {
+	var ordered []string
+	if setup != nil {
+		for _, pt := range setup.PolicyTemplates {
+			for _, ds := range pt.DataStreams {
+				if slices.Contains(dataStreams, ds) && !slices.Contains(ordered, ds) {
+					ordered = append(ordered, ds)
+				}
+			}
+		}
+	}
+	rest := slices.DeleteFunc(slices.Clone(dataStreams), func(ds string) bool { return slices.Contains(ordered, ds) })
+	slices.Sort(rest)
+	return append(ordered, rest...)
+}
+
+// exampleEventPattern matches the line introducing a sample event
+var exampleEventPattern = regexp.MustCompile(`(?i)^\s*an example event for .* looks as follow`)
+
+// isReferenceBoilerplate reports whether a line of a data stream section is
+// written by assembleReference: a placeholder or the line introducing a
+// sample event
+func isReferenceBoilerplate(line string) bool {
+	return placeholderPattern.MatchString(line) || genericPlaceholder.MatchString(line) || exampleEventPattern.MatchString(line)
+}
+
+// dataStreamSummaries returns the text of the sections named after each data
+// stream, without placeholders and subsections. Only the sections under the
+// Reference section are considered unless anywhere is set, as for legacy
+// readmes.
+func dataStreamSummaries(content string, dataStreams []string, anywhere bool) map[string]string {
+	lines := markdown.ParseLines(content)
+	start, end := 0, len(lines)
+	if !anywhere {
+		ref := markdown.FindHeading(lines, "reference")
+		if ref < 0 {
+			return nil
+		}
+		start, end = ref+1, markdown.SectionEnd(lines, ref)
+	}
+	summaries := make(map[string]string)
+	for i := start; i < end; i++ {
+		if lines[i].Level == 0 {
+			continue
+		}
+		ds := matchDataStream(lines[i].Heading, dataStreams)
+		if ds == "" || summaries[ds] != "" {
+			continue
+		}
+		var text []string
+		for j := i + 1; j < end && lines[j].Level == 0; j++ {
+			if !isReferenceBoilerplate(lines[j].Text) {
+				text = append(text, lines[j].Text)
+			}
+		}
+		if summary := strings.TrimSpace(strings.Join(text, "\n")); summary != "" {
+			summaries[ds] = summary
+		}
+	}
+	return summaries
+}
+
+// matchDataStream returns the data stream a heading is named after, or empty
+func matchDataStream(heading string, dataStreams []string) string {
+	name := normalizeHeading(heading)
+	for _, ds := range dataStreams {
+		if normalizeHeading(ds) == name {
+			return ds
+		}
+	}
+	return ""
+}
+
+// assembleReference rebuilds the Reference section of a migrated readme with
+// a section per data stream, in the order of dataStreams: its summary, its
+// exported fields and its sample event if it has one. The summaries are
+// taken from the sections of the migrated readme named after the data
+// streams, or else from those of the original readme. The sections about the
+// data streams and the generic field and event sections are replaced, the
+// other content of the Reference section is kept after the data streams.
+// sampleEvents lists the data streams with a sample event, nil for all. With
+// -data-stream-groups type the data streams are grouped under a section per
+// type when they have several.
+func assembleReference(content, original string, dataStreams, sampleEvents []string, types map[string]string) string {
+	summaries := dataStreamSummaries(content, dataStreams, false)
+	for ds, summary := range dataStreamSummaries(original, dataStreams, true) {
+		if summaries[ds] == "" {
+			summaries[ds] = summary
+		}
+	}
+
+	lines := markdown.ParseLines(content)
+	ref := markdown.FindHeading(lines, "reference")
+	if ref < 0 {
+		lines = append(lines, markdown.Line{Text: ""}, markdown.Line{Text: "## Reference", Level: 2, Heading: "Reference"})
+		ref = len(lines) - 1
+	}
+	refEnd := markdown.SectionEnd(lines, ref)
+	level := min(lines[ref].Level+1, 6)
+
+	// The intro of the Reference section and its other subsections are kept
+	var intro, kept []string
+	i := ref + 1
+	for ; i < refEnd && lines[i].Level == 0; i++ {
+		if !isReferenceBoilerplate(lines[i].Text) {
+			intro = append(intro, lines[i].Text)
+		}
+	}
+	for i < refEnd {
+		end := min(markdown.SectionEnd(lines, i), refEnd)
+		if !isDataStreamContent(lines[i:end], dataStreams) {
+			for _, l := range lines[i:end] {
+				kept = append(kept, l.Text)
+			}
+		}
+		i = end
+	}
+
+	var b strings.Builder
+	b.WriteString(lines[ref].Text + "\n\n")
+	if text := strings.TrimSpace(strings.Join(intro, "\n")); text != "" {
+		b.WriteString(text + "\n\n")
+	}
+	groups := groupDataStreams(dataStreams, types)
+	for _, g := range groups {
+		dsLevel := level
+		if len(groups) > 1 {
+			fmt.Fprintf(&b, "%s %s\n\n", strings.Repeat("#", level), g.Title)
+			dsLevel = min(level+1, 6)
+		}
+		for _, ds := range g.DataStreams {
+			writeDataStreamSection(&b, ds, summaries[ds], dsLevel, sampleEvents == nil || slices.Contains(sampleEvents, ds))
+		}
+	}
+	if text := strings.TrimSpace(strings.Join(kept, "\n")); text != "" {
+		b.WriteString(text + "\n\n")
+	}
+
+	var before, after []string
+	for _, l := range lines[:ref] {
+		before = append(before, l.Text)
+	}
+	for _, l := range lines[refEnd:] {
+		after = append(after, l.Text)
+	}
+	readme := strings.TrimRight(strings.Join(before, "\n"), "\n")
+	if readme != "" {
+		readme += "\n\n"
+	}
+	readme += b.String()
+	if rest := strings.TrimSpace(strings.Join(after, "\n")); rest != "" {
+		return readme + rest + "\n"
+	}
+	return strings.TrimRight(readme, "\n") + "\n"
+}
+
+// writeDataStreamSection writes the Reference section of a data stream with
+// its heading at level
+func writeDataStreamSection(b *strings.Builder, ds, summary string, level int, sampleEvent bool) {
+	fmt.Fprintf(b, "%s %s\n\n", strings.Repeat("#", level), ds)
+	if summary != "" {
+		b.WriteString(summary + "\n\n")
+	} else {
+		fmt.Fprintf(b, "<!-- Add a brief summary of the data the %s data stream collects. -->\n\n", ds)
+	}
+	sub := strings.Repeat("#", min(level+1, 6))
+	fmt.Fprintf(b, "%s Exported fields\n\n%s\n\n", sub, placeholders.fields(ds))
+	if sampleEvent {
+		fmt.Fprintf(b, "%s Sample event\n\nAn example event for \"%s\" looks as following:\n\n%s\n\n", sub, ds, placeholders.event(ds))
+	}
+}
+
+// isDataStreamContent reports whether a subsection of the Reference section
+// is about the data streams: named after one, holding one or their
+// placeholders, or one of the generic field and event sections of the
+// template. Sections about other data streams, such as kept ones, are not.
+func isDataStreamContent(section []markdown.Line, dataStreams []string) bool {
+	if slices.ContainsFunc(dataStreamSections, func(h string) bool { return strings.EqualFold(h, section[0].Heading) }) {
+		return true
+	}
+	for _, l := range section {
+		if l.Level > 0 && matchDataStream(l.Heading, dataStreams) != "" {
+			return true
+		}
+		if l.Level == 0 && (genericPlaceholder.MatchString(l.Text) || slices.ContainsFunc(dataStreams, func(ds string) bool {
+			return strings.Contains(l.Text, placeholders.fields(ds)) || strings.Contains(l.Text, placeholders.event(ds))
+		})) {
+			return true
+		}
+	}
+	return false
+}
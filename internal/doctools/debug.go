@@ -0,0 +1,35 @@
+package doctools
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+)
+
+// startPprof serves the pprof endpoints under /debug/pprof/ on addr in the
+// background
+func startPprof(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	go func() {
+		logInfo("Serving pprof on http://%s/debug/pprof/", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("Error serving pprof: %v", err)
+		}
+	}()
+}
+
+// logRuntimeStats logs memory and goroutine statistics, labelled with what
+// just finished
+func logRuntimeStats(label string) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	log.Printf("Runtime after %s: heap_alloc=%dMiB heap_inuse=%dMiB sys=%dMiB total_alloc=%dMiB num_gc=%d goroutines=%d",
+		label, m.HeapAlloc>>20, m.HeapInuse>>20, m.Sys>>20, m.TotalAlloc>>20, m.NumGC, runtime.NumGoroutine())
+}
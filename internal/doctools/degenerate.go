@@ -0,0 +1,105 @@
+package doctools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+const (
+	// degenerateAttempts is how often the LLM is asked for a readme before
+	// an empty, truncated or suspiciously short answer fails the package
+	degenerateAttempts = 3
+	// minAnswerRatio is the share of the words of the original readme a
+	// migrated readme must have at least, the migration adds to the readme
+	// rather than removes from it
+	minAnswerRatio = 0.3
+	// minCheckedWords is the size of the readmes below which the length of
+	// the answer is not checked, a stub can shrink
+	minCheckedWords = 50
+)
+
+// retryTemperatures are the temperatures of the attempts after a degenerate
+// answer, lower to keep the model from wandering off
+var retryTemperatures = []float32{0.3, 0}
+
+// degenerateRetryPrompt tells the LLM what was wrong with its last answer
+const degenerateRetryPrompt = `
+
+Your previous answer was %s. Answer with the complete migrated README, keeping all the content of the original README.`
+
+// degenerateError is an answer of the LLM that is not a usable readme: empty,
+// cut off at the output token limit or much shorter than the readme
+type degenerateError struct {
+	reason string
+}
+
+func (e degenerateError) Error() string { return "answer of the model was " + e.reason }
+
+// degenerateReason returns why an answer is not a usable migration of
+// readme, or an empty string if it looks like one
+func degenerateReason(text, readme string) string {
+	if strings.TrimSpace(text) == "" {
+		return "empty"
+	}
+	words := len(wordPattern.FindAllString(text, -1))
+	original := len(wordPattern.FindAllString(readme, -1))
+	if original >= minCheckedWords && float64(words) < minAnswerRatio*float64(original) {
+		return fmt.Sprintf("suspiciously short, %d words for an original README of %d", words, original)
+	}
+	return ""
+}
+
+// retryConfiguration returns the model settings of an attempt after a
+// degenerate answer: the largest output of the model and a lower
+// temperature
+func retryConfiguration(b llmclient.Backend, attempt int, configure func(*genai.GenerativeModel)) func(*genai.GenerativeModel) {
+	if attempt == 1 {
+		return configure
+	}
+	return func(m *genai.GenerativeModel) {
+		if configure != nil {
+			configure(m)
+		}
+		if out := backendCapabilities(b).OutputTokens; out > 0 {
+			m.SetMaxOutputTokens(int32(out))
+		}
+		m.SetTemperature(retryTemperatures[min(attempt-2, len(retryTemperatures)-1)])
+	}
+}
+
+// generateReadmeText asks the LLM for a readme and checks the answer,
+// asking again with adjusted settings after an empty, truncated or
+// suspiciously short one
+func generateReadmeText(ctx context.Context, b llmclient.Backend, system, prompt, readme string) (string, llmclient.Usage, error) {
+	var usage llmclient.Usage
+	retryPrompt := ""
+	for attempt := 1; ; attempt++ {
+		text, u, err := generateContent(ctx, b, system, prompt+retryPrompt, retryConfiguration(b, attempt, nil))
+		usage.PromptTokens += u.PromptTokens
+		usage.ResponseTokens += u.ResponseTokens
+		var de degenerateError
+		switch {
+		case errors.As(err, &de):
+		case err != nil:
+			return "", usage, err
+		default:
+			if reason := degenerateReason(text, readme); reason != "" {
+				de = degenerateError{reason}
+			} else {
+				return text, usage, nil
+			}
+		}
+		if attempt == degenerateAttempts {
+			return "", usage, fmt.Errorf("%w, %d attempts in all", de, attempt)
+		}
+		log.Printf("Answer of the model was %s (attempt %d of %d), asking again", de.reason, attempt, degenerateAttempts)
+		retryPrompt = fmt.Sprintf(degenerateRetryPrompt, de.reason)
+	}
+}
@@ -0,0 +1,5 @@
+// Package doctools implements the doc tools of cmd: the docs-template-update
+// migration pipeline with its server, worker and reports, and the changelog,
+// fields, readme-lint, release-notes, qa, anonymize, drift and ecs-mappings
+// commands sharing its package, LLM and markdown code.
+package doctools
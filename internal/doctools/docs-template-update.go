@@ -0,0 +1,933 @@
+package doctools
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/pmezard/go-difflib/difflib"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+const (
+	templateURL = "https://raw.githubusercontent.com/elastic/elastic-package/89b34ec09f562b2c1c921ba4b465b6ef96ea47de/internal/packages/archetype/_static/package-docs-readme.md.tmpl"
+	// System prompt for instructing the LLM
+	systemPrompt = `You are a documentation expert specializing in Elastic documentation templates.
+Your task is to transform the provided README file to conform to the new template structure. This is intended to be an additive process,
+so do not remove any existing content, only restructure it to fit the new template.
+
+Here is some context for you to reference for your task, read it carefully as you will get questions about it later:
+# Original README content:
+%s
+
+# New template structure:
+%s
+`
+	// User prompt template for the LLM
+	userPromptTemplate = `I need to update this README.md file to match our new documentation template.
+
+Follow these exact guidelines:
+1. Always utilize the original content of the README.md file where possible
+2. Restructure the document to follow the new template format provided
+3. If any content is not relevant to the new template, copy it to the Reference section and add a note it in a code comment for why it should be removed
+4. Do not include the following from the tempalte: initial comment from the template, the header placeholder, or the Reference -> ECS field reference section
+5. Always organize the datastreams together under Reference section. For each datastream there should be
+a brief summary, exported fields, and sample events sections all separated with an empty line.
+6. Always prefix sample event placeholders with 'An example event for "data_stream_name" looks as following:'.
+7. Format your response appropriately for a Markdown file
+8. Replace any 'Exported fields' sections with the mustache placeholder: {{fields "data_stream_name"}}
+9. Replace any 'Sample event' sections with the mustache placeholder: {{event "data_stream_name"}}
+10. If there is no content for a section, you must add a code comment with some guidance to the user on what to add.
+11. Do not include any reference to Elastic in the overall integration title. Just name it as the integration name.
+12. Sync the document with the new template structure
+
+Return ONLY the updated Markdown content, without any explanation or commentary.`
+)
+
+var (
+	googleAPIKey string
+	packagePath  string
+	checkOnly    bool
+	hookMode     bool
+
+	watchMode       bool
+	watchInterval   time.Duration
+	watchRegenerate bool
+
+	historyPath string
+
+	packagesDir    string
+	reportPath     string
+	coveragePath   string
+	checkpointPath string
+	maxDuration    time.Duration
+
+	pprofAddr    string
+	debugRuntime bool
+)
+
+// registerMainFlags adds the options of a migration run to the command line,
+// the subcommands have flag sets of their own
+func registerMainFlags() {
+	flag.StringVar(&googleAPIKey, "api-key", "", "Google Gemini API key (required)")
+	flag.StringVar(&apiKeyRef, "api-key-ref", "", secretRefHelp)
+	flag.StringVar(&packagePath, "path", ".", "Path to the package directory")
+	registerVerbosityFlags(flag.CommandLine)
+	flag.BoolVar(&hookMode, "hook", false, "Run as a git pre-commit hook: block staged docs/ README changes without matching _dev/build/docs changes (no network calls)")
+	flag.BoolVar(&checkOnly, "check", false, "Check whether the package docs conform to the template without calling the LLM; exits non-zero if a migration is needed")
+	flag.BoolVar(&watchMode, "watch", false, "Watch the package docs, data streams and manifest and re-run validation on every change")
+	flag.DurationVar(&watchInterval, "watch-interval", time.Second, "How often to poll for changes in watch mode")
+	flag.BoolVar(&watchRegenerate, "watch-regenerate", false, "In watch mode, also regenerate the readme with the LLM on every change")
+	flag.StringVar(&historyPath, "history", os.Getenv(historyEnv), "Path to a SQLite database recording the run history (defaults to "+historyEnv+")")
+	flag.StringVar(&packagesDir, "packages", "", "Migrate every package in this directory instead of the single package in -path")
+	flag.StringVar(&reportPath, "report", "", "Write a JSON report of the run to this file")
+	flag.StringVar(&slackWebhook, "slack-webhook", os.Getenv("SLACK_WEBHOOK_URL"), "With -packages, post a summary of the run to this Slack incoming webhook URL (defaults to SLACK_WEBHOOK_URL)")
+	flag.StringVar(&reportURL, "report-url", "", "URL the -report is published at, e.g. a CI artifact, linked from the Slack summary")
+	flag.StringVar(&coveragePath, "coverage", "", "With -packages, write a markdown backlog of the template sections left without content to this file")
+	flag.DurationVar(&maxDuration, "max-duration", 0, "With -packages, stop starting new packages after this long and report the rest as skipped (0 means no limit)")
+	flag.StringVar(&pprofAddr, "pprof", "", "Serve pprof profiles on this address, e.g. localhost:6060")
+	flag.BoolVar(&debugRuntime, "debug-runtime", false, "Log memory and goroutine statistics after every package")
+	flag.BoolVar(&dryRun, "dry-run", false, "Migrate without writing to the packages or opening follow-up issues and tickets, print the patch and the files that would change instead")
+	flag.BoolVar(&sandboxMode, "sandbox", false, "Migrate each package in memory and only write the results to it once every stage, including -verify, succeeded")
+	flag.StringVar(&verifyCommand, "verify", "", "With -sandbox, command run in a temporary copy of the migrated package, e.g. 'elastic-package build', the package is left untouched when it fails")
+	flag.BoolVar(&refreshSamples, "refresh-samples", false, "Regenerate the sample_event.json of the data streams from the expected documents of their latest pipeline test while migrating")
+	flag.BoolVar(&fixCrossLinks, "fix-cross-links", false, "With -packages, rewrite the links to the docs of other packages of the run that break once those are migrated, instead of only reporting them")
+	flag.BoolVar(&regenerateBadges, "regenerate-badges", false, "Replace the version and subscription badges at the top of the readme with badges generated from manifest.yml, adding them if it has none")
+	flag.StringVar(&linksFile, "links-file", "", "Links table of the {{url}} placeholders of elastic-package, defaults to $"+linksFileEnv+" or the "+linksTableFile+" file of the nearest parent directory of the package")
+	flag.StringVar(&samplePolicyPath, "sample-policy", "", "YAML policy file: replace the real IP addresses, hostnames and account IDs in the sample events of the data streams while migrating, see the README for its settings")
+	flag.StringVar(&checkpointPath, "checkpoint", "", "With -packages, save progress to this file so an interrupted run can be resumed by running it again")
+
+	flag.StringVar(&outputTarget, "target", targetReadme, "Output format: readme for the elastic-package readme template, docs-v3 for an Elastic docs-builder page in _dev/build/docs-v3/index.md")
+	flag.StringVar(&extraDocsMode, "extra-docs", extraDocsMerge, "How markdown files in docs/ besides README.md are migrated: merge merges them into the readme, separate keeps them as pages next to it in _dev/build/docs")
+	flag.StringVar(&dataStreamOrder, "data-stream-order", orderManifest, "Order of the data streams in the Reference section: manifest follows the policy_templates of manifest.yml, name sorts them by name, logs-first puts logs before metrics")
+	flag.StringVar(&dataStreamGroups, "data-stream-groups", groupNone, "Grouping of the data streams in the Reference section: none, or type for a section per data stream type")
+	flag.StringVar(&sinceRef, "since", "", "Git ref, e.g. v1.2.0, to only regenerate the Reference sections of the data streams changed since in a migrated readme, leaving the rest of it byte for byte; with -packages, packages without such changes are skipped")
+	flag.StringVar(&regenerateSection, "section", "", "Heading of the only template section to regenerate in an already migrated readme, e.g. Troubleshooting, the rest of the readme is kept byte for byte")
+	flag.StringVar(&docsLayout, "layout", layoutSingle, "Readme layout: single keeps everything in the readme, split moves the reference of each data stream to its own page in _dev/build/docs")
+	flag.Func("translate", "Comma separated language codes, e.g. ja,fr, to also write translations of the migrated readme next to it", func(value string) error {
+		langs, err := parseLanguages(value)
+		translateLanguages = langs
+		return err
+	})
+	flag.Func("data-streams", "Comma separated data streams, e.g. ds1,ds2, to only regenerate the Reference sections of, the sections of the other data streams are kept", func(value string) error {
+		names, err := parseDataStreams(value)
+		selectedDataStreams = names
+		return err
+	})
+	flag.StringVar(&issuesRepo, "issues-repo", "", "Repository, e.g. elastic/integrations, to open an issue in for each package the LLM left TODOs in, assigned to the package owners of CODEOWNERS (uses GITHUB_TOKEN and GITHUB_API_URL)")
+	flag.StringVar(&issuesQuery, "issues-query", "", "GitHub issue search query for the known issues of each package, {package} stands for the package name, e.g. 'repo:elastic/integrations is:issue is:open label:\"Integration:{package}\"' (uses GITHUB_TOKEN and GITHUB_API_URL)")
+	flag.BoolVar(&strictMode, "strict", false, "Fail a package when its migrated readme has findings of warning severity or above, same as -fail-on warning")
+	flag.StringVar(&failOn, "fail-on", "", "Fail a package when its migrated readme has findings of this severity or above: info, warning or error. With -check, only exit with 1 for such findings")
+	registerNetworkFlags(flag.CommandLine)
+	registerTimeoutFlags(flag.CommandLine)
+	registerECSFlags(flag.CommandLine)
+	registerStyleIndexFlags(flag.CommandLine)
+	registerGlossaryFlags(flag.CommandLine)
+	registerConfigFlags(flag.CommandLine)
+	registerValeFlags(flag.CommandLine)
+	registerReadabilityFlags(flag.CommandLine)
+	registerProviderFlags(flag.CommandLine)
+	registerPromptFlags(flag.CommandLine)
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s serve [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s worker [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s report history|compare [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s auth login|logout\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s benchmark -packages dir -models model,... [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s eval -packages dir -a prompts.yml -b prompts.yml [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s sweep -packages dir [-temperatures 0,0.5,1] [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s index -packages dir [-o style-index.json] [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s self-update [-check] [-version tag] [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "docs-template-update updates documentation templates to the new format.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+}
+
+// Main runs docs-template-update: a migration of the package of -path, or of
+// every package of -packages, or the subcommand of the first argument
+func Main() {
+	shutdownTracing := SetupTracing("docs-template-update")
+	defer shutdownTracing()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "worker":
+			runWorker(os.Args[2:])
+			return
+		case "report":
+			runReport(os.Args[2:])
+			return
+		case "auth":
+			runAuth(os.Args[2:])
+			return
+		case "benchmark":
+			runBenchmark(os.Args[2:])
+			return
+		case "eval":
+			runEval(os.Args[2:])
+			return
+		case "sweep":
+			runSweep(os.Args[2:])
+			return
+		case "index":
+			runIndex(os.Args[2:])
+			return
+		case "self-update":
+			runSelfUpdate(os.Args[2:])
+			return
+		}
+	}
+
+	registerMainFlags()
+	flag.Parse()
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateTimeouts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadGlossary(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadProvider(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadPrompts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadSamplePolicy(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateTarget(outputTarget); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateDataStreamOrder(dataStreamOrder, dataStreamGroups); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateLayout(docsLayout); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateExtraDocs(extraDocsMode, outputTarget); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if len(selectedDataStreams) > 0 && outputTarget == targetDocsV3 {
+		log.Fatalf("Error: -data-streams only applies to the readme target")
+	}
+	if sinceRef != "" && outputTarget == targetDocsV3 {
+		log.Fatalf("Error: -since only applies to the readme target")
+	}
+	if sinceRef != "" && len(selectedDataStreams) > 0 {
+		log.Fatalf("Error: -since cannot be used with -data-streams")
+	}
+	if sinceRef != "" && regenerateSection != "" {
+		log.Fatalf("Error: -since cannot be used with -section")
+	}
+	if regenerateSection != "" && outputTarget == targetDocsV3 {
+		log.Fatalf("Error: -section only applies to the readme target")
+	}
+	if err := validateFailOn(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if verifyCommand != "" && !sandboxMode {
+		log.Fatalf("Error: -verify needs -sandbox")
+	}
+	if dryRun {
+		// A checkpoint would record packages as migrated that were not
+		if checkpointPath != "" {
+			log.Fatalf("Error: -checkpoint cannot be used with -dry-run")
+		}
+		pkgFS = newOverlayFS(osFS{})
+	}
+
+	if hookMode {
+		findings, err := runHook(packagePath, flag.Args())
+		if err != nil {
+			log.Fatalf("Error running hook: %v", err)
+		}
+		for _, f := range findings {
+			fmt.Fprintln(os.Stderr, f)
+		}
+		if len(findings) > 0 {
+			fmt.Fprintln(os.Stderr, "\nCommit blocked: docs/ files are generated by elastic-package from _dev/build/docs.")
+			os.Exit(1)
+		}
+		return
+	}
+
+	if checkOnly {
+		findings, err := checkPackage(context.Background(), packagePath)
+		if err != nil {
+			log.Fatalf("Error checking package: %v", err)
+		}
+		for _, f := range findings {
+			fmt.Printf("%s: %s\n", packagePath, f)
+		}
+		failed := len(findings) > 0
+		if failOn != "" {
+			failed = len(failingFindings(classifyWarnings(findings))) > 0
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if watchMode {
+		if watchRegenerate {
+			requireAPIKey()
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+		defer stop()
+		if err := watchPackage(ctx, packagePath, watchInterval, watchRegenerate); err != nil {
+			log.Fatalf("Error watching package: %v", err)
+		}
+		return
+	}
+
+	requireAPIKey()
+
+	if pprofAddr != "" {
+		startPprof(pprofAddr)
+	}
+
+	// On SIGINT or SIGTERM the in-flight LLM call is cancelled, the readme
+	// is left untouched and the results so far are flushed
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+
+	if packagesDir != "" {
+		report, err := runBatch(ctx, packagesDir, checkpointPath, maxDuration)
+		if err != nil {
+			shutdownTracing()
+			log.Fatalf("Error processing packages: %v", err)
+		}
+		if historyPath != "" {
+			if err := recordBatchHistory(historyPath, report); err != nil {
+				log.Printf("Failed to record run history: %v", err)
+			}
+		}
+		if reportPath != "" {
+			if err := writeReport(reportPath, report); err != nil {
+				log.Printf("Failed to write report: %v", err)
+			}
+		}
+		if coveragePath != "" {
+			if err := writeCoverage(coveragePath, report); err != nil {
+				log.Printf("Failed to write coverage: %v", err)
+			}
+		}
+		// The run may have been interrupted, the summary is still posted
+		if err := notifySlack(context.WithoutCancel(ctx), packagesDir, report); err != nil {
+			log.Printf("%v", err)
+		}
+		if verbosity > verbosityQuiet {
+			fmt.Fprint(os.Stderr, dryRunSummary())
+		}
+		if report.incomplete() && checkpointPath != "" {
+			logInfo("Run stopped early, run again to resume from %s", checkpointPath)
+		}
+		if report.failed() {
+			shutdownTracing()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Process the package
+	started := time.Now()
+	result, err := processPackage(ctx, packagePath)
+	if debugRuntime {
+		logRuntimeStats(packagePath)
+	}
+
+	if historyPath != "" {
+		if herr := recordHistory(historyPath, packagePath, started, result, err); herr != nil {
+			log.Printf("Failed to record run history: %v", herr)
+		}
+	}
+
+	if err != nil {
+		// log.Fatalf skips the deferred flush, the failed run's spans are
+		// the interesting ones.
+		shutdownTracing()
+		log.Fatalf("Error processing package: %v", err)
+	}
+
+	for _, a := range result.Style {
+		logInfo("Style: line %d, %s (%s): %s", a.Line, a.Check, a.Severity, a.Message)
+	}
+	if verbose {
+		for _, r := range result.Readability {
+			log.Printf("Readability of %s: grade %.1f, %.1f words per sentence, %.1f%% passive", r.Section, r.Grade, r.SentenceWords, r.Passive)
+		}
+		for _, g := range result.Gaps {
+			log.Printf("Docs gap: section %q is %s", g.Section, g.Kind)
+		}
+		for _, t := range result.Todos {
+			log.Printf("TODO (%s) in %q, line %d: %s", t.Kind, t.Section, t.Line, t.Text)
+		}
+	}
+
+	// Print the git patch, nothing when the readme is unchanged. Everything
+	// else goes to stderr, so the output can be piped to git apply.
+	if result.Patch != "" {
+		fmt.Println(result.Patch)
+	}
+	if verbosity > verbosityQuiet {
+		fmt.Fprint(os.Stderr, dryRunSummary())
+	}
+}
+
+// recordHistory stores the result of a command line run in the history
+// database at path
+func recordHistory(path, pkgPath string, started time.Time, result *migrateResponse, runErr error) error {
+	history, err := openHistory(path)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+	return history.recordPackageRun(pkgPath, started, result, runErr)
+}
+
+// requireAPIKey falls back to the GOOGLE_API_KEY environment variable when
+// no key was given on the command line and exits if neither is set
+func requireAPIKey() {
+	if llmProvider == llmclient.ProviderFake {
+		return
+	}
+	if err := loadAPIKey(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if googleAPIKey == "" {
+		log.Fatalf("Google API key is required. Set it using the -api-key or -api-key-ref flag or GOOGLE_API_KEY environment variable, or store it with docs-template-update auth login")
+	}
+}
+
+// loadAPIKey fills in the API key from -api-key-ref, the GOOGLE_API_KEY
+// environment variable or the keychain, in that order, when -api-key is not
+// set
+func loadAPIKey() error {
+	if googleAPIKey != "" {
+		return nil
+	}
+	if apiKeyRef != "" {
+		key, err := resolveSecretRef(context.Background(), apiKeyRef)
+		if err != nil {
+			return fmt.Errorf("failed to read API key from %s: %w", apiKeyRef, err)
+		}
+		googleAPIKey = key
+		return nil
+	}
+	googleAPIKey = os.Getenv("GOOGLE_API_KEY")
+	if googleAPIKey == "" {
+		googleAPIKey = keychainAPIKey()
+	}
+	return nil
+}
+
+// redactSecrets removes the API key from messages that are stored or sent
+// to clients, client errors include the request URL which carries the key
+func redactSecrets(msg string) string {
+	if googleAPIKey == "" {
+		return msg
+	}
+	return strings.ReplaceAll(msg, googleAPIKey, "REDACTED")
+}
+
+// findDataStreams discovers data stream directories in the package
+func findDataStreams(pkgPath string) ([]string, error) {
+	dataStreamPath := filepath.Join(pkgPath, "data_stream")
+
+	// Check if data_stream directory exists
+	if _, err := pkgFS.Stat(dataStreamPath); os.IsNotExist(err) {
+		if verbose {
+			log.Printf("No data_stream directory found at %s", dataStreamPath)
+		}
+		return nil, nil
+	}
+
+	// List directories in data_stream directory
+	entries, err := pkgFS.ReadDir(dataStreamPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data_stream directory: %w", err)
+	}
+
+	var dataStreams []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dataStreams = append(dataStreams, entry.Name())
+		}
+	}
+
+	if verbose {
+		log.Printf("Found data streams: %v", dataStreams)
+	}
+
+	return dataStreams, nil
+}
+
+// applyDataStreamPlaceholders replaces generic placeholders with specific
+// data stream names. With several data streams the Reference section is
+// rebuilt with a section per data stream, see assembleReference.
+func applyDataStreamPlaceholders(content string, req migrateRequest) string {
+	dataStreams := req.DataStreams
+	switch len(dataStreams) {
+	case 0:
+		return content
+	case 1:
+		content = genericFieldsPattern.ReplaceAllLiteralString(content, placeholders.fields(dataStreams[0]))
+		return genericEventPattern.ReplaceAllLiteralString(content, placeholders.event(dataStreams[0]))
+	}
+	return assembleReference(content, req.Readme, orderDataStreams(dataStreams, req.DataStreamTypes), req.SampleEvents, req.DataStreamTypes)
+}
+
+// processPackage migrates the readme of the package in place and returns the
+// result of the migration. With -target docs-v3 the docs-builder page is
+// generated from the rendered readme instead. A failed migration returns the
+// result so far with the error, for the usage of the LLM calls it made.
+func processPackage(ctx context.Context, pkgPath string) (*migrateResponse, error) {
+	ctx, span := tracer.Start(ctx, "process-package", trace.WithAttributes(attribute.String("package.path", pkgPath)))
+	defer span.End()
+
+	// With -sandbox the stages write to memory, the package is only changed
+	// once all of them succeeded
+	var sandbox *memFS
+	base := pkgFS
+	if sandboxMode {
+		sandbox = newOverlayFS(base)
+		pkgFS = sandbox
+		defer func() { pkgFS = base }()
+	}
+
+	// The package is read before and written after the migration, which
+	// runs within -package-timeout
+	s := &pipelineState{pkgPath: pkgPath}
+	timings, err := runStages(ctx, []stage{{stagePrepare, prepareStage}}, s, nil)
+	if err != nil {
+		failSpan(span, err)
+		return nil, err
+	}
+	result, err := migrateContent(ctx, s.req, nil)
+	if err != nil {
+		failSpan(span, err)
+		return result, err
+	}
+	result.Consolidated = s.consolidated
+	// Links to merged docs are left dangling once the docs are rebuilt
+	result.Warnings = append(result.Warnings, mergedDocsLinks(result.Markdown, packageName(pkgPath), s.consolidated)...)
+	result.Warnings = append(result.Warnings, s.drift...)
+	s.resp = result
+	written, err := runStages(ctx, []stage{{stageWrite, writeStage}, {stageVerify, verifyStage}}, s, nil)
+	result.Timings = append(append(timings, result.Timings...), written...)
+	// Writing the layout and the translations adds warnings
+	result.Findings = classifyFindings(result)
+	if err != nil {
+		failSpan(span, err)
+		return result, err
+	}
+	if sandbox != nil {
+		if err := sandbox.apply(base); err != nil {
+			err = fmt.Errorf("failed to copy the migrated files from the sandbox: %w", err)
+			failSpan(span, err)
+			return result, err
+		}
+	}
+
+	// The migration is done, follow-up issues and tickets that cannot be
+	// opened are only logged. A dry run leaves no trace.
+	if dryRun {
+		return result, nil
+	}
+	if result.FollowUpIssue, err = openFollowUpIssue(ctx, pkgPath, packageName(pkgPath), result.Todos); err != nil {
+		log.Printf("Failed to open follow-up issue for %s: %v", pkgPath, err)
+	} else if result.FollowUpIssue != "" && verbose {
+		log.Printf("Follow-up issue for %s: %s", pkgPath, result.FollowUpIssue)
+	}
+	if result.FollowUpTicket, err = openFollowUpTicket(ctx, pkgPath, packageName(pkgPath), result.Todos); err != nil {
+		log.Printf("Failed to create follow-up ticket for %s: %v", pkgPath, err)
+	} else if result.FollowUpTicket != "" && verbose {
+		log.Printf("Follow-up ticket for %s: %s", pkgPath, result.FollowUpTicket)
+	}
+
+	return result, nil
+}
+
+// prepareStage reads the readme and everything the migration needs to know
+// about the package
+func prepareStage(ctx context.Context, s *pipelineState) error {
+	pkgPath := s.pkgPath
+	s.targetPath = targetReadmePath(pkgPath)
+	sourcePath := sourceReadmePath(pkgPath)
+
+	// Start from the rendered readme if the package has not been migrated
+	// yet. The target is only created once the migration succeeded, so an
+	// interrupted run leaves the package as it was.
+	s.readPath = s.targetPath
+	if outputTarget == targetDocsV3 {
+		// The readme template has placeholders docs-builder cannot render,
+		// the rendered readme has the actual fields and events.
+		s.targetPath = docsV3Path(pkgPath)
+		s.readPath = sourcePath
+		if _, err := pkgFS.Stat(sourcePath); os.IsNotExist(err) {
+			return fmt.Errorf("source readme not found at %s", sourcePath)
+		}
+	} else if _, err := pkgFS.Stat(s.targetPath); os.IsNotExist(err) {
+		// Check if source readme exists
+		if _, err := pkgFS.Stat(sourcePath); os.IsNotExist(err) {
+			return fmt.Errorf("source readme not found at %s", sourcePath)
+		}
+		if verbose {
+			log.Printf("%s not found, starting from %s", s.targetPath, sourcePath)
+		}
+		s.readPath = sourcePath
+	}
+
+	// Read the existing readme
+	readmeContent, enc, err := readMarkdown(s.readPath)
+	if err != nil {
+		return fmt.Errorf("failed to read readme: %w", err)
+	}
+	s.original = readmeContent
+	switch {
+	case enc.Charset != charsetUTF8:
+		logInfo("Read %s as %s, it is written as UTF-8", s.readPath, enc.Charset)
+	case enc.BOM:
+		logInfo("Read %s with a byte order mark, it is written without one", s.readPath)
+	}
+
+	// Find data streams
+	req, err := packageRequest(pkgPath)
+	if err != nil {
+		return err
+	}
+	s.dataStreams = req.DataStreams
+
+	// Hand-written field tables of legacy readmes drift from fields.yml
+	defined, err := definedFields(pkgPath, s.dataStreams)
+	if err != nil {
+		return err
+	}
+	s.drift = fieldDrift(s.original, defined)
+
+	// Data streams get the sample events of their pipeline tests, including
+	// those without one yet, so their readme sections get an event
+	if refreshSamples {
+		if s.samples, err = refreshSampleEvents(pkgPath, s.dataStreams); err != nil {
+			return fmt.Errorf("failed to refresh sample events: %w", err)
+		}
+		req.SampleEvents = refreshedSampleStreams(pkgPath, s.dataStreams, req.SampleEvents, s.samples)
+	}
+
+	// A split readme is migrated as a whole, with its data stream pages
+	readme := s.original
+	if s.readPath == targetReadmePath(pkgPath) {
+		if s.dataStreamDocs, err = readDataStreamDocs(pkgPath, s.dataStreams); err != nil {
+			return fmt.Errorf("failed to read data stream pages: %w", err)
+		}
+		readme = joinDataStreamDocs(readme, s.dataStreams, s.dataStreamDocs)
+	}
+
+	// Known issues on GitHub are added to the known issues file
+	if issuesQuery != "" {
+		issues, err := searchKnownIssues(ctx, packageName(pkgPath))
+		if err != nil {
+			return err
+		}
+		req.KnownIssues = joinKnownIssues(req.KnownIssues, issues)
+	}
+
+	// Only the selected data streams are regenerated, the sections of the
+	// others are kept from the migrated readme
+	if len(selectedDataStreams) > 0 {
+		if s.readPath != targetReadmePath(pkgPath) {
+			return fmt.Errorf("-data-streams needs a migrated readme at %s, migrate the whole package first", s.targetPath)
+		}
+		if err := selectDataStreams(&req, selectedDataStreams); err != nil {
+			return err
+		}
+	}
+
+	// With -since only the Reference sections of the data streams changed
+	// since the ref are regenerated, the rest of the migrated readme is kept
+	// byte for byte
+	if sinceRef != "" {
+		if s.readPath != targetReadmePath(pkgPath) {
+			return fmt.Errorf("-since needs a migrated readme at %s, migrate the whole package first", s.targetPath)
+		}
+		if err := selectChangedDataStreams(&req, pkgPath); err != nil {
+			return err
+		}
+	}
+
+	// Only the section of -section is regenerated, the rest of the migrated
+	// readme is kept byte for byte
+	if regenerateSection != "" {
+		if s.readPath != targetReadmePath(pkgPath) {
+			return fmt.Errorf("-section needs a migrated readme at %s, migrate the whole package first", s.targetPath)
+		}
+		req.Section = regenerateSection
+	}
+
+	// Docs spread over several files in docs/ are merged into the readme,
+	// or kept as pages next to it with -extra-docs separate
+	if s.readPath == sourcePath {
+		extraDocs, err := findExtraDocs(pkgPath)
+		if err != nil {
+			return fmt.Errorf("failed to list docs: %w", err)
+		}
+		switch {
+		case len(extraDocs) == 0:
+		case extraDocsMode == extraDocsSeparate:
+			if s.pages, err = readDocsPages(pkgPath, extraDocs); err != nil {
+				return err
+			}
+			for _, p := range s.pages {
+				if docsLayout == layoutSplit && slices.Contains(s.dataStreams, strings.TrimSuffix(p.Rel, ".md")) {
+					return fmt.Errorf("docs/%s would be replaced by the page of data stream %s in the split layout, use -extra-docs %s", p.Rel, strings.TrimSuffix(p.Rel, ".md"), extraDocsMerge)
+				}
+				req.DocsPages = append(req.DocsPages, p.Rel)
+			}
+			readme = rewriteDocsLinks(readme, ".", packageName(pkgPath), docsPageNames(s.pages))
+		default:
+			if readme, s.consolidated, err = consolidateDocs(pkgPath, readme, extraDocs); err != nil {
+				return err
+			}
+		}
+	}
+
+	req.Readme, req.encoding = readme, enc
+	// The patch applies to the readme as read, not as sent to the LLM
+	if readme != s.original {
+		req.original = s.original
+	}
+	s.req = req
+	return nil
+}
+
+// writeStage writes the migrated readme, its layout, translations and
+// variants to the package, and its refreshed and sanitized sample events
+func writeStage(ctx context.Context, s *pipelineState) error {
+	result := s.resp
+	err := pkgFS.MkdirAll(filepath.Dir(s.targetPath), 0755)
+	if err == nil && outputTarget != targetDocsV3 && (docsLayout == layoutSplit || len(s.dataStreamDocs) > 0) {
+		err = applyLayout(s.pkgPath, s.original, s.dataStreams, s.dataStreamDocs, result)
+	}
+	if err == nil {
+		err = pkgFS.WriteFile(s.targetPath, s.req.encoding.encode(result.Markdown), 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write updated readme: %w", err)
+	}
+	if verbose {
+		log.Printf("Updated readme written to %s", s.targetPath)
+	}
+	if len(s.consolidated) > 0 {
+		logInfo("Consolidated %s into %s, remove them once the docs are rebuilt", strings.Join(s.consolidated, ", "), s.targetPath)
+	}
+	if len(s.pages) > 0 {
+		if err := writeDocsPages(s.pkgPath, s.pages, result); err != nil {
+			return err
+		}
+		logInfo("Kept %s as pages next to %s", strings.Join(s.req.DocsPages, ", "), s.targetPath)
+	}
+
+	if err := writeTranslations(ctx, s.targetPath, result); err != nil {
+		return err
+	}
+	if outputTarget != targetDocsV3 {
+		if err := writeVariants(ctx, s.pkgPath, result); err != nil {
+			return err
+		}
+	}
+	if sampleSanitizer != nil || len(s.samples) > 0 {
+		if err := writeSampleEvents(s.pkgPath, s.dataStreams, s.samples, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packageName returns the name of a package from its manifest, or the name
+// of its directory
+func packageName(pkgPath string) string {
+	if m, err := readManifest(pkgPath); err == nil && m.Name != "" {
+		return m.Name
+	}
+	return filepath.Base(pkgPath)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so an interrupted write never leaves a truncated file. An
+// existing file keeps its permissions rather than getting perm.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := stageFile(path, data, perm)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return replaceFile(tmp, path)
+}
+
+// stageFile writes data to a temporary file next to path, with the
+// permissions of path if it exists and perm otherwise, and returns its name
+// for replaceFile
+func stageFile(path string, data []byte, perm os.FileMode) (string, error) {
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// generateUpdatedReadme asks the LLM to restructure the readme following the
+// instructions of userPrompt
+func generateUpdatedReadme(ctx context.Context, b llmclient.Backend, readmeContent, templateContent, userPrompt string) (string, llmclient.Usage, error) {
+	// The system instruction holds the readme and the template, the user
+	// turn the instructions
+	_, promptSpan := tracer.Start(ctx, "build-prompt")
+	system := systemInstruction(readmeContent, templateContent)
+	promptSpan.SetAttributes(attribute.Int("prompt.bytes", len(system)+len(userPrompt)))
+	promptSpan.End()
+
+	text, usage, err := generateReadmeText(ctx, b, system, userPrompt, readmeContent)
+	if err != nil {
+		return "", usage, err
+	}
+	return normalizeGenerated(text, readmeContent, templateContent), usage, nil
+}
+
+// systemInstruction returns the system prompt giving the LLM the original
+// readme and the template
+func systemInstruction(readmeContent, templateContent string) string {
+	return fmt.Sprintf(prompts.System, readmeContent, templateContent)
+}
+
+// generateText sends a prompt to the model of b within -llm-timeout and
+// returns the text of the response
+func generateText(ctx context.Context, b llmclient.Backend, prompt string) (string, llmclient.Usage, error) {
+	return generateContent(ctx, b, "", prompt, nil)
+}
+
+// generateContent is generateText with system, if not empty, as the system
+// instruction of the model and prompt as the user turn, and with the model
+// set up by configure, such as for structured output, if it is not nil
+func generateContent(ctx context.Context, b llmclient.Backend, system, prompt string, configure func(*genai.GenerativeModel)) (string, llmclient.Usage, error) {
+	system, prompt = splitSystem(b, system, prompt)
+
+	ctx, cancel := withTimeout(ctx, llmTimeout, "llm-timeout")
+	defer cancel()
+
+	client, err := newLLMClient(ctx, b)
+	if err != nil {
+		return "", llmclient.Usage{}, err
+	}
+	defer client.Close()
+
+	// List available models for debugging
+	if verbosity >= verbosityDebug && b.Provider != llmclient.ProviderFake {
+		log.Printf("Available models:")
+		models, err := client.Models(ctx)
+		if err != nil {
+			log.Printf("Error listing models: %v", err)
+		}
+		for _, m := range models {
+			log.Printf("- %s", m.Name)
+		}
+	}
+
+	if verbose {
+		log.Printf("Using model: %s", b.Model)
+	}
+
+	// Send the request
+	ctx, span := tracer.Start(ctx, "llm-call", trace.WithAttributes(attribute.String("llm.model", b.Model)))
+	defer span.End()
+	text, usage, err := client.Generate(ctx, system, prompt, configure)
+	recordUsage(span, usage)
+	if errors.Is(err, llmclient.ErrMaxTokens) {
+		return "", usage, degenerateError{err.Error()}
+	}
+	if err != nil {
+		failSpan(span, err)
+		return "", usage, err
+	}
+	return text, usage, nil
+}
+
+// recordUsage records the token usage of a call in its span
+func recordUsage(span trace.Span, usage llmclient.Usage) {
+	span.SetAttributes(
+		attribute.Int("llm.prompt_tokens", usage.PromptTokens),
+		attribute.Int("llm.response_tokens", usage.ResponseTokens),
+	)
+}
+
+func generatePatch(filePath, original, updated string) (string, error) {
+	fromLines := patchLines(original)
+	toLines := patchLines(updated)
+
+	diff := difflib.UnifiedDiff{
+		A:        fromLines,
+		B:        toLines,
+		FromFile: "a/" + filepath.Base(filePath),
+		ToFile:   "b/" + filepath.Base(filePath),
+		Context:  3,
+	}
+
+	return difflib.GetUnifiedDiffString(diff)
+}
+
+// patchLines splits content into lines keeping their line endings, as the
+// unified diff expects. A missing final newline is added.
+func patchLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if last := len(lines) - 1; lines[last] == "" {
+		lines = lines[:last]
+	} else {
+		lines[last] += "\n"
+	}
+	return lines
+}
@@ -0,0 +1,147 @@
+package doctools
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// Output targets, selected with -target or the target field of a request
+const (
+	// targetReadme is the elastic-package readme template, rendered by
+	// elastic-package build
+	targetReadme = "readme"
+	// targetDocsV3 is a page for the Elastic docs-builder (V3) with
+	// frontmatter, applies_to metadata and directive blocks
+	targetDocsV3 = "docs-v3"
+)
+
+// outputTarget is the target selected with -target
+var outputTarget string
+
+// docsV3Prompt replaces userPromptTemplate for the docs-v3 target
+const docsV3Prompt = `I need to convert this README.md file into a page for the Elastic docs-builder (docs V3).
+
+Follow these exact guidelines:
+1. Always utilize the original content of the README.md file where possible, do not remove content
+2. Organize the page with the sections of the new template structure provided, in the same order
+3. Start the page with YAML frontmatter containing navigation_title with the integration name and applies_to with "stack: ga" and "serverless: ga", for example:
+---
+navigation_title: Integration name
+applies_to:
+  stack: ga
+  serverless: ga
+---
+4. Follow the frontmatter with a single level 1 heading with the integration name, without any reference to Elastic
+5. Do not use mustache placeholders such as {{fields "..."}} or {{event "..."}}, docs-builder does not render them
+6. Keep the exported fields tables and the sample events of every data stream, each inside a dropdown directive:
+:::{dropdown} Exported fields
+...
+:::
+7. Write notes, warnings, tips and important remarks as admonition directives such as :::{note} ... ::: instead of blockquotes or bold text
+8. If there is no content for a section, add an HTML comment with some guidance to the user on what to add
+9. Keep code blocks and their language unchanged
+
+Return ONLY the page content, without any explanation or commentary.`
+
+// directivePattern matches the opening and closing fences of docs-builder
+// directives, e.g. :::{note} and :::
+var directivePattern = regexp.MustCompile(`^(:{3,})(\{[\w-]+\})?`)
+
+// mustachePattern matches the placeholders of the readme template
+var mustachePattern = regexp.MustCompile(`\{\{\s*(fields|event|url)\b`)
+
+// validateTarget rejects unknown output targets, empty selects the readme
+func validateTarget(target string) error {
+	switch target {
+	case "", targetReadme, targetDocsV3:
+		return nil
+	}
+	return fmt.Errorf("unknown target %q, use %s or %s", target, targetReadme, targetDocsV3)
+}
+
+// docsV3Path returns the path of the docs-builder page in the package
+func docsV3Path(pkgPath string) string {
+	return filepath.Join(pkgPath, "_dev", "build", "docs-v3", "index.md")
+}
+
+// validateDocsV3 checks a docs-builder page against the template and returns
+// findings like validateReadme
+func validateDocsV3(content, template string) []string {
+	var findings []string
+
+	body, frontmatter, ok := splitFrontmatter(content)
+	if !ok {
+		findings = append(findings, "missing frontmatter")
+	} else {
+		var meta struct {
+			AppliesTo map[string]any `yaml:"applies_to"`
+		}
+		if err := yaml.Unmarshal([]byte(frontmatter), &meta); err != nil {
+			findings = append(findings, fmt.Sprintf("invalid frontmatter: %v", err))
+		} else if len(meta.AppliesTo) == 0 {
+			findings = append(findings, "missing applies_to in frontmatter")
+		}
+	}
+
+	findings = append(findings, missingSections(body, template)...)
+	if mustachePattern.MatchString(markdown.CodeFencePattern.ReplaceAllString(body, "")) {
+		findings = append(findings, "mustache placeholder left in page, docs-builder does not render them")
+	}
+	findings = append(findings, checkDirectives(content)...)
+
+	return findings
+}
+
+// splitFrontmatter separates the YAML frontmatter from the body of a page
+func splitFrontmatter(content string) (body, frontmatter string, ok bool) {
+	rest, found := strings.CutPrefix(content, "---\n")
+	if !found {
+		return content, "", false
+	}
+	frontmatter, body, found = strings.Cut(rest, "\n---\n")
+	if !found {
+		return content, "", false
+	}
+	return body, frontmatter, true
+}
+
+// checkDirectives reports directive blocks that are not closed or closed
+// without being opened. Directives nest by using more colons for the outer
+// block.
+func checkDirectives(content string) []string {
+	var findings []string
+	type open struct {
+		fence string
+		line  int
+	}
+	var stack []open
+	inCode := false
+	for i, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "```") {
+			inCode = !inCode
+		}
+		m := directivePattern.FindStringSubmatch(line)
+		if inCode || m == nil {
+			continue
+		}
+		if m[2] != "" {
+			stack = append(stack, open{fence: m[1], line: i + 1})
+			continue
+		}
+		if len(stack) == 0 || stack[len(stack)-1].fence != m[1] {
+			findings = append(findings, fmt.Sprintf("unexpected directive end on line %d", i+1))
+			continue
+		}
+		stack = stack[:len(stack)-1]
+	}
+	for _, o := range stack {
+		findings = append(findings, fmt.Sprintf("directive opened on line %d is not closed", o.line))
+	}
+	return findings
+}
@@ -0,0 +1,365 @@
+package doctools
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// Output formats of drift
+const (
+	driftFormatMarkdown = "markdown"
+	driftFormatJSON     = "json"
+	driftFormatCSV      = "csv"
+)
+
+// rawGitHubURLPattern splits a raw.githubusercontent.com address, such as
+// templateURL, into its repository, ref and path
+var rawGitHubURLPattern = regexp.MustCompile(`^https://raw\.githubusercontent\.com/([^/]+/[^/]+)/([^/]+)/(.+)$`)
+
+// templateVersion is a version of the readme template
+type templateVersion struct {
+	// Name is the file name of a -templates version, or the short commit
+	// and date of a version of the elastic-package history.
+	Name     string
+	Template string
+}
+
+// driftReport is the template drift of the readme of a package
+type driftReport struct {
+	Package string `json:"package"`
+	Path    string `json:"path"`
+	Owner   string `json:"owner,omitempty"`
+	// Version is the newest template version the readme has all the
+	// sections of, empty when it has none or the package was not migrated.
+	Version string `json:"version"`
+	// Current reports the readme conforms to the current template.
+	Current  bool `json:"current"`
+	Migrated bool `json:"migrated"`
+	// Missing are the sections of the current template the readme lacks,
+	// Outdated its sections of former templates the current one dropped or
+	// renamed, and Empty its template sections without content.
+	Missing  []string `json:"missing,omitempty"`
+	Outdated []string `json:"outdated,omitempty"`
+	Empty    []string `json:"empty,omitempty"`
+	// Priority weighs the drift by the sections users rely on most, the
+	// backlog is sorted by it.
+	Priority int    `json:"priority"`
+	Error    string `json:"error,omitempty"`
+}
+
+// RunDrift runs the drift command with the arguments args
+func RunDrift(args []string) {
+	fs := flag.NewFlagSet("drift", flag.ExitOnError)
+	dir := fs.String("packages", "", "Directory of the packages, or the integrations repository holding them in packages/ (required)")
+	templatesDir := fs.String("templates", "", "Directory of the template versions to compare against, sorted by file name with the current template last, instead of the history of the template in elastic-package")
+	versions := fs.Int("template-versions", 5, "Number of versions of the template history to compare against, the current one included")
+	format := fs.String("format", driftFormatMarkdown, "Output format: markdown, json, or csv for spreadsheets")
+	output := fs.String("o", "", "File the backlog is written to (default standard output)")
+	registerVerbosityFlags(fs)
+	registerNetworkFlags(fs)
+	registerTimeoutFlags(fs)
+	registerConfigFlags(fs)
+	_ = fs.Parse(args)
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *dir == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *format != driftFormatMarkdown && *format != driftFormatJSON && *format != driftFormatCSV {
+		log.Fatalf("Error: unknown -format %q, use markdown, json or csv", *format)
+	}
+	if *versions < 1 {
+		log.Fatalf("Error: -template-versions must be at least 1")
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateTimeouts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	ctx := context.Background()
+	var templates []templateVersion
+	var err error
+	if *templatesDir != "" {
+		templates, err = readTemplateVersions(*templatesDir)
+	} else {
+		templates, err = templateHistory(ctx, envGitHubClient(), *versions)
+	}
+	if err != nil {
+		log.Fatalf("Error reading the template versions: %v", err)
+	}
+
+	packagesDir := *dir
+	if _, err := pkgFS.Stat(filepath.Join(packagesDir, "packages")); err == nil {
+		packagesDir = filepath.Join(packagesDir, "packages")
+	}
+	pkgs, err := findPackages(packagesDir)
+	if err != nil {
+		log.Fatalf("Error listing packages: %v", err)
+	}
+	if len(pkgs) == 0 {
+		log.Fatalf("Error: no packages in %s", packagesDir)
+	}
+
+	reports := make([]driftReport, 0, len(pkgs))
+	for _, p := range pkgs {
+		reports = append(reports, packageDrift(p, templates))
+	}
+	slices.SortStableFunc(reports, func(a, b driftReport) int {
+		if a.Priority != b.Priority {
+			return b.Priority - a.Priority
+		}
+		return strings.Compare(a.Package, b.Package)
+	})
+
+	var content string
+	switch *format {
+	case driftFormatJSON:
+		data, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		content = string(data) + "\n"
+	case driftFormatCSV:
+		content, err = driftCSV(reports)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	default:
+		content = driftMarkdown(reports, templates)
+	}
+	if *output == "" {
+		fmt.Print(content)
+		return
+	}
+	if err := writeFileAtomic(*output, []byte(content), 0o644); err != nil {
+		log.Fatalf("Error writing %s: %v", *output, err)
+	}
+	logInfo("Wrote the template drift of %d packages to %s", len(reports), *output)
+}
+
+// readTemplateVersions reads the template versions of -templates, the files
+// of dir sorted by name, oldest first
+func readTemplateVersions(dir string) ([]templateVersion, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var templates []templateVersion
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		templates = append(templates, templateVersion{Name: name, Template: string(data)})
+	}
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("no templates in %s", dir)
+	}
+	return templates, nil
+}
+
+// templateHistory returns up to n versions of the readme template from the
+// history of its file in elastic-package, oldest first, the last being the
+// version of templateURL the packages are migrated to
+func templateHistory(ctx context.Context, c *githubClient, n int) ([]templateVersion, error) {
+	m := rawGitHubURLPattern.FindStringSubmatch(templateURL)
+	if m == nil {
+		return nil, fmt.Errorf("the template %s is not on GitHub, compare against a -templates directory", templateURL)
+	}
+	repo, ref, path := m[1], m[2], m[3]
+	var commits []struct {
+		SHA    string `json:"sha"`
+		Commit struct {
+			Committer struct {
+				Date string `json:"date"`
+			} `json:"committer"`
+		} `json:"commit"`
+	}
+	query := fmt.Sprintf("/repos/%s/commits?path=%s&sha=%s&per_page=%d", repo, url.QueryEscape(path), url.QueryEscape(ref), n)
+	if err := c.do(ctx, http.MethodGet, query, nil, &commits); err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, errors.New("the template has no history")
+	}
+
+	templates := make([]templateVersion, len(commits))
+	for i, commit := range commits {
+		var template string
+		var err error
+		if i == 0 {
+			// The current version is the one every other command uses
+			template, err = fetchTemplate(ctx)
+		} else {
+			template, err = c.fileContent(ctx, repo, path, commit.SHA)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the template of %s: %w", commit.SHA, err)
+		}
+		name := commit.SHA[:min(len(commit.SHA), 7)]
+		if date, _, ok := strings.Cut(commit.Commit.Committer.Date, "T"); ok {
+			name += " (" + date + ")"
+		}
+		templates[len(commits)-1-i] = templateVersion{Name: name, Template: template}
+	}
+	return templates, nil
+}
+
+// packageDrift compares the readme of a package against the template
+// versions, oldest first
+func packageDrift(pkgPath string, templates []templateVersion) driftReport {
+	r := driftReport{Package: packageName(pkgPath), Path: pkgPath}
+	if m, err := readManifest(pkgPath); err == nil {
+		r.Owner = m.Owner.Github
+	}
+	current := templates[len(templates)-1].Template
+	priorities := templatePriorities(current)
+
+	content, _, err := readMarkdown(targetReadmePath(pkgPath))
+	if os.IsNotExist(err) {
+		for _, h := range templateHeadings(current) {
+			r.Missing = append(r.Missing, h.Text)
+			r.Priority += priorities[strings.ToLower(h.Text)]
+		}
+		return r
+	}
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Migrated = true
+
+	for i := len(templates) - 1; i >= 0; i-- {
+		if len(missingSections(content, templates[i].Template)) == 0 {
+			r.Version = templates[i].Name
+			r.Current = i == len(templates)-1
+			break
+		}
+	}
+
+	present := make(map[string]bool)
+	for _, h := range markdown.ParseHeadings(content) {
+		present[strings.ToLower(h.Text)] = true
+	}
+	required := make(map[string]bool)
+	for _, h := range templateHeadings(current) {
+		name := strings.ToLower(h.Text)
+		required[name] = true
+		if !present[name] {
+			r.Missing = append(r.Missing, h.Text)
+			r.Priority += priorities[name]
+		}
+	}
+	for _, t := range templates[:len(templates)-1] {
+		for _, h := range templateHeadings(t.Template) {
+			name := strings.ToLower(h.Text)
+			if present[name] && !required[name] && !slices.ContainsFunc(r.Outdated, func(s string) bool { return strings.EqualFold(s, h.Text) }) {
+				r.Outdated = append(r.Outdated, h.Text)
+				r.Priority++
+			}
+		}
+	}
+	for _, g := range coverageGaps(content, current) {
+		r.Empty = append(r.Empty, g.Section)
+		r.Priority += g.Priority
+	}
+	return r
+}
+
+// templatePriorities returns the weight of every section of a template, by
+// lower case heading, weighed as the docs gaps
+func templatePriorities(template string) map[string]int {
+	priorities := make(map[string]int)
+	levels := make([]int, 7)
+	for _, h := range markdown.ParseHeadings(template) {
+		name := strings.ToLower(h.Text)
+		if p, ok := gapPriorities[name]; ok {
+			levels[h.Level] = p
+		} else if h.Level > 1 {
+			levels[h.Level] = levels[h.Level-1]
+		} else {
+			levels[h.Level] = 1
+		}
+		priorities[name] = max(levels[h.Level], 1)
+	}
+	return priorities
+}
+
+// driftMarkdown returns the migration backlog of the packages as a markdown
+// table, the highest priority first
+func driftMarkdown(reports []driftReport, templates []templateVersion) string {
+	var b strings.Builder
+	b.WriteString("# Template drift\n\n")
+	current := 0
+	for _, r := range reports {
+		if r.Current && len(r.Outdated) == 0 && len(r.Empty) == 0 {
+			current++
+		}
+	}
+	fmt.Fprintf(&b, "%d of %d packages conform to the current template, %s, compared against %d template versions. The packages left to migrate are listed the highest priority first.\n\n",
+		current, len(reports), templates[len(templates)-1].Name, len(templates))
+	b.WriteString("| Priority | Package | Owner | Template version | Missing sections | Outdated sections | Empty sections |\n")
+	b.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, r := range reports {
+		if r.Priority == 0 && r.Error == "" {
+			continue
+		}
+		version := r.Version
+		switch {
+		case r.Error != "":
+			version = "error: " + r.Error
+		case !r.Migrated:
+			version = "not migrated"
+		case version == "":
+			version = "none"
+		}
+		fmt.Fprintf(&b, "| %d | %s | %s | %s | %s | %s | %s |\n", r.Priority, tableCell(r.Package), tableCell(r.Owner), tableCell(version),
+			tableCell(strings.Join(r.Missing, ", ")), tableCell(strings.Join(r.Outdated, ", ")), tableCell(strings.Join(r.Empty, ", ")))
+	}
+	return b.String()
+}
+
+// driftCSV returns the template drift of the packages as CSV, a row per
+// package, the highest priority first
+func driftCSV(reports []driftReport) (string, error) {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	rows := [][]string{{"priority", "package", "owner", "migrated", "template_version", "current", "missing", "outdated", "empty", "error"}}
+	for _, r := range reports {
+		rows = append(rows, []string{
+			strconv.Itoa(r.Priority), r.Package, r.Owner, strconv.FormatBool(r.Migrated), r.Version, strconv.FormatBool(r.Current),
+			strings.Join(r.Missing, "; "), strings.Join(r.Outdated, "; "), strings.Join(r.Empty, "; "), r.Error,
+		})
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,143 @@
+package doctools
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"gopkg.in/yaml.v3"
+)
+
+// maxECSFields bounds the ECS fields described in a prompt
+const maxECSFields = 50
+
+// ecsPrompt is added to the readme prompt with the ECS fields the readme
+// mentions
+const ecsPrompt = `
+
+The README.md mentions these Elastic Common Schema (ECS) fields. Where the document explains what a field means, use these official descriptions instead of paraphrasing them:
+%s`
+
+// ecsSchema is the ECS schema selected with -ecs-schema, the path or URL of
+// an ecs_flat.yml
+var ecsSchema string
+
+var (
+	ecsMu     sync.Mutex
+	ecsFields map[string]ecsField
+
+	// fieldNamePattern matches dotted field names such as source.ip
+	fieldNamePattern = regexp.MustCompile(`\b[a-z][a-z0-9_]*(?:\.[a-z0-9_@]+)+\b`)
+)
+
+// ecsField is a field of the ECS schema
+type ecsField struct {
+	Name  string `yaml:"flat_name" json:"name"`
+	Type  string `yaml:"type" json:"type,omitempty"`
+	Short string `yaml:"short" json:"short"`
+}
+
+// registerECSFlags adds the ECS context flag to fs
+func registerECSFlags(fs *flag.FlagSet) {
+	fs.StringVar(&ecsSchema, "ecs-schema", "", "Path or URL of an ECS ecs_flat.yml, e.g. https://raw.githubusercontent.com/elastic/ecs/main/generated/ecs/ecs_flat.yml, to give the LLM the descriptions of the ECS fields a readme mentions")
+}
+
+// loadECSSchema reads the ECS schema of -ecs-schema, it is cached after the
+// first successful read
+func loadECSSchema(ctx context.Context) (map[string]ecsField, error) {
+	ecsMu.Lock()
+	defer ecsMu.Unlock()
+	if ecsFields != nil {
+		return ecsFields, nil
+	}
+
+	var data []byte
+	var err error
+	if strings.HasPrefix(ecsSchema, "http://") || strings.HasPrefix(ecsSchema, "https://") {
+		data, err = downloadECSSchema(ctx)
+	} else {
+		data, err = os.ReadFile(ecsSchema)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ECS schema: %w", err)
+	}
+
+	fields := make(map[string]ecsField)
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse ECS schema: %w", err)
+	}
+	for name, f := range fields {
+		f.Name = name
+		fields[name] = f
+	}
+	ecsFields = fields
+	return ecsFields, nil
+}
+
+// downloadECSSchema downloads the ECS schema of -ecs-schema
+func downloadECSSchema(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ecsSchema, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: otelhttp.NewTransport(outboundTransport)}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// mentionedECSFields returns the ECS fields mentioned in content, sorted by
+// name
+func mentionedECSFields(content string, schema map[string]ecsField) []ecsField {
+	seen := make(map[string]bool)
+	var fields []ecsField
+	for _, name := range fieldNamePattern.FindAllString(content, -1) {
+		f, ok := schema[name]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		fields = append(fields, f)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	if len(fields) > maxECSFields {
+		fields = fields[:maxECSFields]
+	}
+	return fields
+}
+
+// relevantECSFields returns the ECS fields mentioned in a readme, none
+// without -ecs-schema
+func relevantECSFields(ctx context.Context, readme string) ([]ecsField, error) {
+	if ecsSchema == "" {
+		return nil, nil
+	}
+	schema, err := loadECSSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mentionedECSFields(readme, schema), nil
+}
+
+// formatECSFields formats ECS fields for the prompt
+func formatECSFields(fields []ecsField) string {
+	var b strings.Builder
+	for _, f := range fields {
+		fmt.Fprintf(&b, "- %s (%s): %s\n", f.Name, f.Type, strings.Join(strings.Fields(f.Short), " "))
+	}
+	return b.String()
+}
@@ -0,0 +1,216 @@
+package doctools
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ecsMappingsHeading is the heading of the section of the ECS mappings under
+// Reference
+const ecsMappingsHeading = "Field mappings to ECS"
+
+// ecsTypeFamilies map the field types to their family, a field defined with
+// another type of the family of its ECS type, such as a wildcard for a
+// keyword, still maps to it
+var ecsTypeFamilies = map[string]string{
+	"keyword":          "keyword",
+	"constant_keyword": "keyword",
+	"wildcard":         "keyword",
+	"text":             "text",
+	"match_only_text":  "text",
+}
+
+// mappedField is a field of a package, with the data streams defining it
+type mappedField struct {
+	fieldDoc
+	// ECSType and ECSDescription are those of the ECS schema, empty for the
+	// fields not in ECS.
+	ECSType        string
+	ECSDescription string
+	DataStreams    []string
+}
+
+// RunECSMappings runs the ecs-mappings command with the arguments args
+func RunECSMappings(args []string) {
+	fs := flag.NewFlagSet("ecs-mappings", flag.ExitOnError)
+	pkgPath := fs.String("path", "", "Path to the package whose fields are mapped (required)")
+	inject := fs.Bool("inject", false, "Add the section under the Reference section of the readme of the package, or replace it")
+	output := fs.String("o", "", "File the section is written to (default standard output)")
+	strict := fs.Bool("strict", false, "Exit with 1 if fields are imported from ECS without being in it, or conflict with their ECS type")
+	registerVerbosityFlags(fs)
+	registerNetworkFlags(fs)
+	registerECSFlags(fs)
+	registerConfigFlags(fs)
+	_ = fs.Parse(args)
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *pkgPath == "" || ecsSchema == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *inject && *output != "" {
+		log.Fatalf("Error: -inject and -o cannot be used together")
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	ecs, err := loadECSSchema(context.Background())
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	docs, err := packageFieldDocs(*pkgPath, ecs)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	mapped, custom := mapECSFields(docs, ecs)
+	issues := ecsMappingIssues(mapped, custom)
+	for _, issue := range issues {
+		log.Printf("%s: %s", packageName(*pkgPath), issue)
+	}
+
+	section := func(level int) string { return ecsMappingsSection(mapped, custom, len(docs) > 1, level) }
+	switch {
+	case *inject:
+		readme := targetReadmePath(*pkgPath)
+		content, enc, err := readMarkdown(readme)
+		if err != nil {
+			log.Fatalf("Error reading the readme: %v", err)
+		}
+		if err := pkgFS.WriteFile(readme, enc.encode(applyReferenceSection(content, ecsMappingsHeading, section)), 0o644); err != nil {
+			log.Fatalf("Error writing %s: %v", readme, err)
+		}
+		logInfo("Added the ECS mappings of %d fields to %s", len(mapped), readme)
+	case *output != "":
+		if err := writeFileAtomic(*output, []byte(section(3)), 0o644); err != nil {
+			log.Fatalf("Error writing %s: %v", *output, err)
+		}
+		logInfo("Wrote the ECS mappings of %d fields to %s", len(mapped), *output)
+	default:
+		fmt.Print(section(3))
+	}
+	if *strict && len(issues) > 0 {
+		os.Exit(1)
+	}
+}
+
+// mapECSFields merges the fields of the data streams of a package, sorted by
+// name, into those in the ECS schema and the others. A field defined by
+// several data streams keeps its first definition.
+func mapECSFields(docs map[string][]fieldDoc, ecs map[string]ecsField) (mapped, custom []mappedField) {
+	dataStreams := make([]string, 0, len(docs))
+	for ds := range docs {
+		dataStreams = append(dataStreams, ds)
+	}
+	slices.Sort(dataStreams)
+
+	byName := make(map[string]*mappedField)
+	var names []string
+	for _, ds := range dataStreams {
+		for _, d := range docs[ds] {
+			if f, ok := byName[d.Name]; ok {
+				f.DataStreams = append(f.DataStreams, ds)
+				continue
+			}
+			f := &mappedField{fieldDoc: d, DataStreams: []string{ds}}
+			if e, ok := ecs[d.Name]; ok {
+				f.ECSType, f.ECSDescription = e.Type, e.Short
+			}
+			byName[d.Name] = f
+			names = append(names, d.Name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if f := byName[name]; f.ECSType != "" {
+			mapped = append(mapped, *f)
+		} else {
+			custom = append(custom, *f)
+		}
+	}
+	return mapped, custom
+}
+
+// ecsMappingIssues returns the fields imported from ECS that the schema does
+// not have, and the ECS fields defined with a type of another family than
+// their ECS type
+func ecsMappingIssues(mapped, custom []mappedField) []string {
+	var issues []string
+	for _, f := range custom {
+		if f.Imported {
+			issues = append(issues, fmt.Sprintf("field %s of %s is imported with external: ecs but is not in the ECS schema", f.Name, strings.Join(f.DataStreams, ", ")))
+		}
+	}
+	for _, f := range mapped {
+		if !f.Imported && f.Type != "" && ecsTypeFamily(f.Type) != ecsTypeFamily(f.ECSType) {
+			issues = append(issues, fmt.Sprintf("field %s of %s is defined as %s, its ECS type is %s", f.Name, strings.Join(f.DataStreams, ", "), f.Type, f.ECSType))
+		}
+	}
+	return issues
+}
+
+// ecsTypeFamily returns the family of a field type, the type itself for the
+// types without one
+func ecsTypeFamily(t string) string {
+	if family, ok := ecsTypeFamilies[t]; ok {
+		return family
+	}
+	return t
+}
+
+// ecsMappingsSection returns the Field mappings to ECS section with a heading
+// of the given level: a table of the fields of the package in ECS, linked to
+// the ECS reference, followed by a table of the fields not in ECS. The data
+// streams column is only added for packages with several data streams.
+func ecsMappingsSection(mapped, custom []mappedField, dataStreams bool, level int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n\n", strings.Repeat("#", level), ecsMappingsHeading)
+	total := len(mapped) + len(custom)
+	if total == 0 {
+		b.WriteString("This integration does not define any fields.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%d of the %d fields of this integration map to the [Elastic Common Schema (ECS)](%sindex.html).\n", len(mapped), total, ecsReferenceURL)
+	if len(mapped) > 0 {
+		b.WriteString("\n")
+		mappedFieldsTable(&b, mapped, dataStreams, func(f mappedField) (string, string, string) {
+			return fmt.Sprintf("[%s](%s)", tableCell(f.Name), ecsFieldURL(f.Name)), f.ECSType, f.ECSDescription
+		})
+	}
+	if len(custom) > 0 {
+		fmt.Fprintf(&b, "\n%s Fields not in ECS\n\n", strings.Repeat("#", min(level+1, 6)))
+		fmt.Fprintf(&b, "These %d fields are specific to this integration.\n\n", len(custom))
+		mappedFieldsTable(&b, custom, dataStreams, func(f mappedField) (string, string, string) {
+			return tableCell(f.Name), f.Type, f.Description
+		})
+	}
+	return b.String()
+}
+
+// mappedFieldsTable writes a table of fields to b, with the name, type and
+// description cell returns for each
+func mappedFieldsTable(b *strings.Builder, fields []mappedField, dataStreams bool, cells func(mappedField) (name, fieldType, description string)) {
+	if dataStreams {
+		b.WriteString("| Field | Type | Data streams | Description |\n|---|---|---|---|\n")
+	} else {
+		b.WriteString("| Field | Type | Description |\n|---|---|---|\n")
+	}
+	for _, f := range fields {
+		name, fieldType, description := cells(f)
+		fmt.Fprintf(b, "| %s | %s |", name, tableCell(fieldType))
+		if dataStreams {
+			fmt.Fprintf(b, " %s |", tableCell(strings.Join(f.DataStreams, ", ")))
+		}
+		fmt.Fprintf(b, " %s |\n", tableCell(description))
+	}
+}
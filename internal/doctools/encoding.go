@@ -0,0 +1,127 @@
+package doctools
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Character sets markdown files are read in
+const (
+	charsetUTF8    = "UTF-8"
+	charsetUTF16LE = "UTF-16LE"
+	charsetUTF16BE = "UTF-16BE"
+	// charsetWindows1252 is assumed for files that are not valid UTF-8,
+	// it covers Latin-1 as well
+	charsetWindows1252 = "Windows-1252"
+)
+
+// textEncoding is how a markdown file of a package was encoded. Files are
+// always written as UTF-8 without a byte order mark, with their line endings
+// kept.
+type textEncoding struct {
+	Charset string
+	// BOM reports the file started with a byte order mark.
+	BOM bool
+	// CRLF reports the file had Windows line endings.
+	CRLF bool
+}
+
+// encode returns s in NFC as UTF-8, with the line endings of e
+func (e textEncoding) encode(s string) []byte {
+	s = norm.NFC.String(s)
+	if e.CRLF {
+		s = strings.ReplaceAll(s, "\n", "\r\n")
+	}
+	return []byte(s)
+}
+
+// decodeText returns the content of a markdown file as UTF-8 in NFC with
+// Unix line endings, and how it was encoded. UTF-16 is recognized by its byte
+// order mark.
+func decodeText(data []byte) (string, textEncoding) {
+	enc := textEncoding{Charset: charsetUTF8}
+	var decoded []byte
+	switch {
+	case bytes.HasPrefix(data, []byte{0xef, 0xbb, 0xbf}):
+		enc.BOM = true
+		decoded = data[3:]
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		enc.Charset, enc.BOM = charsetUTF16LE, true
+		decoded, _ = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder().Bytes(data[2:])
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		enc.Charset, enc.BOM = charsetUTF16BE, true
+		decoded, _ = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewDecoder().Bytes(data[2:])
+	case !utf8.Valid(data):
+		enc.Charset = charsetWindows1252
+		decoded, _ = charmap.Windows1252.NewDecoder().Bytes(data)
+	default:
+		decoded = data
+	}
+	enc.CRLF = bytes.Contains(decoded, []byte("\r\n"))
+	return normalizeText(string(decoded)), enc
+}
+
+// normalizeText returns s in NFC with Unix line endings and without a byte
+// order mark
+func normalizeText(s string) string {
+	s = strings.TrimPrefix(s, "\ufeff")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return norm.NFC.String(s)
+}
+
+// readMarkdown reads a markdown file of a package with decodeText
+func readMarkdown(path string) (string, textEncoding, error) {
+	data, err := pkgFS.ReadFile(path)
+	if err != nil {
+		return "", textEncoding{}, err
+	}
+	content, enc := decodeText(data)
+	return content, enc, nil
+}
+
+// typographicReplacements are the characters models put in place of plain
+// quotes and spaces, with their plain replacement
+var typographicReplacements = []struct {
+	char        string
+	replacement string
+}{
+	{"\u2018", "'"}, // left single quotation mark
+	{"\u2019", "'"}, // right single quotation mark
+	{"\u201c", `"`}, // left double quotation mark
+	{"\u201d", `"`}, // right double quotation mark
+	{"\u00a0", " "}, // no-break space
+	{"\u202f", " "}, // narrow no-break space
+	{"\u2009", " "}, // thin space
+	{"\u200b", ""},  // zero width space
+	{"\ufeff", ""},  // zero width no-break space
+}
+
+// normalizeGenerated returns the text generated from sources, such as the
+// readme and the template, in NFC and with the typographic quotes and spaces
+// the sources do not use replaced by plain ones. They break code blocks and
+// placeholders and make the patch noisy.
+func normalizeGenerated(generated string, sources ...string) string {
+	generated = normalizeText(generated)
+	for _, r := range typographicReplacements {
+		if !strings.Contains(generated, r.char) || containsAny(sources, r.char) {
+			continue
+		}
+		generated = strings.ReplaceAll(generated, r.char, r.replacement)
+	}
+	return generated
+}
+
+// containsAny reports whether any of texts contains s
+func containsAny(texts []string, s string) bool {
+	for _, t := range texts {
+		if strings.Contains(t, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,399 @@
+package doctools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+// judgePrompt asks the judge model to score a migrated readme
+const judgePrompt = `You are reviewing the migration of the readme of an Elastic integration to a new documentation template.
+Score the migrated readme from 1 (poor) to 10 (excellent) on:
+- completeness: how much of the content of the original readme it keeps
+- structure: how closely it follows the sections of the template
+- clarity: how clear and well written it is
+
+Answer with JSON only, e.g. {"completeness": 7, "structure": 8, "clarity": 6}.
+
+# Template
+%s
+
+# Original readme
+%s
+
+# Migrated readme
+%s
+`
+
+// evalMetric is a metric prompt presets are compared on
+type evalMetric struct {
+	Name string
+	// HigherIsBetter tells which way the metric improves.
+	HigherIsBetter bool
+	// Judge marks the scores of the judge model.
+	Judge bool
+}
+
+// evalMetrics are the metrics of the eval subcommand, in the order they are
+// reported
+var evalMetrics = []evalMetric{
+	{Name: "findings"},
+	{Name: "gaps"},
+	{Name: "grade"},
+	{Name: "retention", HigherIsBetter: true},
+	{Name: "cost_usd"},
+	{Name: "completeness", HigherIsBetter: true, Judge: true},
+	{Name: "structure", HigherIsBetter: true, Judge: true},
+	{Name: "clarity", HigherIsBetter: true, Judge: true},
+}
+
+// retentionWordPattern matches the words compared by contentRetention
+var retentionWordPattern = regexp.MustCompile(`[\pL\pN][\pL\pN_.-]{3,}`)
+
+// evalResult is the result of a prompt preset on a package
+type evalResult struct {
+	Preset  string             `json:"preset"`
+	Package string             `json:"package"`
+	Error   string             `json:"error,omitempty"`
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+}
+
+// evalComparison compares the presets on a metric
+type evalComparison struct {
+	Metric string  `json:"metric"`
+	A      float64 `json:"a"`
+	B      float64 `json:"b"`
+	// Winner is a, b or tie.
+	Winner string `json:"winner"`
+}
+
+// evalReport is the JSON report of the eval subcommand
+type evalReport struct {
+	StartedAt   time.Time        `json:"started_at"`
+	A           string           `json:"a"`
+	B           string           `json:"b"`
+	Model       string           `json:"model"`
+	Judge       string           `json:"judge,omitempty"`
+	Comparisons []evalComparison `json:"comparisons"`
+	Results     []evalResult     `json:"results"`
+}
+
+// runEval implements the eval subcommand
+func runEval(args []string) {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	dir := fs.String("packages", "", "Directory of the packages to migrate with both prompt presets (required)")
+	presetA := fs.String("a", "", "Prompt preset file A, see -prompts; empty for the built-in prompts")
+	presetB := fs.String("b", "", "Prompt preset file B, see -prompts; empty for the built-in prompts")
+	judge := fs.String("judge", "", "Model scoring the completeness, structure and clarity of the migrated readmes, as in benchmark -models; empty to only use the validators")
+	reportFile := fs.String("report", "", "Write the results of every package to this JSON file")
+	fs.StringVar(&googleAPIKey, "api-key", "", "Google Gemini API key")
+	fs.StringVar(&apiKeyRef, "api-key-ref", "", secretRefHelp)
+	registerVerbosityFlags(fs)
+	registerProviderFlags(fs)
+	registerNetworkFlags(fs)
+	registerTimeoutFlags(fs)
+	registerGlossaryFlags(fs)
+	registerConfigFlags(fs)
+	_ = fs.Parse(args)
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *dir == "" || *presetA == *presetB {
+		fmt.Fprintln(os.Stderr, "eval needs -packages and two different prompt presets in -a and -b")
+		fs.Usage()
+		os.Exit(2)
+	}
+	a, err := loadEvalPreset(*presetA)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	b, err := loadEvalPreset(*presetB)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	var judgeModel *llmclient.Backend
+	if *judge != "" {
+		models, err := llmclient.ParseBackends(*judge)
+		if err != nil {
+			log.Fatalf("Error: invalid -judge: %v", err)
+		}
+		judgeModel = &models[0]
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateTimeouts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadGlossary(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadProvider(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	requireAPIKey()
+	if judgeModel != nil && judgeModel.Provider != llmclient.ProviderFake {
+		if err := checkModelAllowed(judgeModel.Model); err != nil {
+			log.Fatalf("Error: invalid -judge: %v", err)
+		}
+		model := llmclient.Backend{Provider: llmProvider, Model: modelName}
+		useModel(*judgeModel)
+		requireAPIKey()
+		useModel(model)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+
+	pkgs, err := findPackages(*dir)
+	if err != nil {
+		log.Fatalf("Error listing packages: %v", err)
+	}
+	report := &evalReport{StartedAt: time.Now().UTC(), A: evalPresetName(*presetA), B: evalPresetName(*presetB)}
+	report.Model = llmclient.Backend{Provider: llmProvider, Model: modelName}.String()
+	if judgeModel != nil {
+		report.Judge = judgeModel.String()
+	}
+	for _, pkgPath := range pkgs {
+		for _, preset := range []struct {
+			name    string
+			prompts promptPreset
+		}{{"a", a}, {"b", b}} {
+			if ctx.Err() != nil {
+				break
+			}
+			if verbose {
+				log.Printf("Migrating %s with preset %s", pkgPath, preset.name)
+			}
+			prompts = preset.prompts
+			r := evalPackage(ctx, pkgPath, judgeModel)
+			r.Preset = preset.name
+			report.Results = append(report.Results, r)
+		}
+	}
+	report.Comparisons = compareEval(report.Results, judgeModel != nil)
+
+	printEval(report)
+	if *reportFile != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = writeFileAtomic(*reportFile, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			log.Printf("Failed to write report: %v", err)
+		}
+	}
+	if ctx.Err() != nil {
+		os.Exit(1)
+	}
+}
+
+// loadEvalPreset reads a prompt preset file, an empty path is the built-in
+// prompts
+func loadEvalPreset(path string) (promptPreset, error) {
+	if path == "" {
+		return builtinPrompts(), nil
+	}
+	return readPromptPreset(path)
+}
+
+// evalPresetName names a prompt preset in the report
+func evalPresetName(path string) string {
+	if path == "" {
+		return "built-in"
+	}
+	return filepath.Base(path)
+}
+
+// useModel switches the LLM calls to a model, starting the failover chain
+// over from it
+func useModel(m llmclient.Backend) {
+	llmProvider, modelName = m.Provider, m.Model
+	resetFailover()
+}
+
+// evalPackage migrates a package with the current prompts, without writing
+// it, and scores the migrated readme
+func evalPackage(ctx context.Context, pkgPath string, judge *llmclient.Backend) evalResult {
+	r := evalResult{Package: packageName(pkgPath)}
+	s := &pipelineState{pkgPath: pkgPath}
+	if err := prepareStage(ctx, s); err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	result, err := migrateContent(ctx, s.req, nil)
+	if err != nil {
+		r.Error = redactSecrets(err.Error())
+		log.Printf("Error processing %s: %v", pkgPath, err)
+		return r
+	}
+
+	r.Metrics = map[string]float64{
+		"findings":  float64(len(result.Warnings)),
+		"gaps":      float64(len(result.Gaps)),
+		"retention": contentRetention(s.req.Readme, result.Markdown),
+	}
+	if llmProvider != llmclient.ProviderFake {
+		r.Metrics["cost_usd"] = result.Usage.CostUSD
+	}
+	if len(result.Readability) > 0 {
+		var grade float64
+		for _, sr := range result.Readability {
+			grade += sr.Grade
+		}
+		r.Metrics["grade"] = grade / float64(len(result.Readability))
+	}
+	if judge != nil {
+		scores, _, err := judgeReadme(ctx, *judge, s.req.Readme, result.Markdown)
+		if err != nil {
+			log.Printf("Failed to judge %s: %v", pkgPath, err)
+		}
+		for name, score := range scores {
+			r.Metrics[name] = score
+		}
+	}
+	return r
+}
+
+// contentRetention returns the percentage of the distinct words of the
+// original readme that are still in the migrated one
+func contentRetention(original, migrated string) float64 {
+	words := make(map[string]bool)
+	for _, w := range retentionWordPattern.FindAllString(strings.ToLower(original), -1) {
+		words[w] = true
+	}
+	if len(words) == 0 {
+		return 100
+	}
+	kept := make(map[string]bool)
+	for _, w := range retentionWordPattern.FindAllString(strings.ToLower(migrated), -1) {
+		kept[w] = true
+	}
+	n := 0
+	for w := range words {
+		if kept[w] {
+			n++
+		}
+	}
+	return 100 * float64(n) / float64(len(words))
+}
+
+// judgeReadme asks the judge model to score a migrated readme, and returns
+// the scores with the usage of the judge
+func judgeReadme(ctx context.Context, judge llmclient.Backend, original, migrated string) (map[string]float64, llmclient.Usage, error) {
+	template, err := fetchTemplate(ctx)
+	if err != nil {
+		return nil, llmclient.Usage{}, err
+	}
+	answer, usage, err := generateText(ctx, judge, fmt.Sprintf(judgePrompt, template, original, migrated))
+	if err != nil {
+		return nil, usage, err
+	}
+	start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return nil, usage, errors.New("no JSON object in the answer of the judge")
+	}
+	var answered map[string]float64
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &answered); err != nil {
+		return nil, usage, fmt.Errorf("failed to parse the answer of the judge: %w", err)
+	}
+	scores := make(map[string]float64)
+	for _, m := range evalMetrics {
+		if !m.Judge {
+			continue
+		}
+		score, ok := answered[m.Name]
+		if !ok {
+			return nil, usage, fmt.Errorf("judge gave no %s score", m.Name)
+		}
+		scores[m.Name] = score
+	}
+	return scores, usage, nil
+}
+
+// compareEval averages the metrics of each preset over the packages both
+// migrated and names the winner of every metric
+func compareEval(results []evalResult, judged bool) []evalComparison {
+	byPackage := make(map[string]map[string]evalResult)
+	for _, r := range results {
+		if byPackage[r.Package] == nil {
+			byPackage[r.Package] = make(map[string]evalResult)
+		}
+		byPackage[r.Package][r.Preset] = r
+	}
+
+	var comparisons []evalComparison
+	for _, m := range evalMetrics {
+		if m.Judge && !judged {
+			continue
+		}
+		c := evalComparison{Metric: m.Name, Winner: "tie"}
+		n := 0
+		for _, presets := range byPackage {
+			a, aOK := presets["a"].Metrics[m.Name]
+			b, bOK := presets["b"].Metrics[m.Name]
+			if !aOK || !bOK {
+				continue
+			}
+			c.A += a
+			c.B += b
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		c.A /= float64(n)
+		c.B /= float64(n)
+		if math.Abs(c.A-c.B) > 1e-9 {
+			if (c.A > c.B) == m.HigherIsBetter {
+				c.Winner = "a"
+			} else {
+				c.Winner = "b"
+			}
+		}
+		comparisons = append(comparisons, c)
+	}
+	return comparisons
+}
+
+// printEval prints the comparison of the presets
+func printEval(report *evalReport) {
+	failed := map[string]int{}
+	for _, r := range report.Results {
+		if r.Error != "" {
+			failed[r.Preset]++
+		}
+	}
+	fmt.Printf("a: %s, b: %s, model %s", report.A, report.B, report.Model)
+	if report.Judge != "" {
+		fmt.Printf(", judged by %s", report.Judge)
+	}
+	fmt.Printf("\nfailed packages: a %d, b %d\n\n", failed["a"], failed["b"])
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "METRIC\tA\tB\tWINNER")
+	wins := map[string]int{}
+	for _, c := range report.Comparisons {
+		fmt.Fprintf(w, "%s\t%.4g\t%.4g\t%s\n", c.Metric, c.A, c.B, c.Winner)
+		wins[c.Winner]++
+	}
+	w.Flush()
+	fmt.Printf("\na wins %d metrics, b wins %d, %d tied\n", wins["a"], wins["b"], wins["tie"])
+}
@@ -0,0 +1,144 @@
+package doctools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+var (
+	// fallbackModels are the models of -fallback-models
+	fallbackModels string
+	// fallbackBackends are the parsed -fallback-models
+	fallbackBackends []llmclient.Backend
+	// failoverCooldown is how long after failing over the run goes back to
+	// -model, from -failover-cooldown
+	failoverCooldown = 10 * time.Minute
+
+	// failover is the chain of models of a run, -model then the fallback
+	// models. Every call to the LLM takes the backend in use from it.
+	failover = &llmclient.Failover{}
+)
+
+// loadFailover parses -fallback-models into the failover chain. The fallback
+// models must be allowed like -model.
+func loadFailover() error {
+	fallbackBackends = nil
+	if fallbackModels != "" {
+		models, err := llmclient.ParseBackends(fallbackModels)
+		if err != nil {
+			return fmt.Errorf("invalid -fallback-models: %w", err)
+		}
+		for _, m := range models {
+			var err error
+			if m.Provider == llmclient.ProviderFake {
+				err = readFakeResponse()
+			} else {
+				err = checkModelAllowed(m.Model)
+			}
+			if err != nil {
+				return fmt.Errorf("invalid -fallback-models: %w", err)
+			}
+		}
+		fallbackBackends = models
+	}
+	resetFailover()
+	return nil
+}
+
+// resetFailover starts the failover chain over from -provider and -model
+func resetFailover() {
+	failover = llmclient.NewFailover(append([]llmclient.Backend{{Provider: llmProvider, Model: modelName}}, fallbackBackends...), failoverCooldown)
+}
+
+// currentBackend returns the provider and model answering the prompts: the
+// backend in use of the failover chain, or -provider and -model before the
+// chain is set up
+func currentBackend() llmclient.Backend {
+	if b, ok := failover.Current(); ok {
+		return b
+	}
+	return llmclient.Backend{Provider: llmProvider, Model: modelName}
+}
+
+// backendModel returns the model of a backend named as in the Backend of a
+// migrateResponse, the one that produced the readme, and -model for a
+// migration that made no LLM call
+func backendModel(backend string) string {
+	backends, err := llmclient.ParseBackends(backend)
+	if err != nil || len(backends) != 1 {
+		return modelName
+	}
+	return backends[0].Model
+}
+
+// shouldFailOver counts a provider error of backend b and switches the chain
+// to the next model on quota exhaustion or after llmclient.FailoverErrors
+// errors in a row. It reports whether the call should be made again, with
+// the backend in use of the chain.
+func shouldFailOver(b llmclient.Backend, err error) bool {
+	d := failover.Fail(b, err)
+	if !d.Retry {
+		return false
+	}
+	if !d.Switched {
+		log.Printf("Retrying %s after error %d of %d: %v", b, d.Errors, llmclient.FailoverErrors, err)
+		return true
+	}
+	log.Printf("Failing over from %s to %s: %v", b, d.Backend, err)
+	return true
+}
+
+// generateWithFailover calls generate with the backend in use until it
+// succeeds, failing over to the next model of -fallback-models as needed,
+// and returns its answer with the backend that produced it
+func generateWithFailover(ctx context.Context, generate func(b llmclient.Backend) (string, llmclient.Usage, error)) (string, llmclient.Usage, string, error) {
+	var total llmclient.Usage
+	for {
+		b := currentBackend()
+		text, usage, err := generate(b)
+		total.Add(usage)
+		if err == nil {
+			failover.Succeed(b)
+			return text, total, b.String(), nil
+		}
+		if ctx.Err() != nil || !shouldFailOver(b, err) {
+			return "", total, b.String(), err
+		}
+	}
+}
+
+// generatePinned calls generate with backend b, a model a request is routed
+// to outside the failover chain, until it succeeds. Its provider errors are
+// retried like those of the chain, and fail over to the chain on quota
+// exhaustion or after llmclient.FailoverErrors errors in a row. A nil b is
+// generateWithFailover.
+func generatePinned(ctx context.Context, b *llmclient.Backend, generate func(b llmclient.Backend) (string, llmclient.Usage, error)) (string, llmclient.Usage, string, error) {
+	if b == nil {
+		return generateWithFailover(ctx, generate)
+	}
+	var total llmclient.Usage
+	for errs := 1; ; errs++ {
+		text, usage, err := generate(*b)
+		total.Add(usage)
+		if err == nil {
+			return text, total, b.String(), nil
+		}
+		var pe llmclient.ProviderError
+		if ctx.Err() != nil || !errors.As(err, &pe) {
+			return "", total, b.String(), err
+		}
+		if !llmclient.IsQuotaError(err) && errs < llmclient.FailoverErrors {
+			log.Printf("Retrying %s after error %d of %d: %v", b, errs, llmclient.FailoverErrors, err)
+			continue
+		}
+		log.Printf("Failing over from %s to %s: %v", b, currentBackend(), err)
+		text, usage, backend, err := generateWithFailover(ctx, generate)
+		total.Add(usage)
+		return text, total, backend, err
+	}
+}
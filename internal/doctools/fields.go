@@ -0,0 +1,206 @@
+package doctools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxUndocumentedFields bounds the fields named in a finding about the fields
+// a data stream defines and the readme does not document
+const maxUndocumentedFields = 10
+
+// fieldDefinition is a field of a fields/*.yml file of a data stream
+type fieldDefinition struct {
+	Name        string `yaml:"name"`
+	Type        string `yaml:"type"`
+	Description string `yaml:"description"`
+	Unit        string `yaml:"unit"`
+	MetricType  string `yaml:"metric_type"`
+	// External is ecs for the fields imported from the ECS schema, whose
+	// description is that of ECS.
+	External string `yaml:"external"`
+	// Fields are the fields of a group.
+	Fields []fieldDefinition `yaml:"fields"`
+}
+
+var (
+	// fieldColumnHeaders are the headers of the column of field names in
+	// hand-written field tables
+	fieldColumnHeaders = []string{"field", "fields", "field name", "exported field", "name of the field"}
+	// tableFieldPattern matches the field names in field tables
+	tableFieldPattern = regexp.MustCompile(`^@?[A-Za-z0-9_][A-Za-z0-9_@.*-]*$`)
+)
+
+// definedFields returns the leaf fields each data stream defines in its
+// fields directory
+func definedFields(pkgPath string, dataStreams []string) (map[string][]string, error) {
+	defined := make(map[string][]string)
+	for _, ds := range dataStreams {
+		defs, err := readFieldDefinitions(filepath.Join(pkgPath, "data_stream", ds, "fields"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fields of %s: %w", ds, err)
+		}
+		if defs == nil {
+			continue
+		}
+		fields := flattenFields("", defs)
+		sort.Strings(fields)
+		defined[ds] = slices.Compact(fields)
+	}
+	return defined, nil
+}
+
+// readFieldDefinitions parses the fields/*.yml files of a fields directory,
+// in order of their names. It returns nil if the directory does not exist.
+func readFieldDefinitions(dir string) ([]fieldDefinition, error) {
+	entries, err := pkgFS.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defs := []fieldDefinition{}
+	for _, e := range entries {
+		if e.IsDir() || (!strings.HasSuffix(e.Name(), ".yml") && !strings.HasSuffix(e.Name(), ".yaml")) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := pkgFS.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var file []fieldDefinition
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		defs = append(defs, file...)
+	}
+	return defs, nil
+}
+
+// flattenFields returns the dotted names of the leaf fields of defs
+func flattenFields(prefix string, defs []fieldDefinition) []string {
+	var names []string
+	for _, d := range defs {
+		name := d.Name
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if d.Type == "group" || len(d.Fields) > 0 {
+			names = append(names, flattenFields(name, d.Fields)...)
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// documentedFields returns the fields in the field column of the markdown
+// tables of a readme, outside code blocks
+func documentedFields(readme string) []string {
+	var fields []string
+	column := -1
+	inCode := false
+	lines := strings.Split(readme, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "```") {
+			inCode = !inCode
+		}
+		if inCode || !strings.HasPrefix(line, "|") {
+			column = -1
+			continue
+		}
+		cells := tableCells(line)
+		// A header is followed by the delimiter row
+		if i+1 < len(lines) && isTableDelimiter(lines[i+1]) {
+			column = slices.IndexFunc(cells, func(c string) bool {
+				return slices.Contains(fieldColumnHeaders, strings.ToLower(c))
+			})
+			continue
+		}
+		if column < 0 || column >= len(cells) || isTableDelimiter(line) {
+			continue
+		}
+		if f := cells[column]; tableFieldPattern.MatchString(f) {
+			fields = append(fields, f)
+		}
+	}
+	sort.Strings(fields)
+	return slices.Compact(fields)
+}
+
+// tableCells returns the cells of a table row without their formatting
+func tableCells(row string) []string {
+	row = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(row), "|"), "|")
+	// Escaped pipes are part of the cell
+	cells := strings.Split(strings.ReplaceAll(row, `\|`, "\x00"), "|")
+	clean := strings.NewReplacer("\x00", "|", "`", "", "**", "", `\_`, "_", `\*`, "*")
+	for i, c := range cells {
+		cells[i] = strings.TrimSpace(clean.Replace(c))
+	}
+	return cells
+}
+
+// isTableDelimiter reports whether line is the delimiter row of a table
+func isTableDelimiter(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "|") && strings.Trim(line, "|-: ") == "" && strings.Contains(line, "-")
+}
+
+// fieldDrift compares the fields documented in the hand-written tables of a
+// readme with those the data streams define. It reports the documented
+// fields no data stream defines, and for each data stream the fields it
+// defines that are not documented. Readmes without field tables are not
+// compared.
+func fieldDrift(readme string, defined map[string][]string) []string {
+	documented := documentedFields(readme)
+	if len(documented) == 0 || len(defined) == 0 {
+		return nil
+	}
+
+	var findings []string
+	for _, f := range documented {
+		found := false
+		for _, fields := range defined {
+			if slices.Contains(fields, f) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			findings = append(findings, fmt.Sprintf("field %s is documented in the readme but not defined in fields.yml", f))
+		}
+	}
+
+	dataStreams := make([]string, 0, len(defined))
+	for ds := range defined {
+		dataStreams = append(dataStreams, ds)
+	}
+	sort.Strings(dataStreams)
+	for _, ds := range dataStreams {
+		var undocumented []string
+		for _, f := range defined[ds] {
+			if !slices.Contains(documented, f) {
+				undocumented = append(undocumented, f)
+			}
+		}
+		if len(undocumented) == 0 {
+			continue
+		}
+		names := strings.Join(undocumented, ", ")
+		if len(undocumented) > maxUndocumentedFields {
+			names = strings.Join(undocumented[:maxUndocumentedFields], ", ") + fmt.Sprintf(" and %d more", len(undocumented)-maxUndocumentedFields)
+		}
+		findings = append(findings, fmt.Sprintf("data stream %s defines %d fields the readme did not document: %s", ds, len(undocumented), names))
+	}
+	return findings
+}
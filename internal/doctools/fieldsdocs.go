@@ -0,0 +1,293 @@
+package doctools
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// ecsReferenceURL is the ECS field reference the tables link the ECS fields
+// to
+const ecsReferenceURL = "https://www.elastic.co/guide/en/ecs/current/"
+
+// baseFieldSet is the field set of the fields without a dot, as in ECS
+const baseFieldSet = "base"
+
+// fieldDoc is a leaf field of a fields table
+type fieldDoc struct {
+	Name        string
+	Type        string
+	Description string
+	Unit        string
+	MetricType  string
+	// ECS marks the fields of the ECS schema, linked to its reference.
+	ECS bool
+	// Imported marks the fields imported with external: ecs.
+	Imported bool
+}
+
+// RunFields runs the fields command with the arguments args
+func RunFields(args []string) {
+	fs := flag.NewFlagSet("fields", flag.ExitOnError)
+	pkgPath := fs.String("path", "", "Path to the package whose fields are documented (required)")
+	render := fs.String("render", "", "Readme whose fields placeholders are replaced with the tables, instead of writing a page of all the fields")
+	output := fs.String("o", "", "File the documentation is written to (default standard output)")
+	registerVerbosityFlags(fs)
+	registerNetworkFlags(fs)
+	registerECSFlags(fs)
+	registerConfigFlags(fs)
+	_ = fs.Parse(args)
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *pkgPath == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	var ecs map[string]ecsField
+	if ecsSchema != "" {
+		var err error
+		if ecs, err = loadECSSchema(context.Background()); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	tables, err := packageFieldTables(*pkgPath, ecs)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	var content string
+	if *render != "" {
+		readme, err := os.ReadFile(*render)
+		if err != nil {
+			log.Fatalf("Error reading -render: %v", err)
+		}
+		if content, err = renderFieldsPlaceholders(string(readme), tables); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	} else {
+		title := packageName(*pkgPath)
+		if m, err := readManifest(*pkgPath); err == nil && m.Title != "" {
+			title = m.Title
+		}
+		content = fieldsPage(title, tables)
+	}
+
+	if *output == "" {
+		fmt.Print(content)
+		return
+	}
+	if err := writeFileAtomic(*output, []byte(content), 0o644); err != nil {
+		log.Fatalf("Error writing %s: %v", *output, err)
+	}
+	logInfo("Wrote the fields of %d data streams to %s", len(tables), *output)
+}
+
+// packageFieldTables returns the fields tables of every data stream of a
+// package, by name, or of the package itself under the empty name when it
+// has no data streams, as input packages. Data streams without fields are
+// left out. The ECS fields are described with ecs, if not nil.
+func packageFieldTables(pkgPath string, ecs map[string]ecsField) (map[string]string, error) {
+	docs, err := packageFieldDocs(pkgPath, ecs)
+	if err != nil {
+		return nil, err
+	}
+	tables := make(map[string]string)
+	for ds, d := range docs {
+		tables[ds] = fieldsTables(d)
+	}
+	return tables, nil
+}
+
+// packageFieldDocs returns the leaf fields of every data stream of a
+// package, by name, or of the package itself under the empty name when it
+// has no data streams. Data streams without fields are left out.
+func packageFieldDocs(pkgPath string, ecs map[string]ecsField) (map[string][]fieldDoc, error) {
+	dataStreams, err := findDataStreams(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	dirs := make(map[string]string)
+	for _, ds := range dataStreams {
+		dirs[ds] = filepath.Join(pkgPath, "data_stream", ds, "fields")
+	}
+	if len(dataStreams) == 0 {
+		dirs[""] = filepath.Join(pkgPath, "fields")
+	}
+
+	docs := make(map[string][]fieldDoc)
+	for ds, dir := range dirs {
+		defs, err := readFieldDefinitions(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the fields of %s: %w", dir, err)
+		}
+		if d := fieldDocs("", defs, ecs); len(d) > 0 {
+			docs[ds] = d
+		}
+	}
+	return docs, nil
+}
+
+// fieldDocs flattens the definitions of a fields.yml into its leaf fields,
+// sorted by name. A field defined twice, as by a base-fields.yml and the
+// fields.yml of a data stream, keeps its first definition.
+func fieldDocs(prefix string, defs []fieldDefinition, ecs map[string]ecsField) []fieldDoc {
+	var docs []fieldDoc
+	for _, d := range defs {
+		name := d.Name
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if d.Type == "group" || len(d.Fields) > 0 {
+			docs = append(docs, fieldDocs(name, d.Fields, ecs)...)
+			continue
+		}
+		doc := fieldDoc{Name: name, Type: d.Type, Description: d.Description, Unit: d.Unit, MetricType: d.MetricType}
+		f, inECS := ecs[name]
+		doc.Imported = d.External == "ecs"
+		doc.ECS = doc.Imported || inECS
+		if d.External == "ecs" && inECS {
+			if doc.Description == "" {
+				doc.Description = f.Short
+			}
+			if doc.Type == "" {
+				doc.Type = f.Type
+			}
+		}
+		docs = append(docs, doc)
+	}
+	if prefix != "" {
+		return docs
+	}
+	slices.SortStableFunc(docs, func(a, b fieldDoc) int { return strings.Compare(a.Name, b.Name) })
+	return slices.CompactFunc(docs, func(a, b fieldDoc) bool { return a.Name == b.Name })
+}
+
+// fieldSet returns the field set a field is grouped in, the part of its
+// name before the first dot
+func fieldSet(name string) string {
+	set, _, ok := strings.Cut(name, ".")
+	if !ok {
+		return baseFieldSet
+	}
+	return set
+}
+
+// ecsFieldURL returns the link of an ECS field to its field set in the ECS
+// reference. Reused field sets, such as source.geo, link to the field set
+// they are nested in.
+func ecsFieldURL(name string) string {
+	anchor := strings.NewReplacer(".", "-", "_", "-", "@", "").Replace(name)
+	return fmt.Sprintf("%secs-%s.html#field-%s", ecsReferenceURL, fieldSet(name), anchor)
+}
+
+// tableCell escapes a value for a cell of a markdown table, on a single line
+func tableCell(s string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(s), " "), "|", `\|`)
+}
+
+// fieldsTables returns a markdown table of the fields of each field set,
+// each under the name of the set in bold. The unit and metric type columns
+// are only added to a table with fields that have them.
+func fieldsTables(docs []fieldDoc) string {
+	var sets []string
+	bySet := make(map[string][]fieldDoc)
+	for _, d := range docs {
+		set := fieldSet(d.Name)
+		if _, ok := bySet[set]; !ok {
+			sets = append(sets, set)
+		}
+		bySet[set] = append(bySet[set], d)
+	}
+	slices.Sort(sets)
+
+	var b strings.Builder
+	for i, set := range sets {
+		docs := bySet[set]
+		units := slices.ContainsFunc(docs, func(d fieldDoc) bool { return d.Unit != "" })
+		metrics := slices.ContainsFunc(docs, func(d fieldDoc) bool { return d.MetricType != "" })
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "**%s**\n\n", set)
+		header, delimiter := "| Field | Description | Type |", "|---|---|---|"
+		if units {
+			header, delimiter = header+" Unit |", delimiter+"---|"
+		}
+		if metrics {
+			header, delimiter = header+" Metric Type |", delimiter+"---|"
+		}
+		b.WriteString(header + "\n" + delimiter + "\n")
+		for _, d := range docs {
+			name := tableCell(d.Name)
+			if d.ECS {
+				name = fmt.Sprintf("[%s](%s)", name, ecsFieldURL(d.Name))
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s |", name, tableCell(d.Description), tableCell(d.Type))
+			if units {
+				fmt.Fprintf(&b, " %s |", tableCell(d.Unit))
+			}
+			if metrics {
+				fmt.Fprintf(&b, " %s |", tableCell(d.MetricType))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// fieldsPage returns a page documenting the fields of every data stream of
+// a package
+func fieldsPage(title string, tables map[string]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s fields\n", title)
+	if table, ok := tables[""]; ok {
+		b.WriteString("\n" + table)
+	}
+	names := make([]string, 0, len(tables))
+	for ds := range tables {
+		if ds != "" {
+			names = append(names, ds)
+		}
+	}
+	slices.Sort(names)
+	for _, ds := range names {
+		fmt.Fprintf(&b, "\n## %s\n\n%s", ds, tables[ds])
+	}
+	return markdown.Format(b.String())
+}
+
+// renderFieldsPlaceholders replaces the fields placeholders of a readme with
+// the tables of their data streams, or of the package for the placeholder of
+// input packages, the way elastic-package renders them, for docs exported
+// without it. A placeholder left without fields is an error.
+func renderFieldsPlaceholders(readme string, tables map[string]string) (string, error) {
+	for ds, table := range tables {
+		pattern := packageFieldsPattern
+		if ds != "" {
+			pattern = regexp.MustCompile(markdown.PlaceholderRegexp(placeholders.Fields, dataStreamVar, regexp.QuoteMeta(ds)))
+		}
+		readme = pattern.ReplaceAllLiteralString(readme, strings.TrimSuffix(table, "\n"))
+	}
+	left := regexp.MustCompile(markdown.PlaceholderRegexp(placeholders.Fields, dataStreamVar, `[^\s"']*`)+"|"+packageFieldsPattern.String()).FindAllString(readme, -1)
+	if len(left) > 0 {
+		slices.Sort(left)
+		return "", fmt.Errorf("no fields for the placeholders %s", strings.Join(slices.Compact(left), ", "))
+	}
+	return readme, nil
+}
@@ -0,0 +1,197 @@
+package doctools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// codeownersPaths are the locations GitHub reads CODEOWNERS from, relative to
+// the repository root
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// issuesRepo is the repository -issues-repo opens follow-up issues in
+var issuesRepo string
+
+// codeownersRule is a line of a CODEOWNERS file
+type codeownersRule struct {
+	pattern string
+	owners  []string
+}
+
+// findCodeowners returns the CODEOWNERS file of the git repository holding
+// dir and the repository root, or empty strings when there is none
+func findCodeowners(dir string) (string, string) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", ""
+	}
+	for {
+		for _, p := range codeownersPaths {
+			if _, err := pkgFS.Stat(filepath.Join(dir, p)); err == nil {
+				return filepath.Join(dir, p), dir
+			}
+		}
+		if _, err := pkgFS.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// readCodeowners parses a CODEOWNERS file
+func readCodeowners(file string) ([]codeownersRule, error) {
+	data, err := pkgFS.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []codeownersRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		rules = append(rules, codeownersRule{pattern: fields[0], owners: fields[1:]})
+	}
+	return rules, scanner.Err()
+}
+
+// matches reports whether a CODEOWNERS pattern applies to a path relative to
+// the repository root. Patterns without a slash match at any depth, like in
+// gitignore files.
+func (r codeownersRule) matches(rel string) bool {
+	pattern := strings.TrimSuffix(r.pattern, "/")
+	if pattern == "*" {
+		return true
+	}
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		if anchored && i > 0 {
+			break
+		}
+		// A pattern matches a directory and everything below it.
+		for j := len(parts); j > i; j-- {
+			if ok, _ := path.Match(pattern, strings.Join(parts[i:j], "/")); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// packageCodeowners returns the owners of a package from the CODEOWNERS file
+// of its repository, the last matching rule wins
+func packageCodeowners(pkgPath string) ([]string, error) {
+	file, root := findCodeowners(pkgPath)
+	if file == "" {
+		return nil, nil
+	}
+	rules, err := readCodeowners(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CODEOWNERS: %w", err)
+	}
+	abs, err := filepath.Abs(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return nil, err
+	}
+	var owners []string
+	for _, r := range rules {
+		if r.matches(filepath.ToSlash(rel)) {
+			owners = r.owners
+		}
+	}
+	return owners, nil
+}
+
+// followUpBody describes the TODOs of a migrated readme in an issue. Teams
+// cannot be assigned to issues, they are mentioned instead.
+func followUpBody(pkgName string, todos []todo, teams []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The readme of the `%s` package was migrated to the docs template. The migration left these notes for manual follow-up:\n\n", pkgName)
+	for _, kind := range []string{todoGuidance, todoRemoval} {
+		var items []todo
+		for _, t := range todos {
+			if t.Kind == kind {
+				items = append(items, t)
+			}
+		}
+		if len(items) == 0 {
+			continue
+		}
+		if kind == todoGuidance {
+			b.WriteString("**Sections to write**\n\n")
+		} else {
+			b.WriteString("**Content flagged for removal**\n\n")
+		}
+		for _, t := range items {
+			fmt.Fprintf(&b, "- [ ] %s (line %d): %s\n", t.Section, t.Line, t.Text)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("Remove the comments from the readme once they are addressed.\n")
+	if len(teams) > 0 {
+		fmt.Fprintf(&b, "\ncc %s\n", strings.Join(teams, " "))
+	}
+	return b.String()
+}
+
+// openFollowUpIssue opens an issue in -issues-repo listing the TODOs of a
+// migrated package, assigned to the users owning it in CODEOWNERS. Nothing is
+// opened when there are no TODOs or an open issue with the same title
+// exists. It returns the URL of the issue.
+func openFollowUpIssue(ctx context.Context, pkgPath, pkgName string, todos []todo) (string, error) {
+	if issuesRepo == "" || len(todos) == 0 {
+		return "", nil
+	}
+	gh := envGitHubClient()
+	title := fmt.Sprintf("[%s] Follow up on the docs template migration", pkgName)
+	existing, err := gh.searchIssues(ctx, fmt.Sprintf("repo:%s is:issue is:open in:title %q", issuesRepo, title), 10)
+	if err != nil {
+		return "", fmt.Errorf("failed to search follow-up issues: %w", err)
+	}
+	for _, issue := range existing {
+		if issue.Title == title {
+			return issue.HTMLURL, nil
+		}
+	}
+
+	owners, err := packageCodeowners(pkgPath)
+	if err != nil {
+		return "", err
+	}
+	var users, teams []string
+	for _, o := range owners {
+		switch {
+		case strings.Contains(o, "/"):
+			teams = append(teams, o)
+		case strings.HasPrefix(o, "@"):
+			users = append(users, strings.TrimPrefix(o, "@"))
+		}
+	}
+
+	url, err := gh.createIssue(ctx, issuesRepo, title, followUpBody(pkgName, todos, teams), users)
+	if err != nil {
+		return "", fmt.Errorf("failed to open follow-up issue: %w", err)
+	}
+	return url, nil
+}
@@ -0,0 +1,272 @@
+package doctools
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// packageFS is the filesystem packages are read from and written to. Paths
+// are native paths, like those of the os package.
+type packageFS interface {
+	ReadFile(name string) ([]byte, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Stat(name string) (fs.FileInfo, error)
+	// WriteFile replaces the file as a whole, readers never see a partially
+	// written file.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Remove(name string) error
+}
+
+// pkgFS is the filesystem of the packages, -dry-run keeps the writes in
+// memory
+var pkgFS packageFS = osFS{}
+
+// dryRun is set by -dry-run
+var dryRun bool
+
+// osFS is the filesystem of the operating system
+type osFS struct{}
+
+func (osFS) ReadFile(name string) ([]byte, error)       { return os.ReadFile(name) }
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+func (osFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(name) }
+func (osFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+func (osFS) Remove(name string) error { return os.Remove(name) }
+
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return writeFileAtomic(name, data, perm)
+}
+
+// memFS is an in-memory filesystem. On top of a base filesystem it records
+// the writes and removals without touching the base, otherwise it holds the
+// files itself.
+type memFS struct {
+	base packageFS
+
+	mu      sync.Mutex
+	files   map[string]memFile
+	dirs    map[string]bool
+	removed map[string]bool
+}
+
+// memFile is a file written to a memFS
+type memFile struct {
+	data []byte
+	perm fs.FileMode
+}
+
+// newMemFS returns an empty in-memory filesystem
+func newMemFS() *memFS {
+	return &memFS{
+		files:   make(map[string]memFile),
+		dirs:    make(map[string]bool),
+		removed: make(map[string]bool),
+	}
+}
+
+// newOverlayFS returns an in-memory filesystem reading through to base
+func newOverlayFS(base packageFS) *memFS {
+	m := newMemFS()
+	m.base = base
+	return m
+}
+
+// memFileInfo describes a file or directory of a memFS
+type memFileInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memFileInfo) Sys() any           { return nil }
+
+func (m *memFS) ReadFile(name string) ([]byte, error) {
+	name = filepath.Clean(name)
+	m.mu.Lock()
+	f, ok := m.files[name]
+	removed := m.removed[name]
+	m.mu.Unlock()
+	switch {
+	case ok:
+		return append([]byte(nil), f.data...), nil
+	case removed || m.base == nil:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return m.base.ReadFile(name)
+}
+
+func (m *memFS) Stat(name string) (fs.FileInfo, error) {
+	name = filepath.Clean(name)
+	m.mu.Lock()
+	f, ok := m.files[name]
+	dir := m.dirs[name]
+	removed := m.removed[name]
+	m.mu.Unlock()
+	switch {
+	case ok:
+		return memFileInfo{name: filepath.Base(name), size: int64(len(f.data)), mode: f.perm}, nil
+	case dir:
+		return memFileInfo{name: filepath.Base(name), mode: fs.ModeDir | 0o755}, nil
+	case removed || m.base == nil:
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return m.base.Stat(name)
+}
+
+// ReadDir lists the entries of the base directory merged with those written
+// to memory, sorted by name
+func (m *memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	name = filepath.Clean(name)
+	entries := make(map[string]fs.DirEntry)
+	var baseErr error
+	if m.base != nil {
+		var base []fs.DirEntry
+		base, baseErr = m.base.ReadDir(name)
+		for _, e := range base {
+			entries[e.Name()] = e
+		}
+	}
+
+	m.mu.Lock()
+	found := m.dirs[name]
+	for path := range m.removed {
+		if filepath.Dir(path) == name {
+			delete(entries, filepath.Base(path))
+		}
+	}
+	for path, f := range m.files {
+		if filepath.Dir(path) == name {
+			entries[filepath.Base(path)] = fs.FileInfoToDirEntry(memFileInfo{name: filepath.Base(path), size: int64(len(f.data)), mode: f.perm})
+			found = true
+		}
+	}
+	for path := range m.dirs {
+		if path != name && filepath.Dir(path) == name {
+			entries[filepath.Base(path)] = fs.FileInfoToDirEntry(memFileInfo{name: filepath.Base(path), mode: fs.ModeDir | 0o755})
+		}
+	}
+	m.mu.Unlock()
+
+	if !found {
+		if m.base == nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		if baseErr != nil {
+			return nil, baseErr
+		}
+	}
+	list := make([]fs.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+// WriteFile creates the missing parent directories, unlike os.WriteFile. As
+// with osFS, a file that exists keeps its permissions and perm only applies
+// to new files.
+func (m *memFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	name = filepath.Clean(name)
+	if info, err := m.Stat(name); err == nil && info.Mode().IsRegular() {
+		perm = info.Mode()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[name] = memFile{data: append([]byte(nil), data...), perm: perm.Perm()}
+	delete(m.removed, name)
+	m.mkdirAll(filepath.Dir(name))
+	return nil
+}
+
+func (m *memFS) MkdirAll(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mkdirAll(filepath.Clean(name))
+	return nil
+}
+
+// mkdirAll records a directory and its parents, m.mu must be held
+func (m *memFS) mkdirAll(dir string) {
+	for {
+		m.dirs[dir] = true
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return
+		}
+		dir = parent
+	}
+}
+
+func (m *memFS) Remove(name string) error {
+	name = filepath.Clean(name)
+	m.mu.Lock()
+	_, ok := m.files[name]
+	delete(m.files, name)
+	m.mu.Unlock()
+	if m.base != nil {
+		if _, err := m.base.Stat(name); err == nil {
+			m.mu.Lock()
+			m.removed[name] = true
+			m.mu.Unlock()
+			ok = true
+		}
+	}
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	return nil
+}
+
+// changed returns the files written to or removed from memory, sorted
+func (m *memFS) changed() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var paths []string
+	for path := range m.files {
+		paths = append(paths, path)
+	}
+	for path := range m.removed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// dryRunSummary lists the files a -dry-run would have changed, relative to
+// the working directory where possible
+func dryRunSummary() string {
+	m, ok := pkgFS.(*memFS)
+	if !ok {
+		return ""
+	}
+	wd, _ := os.Getwd()
+	var b strings.Builder
+	for _, path := range m.changed() {
+		action := "write"
+		if _, err := m.Stat(path); err != nil {
+			action = "remove"
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			if rel, err := filepath.Rel(wd, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				path = rel
+			}
+		}
+		fmt.Fprintf(&b, "Would %s %s\n", action, path)
+	}
+	return b.String()
+}
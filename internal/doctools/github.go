@@ -0,0 +1,372 @@
+package doctools
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// errNotFound is returned by githubClient when the requested resource does
+// not exist
+var errNotFound = errors.New("not found")
+
+// githubClient is a minimal GitHub REST API client covering what the
+// webhook bot needs
+type githubClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newGitHubClient(baseURL, token string) *githubClient {
+	return &githubClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second, Transport: outboundTransport},
+	}
+}
+
+// envGitHubClient returns a client authenticated with GITHUB_TOKEN, for the
+// command line features using GitHub. GITHUB_API_URL selects a GitHub
+// Enterprise server.
+func envGitHubClient() *githubClient {
+	apiURL := os.Getenv("GITHUB_API_URL")
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+	return newGitHubClient(apiURL, os.Getenv("GITHUB_TOKEN"))
+}
+
+// githubContent is an entry of a directory listing from the contents API
+type githubContent struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Type string `json:"type"`
+	SHA  string `json:"sha"`
+}
+
+func (c *githubClient) newRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// send performs the request and decodes a JSON response into out, if not nil
+func (c *githubClient) send(req *http.Request, out any) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *githubClient) do(ctx context.Context, method, path string, body, out any) error {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	return c.send(req, out)
+}
+
+// pullRequestFiles lists the paths of the files changed by a pull request
+func (c *githubClient) pullRequestFiles(ctx context.Context, repo string, number int) ([]string, error) {
+	var files []string
+	// The API returns at most 3000 files, 100 per page.
+	for page := 1; page <= 30; page++ {
+		var batch []struct {
+			Filename string `json:"filename"`
+			Status   string `json:"status"`
+		}
+		path := fmt.Sprintf("/repos/%s/pulls/%d/files?per_page=100&page=%d", repo, number, page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &batch); err != nil {
+			return nil, err
+		}
+		for _, f := range batch {
+			if f.Status != "removed" {
+				files = append(files, f.Filename)
+			}
+		}
+		if len(batch) < 100 {
+			break
+		}
+	}
+	return files, nil
+}
+
+func contentsPath(repo, path, ref string) string {
+	return fmt.Sprintf("/repos/%s/contents/%s?ref=%s", repo, escapePath(path), url.QueryEscape(ref))
+}
+
+func escapePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// fileContent returns the content of a file at the given ref
+func (c *githubClient) fileContent(ctx context.Context, repo, path, ref string) (string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, contentsPath(repo, path, ref), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.raw")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", req.URL.Path, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// fileSHA returns the blob SHA of a file at the given ref, as required to
+// update it through the contents API
+func (c *githubClient) fileSHA(ctx context.Context, repo, path, ref string) (string, error) {
+	var content githubContent
+	if err := c.do(ctx, http.MethodGet, contentsPath(repo, path, ref), nil, &content); err != nil {
+		return "", err
+	}
+	return content.SHA, nil
+}
+
+// listDir lists a directory at the given ref
+func (c *githubClient) listDir(ctx context.Context, repo, path, ref string) ([]githubContent, error) {
+	var entries []githubContent
+	if err := c.do(ctx, http.MethodGet, contentsPath(repo, path, ref), nil, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// githubIssue is an issue returned by the search API
+type githubIssue struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+	State   string `json:"state"`
+}
+
+// searchIssues returns up to limit issues matching a search query, e.g.
+// repo:elastic/integrations is:issue label:bug
+func (c *githubClient) searchIssues(ctx context.Context, query string, limit int) ([]githubIssue, error) {
+	var result struct {
+		Items []githubIssue `json:"items"`
+	}
+	path := fmt.Sprintf("/search/issues?q=%s&per_page=%d", url.QueryEscape(query), min(limit, 100))
+	if err := c.do(ctx, http.MethodGet, path, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Items, nil
+}
+
+// createComment adds a comment to an issue or pull request
+func (c *githubClient) createComment(ctx context.Context, repo string, number int, body string) error {
+	path := fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number)
+	return c.do(ctx, http.MethodPost, path, map[string]string{"body": body}, nil)
+}
+
+// githubUser is a GitHub account
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+// githubComment is a comment on an issue or pull request
+type githubComment struct {
+	ID   int64      `json:"id"`
+	Body string     `json:"body"`
+	User githubUser `json:"user"`
+}
+
+// authenticatedUser returns the login of the account of the token
+func (c *githubClient) authenticatedUser(ctx context.Context) (string, error) {
+	var user githubUser
+	if err := c.do(ctx, http.MethodGet, "/user", nil, &user); err != nil {
+		return "", err
+	}
+	return user.Login, nil
+}
+
+// issueComments lists the comments of an issue or pull request
+func (c *githubClient) issueComments(ctx context.Context, repo string, number int) ([]githubComment, error) {
+	var comments []githubComment
+	for page := 1; ; page++ {
+		var batch []githubComment
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100&page=%d", repo, number, page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &batch); err != nil {
+			return nil, err
+		}
+		comments = append(comments, batch...)
+		if len(batch) < 100 {
+			return comments, nil
+		}
+	}
+}
+
+// updateComment replaces the body of a comment on an issue or pull request
+func (c *githubClient) updateComment(ctx context.Context, repo string, id int64, body string) error {
+	path := fmt.Sprintf("/repos/%s/issues/comments/%d", repo, id)
+	return c.do(ctx, http.MethodPatch, path, map[string]string{"body": body}, nil)
+}
+
+// createIssue opens an issue assigned to assignees and returns its URL
+func (c *githubClient) createIssue(ctx context.Context, repo, title, body string, assignees []string) (string, error) {
+	var issue githubIssue
+	req := map[string]any{"title": title, "body": body}
+	if len(assignees) > 0 {
+		req["assignees"] = assignees
+	}
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/issues", repo), req, &issue); err != nil {
+		return "", err
+	}
+	return issue.HTMLURL, nil
+}
+
+// createBranch creates a branch pointing at sha
+func (c *githubClient) createBranch(ctx context.Context, repo, branch, sha string) error {
+	path := fmt.Sprintf("/repos/%s/git/refs", repo)
+	return c.do(ctx, http.MethodPost, path, map[string]string{"ref": "refs/heads/" + branch, "sha": sha}, nil)
+}
+
+// putFile creates or updates a file on a branch with a single commit. sha
+// must be the current blob SHA when updating an existing file.
+func (c *githubClient) putFile(ctx context.Context, repo, path, branch, message, content, sha string) error {
+	body := map[string]string{
+		"message": message,
+		"content": base64.StdEncoding.EncodeToString([]byte(content)),
+		"branch":  branch,
+	}
+	if sha != "" {
+		body["sha"] = sha
+	}
+	return c.do(ctx, http.MethodPut, fmt.Sprintf("/repos/%s/contents/%s", repo, escapePath(path)), body, nil)
+}
+
+// createPullRequest opens a pull request and returns its URL
+func (c *githubClient) createPullRequest(ctx context.Context, repo, head, base, title, body string) (string, error) {
+	var pr struct {
+		HTMLURL string `json:"html_url"`
+	}
+	req := map[string]string{"head": head, "base": base, "title": title, "body": body}
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/pulls", repo), req, &pr); err != nil {
+		return "", err
+	}
+	return pr.HTMLURL, nil
+}
+
+// githubRelease is a release of a repository with its assets
+type githubRelease struct {
+	TagName string        `json:"tag_name"`
+	HTMLURL string        `json:"html_url"`
+	Assets  []githubAsset `json:"assets"`
+}
+
+// githubAsset is a file attached to a release, URL is its API address
+type githubAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Size int64  `json:"size"`
+}
+
+// release returns the release of a repository with the given tag, or its
+// latest release for an empty tag. Drafts and prereleases are never the
+// latest.
+func (c *githubClient) release(ctx context.Context, repo, tag string) (githubRelease, error) {
+	path := fmt.Sprintf("/repos/%s/releases/latest", repo)
+	if tag != "" {
+		path = fmt.Sprintf("/repos/%s/releases/tags/%s", repo, url.PathEscape(tag))
+	}
+	var r githubRelease
+	err := c.do(ctx, http.MethodGet, path, nil, &r)
+	return r, err
+}
+
+// downloadAsset returns the content of a release asset, which fails if it
+// is larger than limit bytes. The API redirects to the storage of the
+// asset, which does not get the token.
+func (c *githubClient) downloadAsset(ctx context.Context, a githubAsset, limit int64) ([]byte, error) {
+	if a.Size > limit {
+		return nil, fmt.Errorf("%s is %d bytes, larger than the limit of %d", a.Name, a.Size, limit)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	// The binaries outlast the timeout of the API requests, the download
+	// is bounded by ctx instead
+	client := &http.Client{Transport: c.http.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s: %s", a.Name, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", a.Name, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s is larger than the limit of %d bytes", a.Name, limit)
+	}
+	return data, nil
+}
@@ -0,0 +1,127 @@
+package doctools
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// glossaryPrompt is added to the readme prompt with the terms of -glossary
+const glossaryPrompt = `
+
+Use these product terms exactly as written, with the same capitalization, and avoid the alternatives listed for them:
+%s`
+
+// glossaryTerm is an approved product term of the -glossary file
+type glossaryTerm struct {
+	Term       string `yaml:"term"`
+	Definition string `yaml:"definition"`
+	// Avoid are other names of the term that must not be used, matched
+	// case sensitively.
+	Avoid []string `yaml:"avoid"`
+}
+
+var (
+	// glossaryPath is the glossary file selected with -glossary
+	glossaryPath string
+	// glossary are the terms loaded from glossaryPath
+	glossary []glossaryTerm
+
+	// nonProsePattern matches the spans terminology checks skip: the spans a
+	// translation keeps, link targets and URLs
+	nonProsePattern = regexp.MustCompile("(?ms)^```.*?^```|`[^`\n]+`|\\{\\{.*?\\}\\}|^:{3,}.*?$|\\]\\([^)]*\\)|https?://\\S+|<!--.*?-->")
+)
+
+// registerGlossaryFlags adds the glossary flag to fs
+func registerGlossaryFlags(fs *flag.FlagSet) {
+	fs.StringVar(&glossaryPath, "glossary", "", "YAML file of approved product terms, given to the LLM and checked in the migrated readme")
+}
+
+// loadGlossary reads the terms of -glossary
+func loadGlossary() error {
+	if glossaryPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(glossaryPath)
+	if err != nil {
+		return fmt.Errorf("failed to read glossary: %w", err)
+	}
+	var terms []glossaryTerm
+	if err := yaml.Unmarshal(data, &terms); err != nil {
+		return fmt.Errorf("failed to parse glossary: %w", err)
+	}
+	for i, t := range terms {
+		if strings.TrimSpace(t.Term) == "" {
+			return fmt.Errorf("glossary entry %d has no term", i+1)
+		}
+	}
+	glossary = terms
+	return nil
+}
+
+// formatGlossary formats the glossary for the prompt
+func formatGlossary(terms []glossaryTerm) string {
+	var b strings.Builder
+	for _, t := range terms {
+		fmt.Fprintf(&b, "- %s", t.Term)
+		if t.Definition != "" {
+			fmt.Fprintf(&b, ": %s", strings.Join(strings.Fields(t.Definition), " "))
+		}
+		if len(t.Avoid) > 0 {
+			fmt.Fprintf(&b, " (not %s)", strings.Join(t.Avoid, ", "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// proseOnly blanks out the parts of a markdown document that are not prose,
+// keeping offsets and line numbers
+func proseOnly(content string) string {
+	return nonProsePattern.ReplaceAllStringFunc(content, func(span string) string {
+		return strings.Map(func(r rune) rune {
+			if r == '\n' {
+				return r
+			}
+			return ' '
+		}, span)
+	})
+}
+
+// lineAt returns the line number of an offset in content
+func lineAt(content string, offset int) int {
+	return strings.Count(content[:offset], "\n") + 1
+}
+
+// termPattern matches a term as a whole word, ignoring case
+func termPattern(term string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(term) + `\b`)
+}
+
+// glossaryFindings reports the prose of content that misspells a glossary
+// term, by capitalization or by using a name to avoid
+func glossaryFindings(content string, terms []glossaryTerm) []string {
+	if len(terms) == 0 {
+		return nil
+	}
+	prose := proseOnly(content)
+	var findings []string
+	for _, t := range terms {
+		for _, loc := range termPattern(t.Term).FindAllStringIndex(prose, -1) {
+			if got := prose[loc[0]:loc[1]]; got != t.Term {
+				findings = append(findings, fmt.Sprintf("line %d: use %q instead of %q", lineAt(prose, loc[0]), t.Term, got))
+			}
+		}
+		for _, avoid := range t.Avoid {
+			re := regexp.MustCompile(`\b` + regexp.QuoteMeta(avoid) + `\b`)
+			for _, loc := range re.FindAllStringIndex(prose, -1) {
+				findings = append(findings, fmt.Sprintf("line %d: use %q instead of %q", lineAt(prose, loc[0]), t.Term, avoid))
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,249 @@
+package doctools
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+// goldenFixture is the fixture.yml of a golden test, the options the package
+// of the fixture is migrated with
+type goldenFixture struct {
+	Layout    string `yaml:"layout"`
+	Target    string `yaml:"target"`
+	ExtraDocs string `yaml:"extra_docs"`
+	// ResponseFormat is the -response-format, the fake response of json and
+	// operations fixtures is in response.json instead of response.md.
+	ResponseFormat string `yaml:"response_format"`
+}
+
+// updateGolden rewrites the expected output of the fixtures, run the tests
+// with it after an intended change
+var updateGolden = flag.Bool("update-golden", false, "Write the actual output of the golden fixtures as their expected output")
+
+// TestGolden migrates the fixtures of testdata/golden in memory with the fake
+// LLM provider and compares the result with their expected output. The
+// fixtures share the globals of the pipeline, so they do not run in
+// parallel.
+func TestGolden(t *testing.T) {
+	dir := filepath.Join("testdata", "golden")
+	template, err := os.ReadFile(filepath.Join(dir, "template.md"))
+	if err != nil {
+		t.Fatalf("reading fixture template: %v", err)
+	}
+	// The fixtures are migrated with the template of the fixtures and the
+	// response of the fixture, without network access
+	cachedTemplate = string(template)
+	useModel(llmclient.Backend{Provider: llmclient.ProviderFake, Model: modelName})
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("reading fixtures: %v", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		t.Run(e.Name(), func(t *testing.T) {
+			diffs, err := runGoldenFixture(context.Background(), filepath.Join(dir, e.Name()), *updateGolden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range diffs {
+				t.Error(d)
+			}
+			if len(diffs) > 0 {
+				t.Log("run with -update-golden if the changes are expected")
+			}
+		})
+	}
+}
+
+// runGoldenFixture migrates the package of a fixture in memory and compares
+// the result with the expected output of the fixture, or replaces the
+// expected output with it when update is set. It returns the differences.
+func runGoldenFixture(ctx context.Context, dir string, update bool) ([]string, error) {
+	fixture := goldenFixture{Layout: layoutSingle, Target: targetReadme, ExtraDocs: extraDocsMerge, ResponseFormat: responseMarkdown}
+	data, err := os.ReadFile(filepath.Join(dir, "fixture.yml"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture.yml: %w", err)
+	}
+	if err := validateLayout(fixture.Layout); err != nil {
+		return nil, err
+	}
+	if err := validateTarget(fixture.Target); err != nil {
+		return nil, err
+	}
+	if err := validateExtraDocs(fixture.ExtraDocs, fixture.Target); err != nil {
+		return nil, err
+	}
+	if err := validateResponseFormat(fixture.ResponseFormat); err != nil {
+		return nil, err
+	}
+	docsLayout, outputTarget, extraDocsMode = fixture.Layout, fixture.Target, fixture.ExtraDocs
+	responseFormat = fixture.ResponseFormat
+
+	responseFile := "response.md"
+	if responseFormat != responseMarkdown && responseFormat != responseChunked {
+		responseFile = "response.json"
+	}
+	response, err := os.ReadFile(filepath.Join(dir, responseFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fake response: %w", err)
+	}
+	fakeResponse = string(response)
+
+	actual, err := goldenOutput(ctx, filepath.Join(dir, "package"))
+	if err != nil {
+		return nil, err
+	}
+	expectedDir := filepath.Join(dir, "expected")
+	if update {
+		return nil, writeGolden(expectedDir, actual)
+	}
+	expected, err := readGolden(expectedDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read expected output: %w", err)
+	}
+	return diffGolden(expected, actual)
+}
+
+// goldenOutput migrates a package with the writes kept in memory and returns
+// the output compared by the golden tests, keyed by file name: the patch,
+// the warnings, the files written to the package below package/ and the
+// removed ones, or the error of a failed migration
+func goldenOutput(ctx context.Context, pkgPath string) (map[string]string, error) {
+	overlay := newOverlayFS(osFS{})
+	pkgFS = overlay
+	defer func() { pkgFS = osFS{} }()
+
+	result, err := processPackage(ctx, pkgPath)
+	if err != nil {
+		return map[string]string{"error.txt": err.Error() + "\n"}, nil
+	}
+	out := map[string]string{"patch.diff": result.Patch}
+	if len(result.Warnings) > 0 {
+		out["warnings.txt"] = strings.Join(result.Warnings, "\n") + "\n"
+	}
+	if len(result.Edits) > 0 {
+		var b strings.Builder
+		for _, e := range result.Edits {
+			fmt.Fprintln(&b, e)
+		}
+		out["edits.txt"] = b.String()
+	}
+	var removed []string
+	for _, path := range overlay.changed() {
+		rel, err := filepath.Rel(pkgPath, path)
+		if err != nil {
+			return nil, err
+		}
+		data, err := overlay.ReadFile(path)
+		if os.IsNotExist(err) {
+			removed = append(removed, filepath.ToSlash(rel))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out["package/"+filepath.ToSlash(rel)] = string(data)
+	}
+	if len(removed) > 0 {
+		out["removed.txt"] = strings.Join(removed, "\n") + "\n"
+	}
+	return out, nil
+}
+
+// readGolden reads the expected output of a fixture
+func readGolden(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	return files, err
+}
+
+// writeGolden replaces the expected output of a fixture
+func writeGolden(dir string, files map[string]string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffGolden returns a unified diff per file that differs between the
+// expected and the actual output
+func diffGolden(expected, actual map[string]string) ([]string, error) {
+	names := make(map[string]bool)
+	for name := range expected {
+		names[name] = true
+	}
+	for name := range actual {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var diffs []string
+	for _, name := range sorted {
+		want, wantOK := expected[name]
+		got, gotOK := actual[name]
+		switch {
+		case !gotOK:
+			diffs = append(diffs, fmt.Sprintf("%s: expected but not produced", name))
+		case !wantOK:
+			diffs = append(diffs, fmt.Sprintf("%s: produced but not expected", name))
+		case want != got:
+			diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        patchLines(want),
+				B:        patchLines(got),
+				FromFile: "expected/" + name,
+				ToFile:   "actual/" + name,
+				Context:  3,
+			})
+			if err != nil {
+				return nil, err
+			}
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, nil
+}
@@ -0,0 +1,153 @@
+package doctools
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kgeller/go-examples/pkg/migratepb"
+)
+
+// grpcStages maps the migration stage names to their protobuf enum values
+var grpcStages = map[string]migratepb.Stage{
+	stageFetchTemplate:     migratepb.Stage_STAGE_FETCH_TEMPLATE,
+	stageGenerate:          migratepb.Stage_STAGE_GENERATE,
+	stageApplyPlaceholders: migratepb.Stage_STAGE_APPLY_PLACEHOLDERS,
+	stageDiff:              migratepb.Stage_STAGE_DIFF,
+	stageValidate:          migratepb.Stage_STAGE_VALIDATE,
+}
+
+// migrationServer implements migratepb.MigrationServiceServer on top of the
+// same pipeline as the REST API
+type migrationServer struct {
+	migratepb.UnimplementedMigrationServiceServer
+}
+
+func (migrationServer) Migrate(req *migratepb.MigrateRequest, stream migratepb.MigrationService_MigrateServer) error {
+	if req.GetReadme() == "" {
+		return status.Error(codes.InvalidArgument, "readme is required")
+	}
+	if err := validateTarget(req.GetTarget()); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := validatePackageType(req.GetPackageType()); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var sendErr error
+	progress := func(stage string) {
+		if sendErr != nil {
+			return
+		}
+		sendErr = stream.Send(&migratepb.MigrateResponse{
+			Event: &migratepb.MigrateResponse_Progress{
+				Progress: &migratepb.ProgressEvent{Stage: grpcStages[stage], Message: stage},
+			},
+		})
+	}
+
+	resp, err := migrateContent(stream.Context(), migrateRequest{
+		Readme:       req.GetReadme(),
+		DataStreams:  req.GetDataStreams(),
+		SampleEvents: req.GetSampleEvents(),
+		Target:       req.GetTarget(),
+		PackageType:  req.GetPackageType(),
+		Setup:        setupFromProto(req.GetSetup()),
+	}, progress)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return status.FromContextError(err).Err()
+		}
+		log.Printf("Error migrating readme: %v", err)
+		return status.Error(codes.Unavailable, redactSecrets(err.Error()))
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+
+	return stream.Send(&migratepb.MigrateResponse{
+		Event: &migratepb.MigrateResponse_Result{
+			Result: &migratepb.MigrateResult{
+				Markdown: resp.Markdown,
+				Patch:    resp.Patch,
+				Warnings: resp.Warnings,
+			},
+		},
+	})
+}
+
+// setupFromProto converts the package setup of a gRPC request, nil if it has
+// none
+func setupFromProto(s *migratepb.PackageSetup) *packageSetup {
+	if s == nil {
+		return nil
+	}
+	setup := &packageSetup{Vars: varsFromProto(s.GetVars())}
+	for _, t := range s.GetPolicyTemplates() {
+		pt := policyTemplate{
+			Name:        t.GetName(),
+			Title:       t.GetTitle(),
+			Description: t.GetDescription(),
+			DataStreams: t.GetDataStreams(),
+			Input:       t.GetInput(),
+			Vars:        varsFromProto(t.GetVars()),
+		}
+		for _, in := range t.GetInputs() {
+			pt.Inputs = append(pt.Inputs, policyInput{
+				Type:        in.GetType(),
+				Title:       in.GetTitle(),
+				Description: in.GetDescription(),
+				Vars:        varsFromProto(in.GetVars()),
+			})
+		}
+		setup.PolicyTemplates = append(setup.PolicyTemplates, pt)
+	}
+	return setup
+}
+
+// varsFromProto converts the settings of a package setup of a gRPC request
+func varsFromProto(vars []*migratepb.ManifestVar) []manifestVar {
+	var converted []manifestVar
+	for _, v := range vars {
+		mv := manifestVar{
+			Name:        v.GetName(),
+			Type:        v.GetType(),
+			Title:       v.GetTitle(),
+			Description: v.GetDescription(),
+			Required:    v.GetRequired(),
+			ShowUser:    v.GetShowUser(),
+			Multi:       v.GetMulti(),
+			Secret:      v.GetSecret(),
+		}
+		if v.GetDefault() != nil {
+			mv.Default = v.GetDefault().AsInterface()
+		}
+		converted = append(converted, mv)
+	}
+	return converted
+}
+
+// serveGRPC runs the gRPC server until ctx is cancelled
+func serveGRPC(ctx context.Context, addr string, auth *apiAuth) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	srv := grpc.NewServer(grpc.StatsHandler(otelgrpc.NewServerHandler()), grpc.StreamInterceptor(auth.streamInterceptor))
+	migratepb.RegisterMigrationServiceServer(srv, migrationServer{})
+
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+
+	log.Printf("Serving gRPC on %s", addr)
+	return srv.Serve(lis)
+}
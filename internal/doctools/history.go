@@ -0,0 +1,445 @@
+package doctools
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+// historyEnv is the environment variable holding the default path of the
+// history database
+const historyEnv = "DOCS_TEMPLATE_UPDATE_HISTORY"
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id             TEXT PRIMARY KEY,
+	mode           TEXT NOT NULL,
+	model          TEXT NOT NULL,
+	prompt_version TEXT NOT NULL DEFAULT '',
+	started_at     TIMESTAMP NOT NULL,
+	finished_at    TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS package_results (
+	run_id          TEXT NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+	package         TEXT NOT NULL,
+	owner           TEXT NOT NULL DEFAULT '',
+	status          TEXT NOT NULL,
+	error           TEXT NOT NULL DEFAULT '',
+	prompt_tokens   INTEGER NOT NULL DEFAULT 0,
+	response_tokens INTEGER NOT NULL DEFAULT 0,
+	duration_ms     INTEGER NOT NULL DEFAULT 0,
+	cost_usd        REAL NOT NULL DEFAULT 0,
+	PRIMARY KEY (run_id, package)
+);
+
+CREATE TABLE IF NOT EXISTS findings (
+	run_id  TEXT NOT NULL,
+	package TEXT NOT NULL,
+	finding TEXT NOT NULL,
+	FOREIGN KEY (run_id, package) REFERENCES package_results(run_id, package) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS package_results_package ON package_results(package);
+
+CREATE TABLE IF NOT EXISTS approvals (
+	id            TEXT PRIMARY KEY,
+	repo          TEXT NOT NULL,
+	base          TEXT NOT NULL,
+	sha           TEXT NOT NULL,
+	pkg_dir       TEXT NOT NULL,
+	target_path   TEXT NOT NULL,
+	target_exists INTEGER NOT NULL,
+	original      TEXT NOT NULL,
+	result        TEXT NOT NULL,
+	status        TEXT NOT NULL,
+	created_at    TIMESTAMP NOT NULL,
+	pull_request  TEXT NOT NULL DEFAULT '',
+	audit         TEXT NOT NULL DEFAULT '[]'
+);
+`
+
+// historyColumns are columns added to the tables after their creation, by
+// table, they are added to older databases when opened
+var historyColumns = map[string]map[string]string{
+	"runs": {
+		"prompt_version": "TEXT NOT NULL DEFAULT ''",
+	},
+	"package_results": {
+		"owner":    "TEXT NOT NULL DEFAULT ''",
+		"cost_usd": "REAL NOT NULL DEFAULT 0",
+	},
+}
+
+// historyStore persists runs and their per-package results in an embedded
+// SQLite database
+type historyStore struct {
+	db *sql.DB
+}
+
+// runRecord is a run as stored in the history database
+type runRecord struct {
+	ID   string
+	Mode string
+	// Model are the models that migrated the packages, comma separated.
+	Model string
+	// PromptVersion is the promptVersion of the run.
+	PromptVersion string
+	StartedAt     time.Time
+	FinishedAt    time.Time
+	Packages      []packageRecord
+}
+
+// packageRecord is the result of a package within a run
+type packageRecord struct {
+	Name string
+	// Owner is the team owning the package, if known.
+	Owner    string
+	Status   string
+	Error    string
+	Usage    llmclient.Usage
+	Duration time.Duration
+	// Findings are the validation findings left after the migration.
+	Findings []string
+	// Model is the model that migrated the package, a fallback model after
+	// a failover.
+	Model string
+}
+
+// runModels names the models that migrated the packages of a run, in order
+func runModels(packages []packageRecord) string {
+	var models []string
+	for _, p := range packages {
+		if !slices.Contains(models, p.Model) {
+			models = append(models, p.Model)
+		}
+	}
+	return strings.Join(models, ",")
+}
+
+// openHistory opens, and creates if needed, the history database at path
+func openHistory(path string) (*historyStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create history schema: %w", err)
+	}
+	if err := addHistoryColumns(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to upgrade history schema: %w", err)
+	}
+	return &historyStore{db: db}, nil
+}
+
+// addHistoryColumns adds the columns missing from a database created by an
+// older version
+func addHistoryColumns(db *sql.DB) error {
+	for table, columns := range historyColumns {
+		rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+		if err != nil {
+			return err
+		}
+		existing := make(map[string]bool)
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return err
+			}
+			existing[name] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for name, def := range columns {
+			if existing[name] {
+				continue
+			}
+			if _, err := db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + name + ` ` + def); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (h *historyStore) Close() error {
+	return h.db.Close()
+}
+
+// recordRun stores a run and all its package results in one transaction
+func (h *historyStore) recordRun(run runRecord) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // Rollback after Commit is a no-op.
+
+	if _, err := tx.Exec(`INSERT INTO runs (id, mode, model, prompt_version, started_at, finished_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		run.ID, run.Mode, run.Model, run.PromptVersion, run.StartedAt.UTC(), run.FinishedAt.UTC()); err != nil {
+		return err
+	}
+	for _, p := range run.Packages {
+		if _, err := tx.Exec(`INSERT INTO package_results (run_id, package, owner, status, error, prompt_tokens, response_tokens, duration_ms, cost_usd) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			run.ID, p.Name, p.Owner, p.Status, redactSecrets(p.Error), p.Usage.PromptTokens, p.Usage.ResponseTokens, p.Duration.Milliseconds(),
+			p.Usage.CostUSD); err != nil {
+			return err
+		}
+		for _, f := range p.Findings {
+			if _, err := tx.Exec(`INSERT INTO findings (run_id, package, finding) VALUES (?, ?, ?)`, run.ID, p.Name, f); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// recordPackageRun stores the result of migrating a single package from the
+// command line
+func (h *historyStore) recordPackageRun(pkgPath string, started time.Time, result *migrateResponse, runErr error) error {
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+
+	name := pkgPath
+	if abs, err := filepath.Abs(pkgPath); err == nil {
+		name = filepath.Base(abs)
+	}
+	finished := time.Now()
+	pkg := packageRecord{Name: name, Status: statusSucceeded, Duration: finished.Sub(started), Model: modelName}
+	if m, err := readManifest(pkgPath); err == nil {
+		pkg.Owner = m.Owner.Github
+	}
+	// A failed migration still has the usage of the calls it made
+	if result != nil {
+		pkg.Usage = result.Usage
+	}
+	if runErr != nil {
+		pkg.Status = statusFailed
+		pkg.Error = runErr.Error()
+	} else {
+		pkg.Findings = result.Warnings
+		pkg.Model = backendModel(result.Backend)
+	}
+
+	return h.recordRun(runRecord{
+		ID:            id,
+		Mode:          "cli",
+		Model:         runModels([]packageRecord{pkg}),
+		PromptVersion: promptVersion(),
+		StartedAt:     started,
+		FinishedAt:    finished,
+		Packages:      []packageRecord{pkg},
+	})
+}
+
+// runSummary aggregates a run for the history report
+type runSummary struct {
+	ID            string
+	Mode          string
+	Model         string
+	PromptVersion string
+	StartedAt     time.Time
+	Packages      int
+	Succeeded     int
+	Failed        int
+	Findings      int
+	Usage         llmclient.Usage
+	CostUSD       float64
+}
+
+// runs returns the most recent runs, newest first
+func (h *historyStore) runs(limit int) ([]runSummary, error) {
+	rows, err := h.db.Query(`
+		SELECT r.id, r.mode, r.model, r.prompt_version, r.started_at,
+			COUNT(p.package),
+			COALESCE(SUM(p.status = 'succeeded'), 0),
+			COALESCE(SUM(p.status = 'failed'), 0),
+			(SELECT COUNT(*) FROM findings f WHERE f.run_id = r.id),
+			COALESCE(SUM(p.prompt_tokens), 0),
+			COALESCE(SUM(p.response_tokens), 0),
+			COALESCE(SUM(p.cost_usd), 0)
+		FROM runs r
+		LEFT JOIN package_results p ON p.run_id = r.id
+		GROUP BY r.id
+		ORDER BY r.started_at DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []runSummary
+	for rows.Next() {
+		var r runSummary
+		if err := rows.Scan(&r.ID, &r.Mode, &r.Model, &r.PromptVersion, &r.StartedAt, &r.Packages, &r.Succeeded, &r.Failed, &r.Findings,
+			&r.Usage.PromptTokens, &r.Usage.ResponseTokens, &r.CostUSD); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// packageHistoryEntry is the result of a package in one run
+type packageHistoryEntry struct {
+	RunID     string
+	StartedAt time.Time
+	Model     string
+	Status    string
+	Error     string
+	Usage     llmclient.Usage
+	CostUSD   float64
+	Duration  time.Duration
+	Findings  int
+}
+
+// packageHistory returns the results of a package across runs, newest first
+func (h *historyStore) packageHistory(name string, limit int) ([]packageHistoryEntry, error) {
+	rows, err := h.db.Query(`
+		SELECT r.id, r.started_at, r.model, p.status, p.error, p.prompt_tokens, p.response_tokens, p.cost_usd, p.duration_ms,
+			(SELECT COUNT(*) FROM findings f WHERE f.run_id = p.run_id AND f.package = p.package)
+		FROM package_results p
+		JOIN runs r ON r.id = p.run_id
+		WHERE p.package = ?
+		ORDER BY r.started_at DESC
+		LIMIT ?`, name, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []packageHistoryEntry
+	for rows.Next() {
+		var e packageHistoryEntry
+		var durationMS int64
+		if err := rows.Scan(&e.RunID, &e.StartedAt, &e.Model, &e.Status, &e.Error,
+			&e.Usage.PromptTokens, &e.Usage.ResponseTokens, &e.CostUSD, &durationMS, &e.Findings); err != nil {
+			return nil, err
+		}
+		e.Duration = time.Duration(durationMS) * time.Millisecond
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// ownerUsage is the spend attributed to a team
+type ownerUsage struct {
+	Owner    string
+	Packages int
+	Usage    llmclient.Usage
+	CostUSD  float64
+}
+
+// usageByOwner sums the usage of all recorded package results per owning
+// team since the given time, most expensive first
+func (h *historyStore) usageByOwner(since time.Time) ([]ownerUsage, error) {
+	rows, err := h.db.Query(`
+		SELECT p.owner, COUNT(DISTINCT p.package), SUM(p.prompt_tokens), SUM(p.response_tokens), SUM(p.cost_usd)
+		FROM package_results p
+		JOIN runs r ON r.id = p.run_id
+		WHERE r.started_at >= ?
+		GROUP BY p.owner
+		ORDER BY SUM(p.cost_usd) DESC`, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var owners []ownerUsage
+	for rows.Next() {
+		var o ownerUsage
+		if err := rows.Scan(&o.Owner, &o.Packages, &o.Usage.PromptTokens, &o.Usage.ResponseTokens, &o.CostUSD); err != nil {
+			return nil, err
+		}
+		owners = append(owners, o)
+	}
+	return owners, rows.Err()
+}
+
+// promptVersionSummary aggregates the package results of the runs made with
+// a prompt version
+type promptVersionSummary struct {
+	PromptVersion string
+	FirstRun      time.Time
+	LastRun       time.Time
+	Runs          int
+	Packages      int
+	Succeeded     int
+	Findings      int
+	Usage         llmclient.Usage
+	CostUSD       float64
+	Duration      time.Duration
+}
+
+// parseHistoryTime parses a timestamp returned by an aggregate function,
+// which the driver does not convert back to a time
+func parseHistoryTime(s string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", s)
+	if err != nil {
+		return t, fmt.Errorf("failed to parse history timestamp %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// promptVersions returns the prompt versions of the runs started since the
+// given time, oldest first. Runs recorded before prompt versions were
+// stored have an empty version.
+func (h *historyStore) promptVersions(since time.Time) ([]promptVersionSummary, error) {
+	rows, err := h.db.Query(`
+		SELECT r.prompt_version, MIN(r.started_at), MAX(r.started_at), COUNT(DISTINCT r.id),
+			COUNT(p.package),
+			COALESCE(SUM(p.status = 'succeeded'), 0),
+			(SELECT COUNT(*) FROM findings f JOIN runs fr ON fr.id = f.run_id
+				WHERE fr.prompt_version = r.prompt_version AND fr.started_at >= ?),
+			COALESCE(SUM(p.prompt_tokens), 0),
+			COALESCE(SUM(p.response_tokens), 0),
+			COALESCE(SUM(p.cost_usd), 0),
+			COALESCE(SUM(p.duration_ms), 0)
+		FROM runs r
+		LEFT JOIN package_results p ON p.run_id = r.id
+		WHERE r.started_at >= ?
+		GROUP BY r.prompt_version
+		ORDER BY MIN(r.started_at)`, since.UTC(), since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []promptVersionSummary
+	for rows.Next() {
+		var v promptVersionSummary
+		var first, last string
+		var durationMS int64
+		if err := rows.Scan(&v.PromptVersion, &first, &last, &v.Runs, &v.Packages, &v.Succeeded, &v.Findings,
+			&v.Usage.PromptTokens, &v.Usage.ResponseTokens, &v.CostUSD, &durationMS); err != nil {
+			return nil, err
+		}
+		if v.FirstRun, err = parseHistoryTime(first); err != nil {
+			return nil, err
+		}
+		if v.LastRun, err = parseHistoryTime(last); err != nil {
+			return nil, err
+		}
+		v.Duration = time.Duration(durationMS) * time.Millisecond
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
@@ -0,0 +1,123 @@
+package doctools
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// generatedReadmePattern matches markdown files that elastic-package renders
+// into a package's docs directory, capturing the package directory
+func generatedReadmePattern() *regexp.Regexp {
+	return regexp.MustCompile(`^(?:(.*)/)?` + regexp.QuoteMeta(path.Dir(sourceReadmeRel)) + `/[^/]+\.md$`)
+}
+
+// stagedFiles returns the files staged for commit in the git repository
+// containing dir, relative to the repository root
+func stagedFiles(dir string) (root string, files []string, err error) {
+	out, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", nil, err
+	}
+	root = strings.TrimSpace(out)
+
+	out, err = gitOutput(root, "diff", "--cached", "--name-only", "--diff-filter=ACMR")
+	if err != nil {
+		return "", nil, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return root, files, nil
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// checkStagedDocs looks for changes to generated docs under a package's
+// docs/ directory that have no matching staged change to the sources under
+// _dev/build/docs. It returns one message per offending file. No network
+// calls are made so it is cheap enough to run as a pre-commit hook.
+func checkStagedDocs(root string, files, stagedPaths []string) []string {
+	staged := make(map[string]bool, len(stagedPaths))
+	for _, f := range stagedPaths {
+		staged[filepath.ToSlash(f)] = true
+	}
+
+	pattern := generatedReadmePattern()
+	var findings []string
+	for _, f := range files {
+		f = filepath.ToSlash(f)
+		m := pattern.FindStringSubmatch(f)
+		if m == nil {
+			continue
+		}
+		pkgDir := m[1]
+
+		// Only look at directories that are actually packages.
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(pkgDir), "manifest.yml")); err != nil {
+			continue
+		}
+
+		sourceDir := path.Join(pkgDir, path.Dir(targetReadmeRel))
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(sourceDir))); os.IsNotExist(err) {
+			findings = append(findings, fmt.Sprintf(
+				"%s: package has not been migrated to the docs template; run 'docs-template-update -path %s' and edit %s instead",
+				f, displayPath(pkgDir), sourceDir))
+			continue
+		}
+
+		sourceChanged := false
+		for s := range staged {
+			if strings.HasPrefix(s, sourceDir+"/") {
+				sourceChanged = true
+				break
+			}
+		}
+		if !sourceChanged {
+			findings = append(findings, fmt.Sprintf(
+				"%s: generated file changed without a change under %s; edit the template there and run 'elastic-package build' to regenerate it",
+				f, sourceDir))
+		}
+	}
+	return findings
+}
+
+// displayPath renders a repository relative directory for use in messages
+func displayPath(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
+
+// runHook implements the pre-commit hook mode. When files are passed on the
+// command line (as done by the pre-commit framework) only those are
+// inspected, otherwise every staged file is.
+func runHook(dir string, args []string) ([]string, error) {
+	root, staged, err := stagedFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list staged files: %w", err)
+	}
+	files := staged
+	if len(args) > 0 {
+		files = args
+	}
+	return checkStagedDocs(root, files, staged), nil
+}
@@ -0,0 +1,199 @@
+package doctools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// jiraConfig is the jira section of the -config file, where the follow-up
+// tickets of migrated packages are created
+type jiraConfig struct {
+	// URL is the base URL of the Jira site, e.g. https://elastic.atlassian.net.
+	URL     string `yaml:"url"`
+	Project string `yaml:"project"`
+	// IssueType defaults to Task.
+	IssueType string   `yaml:"issue_type"`
+	Labels    []string `yaml:"labels"`
+}
+
+// validate checks the settings of the jira section
+func (c *jiraConfig) validate() error {
+	if c.URL == "" || c.Project == "" {
+		return errors.New("jira needs a url and a project")
+	}
+	if os.Getenv("JIRA_TOKEN") == "" {
+		return errors.New("jira needs an API token in JIRA_TOKEN")
+	}
+	if c.IssueType == "" {
+		c.IssueType = "Task"
+	}
+	return nil
+}
+
+// jiraClient is a minimal Jira REST API client covering ticket creation
+type jiraClient struct {
+	config *jiraConfig
+	http   *http.Client
+}
+
+func newJiraClient(config *jiraConfig) *jiraClient {
+	return &jiraClient{
+		config: config,
+		http:   &http.Client{Timeout: 30 * time.Second, Transport: outboundTransport},
+	}
+}
+
+// do sends a request to the Jira API and decodes the JSON response into out.
+// Jira Cloud authenticates with the JIRA_USER email and the JIRA_TOKEN API
+// token, Jira Data Center with JIRA_TOKEN as personal access token.
+func (c *jiraClient) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.config.URL, "/")+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if user := os.Getenv("JIRA_USER"); user != "" {
+		req.SetBasicAuth(user, os.Getenv("JIRA_TOKEN"))
+	} else {
+		req.Header.Set("Authorization", "Bearer "+os.Getenv("JIRA_TOKEN"))
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// browseURL returns the URL of a ticket
+func (c *jiraClient) browseURL(key string) string {
+	return strings.TrimSuffix(c.config.URL, "/") + "/browse/" + key
+}
+
+// findOpenTicket returns the key of an unresolved ticket of the project with
+// exactly the given summary, or an empty string
+func (c *jiraClient) findOpenTicket(ctx context.Context, summary string) (string, error) {
+	jql := fmt.Sprintf("project = %q AND summary ~ %q AND statusCategory != Done", c.config.Project, `"`+summary+`"`)
+	var result struct {
+		Issues []struct {
+			Key    string `json:"key"`
+			Fields struct {
+				Summary string `json:"summary"`
+			} `json:"fields"`
+		} `json:"issues"`
+	}
+	body := map[string]any{"jql": jql, "fields": []string{"summary"}, "maxResults": 10}
+	if err := c.do(ctx, http.MethodPost, "/rest/api/2/search", body, &result); err != nil {
+		return "", err
+	}
+	for _, issue := range result.Issues {
+		if issue.Fields.Summary == summary {
+			return issue.Key, nil
+		}
+	}
+	return "", nil
+}
+
+// createTicket creates a ticket in the project and returns its key
+func (c *jiraClient) createTicket(ctx context.Context, summary, description string) (string, error) {
+	fields := map[string]any{
+		"project":     map[string]string{"key": c.config.Project},
+		"issuetype":   map[string]string{"name": c.config.IssueType},
+		"summary":     summary,
+		"description": description,
+	}
+	if len(c.config.Labels) > 0 {
+		fields["labels"] = c.config.Labels
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/rest/api/2/issue", map[string]any{"fields": fields}, &created); err != nil {
+		return "", err
+	}
+	return created.Key, nil
+}
+
+// jiraDescription describes the TODOs of a migrated readme in Jira wiki
+// markup
+func jiraDescription(pkgName string, todos []todo, owners []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The readme of the {{%s}} package was migrated to the docs template. The migration left these notes for manual follow-up:\n", pkgName)
+	for _, kind := range []string{todoGuidance, todoRemoval} {
+		title := "Sections to write"
+		if kind == todoRemoval {
+			title = "Content flagged for removal"
+		}
+		header := false
+		for _, t := range todos {
+			if t.Kind != kind {
+				continue
+			}
+			if !header {
+				fmt.Fprintf(&b, "\nh3. %s\n", title)
+				header = true
+			}
+			fmt.Fprintf(&b, "* %s (line %d): %s\n", t.Section, t.Line, t.Text)
+		}
+	}
+	b.WriteString("\nRemove the comments from the readme once they are addressed.\n")
+	if len(owners) > 0 {
+		fmt.Fprintf(&b, "\nPackage owners: %s\n", strings.Join(owners, ", "))
+	}
+	return b.String()
+}
+
+// openFollowUpTicket creates a Jira ticket listing the TODOs of a migrated
+// package, with the jira settings of -config. Nothing is created when there
+// are no TODOs or an unresolved ticket for the package exists. It returns the
+// URL of the ticket.
+func openFollowUpTicket(ctx context.Context, pkgPath, pkgName string, todos []todo) (string, error) {
+	if config.Jira == nil || len(todos) == 0 {
+		return "", nil
+	}
+	jira := newJiraClient(config.Jira)
+	summary := fmt.Sprintf("[%s] Follow up on the docs template migration", pkgName)
+	key, err := jira.findOpenTicket(ctx, summary)
+	if err != nil {
+		return "", fmt.Errorf("failed to search follow-up tickets: %w", err)
+	}
+	if key != "" {
+		return jira.browseURL(key), nil
+	}
+
+	owners, err := packageCodeowners(pkgPath)
+	if err != nil {
+		return "", err
+	}
+	if key, err = jira.createTicket(ctx, summary, jiraDescription(pkgName, todos, owners)); err != nil {
+		return "", fmt.Errorf("failed to create follow-up ticket: %w", err)
+	}
+	return jira.browseURL(key), nil
+}
@@ -0,0 +1,672 @@
+package doctools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+// Job and package states reported by the jobs API
+const (
+	statusQueued    = "queued"
+	statusRunning   = "running"
+	statusSucceeded = "succeeded"
+	statusFailed    = "failed"
+	statusCompleted = "completed"
+	statusCancelled = "cancelled"
+)
+
+// jobPackage is a package submitted as part of a batch job
+type jobPackage struct {
+	Name string `json:"name"`
+	migrateRequest
+}
+
+// jobRequest is the body of a POST /v1/jobs request
+type jobRequest struct {
+	Packages []jobPackage `json:"packages"`
+}
+
+// Review decisions that can be recorded for a package
+const (
+	decisionAccepted = "accepted"
+	decisionRejected = "rejected"
+)
+
+// packageResult is the state of a single package in a job
+type packageResult struct {
+	Name     string    `json:"name"`
+	Status   string    `json:"status"`
+	Warnings []string  `json:"warnings,omitempty"`
+	Findings []finding `json:"findings,omitempty"`
+	// Edits is the edit log of -response-format operations.
+	Edits []editOperation `json:"edits,omitempty"`
+	// Backend is the provider and model that produced the readme.
+	Backend string `json:"backend,omitempty"`
+	// Candidates are the scores of the readmes of -candidates.
+	Candidates []candidateScore `json:"candidates,omitempty"`
+	Style      []styleAlert     `json:"style,omitempty"`
+	// Readability scores the overview and setup sections of the migrated
+	// readme.
+	Readability []sectionReadability `json:"readability,omitempty"`
+	// Gaps lists the template sections of the migrated readme that have no
+	// content.
+	Gaps []docsGap `json:"gaps,omitempty"`
+	// Todos lists the guidance and removal notes the LLM added to the
+	// migrated readme.
+	Todos []todo `json:"todos,omitempty"`
+	Error string `json:"error,omitempty"`
+	// Decision is the review outcome for the generated patch, empty until a
+	// reviewer accepted or rejected it. DecidedBy is the authenticated user
+	// who made it.
+	Decision  string          `json:"decision,omitempty"`
+	DecidedAt *time.Time      `json:"decided_at,omitempty"`
+	DecidedBy string          `json:"decided_by,omitempty"`
+	Usage     llmclient.Usage `json:"usage"`
+
+	markdown string
+	patch    string
+	duration time.Duration
+}
+
+// job is an asynchronous batch migration
+type job struct {
+	ID         string           `json:"id"`
+	Status     string           `json:"status"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	Packages   []*packageResult `json:"packages"`
+
+	requests []jobPackage
+	cancel   context.CancelFunc
+}
+
+// jobStore keeps track of the submitted jobs and runs them in the background
+type jobStore struct {
+	ctx context.Context
+	// history records finished jobs, if not nil.
+	history *historyStore
+	// queue hands packages out to remote workers instead of migrating them
+	// in this process, if not nil.
+	queue    *workQueue
+	leaseTTL time.Duration
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	// order keeps jobs in submission order for listing.
+	order []string
+}
+
+// newJobStore creates a job store whose jobs are cancelled when ctx is done.
+// Finished jobs are recorded in history unless it is nil.
+func newJobStore(ctx context.Context, history *historyStore) *jobStore {
+	return &jobStore{ctx: ctx, history: history, jobs: make(map[string]*job)}
+}
+
+// useQueue makes the store a coordinator that enqueues the packages of new
+// jobs in q for workers to lease for ttl. The jobs already in the queue are
+// restored.
+func (s *jobStore) useQueue(q *workQueue, ttl time.Duration) error {
+	jobs, err := q.load()
+	if err != nil {
+		return fmt.Errorf("failed to load queued jobs: %w", err)
+	}
+
+	s.mu.Lock()
+	s.queue, s.leaseTTL = q, ttl
+	for _, j := range jobs {
+		s.jobs[j.ID] = j
+		s.order = append(s.order, j.ID)
+	}
+	s.mu.Unlock()
+
+	// The coordinator may have stopped between the last package finishing
+	// and the job being marked as finished.
+	for _, j := range jobs {
+		s.finishIfDone(j)
+	}
+	return nil
+}
+
+// newID returns a random identifier for jobs and runs
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// submit validates and enqueues a job, then starts running it or leaves it
+// to the workers
+func (s *jobStore) submit(req jobRequest) (*job, error) {
+	if len(req.Packages) == 0 {
+		return nil, errors.New("at least one package is required")
+	}
+	seen := make(map[string]bool, len(req.Packages))
+	for i, p := range req.Packages {
+		if p.Name == "" {
+			return nil, fmt.Errorf("packages[%d]: name is required", i)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("packages[%d]: duplicate package name %q", i, p.Name)
+		}
+		seen[p.Name] = true
+		if p.Readme == "" {
+			return nil, fmt.Errorf("packages[%d]: readme is required", i)
+		}
+		if err := validateTarget(p.Target); err != nil {
+			return nil, fmt.Errorf("packages[%d]: %w", i, err)
+		}
+		if err := validatePackageType(p.PackageType); err != nil {
+			return nil, fmt.Errorf("packages[%d]: %w", i, err)
+		}
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate job id: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	j := &job{
+		ID:        id,
+		Status:    statusQueued,
+		CreatedAt: time.Now().UTC(),
+		requests:  req.Packages,
+		cancel:    cancel,
+	}
+	for _, p := range req.Packages {
+		j.Packages = append(j.Packages, &packageResult{Name: p.Name, Status: statusQueued})
+	}
+
+	if s.queue != nil {
+		if err := s.queue.enqueue(id, j.CreatedAt, req.Packages); err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to enqueue job: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.jobs[id] = j
+	s.order = append(s.order, id)
+	s.mu.Unlock()
+
+	if s.queue == nil {
+		go s.run(ctx, j)
+	}
+	return j, nil
+}
+
+// run migrates the packages of a job one after the other
+func (s *jobStore) run(ctx context.Context, j *job) {
+	defer j.cancel()
+
+	s.update(func() { j.Status = statusRunning })
+	for i, p := range j.requests {
+		result := j.Packages[i]
+		if ctx.Err() != nil {
+			s.update(func() { result.Status = statusCancelled })
+			continue
+		}
+
+		s.update(func() { result.Status = statusRunning })
+		started := time.Now()
+		resp, err := migrateContent(ctx, p.migrateRequest, nil)
+		s.update(func() {
+			result.duration = time.Since(started)
+			// Failed and cancelled packages still used tokens
+			if resp != nil {
+				result.Usage = resp.Usage
+			}
+			switch {
+			case err != nil && ctx.Err() != nil:
+				result.Status = statusCancelled
+			case err != nil:
+				result.Status = statusFailed
+				result.Error = redactSecrets(err.Error())
+			default:
+				result.Status = statusSucceeded
+				result.Warnings = resp.Warnings
+				result.Findings = resp.Findings
+				result.Edits = resp.Edits
+				result.Backend = resp.Backend
+				result.Candidates = resp.Candidates
+				result.Style = resp.Style
+				result.Readability = resp.Readability
+				result.Gaps = resp.Gaps
+				result.Todos = resp.Todos
+				result.markdown = resp.Markdown
+				result.patch = resp.Patch
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Job %s: error migrating %s: %v", j.ID, p.Name, err)
+		}
+		if debugRuntime {
+			logRuntimeStats(fmt.Sprintf("job %s package %s", j.ID, p.Name))
+		}
+	}
+
+	s.finish(j, ctx.Err() != nil)
+}
+
+// finish marks a job as done and records it in the history. Jobs that
+// already finished are left alone.
+func (s *jobStore) finish(j *job, cancelled bool) {
+	s.mu.Lock()
+	if j.FinishedAt != nil {
+		s.mu.Unlock()
+		return
+	}
+	now := time.Now().UTC()
+	j.FinishedAt = &now
+	if cancelled {
+		j.Status = statusCancelled
+	} else {
+		j.Status = statusCompleted
+	}
+	s.mu.Unlock()
+
+	if s.queue != nil {
+		if err := s.queue.finish(j.ID, *j.FinishedAt); err != nil {
+			log.Printf("Job %s: failed to mark as finished in the queue: %v", j.ID, err)
+		}
+	}
+	if s.history != nil {
+		if err := s.history.recordRun(s.runRecord(j)); err != nil {
+			log.Printf("Job %s: failed to record history: %v", j.ID, err)
+		}
+	}
+}
+
+// runRecord converts a finished job to its history representation
+func (s *jobStore) runRecord(j *job) runRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run := runRecord{
+		ID:            j.ID,
+		Mode:          "job",
+		PromptVersion: promptVersion(),
+		StartedAt:     j.CreatedAt,
+		FinishedAt:    *j.FinishedAt,
+	}
+	for _, p := range j.Packages {
+		pkg := packageRecord{
+			Name:     p.Name,
+			Status:   p.Status,
+			Error:    p.Error,
+			Usage:    p.Usage,
+			Duration: p.duration,
+			Findings: p.Warnings,
+			Model:    backendModel(p.Backend),
+		}
+		run.Packages = append(run.Packages, pkg)
+	}
+	run.Model = runModels(run.Packages)
+	return run
+}
+
+// update applies f while holding the store lock
+func (s *jobStore) update(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f()
+}
+
+// get returns a JSON encoded snapshot of a job
+func (s *jobStore) get(id string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	data, err := json.Marshal(j)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// list returns a JSON encoded snapshot of all jobs in submission order
+func (s *jobStore) list() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]*job, 0, len(s.order))
+	for _, id := range s.order {
+		jobs = append(jobs, s.jobs[id])
+	}
+	return json.Marshal(struct {
+		Jobs []*job `json:"jobs"`
+	}{Jobs: jobs})
+}
+
+// packageOutput returns the patch or markdown produced for a package
+func (s *jobStore) packageOutput(id, name string, markdown bool) (string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return "job not found", http.StatusNotFound
+	}
+	for _, p := range j.Packages {
+		if p.Name != name {
+			continue
+		}
+		if p.Status != statusSucceeded {
+			return fmt.Sprintf("package %s is %s", name, p.Status), http.StatusConflict
+		}
+		if markdown {
+			return p.markdown, http.StatusOK
+		}
+		return p.patch, http.StatusOK
+	}
+	return "package not found", http.StatusNotFound
+}
+
+// snapshot returns a copy of a job, including the generated output of its
+// packages, that is safe to use without holding the lock
+func (s *jobStore) snapshot(id string) (*job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return copyJob(j), true
+}
+
+// snapshots returns copies of all jobs in submission order
+func (s *jobStore) snapshots() []*job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]*job, 0, len(s.order))
+	for _, id := range s.order {
+		jobs = append(jobs, copyJob(s.jobs[id]))
+	}
+	return jobs
+}
+
+func copyJob(j *job) *job {
+	c := *j
+	c.Packages = make([]*packageResult, len(j.Packages))
+	for i, p := range j.Packages {
+		pc := *p
+		c.Packages[i] = &pc
+	}
+	return &c
+}
+
+// decide records the review decision of reviewer for a package of a job
+func (s *jobStore) decide(id, name, reviewer, decision string) (int, error) {
+	if decision != decisionAccepted && decision != decisionRejected {
+		return http.StatusBadRequest, fmt.Errorf("invalid decision %q, must be %q or %q", decision, decisionAccepted, decisionRejected)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return http.StatusNotFound, errors.New("job not found")
+	}
+	for _, p := range j.Packages {
+		if p.Name != name {
+			continue
+		}
+		if p.Status != statusSucceeded {
+			return http.StatusConflict, fmt.Errorf("package %s is %s", name, p.Status)
+		}
+		now := time.Now().UTC()
+		p.Decision = decision
+		p.DecidedAt = &now
+		p.DecidedBy = reviewer
+		return http.StatusOK, nil
+	}
+	return http.StatusNotFound, errors.New("package not found")
+}
+
+// cancelJob stops a job. Packages that already finished keep their results.
+// Packages leased by workers are left to finish.
+func (s *jobStore) cancelJob(id string) (bool, error) {
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	s.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	j.cancel()
+
+	if s.queue != nil {
+		if err := s.queue.cancel(id); err != nil {
+			return true, fmt.Errorf("failed to cancel queued packages: %w", err)
+		}
+		s.update(func() {
+			for _, p := range j.Packages {
+				if p.Status == statusQueued {
+					p.Status = statusCancelled
+				}
+			}
+		})
+		s.finishIfDone(j)
+	}
+	return true, nil
+}
+
+// finishIfDone finishes a queued job once none of its packages is waiting
+// for or leased to a worker
+func (s *jobStore) finishIfDone(j *job) {
+	s.mu.Lock()
+	done, cancelled := j.FinishedAt == nil, false
+	for _, p := range j.Packages {
+		switch p.Status {
+		case statusQueued, statusRunning:
+			done = false
+		case statusCancelled:
+			cancelled = true
+		}
+	}
+	s.mu.Unlock()
+
+	if done {
+		s.finish(j, cancelled)
+	}
+}
+
+// leaseWork hands the next queued package to a worker. It returns nil if
+// there is no work.
+func (s *jobStore) leaseWork(worker string) (*workItem, error) {
+	failed, err := s.queue.expireLeases()
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire leases: %w", err)
+	}
+	for _, ref := range failed {
+		log.Printf("Job %s: giving up on %s: %s", ref.JobID, ref.Package, errLeaseExpired)
+		s.updatePackage(ref.JobID, ref.Package, func(p *packageResult) {
+			p.Status = statusFailed
+			p.Error = errLeaseExpired
+		})
+	}
+
+	item, err := s.queue.lease(worker, s.leaseTTL)
+	if err != nil || item == nil {
+		return nil, err
+	}
+	if verbose {
+		log.Printf("Job %s: leased %s to %s", item.JobID, item.Package, worker)
+	}
+	s.update(func() {
+		if j, ok := s.jobs[item.JobID]; ok {
+			j.Status = statusRunning
+		}
+	})
+	s.updatePackage(item.JobID, item.Package, func(p *packageResult) { p.Status = statusRunning })
+	return item, nil
+}
+
+// completeWork stores the result a worker reported for a leased package
+func (s *jobStore) completeWork(leaseID string, res workResult) error {
+	if res.Error == "" && res.Result == nil {
+		return errors.New("either result or error is required")
+	}
+	res.Error = redactSecrets(res.Error)
+
+	jobID, name, err := s.queue.complete(leaseID, res)
+	if err != nil {
+		return err
+	}
+	if res.Error != "" {
+		log.Printf("Job %s: error migrating %s: %s", jobID, name, res.Error)
+	}
+	s.updatePackage(jobID, name, func(p *packageResult) {
+		p.duration = time.Duration(res.DurationMS) * time.Millisecond
+		if res.Result != nil {
+			p.Usage = res.Result.Usage
+		}
+		if res.Error != "" {
+			p.Status = statusFailed
+			p.Error = res.Error
+			return
+		}
+		p.Status = statusSucceeded
+		p.Warnings = res.Result.Warnings
+		p.Findings = res.Result.Findings
+		p.Edits = res.Result.Edits
+		p.Backend = res.Result.Backend
+		p.Candidates = res.Result.Candidates
+		p.Style = res.Result.Style
+		p.Readability = res.Result.Readability
+		p.Gaps = res.Result.Gaps
+		p.Todos = res.Result.Todos
+		p.markdown = res.Result.Markdown
+		p.patch = res.Result.Patch
+	})
+	return nil
+}
+
+// updatePackage applies f to a package of a job while holding the store
+// lock, then finishes the job if it was the last outstanding package
+func (s *jobStore) updatePackage(jobID, name string, f func(p *packageResult)) {
+	s.mu.Lock()
+	j, ok := s.jobs[jobID]
+	if ok {
+		for _, p := range j.Packages {
+			if p.Name == name {
+				f(p)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if ok {
+		s.finishIfDone(j)
+	}
+}
+
+// registerJobRoutes adds the batch job endpoints to mux. Decisions are
+// recorded with the user auth authenticated.
+func registerJobRoutes(mux *http.ServeMux, store *jobStore, auth *apiAuth) {
+	mux.HandleFunc("POST /v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		var req jobRequest
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJobRequestBytes))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+			return
+		}
+
+		j, err := store.submit(req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		data, _ := store.get(j.ID)
+		w.Header().Set("Location", "/v1/jobs/"+j.ID)
+		writeRawJSON(w, http.StatusAccepted, data)
+	})
+
+	mux.HandleFunc("GET /v1/jobs", func(w http.ResponseWriter, _ *http.Request) {
+		data, err := store.list()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+			return
+		}
+		writeRawJSON(w, http.StatusOK, data)
+	})
+
+	mux.HandleFunc("GET /v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := store.get(r.PathValue("id"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: "job not found"})
+			return
+		}
+		writeRawJSON(w, http.StatusOK, data)
+	})
+
+	mux.HandleFunc("POST /v1/jobs/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		ok, err := store.cancelJob(r.PathValue("id"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: "job not found"})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+			return
+		}
+		data, _ := store.get(r.PathValue("id"))
+		writeRawJSON(w, http.StatusAccepted, data)
+	})
+
+	packageOutput := func(markdown bool, contentType string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			out, status := store.packageOutput(r.PathValue("id"), r.PathValue("name"), markdown)
+			if status != http.StatusOK {
+				writeJSON(w, status, errorResponse{Error: out})
+				return
+			}
+			w.Header().Set("Content-Type", contentType)
+			_, _ = w.Write([]byte(out))
+		}
+	}
+	mux.HandleFunc("POST /v1/jobs/{id}/packages/{name}/decision", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Decision string `json:"decision"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+			return
+		}
+		reviewer, _ := auth.identity(r)
+		if status, err := store.decide(r.PathValue("id"), r.PathValue("name"), reviewer, req.Decision); err != nil {
+			writeJSON(w, status, errorResponse{Error: err.Error()})
+			return
+		}
+		data, _ := store.get(r.PathValue("id"))
+		writeRawJSON(w, http.StatusOK, data)
+	})
+
+	mux.HandleFunc("GET /v1/jobs/{id}/packages/{name}/patch", packageOutput(false, "text/x-diff; charset=utf-8"))
+	mux.HandleFunc("GET /v1/jobs/{id}/packages/{name}/markdown", packageOutput(true, "text/markdown; charset=utf-8"))
+}
+
+func writeRawJSON(w http.ResponseWriter, status int, data []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(data, '\n'))
+}
@@ -0,0 +1,82 @@
+package doctools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// licenseCommentPattern matches an HTML comment at the top of a readme,
+	// a license header if it mentions a license or copyright
+	licenseCommentPattern = regexp.MustCompile(`^<!--[\s\S]*?-->`)
+	licenseTextPattern    = regexp.MustCompile(`(?i)copyright|licen[cs]e|spdx-license-identifier|©`)
+	// licenseLinesPattern matches the lines at the top of a readme starting
+	// with a copyright notice or an SPDX identifier
+	licenseLinesPattern = regexp.MustCompile(`^(?i:(?:copyright\b|©|\(c\)|spdx-license-identifier:)[^\n]*(?:\n|$))+`)
+)
+
+// licenseHeader returns the license or copyright header at the top of a
+// readme, without the blank lines around it, or an empty string if it has
+// none. A header is an HTML comment mentioning a license or copyright, or
+// lines of copyright notices and SPDX identifiers.
+func licenseHeader(content string) string {
+	content = strings.TrimLeft(content, " \t\n")
+	if m := licenseCommentPattern.FindString(content); m != "" && licenseTextPattern.MatchString(m) {
+		return m
+	}
+	return strings.TrimRight(licenseLinesPattern.FindString(content), "\n")
+}
+
+// stripLicenseHeader removes the license header from the top of a readme, so
+// the LLM never sees it
+func stripLicenseHeader(content, header string) string {
+	rest, _ := strings.CutPrefix(strings.TrimLeft(content, " \t\n"), header)
+	return strings.TrimLeft(rest, " \t\n")
+}
+
+// applyLicenseHeader puts header, as it is, at the top of a migrated readme,
+// or right after the frontmatter of a docs-builder page. A header the LLM
+// wrote at the top in its place is replaced.
+func applyLicenseHeader(content, header string) string {
+	if header == "" {
+		return content
+	}
+	prefix := ""
+	if body, frontmatter, ok := splitFrontmatter(content); ok {
+		prefix, content = "---\n"+frontmatter+"\n---\n", body
+	}
+	if got := licenseHeader(content); got != "" {
+		content = stripLicenseHeader(content, got)
+	} else {
+		content = strings.Replace(content, header, "", 1)
+	}
+	return prefix + header + "\n\n" + strings.TrimLeft(content, " \t\n")
+}
+
+// licenseHeaderFindings reports a migrated readme whose license header is not
+// exactly header, with the first position where they differ
+func licenseHeaderFindings(content, header string) []string {
+	if header == "" {
+		return nil
+	}
+	if body, _, ok := splitFrontmatter(content); ok {
+		content = body
+	}
+	got := licenseHeader(content)
+	if got == header {
+		return nil
+	}
+	if got == "" {
+		return []string{"license header of the original readme is missing"}
+	}
+	line, col := 1, 1
+	for i := 0; i < len(got) && i < len(header) && got[i] == header[i]; i++ {
+		if got[i] == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	return []string{fmt.Sprintf("license header differs from the original readme at line %d, column %d", line, col)}
+}
@@ -0,0 +1,204 @@
+package doctools
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// linksTableFile is the file of the integrations repository that
+	// elastic-package reads the link keys of {{url}} placeholders from
+	linksTableFile = "links_table.yml"
+	// linksFileEnv is the variable elastic-package reads the path of the
+	// links table from
+	linksFileEnv = "ELASTIC_PACKAGE_LINKS_FILE_PATH"
+)
+
+// linksPrompt is added to the prompt of a readme when the package has a
+// links table
+const linksPrompt = `
+
+Link to these Elastic docs with the url mustache placeholder of their key instead of the address, as {{ url "key" "link text" }}, or {{ url "key" }} for the bare address:
+%s`
+
+// linksFile is the links table selected with -links-file
+var linksFile string
+
+var (
+	// urlPlaceholderPattern matches {{url}} placeholders and captures their
+	// link key
+	urlPlaceholderPattern = regexp.MustCompile(`\{\{-?\s*url\s+"([^"]*)"(?:\s+"(?:[^"\\]|\\.)*")?\s*-?\}\}`)
+	// elasticLinkPattern matches the markdown links, autolinks and bare
+	// addresses of elastic.co pages. Only the address of links with a title
+	// or brackets in their caption is matched.
+	elasticLinkPattern = regexp.MustCompile(`(!?)\[([^\[\]\n]+)\]\((https?://(?:www\.)?elastic\.co/[^\s)]*)\)|<(https?://(?:www\.)?elastic\.co/[^\s>]*)>|https?://(?:www\.)?elastic\.co/[^\s)<>\]"'` + "`" + `]*[^\s)<>\]"'.,;:!?` + "`" + `]`)
+	// docsVersionPattern matches the version of a docs page address, pages
+	// of all versions match the link of the table
+	docsVersionPattern = regexp.MustCompile(`^(elastic\.co/guide/[a-z]{2}/[^/]+/)(?:current|master|main|\d+\.(?:\d+|x))/`)
+	// linkSkipPattern matches the spans whose links are not converted: code,
+	// placeholders and comments
+	linkSkipPattern = regexp.MustCompile("(?ms)^```.*?^```|`[^`\n]+`|\\{\\{.*?\\}\\}|<!--.*?-->")
+)
+
+// linksTable is the links_table.yml file of elastic-package
+type linksTable struct {
+	Links map[string]string `yaml:"links"`
+}
+
+// findLinksFile returns the links table of a package: -links-file, the file
+// of ELASTIC_PACKAGE_LINKS_FILE_PATH or the links_table.yml file of the
+// nearest parent directory of the package, up to the repository root. It
+// returns an empty path if there is none.
+func findLinksFile(pkgPath string) string {
+	if linksFile != "" {
+		return linksFile
+	}
+	if p := os.Getenv(linksFileEnv); p != "" {
+		return p
+	}
+	dir, err := filepath.Abs(pkgPath)
+	if err != nil {
+		return ""
+	}
+	for {
+		p := filepath.Join(dir, linksTableFile)
+		if _, err := pkgFS.Stat(p); err == nil {
+			return p
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// readLinks returns the link keys of the links table of a package, by key
+func readLinks(pkgPath string) (map[string]string, error) {
+	path := findLinksFile(pkgPath)
+	if path == "" {
+		return nil, nil
+	}
+	data, err := pkgFS.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read links table: %w", err)
+	}
+	var table linksTable
+	if err := yaml.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("failed to parse links table %s: %w", path, err)
+	}
+	if verbose {
+		log.Printf("Using %d links of %s", len(table.Links), path)
+	}
+	return table.Links, nil
+}
+
+// formatLinks formats the links table for the prompt
+func formatLinks(links map[string]string) string {
+	var b strings.Builder
+	for _, key := range sortedKeys(links) {
+		fmt.Fprintf(&b, "- %s: %s\n", key, links[key])
+	}
+	return b.String()
+}
+
+// sortedKeys returns the keys of links in order
+func sortedKeys(links map[string]string) []string {
+	keys := make([]string, 0, len(links))
+	for key := range links {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// linkKey returns the form of an address links are matched with: without
+// scheme, www, version of docs pages and trailing slash
+func linkKey(address string) string {
+	address = strings.TrimPrefix(strings.TrimPrefix(address, "https://"), "http://")
+	address = strings.TrimPrefix(address, "www.")
+	address = docsVersionPattern.ReplaceAllString(address, "${1}current/")
+	return strings.TrimSuffix(address, "/")
+}
+
+// applyLinkPlaceholders replaces the hard-coded elastic.co links of content
+// that are in the links table with {{url}} placeholders, so elastic-package
+// renders them with the address of the table. Links in code, placeholders
+// and comments are kept.
+func applyLinkPlaceholders(content string, links map[string]string) string {
+	if len(links) == 0 {
+		return content
+	}
+	keys := make(map[string]string, len(links))
+	// Sorted, so the first key wins when several have the same address
+	for _, key := range sortedKeys(links) {
+		if k := linkKey(links[key]); keys[k] == "" {
+			keys[k] = key
+		}
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, span := range linkSkipPattern.FindAllStringIndex(content, -1) {
+		b.WriteString(replaceElasticLinks(content[last:span[0]], keys))
+		b.WriteString(content[span[0]:span[1]])
+		last = span[1]
+	}
+	b.WriteString(replaceElasticLinks(content[last:], keys))
+	return b.String()
+}
+
+// replaceElasticLinks replaces the elastic.co links of text whose address
+// has a key with their placeholder
+func replaceElasticLinks(text string, keys map[string]string) string {
+	return elasticLinkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		m := elasticLinkPattern.FindStringSubmatch(link)
+		switch {
+		case m[1] == "!":
+			// Images are not links
+			return link
+		case m[3] != "" && !strings.Contains(m[2], "{{") && !strings.Contains(m[2], "}}"):
+			if key := keys[linkKey(m[3])]; key != "" {
+				return fmt.Sprintf("{{ url %s %s }}", strconv.Quote(key), strconv.Quote(m[2]))
+			}
+		case m[4] != "":
+			if key := keys[linkKey(m[4])]; key != "" {
+				return fmt.Sprintf("{{ url %s }}", strconv.Quote(key))
+			}
+		default:
+			if key := keys[linkKey(link)]; key != "" {
+				return fmt.Sprintf("{{ url %s }}", strconv.Quote(key))
+			}
+		}
+		return link
+	})
+}
+
+// linkFindings returns a finding for every {{url}} placeholder of content
+// whose key is not in the links table. Without a links table the keys are
+// not checked.
+func linkFindings(content string, links map[string]string) []string {
+	if len(links) == 0 {
+		return nil
+	}
+	var findings []string
+	seen := make(map[string]bool)
+	for _, m := range urlPlaceholderPattern.FindAllStringSubmatch(content, -1) {
+		if _, ok := links[m[1]]; !ok && !seen[m[1]] {
+			seen[m[1]] = true
+			findings = append(findings, fmt.Sprintf("url placeholder with unknown link key %q", m[1]))
+		}
+	}
+	return findings
+}
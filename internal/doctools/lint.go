@@ -0,0 +1,315 @@
+package doctools
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+const (
+	// lintURLConcurrency bounds the addresses -check-urls requests at the
+	// same time
+	lintURLConcurrency = 8
+	// lintURLTimeout bounds the request of a single address
+	lintURLTimeout = 15 * time.Second
+)
+
+// Output formats of readme-lint
+const (
+	lintFormatText   = "text"
+	lintFormatJSON   = "json"
+	lintFormatGitHub = "github"
+)
+
+// lintResult is the result of readme-lint for a package
+type lintResult struct {
+	Package  string    `json:"package"`
+	Path     string    `json:"path"`
+	Readme   string    `json:"readme"`
+	Findings []finding `json:"findings"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// RunReadmeLint runs the readme-lint command with the arguments args
+func RunReadmeLint(args []string) {
+	fs := flag.NewFlagSet("readme-lint", flag.ExitOnError)
+	pkgPath := fs.String("path", "", "Path to the package to lint")
+	dir := fs.String("packages", "", "Directory of the packages to lint")
+	templateFile := fs.String("template", "", "Readme template to lint against instead of downloading the current one")
+	format := fs.String("format", lintFormatText, "Output format: text, json, or github for workflow annotations on the readmes")
+	checkURLs := fs.Bool("check-urls", false, "Also request the web addresses the readmes link to, and those of their url placeholders, and report those that fail")
+	fs.StringVar(&failOn, "fail-on", "", "Only exit with 1 for findings of this severity or above: info, warning or error")
+	fs.StringVar(&outputTarget, "target", targetReadme, "Readme to lint: readme for the elastic-package readme template, docs-v3 for the Elastic docs-builder page")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [options] [package ...]\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	registerVerbosityFlags(fs)
+	registerNetworkFlags(fs)
+	registerTimeoutFlags(fs)
+	registerGlossaryFlags(fs)
+	registerConfigFlags(fs)
+	registerReadabilityFlags(fs)
+	_ = fs.Parse(args)
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	pkgs := fs.Args()
+	if *pkgPath != "" {
+		pkgs = append(pkgs, *pkgPath)
+	}
+	if *dir != "" {
+		found, err := findPackages(*dir)
+		if err != nil {
+			log.Fatalf("Error listing packages: %v", err)
+		}
+		pkgs = append(pkgs, found...)
+	}
+	if len(pkgs) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *format != lintFormatText && *format != lintFormatJSON && *format != lintFormatGitHub {
+		log.Fatalf("Error: unknown -format %q, use text, json or github", *format)
+	}
+	if err := validateTarget(outputTarget); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateFailOn(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateTimeouts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadGlossary(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *templateFile != "" {
+		data, err := os.ReadFile(*templateFile)
+		if err != nil {
+			log.Fatalf("Error reading -template: %v", err)
+		}
+		cachedTemplate = string(data)
+	}
+
+	ctx := context.Background()
+	var results []lintResult
+	failed := false
+	for _, p := range pkgs {
+		r := lintPackage(ctx, p, *checkURLs)
+		results = append(results, r)
+		if r.Error != "" {
+			failed = true
+		} else if failOn == "" {
+			failed = failed || len(r.Findings) > 0
+		} else {
+			failed = failed || len(failingFindings(r.Findings)) > 0
+		}
+	}
+
+	switch *format {
+	case lintFormatJSON:
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Println(string(data))
+	case lintFormatGitHub:
+		for _, r := range results {
+			if r.Error != "" {
+				fmt.Printf("::error file=%s,title=readme-lint::%s\n", r.Readme, githubEscape(r.Error))
+			}
+			for _, f := range r.Findings {
+				fmt.Printf("::%s file=%s,title=readme-lint (%s)::%s\n", githubLevel(f.Severity), r.Readme, f.Source, githubEscape(f.Message))
+			}
+		}
+	default:
+		for _, r := range results {
+			if r.Error != "" {
+				fmt.Printf("%s: %s\n", r.Path, r.Error)
+			}
+			for _, f := range r.Findings {
+				fmt.Printf("%s: %s\n", r.Path, f)
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// githubLevel returns the workflow command of the annotation of a finding
+func githubLevel(severity string) string {
+	switch severity {
+	case severityError:
+		return "error"
+	case severityWarning:
+		return "warning"
+	}
+	return "notice"
+}
+
+// githubEscape escapes a message of a workflow command
+func githubEscape(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// lintPackage checks the readme of a package against the template as -check
+// does, and that its links resolve: the anchors of the readme, the files of
+// the package it links to relative to the docs directory, and with checkURLs
+// the web addresses it links to
+func lintPackage(ctx context.Context, pkgPath string, checkURLs bool) lintResult {
+	readme := targetReadmePath(pkgPath)
+	if outputTarget == targetDocsV3 {
+		readme = docsV3Path(pkgPath)
+	}
+	r := lintResult{Package: packageName(pkgPath), Path: pkgPath, Readme: filepath.ToSlash(readme), Findings: []finding{}}
+	warnings, err := checkPackage(ctx, pkgPath)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	if content, _, err := readMarkdown(readme); err == nil {
+		warnings = append(warnings, localLinkFindings(pkgPath, content)...)
+		if checkURLs {
+			links, err := readLinks(pkgPath)
+			if err != nil {
+				r.Error = err.Error()
+				return r
+			}
+			warnings = append(warnings, urlFindings(ctx, content, links)...)
+		}
+	}
+	r.Findings = classifyWarnings(warnings)
+	return r
+}
+
+// lintLinkTargets returns the targets of the markdown links and images of
+// content, outside code, placeholders and comments
+func lintLinkTargets(content string) []string {
+	content = linkSkipPattern.ReplaceAllString(content, "")
+	var targets []string
+	for _, m := range markdownLinkPattern.FindAllStringSubmatch(content, -1) {
+		if !slices.Contains(targets, m[1]) {
+			targets = append(targets, m[1])
+		}
+	}
+	return targets
+}
+
+// localLinkFindings returns a finding for every link of a readme to an
+// anchor no heading of it has, or to a file of the package that does not
+// exist. Relative links are resolved against the docs directory the readme
+// is rendered to, or the directory of the readme itself.
+func localLinkFindings(pkgPath, content string) []string {
+	anchors := markdown.Anchors(content)
+	var findings []string
+	for _, target := range lintLinkTargets(content) {
+		file, anchor, _ := strings.Cut(target, "#")
+		switch {
+		case file == "" && anchor != "":
+			if _, ok := anchors[strings.ToLower(anchor)]; !ok {
+				findings = append(findings, fmt.Sprintf("link to %s does not resolve, no heading of the readme has this anchor", target))
+			}
+		case file == "" || strings.Contains(file, ":") || strings.HasPrefix(file, "/"):
+			// Web addresses, mailto links and paths of the docs site
+		default:
+			dirs := []string{sourceDocsDir(pkgPath), filepath.Dir(targetReadmePath(pkgPath))}
+			found := slices.ContainsFunc(dirs, func(dir string) bool {
+				_, err := pkgFS.Stat(filepath.Join(dir, filepath.FromSlash(path.Clean(file))))
+				return err == nil
+			})
+			if !found {
+				findings = append(findings, fmt.Sprintf("link to %s does not resolve, the file is not in the package", target))
+			}
+		}
+	}
+	return findings
+}
+
+// urlFindings requests the web addresses a readme links to, with those of its
+// url placeholders in the links table, and returns a finding for those that
+// fail or answer with an error status. Rate limited requests are not
+// reported.
+func urlFindings(ctx context.Context, content string, links map[string]string) []string {
+	var urls []string
+	for _, target := range lintLinkTargets(content) {
+		if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+			urls = append(urls, target)
+		}
+	}
+	for _, m := range urlPlaceholderPattern.FindAllStringSubmatch(content, -1) {
+		if u, ok := links[m[1]]; ok && !slices.Contains(urls, u) {
+			urls = append(urls, u)
+		}
+	}
+
+	client := &http.Client{Transport: otelhttp.NewTransport(outboundTransport), Timeout: lintURLTimeout}
+	broken := make([]string, len(urls))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, lintURLConcurrency)
+	for i, u := range urls {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			broken[i] = checkURL(ctx, client, u)
+		}()
+	}
+	wg.Wait()
+
+	var findings []string
+	for i, reason := range broken {
+		if reason != "" {
+			findings = append(findings, fmt.Sprintf("link to %s does not resolve, %s", urls[i], reason))
+		}
+	}
+	return findings
+}
+
+// checkURL requests an address with HEAD, or GET for servers that do not
+// allow HEAD, and returns why it failed, or an empty string
+func checkURL(ctx context.Context, client *http.Client, u string) string {
+	var status int
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(ctx, method, u, nil)
+		if err != nil {
+			return err.Error()
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return redactSecrets(err.Error())
+		}
+		resp.Body.Close()
+		status = resp.StatusCode
+		if status != http.StatusMethodNotAllowed {
+			break
+		}
+	}
+	if status >= 400 && status != http.StatusTooManyRequests {
+		return fmt.Sprintf("the server answered %d %s", status, http.StatusText(status))
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package doctools
+
+import (
+	"errors"
+	"flag"
+	"log"
+)
+
+// Verbosity levels selected with -q, -v and -vv
+const (
+	verbosityQuiet   = -1
+	verbosityNormal  = 0
+	verbosityVerbose = 1
+	verbosityDebug   = 2
+)
+
+var (
+	// verbosity is the level selected with -q, -v or -vv
+	verbosity = verbosityNormal
+	// verbose reports -v or -vv
+	verbose bool
+
+	quietFlag   bool
+	verboseFlag bool
+	debugFlag   bool
+)
+
+// registerVerbosityFlags adds the -q, -v and -vv flags to fs, -verbose is
+// kept as a synonym of -v
+func registerVerbosityFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&quietFlag, "q", false, "Only log errors, so the standard output only holds the patch")
+	fs.BoolVar(&verboseFlag, "v", false, "Enable verbose logging")
+	fs.BoolVar(&verboseFlag, "verbose", false, "Same as -v")
+	fs.BoolVar(&debugFlag, "vv", false, "Enable debug logging: also the duration of every stage, the LLM calls and the available models")
+}
+
+// applyVerbosity sets the verbosity from the flags
+func applyVerbosity() error {
+	switch {
+	case quietFlag && (verboseFlag || debugFlag):
+		return errors.New("-q cannot be used with -v or -vv")
+	case quietFlag:
+		verbosity = verbosityQuiet
+	case debugFlag:
+		verbosity = verbosityDebug
+	case verboseFlag:
+		verbosity = verbosityVerbose
+	default:
+		verbosity = verbosityNormal
+	}
+	verbose = verbosity >= verbosityVerbose
+	return nil
+}
+
+// logInfo logs a message that is neither an error nor a failure, unless -q
+// was given
+func logInfo(format string, v ...any) {
+	if verbosity > verbosityQuiet {
+		log.Printf(format, v...)
+	}
+}
+
+// logDebug logs a message with -vv
+func logDebug(format string, v ...any) {
+	if verbosity >= verbosityDebug {
+		log.Printf(format, v...)
+	}
+}
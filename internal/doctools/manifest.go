@@ -0,0 +1,93 @@
+package doctools
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// packageManifest is the part of a package's manifest.yml the tool uses
+type packageManifest struct {
+	Name    string `yaml:"name"`
+	Title   string `yaml:"title"`
+	Type    string `yaml:"type"`
+	Version string `yaml:"version"`
+	Owner   struct {
+		Github string `yaml:"github"`
+	} `yaml:"owner"`
+	Conditions struct {
+		Elastic struct {
+			Subscription string `yaml:"subscription"`
+		} `yaml:"elastic"`
+	} `yaml:"conditions"`
+	Screenshots  []screenshot `yaml:"screenshots"`
+	packageSetup `yaml:",inline"`
+}
+
+// screenshot is an image of the package shown in the integrations UI
+type screenshot struct {
+	// Src is the path of the image in the package, e.g. /img/overview.png.
+	Src   string `yaml:"src" json:"src"`
+	Title string `yaml:"title" json:"title,omitempty"`
+}
+
+// packageSetup is how a package is configured when it is added to a policy
+type packageSetup struct {
+	// Vars are the package level settings, shared by all inputs.
+	Vars            []manifestVar    `yaml:"vars" json:"vars,omitempty"`
+	PolicyTemplates []policyTemplate `yaml:"policy_templates" json:"policy_templates,omitempty"`
+}
+
+// policyTemplate is an entry of policy_templates in a manifest
+type policyTemplate struct {
+	Name        string `yaml:"name" json:"name"`
+	Title       string `yaml:"title" json:"title,omitempty"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	// DataStreams are the data streams of the policy template, in the order
+	// the package documents them.
+	DataStreams []string      `yaml:"data_streams" json:"data_streams,omitempty"`
+	Inputs      []policyInput `yaml:"inputs" json:"inputs,omitempty"`
+	// Input and Vars are set instead of Inputs in input packages.
+	Input string        `yaml:"input" json:"input,omitempty"`
+	Vars  []manifestVar `yaml:"vars" json:"vars,omitempty"`
+}
+
+// policyInput is an input of a policy template
+type policyInput struct {
+	Type        string        `yaml:"type" json:"type"`
+	Title       string        `yaml:"title" json:"title,omitempty"`
+	Description string        `yaml:"description" json:"description,omitempty"`
+	Vars        []manifestVar `yaml:"vars" json:"vars,omitempty"`
+}
+
+// manifestVar is a setting of a package, policy template or input
+type manifestVar struct {
+	Name        string `yaml:"name" json:"name"`
+	Type        string `yaml:"type" json:"type,omitempty"`
+	Title       string `yaml:"title" json:"title,omitempty"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	Required    bool   `yaml:"required" json:"required,omitempty"`
+	ShowUser    bool   `yaml:"show_user" json:"show_user,omitempty"`
+	Multi       bool   `yaml:"multi" json:"multi,omitempty"`
+	Secret      bool   `yaml:"secret" json:"secret,omitempty"`
+	Default     any    `yaml:"default" json:"default,omitempty"`
+}
+
+// readManifest parses the manifest.yml of a package
+func readManifest(pkgPath string) (*packageManifest, error) {
+	data, err := pkgFS.ReadFile(filepath.Join(pkgPath, "manifest.yml"))
+	if err != nil {
+		return nil, err
+	}
+	return parseManifest(data)
+}
+
+// parseManifest parses the content of a manifest.yml
+func parseManifest(data []byte) (*packageManifest, error) {
+	var m packageManifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return &m, nil
+}
@@ -0,0 +1,63 @@
+package doctools
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// metricsNamespace prefixes all exported metrics
+const metricsNamespace = "docs_template_update"
+
+var (
+	llmRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "Latency of LLM generation requests.",
+		// Generating a whole readme takes from seconds to minutes.
+		Buckets: []float64{1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"model", "outcome"})
+
+	llmTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "llm_tokens_total",
+		Help:      "Tokens consumed by LLM requests, by direction (in or out).",
+	}, []string{"model", "direction"})
+
+	providerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "provider_errors_total",
+		Help:      "LLM requests that failed or returned no usable response.",
+	}, []string{"model"})
+
+	packagesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "packages_processed_total",
+		Help:      "Packages run through the migration pipeline, by status.",
+	}, []string{"status"})
+
+	packagesWithFindings = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "packages_validation_failed_total",
+		Help:      "Migrated packages whose result still has validation findings.",
+	})
+
+	validationFindings = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "validation_findings_total",
+		Help:      "Validation findings left in migrated packages.",
+	})
+)
+
+// observeMigration records the outcome of running a package through the
+// pipeline
+func observeMigration(resp *migrateResponse, err error) {
+	if err != nil {
+		packagesProcessed.WithLabelValues(statusFailed).Inc()
+		return
+	}
+	packagesProcessed.WithLabelValues(statusSucceeded).Inc()
+	if len(resp.Warnings) > 0 {
+		packagesWithFindings.Inc()
+		validationFindings.Add(float64(len(resp.Warnings)))
+	}
+}
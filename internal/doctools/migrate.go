@@ -0,0 +1,437 @@
+package doctools
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// migrateRequest is the body of a POST /v1/migrate request
+type migrateRequest struct {
+	// Readme is the markdown to restructure.
+	Readme string `json:"readme"`
+	// DataStreams are the package's data stream names, used to fill in the
+	// fields and event placeholders.
+	DataStreams []string `json:"data_streams,omitempty"`
+	// SampleEvents are the data streams that have a sample event. Defaults
+	// to all data streams.
+	SampleEvents []string `json:"sample_events,omitempty"`
+	// DataStreamTypes are the types of the data streams, such as logs or
+	// metrics, by name, for -data-stream-order and -data-stream-groups.
+	DataStreamTypes map[string]string `json:"data_stream_types,omitempty"`
+	// Target selects the output format, readme (the default) or docs-v3.
+	Target string `json:"target,omitempty"`
+	// PackageType is the type of the package, integration (the default),
+	// input or content.
+	PackageType string `json:"package_type,omitempty"`
+	// SampleEvent reports whether an input package ships a sample event.
+	SampleEvent bool `json:"sample_event,omitempty"`
+	// KeepDataStreams are data streams left out of DataStreams whose
+	// Reference sections are kept as they are in the readme.
+	KeepDataStreams []string `json:"keep_data_streams,omitempty"`
+	// Setup are the settings of the package from its manifest, used to
+	// write the Configuration settings section.
+	Setup *packageSetup `json:"setup,omitempty"`
+	// Collection describes how the package collects data, from its agent
+	// templates, for the How it works section.
+	Collection []streamCollection `json:"collection,omitempty"`
+	// KnownIssues are the known issues of the package, from its known
+	// issues file or GitHub, used to write the Troubleshooting section.
+	KnownIssues string `json:"known_issues,omitempty"`
+	// ECSFields are the ECS fields the readme mentions, described to the
+	// LLM. Filled in from -ecs-schema when not given.
+	ECSFields []ecsField `json:"ecs_fields,omitempty"`
+	// Assets are the kibana assets of the package, used to write its
+	// Dashboards section.
+	Assets []kibanaAsset `json:"assets,omitempty"`
+	// Screenshots are the screenshots of the manifest found in img/, shown
+	// in the Dashboards section.
+	Screenshots []screenshot `json:"screenshots,omitempty"`
+	// Images are the images of the img/ directory of the package, such as
+	// /img/overview.png, the images the readme shows are checked against.
+	Images []string `json:"images,omitempty"`
+	// StyleExamples are overview and setup sections of the migrated readmes
+	// of other packages, given to the LLM as examples of style. Looked up in
+	// -style-index when not given.
+	StyleExamples []styleExample `json:"style_examples,omitempty"`
+	// DocsPages are the other pages of the package docs, relative to the
+	// readme, that the readme may link to.
+	DocsPages []string `json:"docs_pages,omitempty"`
+	// Links are the link keys of the elastic-package links table, by key.
+	// Elastic docs links with a key are written as url placeholders.
+	Links map[string]string `json:"links,omitempty"`
+	// Version and Subscription are the version and subscription level of
+	// the package, from its manifest, for -regenerate-badges.
+	Version      string `json:"version,omitempty"`
+	Subscription string `json:"subscription,omitempty"`
+	// LicenseHeader is the license or copyright header of the readme. It is
+	// taken out of the readme before the LLM pass and put back as it is.
+	// Detected at the top of the readme when not given.
+	LicenseHeader string `json:"license_header,omitempty"`
+	// Section is the heading of the only template section to regenerate in
+	// an already migrated readme, the rest of the readme is kept as it is.
+	Section string `json:"section,omitempty"`
+
+	// sinceDataStreams are the data streams changed since -since, only
+	// their Reference sections are regenerated in an already migrated
+	// readme
+	sinceDataStreams []string
+	// name is the name of the package, its own sections are not style
+	// examples
+	name string
+	// manifestScreenshots are all the screenshots of the manifest, including
+	// those whose image is missing
+	manifestScreenshots []screenshot
+	// original is the readme as read from the package, when it was changed
+	// before the migration. The patch applies to it.
+	original string
+	// encoding is how the readme was encoded in the package, the patch and
+	// the written readme keep its line endings.
+	encoding textEncoding
+}
+
+// migrateResponse is the body of a successful POST /v1/migrate response
+type migrateResponse struct {
+	Markdown string          `json:"markdown"`
+	Patch    string          `json:"patch"`
+	Warnings []string        `json:"warnings"`
+	Usage    llmclient.Usage `json:"usage"`
+	// Backend is the provider and model that produced the readme, a
+	// fallback model after a failover.
+	Backend string `json:"backend,omitempty"`
+	// Findings are the warnings, style alerts, docs gaps and TODOs
+	// classified by severity.
+	Findings []finding `json:"findings,omitempty"`
+	// Edits is the edit log of -response-format operations, the operations
+	// of the LLM on the original readme in order.
+	Edits []editOperation `json:"edits,omitempty"`
+	// Consolidated lists the files of the package docs/ directory that were
+	// merged into the readme, only set for packages migrated on disk.
+	Consolidated []string `json:"consolidated,omitempty"`
+	// FollowUpIssue is the URL of the issue opened for the TODOs with
+	// -issues-repo, only set for packages migrated on disk.
+	FollowUpIssue string `json:"follow_up_issue,omitempty"`
+	// FollowUpTicket is the URL of the Jira ticket created for the TODOs
+	// with the jira settings of -config, only set for packages migrated on
+	// disk.
+	FollowUpTicket string `json:"follow_up_ticket,omitempty"`
+	// Style lists the style issues of the migrated readme, with -vale.
+	Style []styleAlert `json:"style,omitempty"`
+	// Readability scores the overview and setup sections of the migrated
+	// readme.
+	Readability []sectionReadability `json:"readability,omitempty"`
+	// Gaps lists the template sections of the migrated readme that have no
+	// content.
+	Gaps []docsGap `json:"gaps,omitempty"`
+	// Todos lists the guidance and removal notes the LLM added to the
+	// migrated readme.
+	Todos []todo `json:"todos,omitempty"`
+	// Preserved lists the sections of a partially migrated readme that
+	// already followed the template and were kept as they were.
+	Preserved []string `json:"preserved,omitempty"`
+	// Timings are the durations of the stages of the migration.
+	Timings []stageTiming `json:"timings,omitempty"`
+	// Candidates are the scores of the readmes of -candidates, the one
+	// kept is selected.
+	Candidates []candidateScore `json:"candidates,omitempty"`
+}
+
+// Names of the migration stages reported to progress callbacks
+const (
+	stageFetchTemplate     = "fetch-template"
+	stageGenerate          = "generate"
+	stageApplyPlaceholders = "apply-placeholders"
+	stageDiff              = "diff"
+	stageValidate          = "validate"
+)
+
+// migrateContent runs the migration on readme content without touching the
+// filesystem, within -package-timeout. If progress is not nil it is called
+// as each stage starts.
+func migrateContent(ctx context.Context, req migrateRequest, progress func(stage string)) (*migrateResponse, error) {
+	ctx, cancel := withTimeout(ctx, packageTimeout, "package-timeout")
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "migrate", trace.WithAttributes(
+		attribute.Int("readme.bytes", len(req.Readme)),
+		attribute.Int("data_streams", len(req.DataStreams)),
+	))
+	defer span.End()
+
+	resp, err := runCandidates(ctx, req, progress)
+	err = timeoutError(ctx, err)
+	observeMigration(resp, err)
+	if err != nil {
+		failSpan(span, err)
+	} else {
+		span.SetAttributes(attribute.Int("validation.findings", len(resp.Warnings)))
+	}
+	return resp, err
+}
+
+// runPipeline runs the migration stages for migrateContent. When a stage
+// fails it returns the response so far with the error, for the usage of the
+// LLM calls already made.
+func runPipeline(ctx context.Context, req migrateRequest, progress func(stage string)) (*migrateResponse, error) {
+	// Readmes are compared and patched in NFC with Unix line endings
+	req.Readme, req.original = normalizeText(req.Readme), normalizeText(req.original)
+	if header := licenseHeader(req.Readme); header != "" {
+		// The header bypasses the LLM, the patch still applies to the readme
+		// with it
+		if req.original == "" {
+			req.original = req.Readme
+		}
+		req.Readme = stripLicenseHeader(req.Readme, header)
+		if req.LicenseHeader == "" {
+			req.LicenseHeader = header
+		}
+	}
+	if fixed := applyRules(req.Readme, ruleBefore); fixed != req.Readme {
+		// The patch still applies to the readme as it was
+		if req.original == "" {
+			req.original = req.Readme
+		}
+		req.Readme = fixed
+	}
+	s := &pipelineState{req: req, resp: &migrateResponse{Markdown: req.Readme}}
+	timings, err := runStages(ctx, migrationStages(req.Target), s, progress)
+	if err != nil {
+		return s.resp, err
+	}
+	if s.resp.Warnings == nil {
+		s.resp.Warnings = []string{}
+	}
+	s.resp.Timings = timings
+	return s.resp, nil
+}
+
+// migrationStages returns the stages migrating a readme to target
+func migrationStages(target string) []stage {
+	generate := generateStage
+	if target == targetDocsV3 {
+		generate = generateDocsV3Stage
+	}
+	return []stage{
+		{stageFetchTemplate, fetchTemplateStage},
+		{stageGenerate, generate},
+		{stageApplyPlaceholders, applyPlaceholdersStage},
+		{stageValidate, validateStage},
+		{stageDiff, diffStage},
+	}
+}
+
+// fetchTemplateStage downloads the template and adapts it to the package
+func fetchTemplateStage(ctx context.Context, s *pipelineState) error {
+	template, err := fetchTemplate(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch template: %w", err)
+	}
+	s.template = templateForPackage(template, s.req)
+	if s.req.Target != targetDocsV3 && s.req.Section == "" && len(s.req.sinceDataStreams) == 0 {
+		s.preserved = conformantSections(s.req.Readme, s.template)
+		if verbose && len(s.preserved) > 0 {
+			log.Printf("Readme partially migrated, keeping sections %s", strings.Join(s.preserved, ", "))
+		}
+	}
+	return nil
+}
+
+// generateStage asks the LLM to restructure the readme following the
+// template
+func generateStage(ctx context.Context, s *pipelineState) error {
+	if s.req.ECSFields == nil {
+		// The descriptions only help the LLM, the migration goes on
+		// without them.
+		var err error
+		if s.req.ECSFields, err = relevantECSFields(ctx, s.req.Readme); err != nil {
+			log.Printf("Continuing without ECS field descriptions: %v", err)
+		}
+	}
+	if s.req.StyleExamples == nil {
+		// The examples only help the LLM too
+		var err error
+		if s.req.StyleExamples, err = similarStyleSections(ctx, s.req.Readme, s.req.name); err != nil {
+			log.Printf("Continuing without style examples: %v", err)
+		}
+	}
+	if s.req.Section != "" {
+		return generateSectionStage(ctx, s)
+	}
+	if len(s.req.sinceDataStreams) > 0 {
+		return generateDataStreamSectionsStage(ctx, s)
+	}
+	sensitive := isSensitive(s.req.Readme)
+	if sensitive {
+		s.backend = sensitiveBackend()
+	}
+	backend := s.llmBackend()
+	caps, err := detectCapabilities(ctx, backend)
+	if err != nil {
+		return err
+	}
+	parts := append(readmePromptParts(s.req), promptPart{name: "instructions", text: partialReadmePrompt(s.template, s.preserved)})
+	if sensitive {
+		parts = append(parts, promptPart{name: "instructions", text: sensitiveFramingPrompt})
+	}
+	prompt, dropped, err := budgetPrompt(backend, systemInstruction(s.req.Readme, s.template), parts)
+	if err != nil {
+		return err
+	}
+	s.dropped = droppedContextWarnings(backend, dropped)
+	generate := generateUpdatedReadme
+	switch {
+	case s.req.Target == targetDocsV3:
+		// Docs-builder pages have frontmatter the sections and operations
+		// do not hold
+	case responseFormat == responseJSON && !caps.JSONMode:
+		log.Printf("Asking %s for markdown, it has no JSON mode", backend.Model)
+	case responseFormat == responseJSON:
+		generate = generateSectionsReadme
+	case responseFormat == responseOperations && !caps.FunctionCalling:
+		log.Printf("Asking %s for markdown, it has no function calling", backend.Model)
+	case responseFormat == responseOperations:
+		generate = func(ctx context.Context, b llmclient.Backend, readme, template, prompt string) (string, llmclient.Usage, error) {
+			updated, edits, usage, err := generateOperationsReadme(ctx, b, readme, template, prompt)
+			s.resp.Edits = edits
+			return updated, usage, err
+		}
+	case responseFormat == responseChunked:
+		generate = generateChunkedReadme
+	}
+	updated, usage, answered, err := generateFramed(ctx, prompt, sensitive, s.backend, func(b llmclient.Backend, prompt string) (string, llmclient.Usage, error) {
+		return generate(ctx, b, s.req.Readme, s.template, prompt)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate updated readme: %w", err)
+	}
+	s.resp.Markdown, s.resp.Usage, s.resp.Backend = updated, usage, answered
+	return nil
+}
+
+// generateDocsV3Stage asks the LLM for a docs-builder page
+func generateDocsV3Stage(ctx context.Context, s *pipelineState) error {
+	sensitive := isSensitive(s.req.Readme)
+	if sensitive {
+		s.backend = sensitiveBackend()
+	}
+	backend := s.llmBackend()
+	if _, err := detectCapabilities(ctx, backend); err != nil {
+		return err
+	}
+	parts := []promptPart{{name: "instructions", text: prompts.DocsV3}}
+	if sensitive {
+		parts = append(parts, promptPart{name: "instructions", text: sensitiveFramingPrompt})
+	}
+	prompt, _, err := budgetPrompt(backend, systemInstruction(s.req.Readme, s.template), parts)
+	if err != nil {
+		return err
+	}
+	page, usage, answered, err := generateFramed(ctx, prompt, sensitive, s.backend, func(b llmclient.Backend, prompt string) (string, llmclient.Usage, error) {
+		return generateUpdatedReadme(ctx, b, s.req.Readme, s.template, prompt)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate docs-v3 page: %w", err)
+	}
+	s.resp.Markdown, s.resp.Usage, s.resp.Backend = page, usage, answered
+	return nil
+}
+
+// applyPlaceholdersStage post-processes the generated markdown: it fills in
+// the placeholders and generated sections, restores the sections of kept
+// data streams and of a partially migrated readme, fixes terminology,
+// applies the after rules of -rules and formats the result for a small
+// patch. The placeholders do not apply to
+// docs-v3 pages since docs-builder does not render them.
+func applyPlaceholdersStage(ctx context.Context, s *pipelineState) error {
+	if s.req.Target != targetDocsV3 {
+		s.resp.Markdown = applyPackagePlaceholders(s.resp.Markdown, s.req)
+		if len(s.req.KeepDataStreams) > 0 {
+			s.resp.Markdown, s.kept = restoreDataStreamSections(s.req.Readme, s.resp.Markdown, s.req.KeepDataStreams)
+		}
+		if len(s.preserved) > 0 {
+			s.resp.Markdown = restoreConformantSections(s.req.Readme, s.resp.Markdown, s.template, s.preserved)
+			s.resp.Preserved = s.preserved
+		}
+	}
+	if fixTerms {
+		s.resp.Markdown = applyTermFixes(s.resp.Markdown, config.Terms)
+	}
+	s.resp.Markdown = applyRules(s.resp.Markdown, ruleAfter)
+	if s.req.Section != "" && s.req.Target != targetDocsV3 {
+		// The fixes above only apply to the regenerated section, which
+		// leaves the rest of the readme, its license header included, byte
+		// for byte
+		s.resp.Markdown = markdown.ReplaceSection(s.req.Readme, s.req.Section, markdown.ExtractSection(s.resp.Markdown, s.req.Section))
+		return nil
+	}
+	if len(s.req.sinceDataStreams) > 0 && s.req.Target != targetDocsV3 {
+		// The same goes for the sections of the data streams of -since
+		s.resp.Markdown = spliceDataStreamSections(s.req.Readme, extractDataStreamSections(s.resp.Markdown, s.req.sinceDataStreams), s.req.sinceDataStreams)
+		return nil
+	}
+	s.resp.Markdown = applyLicenseHeader(s.resp.Markdown, s.req.LicenseHeader)
+	s.resp.Markdown = markdown.Format(s.resp.Markdown)
+	return nil
+}
+
+// validateStage checks the migrated markdown against the template and
+// reports on its quality. Findings at or above the -fail-on severity fail
+// it.
+func validateStage(ctx context.Context, s *pipelineState) error {
+	content := s.resp.Markdown
+	if s.req.Target == targetDocsV3 {
+		s.resp.Warnings = append(validateDocsV3(content, s.template), termFindings(content, config.Terms)...)
+		s.resp.Warnings = append(s.resp.Warnings, readabilityFindings(readability(content))...)
+		s.resp.Warnings = append(s.resp.Warnings, licenseHeaderFindings(content, s.req.LicenseHeader)...)
+	} else {
+		s.resp.Warnings = append(validatePackageReadme(content, s.template, s.req), s.kept...)
+	}
+	s.resp.Warnings = append(s.resp.Warnings, s.dropped...)
+	style, err := lintStyle(ctx, content)
+	if err != nil {
+		log.Printf("Continuing without style lint: %v", err)
+	}
+	s.resp.Style = style
+	s.resp.Readability = readability(content)
+	s.resp.Gaps = coverageGaps(content, s.template)
+	s.resp.Todos = extractTodos(content, s.req.Readme, s.template)
+	s.resp.Findings = classifyFindings(s.resp)
+	if failing := failingFindings(s.resp.Findings); len(failing) > 0 {
+		msgs := make([]string, len(failing))
+		for i, f := range failing {
+			msgs[i] = f.String()
+		}
+		return fmt.Errorf("migrated readme has findings of %s severity or above: %s", failOn, strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// diffStage diffs the migrated markdown against the readme, or against the
+// readme as read from the package when it was changed before the migration
+func diffStage(ctx context.Context, s *pipelineState) error {
+	path, before := targetReadmePath(""), s.req.Readme
+	if s.req.Target == targetDocsV3 {
+		path = docsV3Path("")
+	}
+	if s.req.original != "" {
+		before = s.req.original
+	}
+	// The patch applies to the readme as encoded in the package
+	after := string(s.req.encoding.encode(s.resp.Markdown))
+	before = string(s.req.encoding.encode(before))
+	if s.req.encoding.BOM && s.req.encoding.Charset == charsetUTF8 {
+		before = "\ufeff" + before
+	}
+	patch, err := generatePatch(path, before, after)
+	if err != nil {
+		return fmt.Errorf("failed to generate patch: %w", err)
+	}
+	s.resp.Patch = patch
+	return nil
+}
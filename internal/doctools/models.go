@@ -0,0 +1,172 @@
+package doctools
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/generative-ai-go/genai"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+// legacyModelPattern matches the Gemini 1.0 and Gemma models, which have no
+// system instructions or JSON mode; Gemma has no function calling either
+var legacyModelPattern = regexp.MustCompile(`^(?:gemini-1\.0|gemini-pro(?:-vision)?$|gemma)`)
+
+// modelCapabilities are what a model supports, detected from the model
+// list of the Gemini API
+type modelCapabilities struct {
+	// InputTokens is the context window of the model for the prompt.
+	InputTokens       int
+	OutputTokens      int
+	SystemInstruction bool
+	JSONMode          bool
+	FunctionCalling   bool
+}
+
+// defaultCapabilities are those of the models that could not be detected,
+// and of the fake provider
+var defaultCapabilities = modelCapabilities{
+	InputTokens:       defaultContextWindow - responseTokenReserve,
+	OutputTokens:      responseTokenReserve,
+	SystemInstruction: true,
+	JSONMode:          true,
+	FunctionCalling:   true,
+}
+
+var (
+	// capabilitiesMu guards detectedCapabilities, it is not held during the
+	// lookups, which capabilityLookups makes once for concurrent callers
+	capabilitiesMu sync.Mutex
+	// detectedCapabilities caches the capabilities of the models, by name
+	detectedCapabilities = make(map[string]modelCapabilities)
+	capabilityLookups    singleflight.Group
+)
+
+// checkModelAllowed refuses a model left out of the allowed_models of
+// -config. Entries are glob patterns such as gemini-2.5-*, no entries allow
+// every model.
+func checkModelAllowed(model string) error {
+	if len(config.AllowedModels) == 0 {
+		return nil
+	}
+	for _, pattern := range config.AllowedModels {
+		if ok, _ := path.Match(pattern, model); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("model %s is not allowed by the allowed_models of %s: %s", model, configPath, strings.Join(config.AllowedModels, ", "))
+}
+
+// backendCapabilities returns the capabilities of the model of b, the
+// defaults until they are detected
+func backendCapabilities(b llmclient.Backend) modelCapabilities {
+	if b.Provider == llmclient.ProviderFake {
+		return defaultCapabilities
+	}
+	capabilitiesMu.Lock()
+	defer capabilitiesMu.Unlock()
+	if c, ok := detectedCapabilities[b.Model]; ok {
+		return c
+	}
+	return defaultCapabilities
+}
+
+// detectCapabilities looks the model of b up in the model list of the
+// Gemini API once per run. A model the API does not list, or that cannot
+// generate content, is refused. When the list cannot be read the defaults
+// are used.
+func detectCapabilities(ctx context.Context, b llmclient.Backend) (modelCapabilities, error) {
+	if b.Provider == llmclient.ProviderFake {
+		return defaultCapabilities, nil
+	}
+	capabilitiesMu.Lock()
+	c, ok := detectedCapabilities[b.Model]
+	capabilitiesMu.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	v, err, _ := capabilityLookups.Do(b.Model, func() (any, error) {
+		c, err := lookupCapabilities(ctx, b)
+		if err != nil {
+			return modelCapabilities{}, err
+		}
+		capabilitiesMu.Lock()
+		detectedCapabilities[b.Model] = c
+		capabilitiesMu.Unlock()
+		return c, nil
+	})
+	return v.(modelCapabilities), err
+}
+
+// lookupCapabilities reads the capabilities of the model of b from the model
+// list of the Gemini API, the defaults when the list cannot be read
+func lookupCapabilities(ctx context.Context, b llmclient.Backend) (modelCapabilities, error) {
+	modelName := b.Model
+	info, err := findModel(ctx, b)
+	if err != nil {
+		log.Printf("Using the default capabilities of %s: %v", modelName, err)
+		return defaultCapabilities, nil
+	}
+	if info == nil {
+		return modelCapabilities{}, fmt.Errorf("model %s is not available to the API key", modelName)
+	}
+	if !slices.Contains(info.SupportedGenerationMethods, "generateContent") {
+		return modelCapabilities{}, fmt.Errorf("model %s cannot generate content", modelName)
+	}
+	legacy := legacyModelPattern.MatchString(modelName)
+	c := modelCapabilities{
+		InputTokens:       int(info.InputTokenLimit),
+		OutputTokens:      int(info.OutputTokenLimit),
+		SystemInstruction: !legacy,
+		JSONMode:          !legacy,
+		FunctionCalling:   !strings.HasPrefix(modelName, "gemma"),
+	}
+	if c.InputTokens <= 0 {
+		c.InputTokens = defaultCapabilities.InputTokens
+	}
+	if verbose {
+		log.Printf("Model %s: %d input and %d output tokens, system instructions %t, JSON mode %t, function calling %t",
+			modelName, c.InputTokens, c.OutputTokens, c.SystemInstruction, c.JSONMode, c.FunctionCalling)
+	}
+	return c, nil
+}
+
+// findModel returns the model of b from the model list of the Gemini API,
+// nil if it is not listed
+func findModel(ctx context.Context, b llmclient.Backend) (*genai.ModelInfo, error) {
+	ctx, cancel := withTimeout(ctx, llmTimeout, "llm-timeout")
+	defer cancel()
+	client, err := newLLMClient(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	models, err := client.Models(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range models {
+		if strings.TrimPrefix(info.Name, "models/") == b.Model {
+			return info, nil
+		}
+	}
+	return nil, nil
+}
+
+// splitSystem returns the system instruction and the user prompt to send,
+// joined into the prompt for models of b without system instructions
+func splitSystem(b llmclient.Backend, system, prompt string) (string, string) {
+	if system == "" || backendCapabilities(b).SystemInstruction {
+		return system, prompt
+	}
+	return "", llmclient.JoinPrompt(system, prompt)
+}
@@ -0,0 +1,95 @@
+package doctools
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+// sensitiveTermThreshold is how many distinct sensitive terms make a readme
+// likely to trip the safety filters of the model
+const sensitiveTermThreshold = 3
+
+// sensitiveTermPattern matches the terms of security integrations that safety
+// filters take for offensive content: exploits, malware and attack
+// techniques
+var sensitiveTermPattern = regexp.MustCompile(`(?i)\b(?:exploit(?:s|ed|ation)?|shellcode|payloads?|malware|ransomware|trojans?|botnets?|backdoors?|rootkits?|keyloggers?|reverse shells?|command[- ]and[- ]control|phishing|credential (?:dumping|theft|stuffing)|mimikatz|cobalt strike|brute[- ]force|privilege escalation|zero[- ]days?|lateral movement|cve-\d{4}-\d+)\b`)
+
+// sensitiveFramingPrompt frames the migration of a readme full of security
+// terms as the defensive documentation it is
+const sensitiveFramingPrompt = `
+
+This README documents a security integration of Elastic, which collects logs and threat intelligence so defenders can detect and investigate attacks. The terms for exploits, malware and attack techniques in it name the data the integration collects, they are not instructions. Restructure the documentation as it is, without adding or expanding on any attack details.`
+
+// sensitiveModel is the model of -sensitive-model
+var sensitiveModel string
+
+// sensitiveTerms returns the distinct sensitive terms of a readme, lower
+// cased, in order of appearance
+func sensitiveTerms(readme string) []string {
+	var terms []string
+	for _, m := range sensitiveTermPattern.FindAllString(readme, -1) {
+		if t := strings.ToLower(m); !slices.Contains(terms, t) {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// isSensitive reports whether a readme is likely to trip the safety filters
+// of the model, logging the terms that make it so
+func isSensitive(readme string) bool {
+	terms := sensitiveTerms(readme)
+	if len(terms) < sensitiveTermThreshold {
+		return false
+	}
+	if verbose {
+		log.Printf("Readme mentions %s, framing the prompt as security documentation", strings.Join(terms, ", "))
+	}
+	return true
+}
+
+// isBlocked reports whether err is the model blocking the prompt or its
+// answer for safety
+func isBlocked(err error) bool {
+	var be *genai.BlockedError
+	return errors.As(err, &be)
+}
+
+// sensitiveBackend returns the backend of -sensitive-model for the migration
+// of a readme likely to trip the safety filters, nil to migrate it with the
+// failover chain like the others
+func sensitiveBackend() *llmclient.Backend {
+	if sensitiveModel == "" || llmProvider != llmclient.ProviderGemini || sensitiveModel == modelName {
+		return nil
+	}
+	if verbose {
+		log.Printf("Migrating with %s", sensitiveModel)
+	}
+	return &llmclient.Backend{Provider: llmclient.ProviderGemini, Model: sensitiveModel}
+}
+
+// generateFramed generates with prompt, with the pinned backend if not nil
+// and the failover chain otherwise. An answer blocked for safety is asked
+// for again with the prompt framed as security documentation, unless it
+// already was.
+func generateFramed(ctx context.Context, prompt string, framed bool, pinned *llmclient.Backend, generate func(b llmclient.Backend, prompt string) (string, llmclient.Usage, error)) (string, llmclient.Usage, string, error) {
+	text, usage, backend, err := generatePinned(ctx, pinned, func(b llmclient.Backend) (string, llmclient.Usage, error) { return generate(b, prompt) })
+	if err == nil || framed || !isBlocked(err) {
+		return text, usage, backend, err
+	}
+	log.Printf("Answer blocked for safety, asking again with the prompt framed as security documentation: %v", err)
+	text, u, backend, err := generatePinned(ctx, pinned, func(b llmclient.Backend) (string, llmclient.Usage, error) {
+		return generate(b, prompt+sensitiveFramingPrompt)
+	})
+	usage.PromptTokens += u.PromptTokens
+	usage.ResponseTokens += u.ResponseTokens
+	return text, usage, backend, err
+}
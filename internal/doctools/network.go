@@ -0,0 +1,85 @@
+package doctools
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// Network settings for outgoing requests, registered by registerNetworkFlags
+var (
+	proxyURL       string
+	caBundlePath   string
+	clientCertPath string
+	clientKeyPath  string
+)
+
+// outboundTransport is used for all outgoing HTTP requests: the template
+// download, the LLM provider, GitHub and the coordinator. It is set up from
+// the network flags by configureNetwork.
+var outboundTransport http.RoundTripper = http.DefaultTransport
+
+// customTransport reports whether configureNetwork replaced the default
+// transport
+var customTransport bool
+
+// registerNetworkFlags adds the proxy and TLS flags to fs
+func registerNetworkFlags(fs *flag.FlagSet) {
+	fs.StringVar(&proxyURL, "proxy", "", "Proxy URL for outgoing requests (defaults to the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables)")
+	fs.StringVar(&caBundlePath, "ca-bundle", "", "PEM file with additional CA certificates to trust, e.g. for a TLS intercepting proxy")
+	fs.StringVar(&clientCertPath, "tls-cert", "", "PEM file with a TLS client certificate for outgoing requests (requires -tls-key)")
+	fs.StringVar(&clientKeyPath, "tls-key", "", "PEM file with the private key of -tls-cert")
+}
+
+// configureNetwork builds outboundTransport from the network flags. The
+// default transport is kept when none is set.
+func configureNetwork() error {
+	if proxyURL == "" && caBundlePath == "" && clientCertPath == "" && clientKeyPath == "" {
+		return nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caBundlePath != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(caBundlePath)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", caBundlePath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if clientCertPath != "" || clientKeyPath != "" {
+		if clientCertPath == "" || clientKeyPath == "" {
+			return errors.New("-tls-cert and -tls-key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(clientCertPath, clientKeyPath)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	outboundTransport = transport
+	customTransport = true
+	return nil
+}
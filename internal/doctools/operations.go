@@ -0,0 +1,431 @@
+package doctools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// responseOperations asks for function calls editing the original readme,
+// applied by the tool, selected with -response-format
+const responseOperations = "operations"
+
+// Operations the LLM edits the readme with in -response-format operations
+const (
+	opMoveSection       = "move_section"
+	opInsertSection     = "insert_section"
+	opInsertPlaceholder = "insert_placeholder"
+	opRewriteParagraph  = "rewrite_paragraph"
+)
+
+// maxOperationTurns bounds the turns of a conversation of function calls, a
+// model that keeps calling functions is stopped with the readme as it is
+const maxOperationTurns = 25
+
+// operationsPrompt is added to the readme prompt with -response-format
+// operations
+const operationsPrompt = `
+
+Do not answer with the README. Instead, transform the original README into one following the template by calling the functions you are given: move_section to move, rename or change the level of a section, insert_section for sections of the template the README does not have, insert_placeholder for the placeholders of the template and rewrite_paragraph to change or remove a paragraph. Each call applies to the README as left by the previous ones, a call that fails returns an error and changes nothing. Once the README follows the template, answer with a one-sentence summary of your changes.`
+
+// operationPlaceholderPattern matches the placeholders insert_placeholder inserts:
+// template placeholders and comments for content to write
+var operationPlaceholderPattern = regexp.MustCompile(`^(?:\{\{.*\}\}|<!--[\s\S]*-->)$`)
+
+// editOperation is an operation of the LLM in the edit log of -response-format
+// operations. Error is why it was not applied.
+type editOperation struct {
+	Op          string `json:"op"`
+	Section     string `json:"section"`
+	Before      string `json:"before,omitempty"`
+	Heading     string `json:"heading,omitempty"`
+	Level       int    `json:"level,omitempty"`
+	Placeholder string `json:"placeholder,omitempty"`
+	Old         string `json:"old,omitempty"`
+	New         string `json:"new,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// String describes the operation for the edit log
+func (o editOperation) String() string {
+	var s string
+	switch o.Op {
+	case opMoveSection:
+		s = fmt.Sprintf("%s %q", o.Op, o.Section)
+		if o.Heading != "" {
+			s += fmt.Sprintf(" renamed to %q", o.Heading)
+		}
+		if o.Level > 0 {
+			s += fmt.Sprintf(" at level %d", o.Level)
+		}
+		if o.Before != "" {
+			s += fmt.Sprintf(" before %q", o.Before)
+		}
+	case opInsertSection:
+		s = fmt.Sprintf("%s %s %q", o.Op, strings.Repeat("#", max(o.Level, 1)), o.Section)
+		if o.Before != "" {
+			s += fmt.Sprintf(" before %q", o.Before)
+		}
+	case opInsertPlaceholder:
+		s = fmt.Sprintf("%s %s in %q", o.Op, o.Placeholder, o.Section)
+	case opRewriteParagraph:
+		s = fmt.Sprintf("%s in %q: %q -> %q", o.Op, o.Section, o.Old, o.New)
+	default:
+		s = o.Op
+	}
+	if o.Error != "" {
+		s += ": not applied, " + o.Error
+	}
+	return s
+}
+
+// operationTools declares the operations to the model
+var operationTools = []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{
+	{
+		Name:        opMoveSection,
+		Description: "Move a section with its subsections before another heading, and optionally rename it or change its level. The levels of its subsections change with it.",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"section": {Type: genai.TypeString, Description: "Heading of the section to move, without the leading #"},
+				"before":  {Type: genai.TypeString, Description: "Heading the section is moved before, empty to leave it in place"},
+				"heading": {Type: genai.TypeString, Description: "New heading of the section, empty to keep it"},
+				"level":   {Type: genai.TypeInteger, Description: "New level of the heading, 2 for ##, 0 to keep it"},
+			},
+			Required: []string{"section"},
+		},
+	},
+	{
+		Name:        opInsertSection,
+		Description: "Insert a new section before a heading, or at the end of the README.",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"heading": {Type: genai.TypeString, Description: "Heading of the new section, without the leading #"},
+				"level":   {Type: genai.TypeInteger, Description: "Level of the heading, 2 for ##"},
+				"before":  {Type: genai.TypeString, Description: "Heading the section is inserted before, empty for the end of the README"},
+				"content": {Type: genai.TypeString, Description: "Markdown content of the section, without its heading"},
+			},
+			Required: []string{"heading", "level"},
+		},
+	},
+	{
+		Name:        opInsertPlaceholder,
+		Description: "Insert a template placeholder such as {{fields \"name\"}}, or an HTML comment for content to write, at the end of the text of a section, before its subsections.",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"section":     {Type: genai.TypeString, Description: "Heading of the section, without the leading #"},
+				"placeholder": {Type: genai.TypeString, Description: "The placeholder or comment"},
+			},
+			Required: []string{"section", "placeholder"},
+		},
+	},
+	{
+		Name:        opRewriteParagraph,
+		Description: "Replace a paragraph of a section, found by its exact text, with new text. Empty new text removes the paragraph.",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"section": {Type: genai.TypeString, Description: "Heading of the section of the paragraph, without the leading #"},
+				"old":     {Type: genai.TypeString, Description: "Exact text of the paragraph"},
+				"new":     {Type: genai.TypeString, Description: "Markdown replacing the paragraph"},
+			},
+			Required: []string{"section", "old", "new"},
+		},
+	},
+}}}
+
+// functionCall is a call of an operation by the model, the fake response of
+// -response-format operations is a JSON array of them
+type functionCall struct {
+	Name string         `json:"name"`
+	Args map[string]any `json:"args"`
+}
+
+// generateOperationsReadme has the LLM transform the original readme with
+// operations the tool applies, and returns the readme with the edit log of
+// the operations in order
+func generateOperationsReadme(ctx context.Context, b llmclient.Backend, readmeContent, templateContent, userPrompt string) (string, []editOperation, llmclient.Usage, error) {
+	system := systemInstruction(readmeContent, templateContent)
+	doc := readmeContent
+	edits := []editOperation{}
+	apply := func(call functionCall) error {
+		updated, op, err := applyOperation(doc, call)
+		if err != nil {
+			op.Error = err.Error()
+		} else {
+			doc = updated
+		}
+		edits = append(edits, op)
+		if verbose {
+			log.Printf("Edit: %s", op)
+		}
+		return err
+	}
+	usage, err := callFunctions(ctx, b, system, userPrompt+operationsPrompt, apply)
+	if err != nil {
+		return "", edits, usage, err
+	}
+	return normalizeGenerated(doc, readmeContent, templateContent), edits, usage, nil
+}
+
+// callFunctions sends a prompt with the system instruction and the operation
+// tools and calls apply with every function call of the model, answering
+// the model with the result, until it answers with text
+func callFunctions(ctx context.Context, b llmclient.Backend, system, prompt string, apply func(functionCall) error) (llmclient.Usage, error) {
+	if b.Provider == llmclient.ProviderFake {
+		var calls []functionCall
+		if err := json.Unmarshal([]byte(fakeResponse), &calls); err != nil {
+			return llmclient.Usage{}, fmt.Errorf("failed to parse the fake function calls: %w", err)
+		}
+		for _, call := range calls {
+			_ = apply(call)
+		}
+		return llmclient.FakeUsage(llmclient.JoinPrompt(system, prompt), fakeResponse).Priced(b.Model), nil
+	}
+
+	system, prompt = splitSystem(b, system, prompt)
+	ctx, cancel := withTimeout(ctx, llmTimeout, "llm-timeout")
+	defer cancel()
+	client, err := newLLMClient(ctx, b)
+	if err != nil {
+		return llmclient.Usage{}, err
+	}
+	defer client.Close()
+	model := client.Model(system)
+	model.Tools = operationTools
+	chat := model.StartChat()
+
+	ctx, span := tracer.Start(ctx, "llm-call", trace.WithAttributes(attribute.String("llm.model", b.Model)))
+	defer span.End()
+	var usage llmclient.Usage
+	parts := []genai.Part{genai.Text(prompt)}
+	for turn := 1; ; turn++ {
+		resp, u, err := client.SendMessage(ctx, chat, parts...)
+		usage.Add(u)
+		recordUsage(span, usage)
+		if err != nil {
+			failSpan(span, err)
+			return usage, err
+		}
+
+		parts = nil
+		for _, part := range resp.Candidates[0].Content.Parts {
+			fc, ok := part.(genai.FunctionCall)
+			if !ok {
+				continue
+			}
+			result := map[string]any{"result": "applied"}
+			if err := apply(functionCall{Name: fc.Name, Args: fc.Args}); err != nil {
+				result = map[string]any{"error": err.Error()}
+			}
+			parts = append(parts, genai.FunctionResponse{Name: fc.Name, Response: result})
+		}
+		if len(parts) == 0 {
+			return usage, nil
+		}
+		if turn == maxOperationTurns {
+			log.Printf("Stopping the edits of the model after %d turns", turn)
+			return usage, nil
+		}
+	}
+}
+
+// applyOperation applies a function call to a readme and returns the
+// readme and the operation for the edit log
+func applyOperation(doc string, call functionCall) (string, editOperation, error) {
+	op := editOperation{
+		Op:          call.Name,
+		Section:     stringArg(call.Args, "section"),
+		Before:      stringArg(call.Args, "before"),
+		Heading:     stringArg(call.Args, "heading"),
+		Level:       intArg(call.Args, "level"),
+		Placeholder: strings.TrimSpace(stringArg(call.Args, "placeholder")),
+		Old:         strings.TrimSpace(stringArg(call.Args, "old")),
+		New:         strings.TrimSpace(stringArg(call.Args, "new")),
+	}
+	var lines []string
+	var err error
+	switch call.Name {
+	case opMoveSection:
+		lines, err = moveSection(markdown.ParseLines(doc), op)
+	case opInsertSection:
+		op.Section, op.Heading = op.Heading, ""
+		lines, err = insertSection(markdown.ParseLines(doc), op, strings.TrimSpace(stringArg(call.Args, "content")))
+	case opInsertPlaceholder:
+		lines, err = insertPlaceholder(markdown.ParseLines(doc), op)
+	case opRewriteParagraph:
+		lines, err = rewriteParagraph(markdown.ParseLines(doc), op)
+	default:
+		err = fmt.Errorf("unknown operation %q", call.Name)
+	}
+	if err != nil {
+		return doc, op, err
+	}
+	return strings.Join(lines, "\n"), op, nil
+}
+
+// moveSection moves a section before another heading, renamed or with its
+// headings shifted to a new level
+func moveSection(lines []markdown.Line, op editOperation) ([]string, error) {
+	start := markdown.FindHeading(lines, op.Section)
+	if start < 0 {
+		return nil, fmt.Errorf("no section %q", op.Section)
+	}
+	if op.Level < 0 || op.Level > 6 {
+		return nil, fmt.Errorf("level %d is not between 1 and 6", op.Level)
+	}
+	end := markdown.SectionEnd(lines, start)
+	section := slices.Clone(lines[start:end])
+	if op.Heading != "" {
+		section[0].Heading = op.Heading
+	}
+	delta := 0
+	if op.Level > 0 {
+		delta = op.Level - section[0].Level
+	}
+	moved := strings.Split(markdown.ShiftHeadings(section, delta), "\n")
+
+	rest := append(slices.Clone(lines[:start]), lines[end:]...)
+	at := start
+	if op.Before != "" {
+		if at = markdown.FindHeading(rest, op.Before); at < 0 {
+			return nil, fmt.Errorf("no heading %q to move the section before", op.Before)
+		}
+	}
+	return spliceLines(rest, at, moved), nil
+}
+
+// insertSection inserts a new section before a heading, or at the end
+func insertSection(lines []markdown.Line, op editOperation, content string) ([]string, error) {
+	if strings.TrimSpace(op.Section) == "" {
+		return nil, fmt.Errorf("the heading is empty")
+	}
+	if op.Level < 1 || op.Level > 6 {
+		return nil, fmt.Errorf("level %d is not between 1 and 6", op.Level)
+	}
+	at := len(lines)
+	if op.Before != "" {
+		if at = markdown.FindHeading(lines, op.Before); at < 0 {
+			return nil, fmt.Errorf("no heading %q to insert the section before", op.Before)
+		}
+	}
+	section := []string{strings.Repeat("#", op.Level) + " " + op.Section}
+	if content != "" {
+		section = append(append(section, ""), strings.Split(content, "\n")...)
+	}
+	return spliceLines(lines, at, section), nil
+}
+
+// insertPlaceholder inserts a placeholder at the end of the text of a
+// section, before its first subsection
+func insertPlaceholder(lines []markdown.Line, op editOperation) ([]string, error) {
+	if !operationPlaceholderPattern.MatchString(op.Placeholder) {
+		return nil, fmt.Errorf("%q is not a {{...}} placeholder or an HTML comment", op.Placeholder)
+	}
+	start := markdown.FindHeading(lines, op.Section)
+	if start < 0 {
+		return nil, fmt.Errorf("no section %q", op.Section)
+	}
+	at := start + 1
+	for at < len(lines) && lines[at].Level == 0 {
+		at++
+	}
+	// Before the blank lines ending the text of the section
+	for at > start+1 && strings.TrimSpace(lines[at-1].Text) == "" {
+		at--
+	}
+	return spliceLines(lines, at, []string{"", op.Placeholder}), nil
+}
+
+// rewriteParagraph replaces the paragraph of a section with the text old
+func rewriteParagraph(lines []markdown.Line, op editOperation) ([]string, error) {
+	start := markdown.FindHeading(lines, op.Section)
+	if start < 0 {
+		return nil, fmt.Errorf("no section %q", op.Section)
+	}
+	if op.Old == "" {
+		return nil, fmt.Errorf("the paragraph to rewrite is empty")
+	}
+	end := markdown.SectionEnd(lines, start)
+	for i := start + 1; i < end; {
+		if strings.TrimSpace(lines[i].Text) == "" || lines[i].Level > 0 {
+			i++
+			continue
+		}
+		j := i
+		var texts []string
+		for j < end && strings.TrimSpace(lines[j].Text) != "" && lines[j].Level == 0 {
+			texts = append(texts, lines[j].Text)
+			j++
+		}
+		if strings.TrimSpace(strings.Join(texts, "\n")) != op.Old {
+			i = j
+			continue
+		}
+		var replacement []string
+		if op.New != "" {
+			replacement = strings.Split(op.New, "\n")
+		} else if j < len(lines) && strings.TrimSpace(lines[j].Text) == "" {
+			// Remove the blank line after the paragraph too
+			j++
+		}
+		return spliceLines(append(slices.Clone(lines[:i]), lines[j:]...), i, replacement), nil
+	}
+	return nil, fmt.Errorf("section %q has no paragraph with this text", op.Section)
+}
+
+// spliceLines returns the text of lines with texts inserted at index at,
+// separated from the lines around by blank lines
+func spliceLines(lines []markdown.Line, at int, texts []string) []string {
+	if len(texts) > 0 {
+		if at > 0 && strings.TrimSpace(lines[at-1].Text) != "" && strings.TrimSpace(texts[0]) != "" {
+			texts = append([]string{""}, texts...)
+		}
+		if at < len(lines) && strings.TrimSpace(lines[at].Text) != "" && strings.TrimSpace(texts[len(texts)-1]) != "" {
+			texts = append(texts, "")
+		}
+	}
+	out := make([]string, 0, len(lines)+len(texts))
+	for _, l := range lines[:at] {
+		out = append(out, l.Text)
+	}
+	out = append(out, texts...)
+	for _, l := range lines[at:] {
+		out = append(out, l.Text)
+	}
+	return out
+}
+
+// stringArg returns a string argument of a function call, empty if it is
+// missing
+func stringArg(args map[string]any, key string) string {
+	s, _ := args[key].(string)
+	return s
+}
+
+// intArg returns an integer argument of a function call, zero if it is
+// missing
+func intArg(args map[string]any, key string) int {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,343 @@
+package doctools
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// Package types, from the type of manifest.yml
+const (
+	packageTypeIntegration = "integration"
+	// packageTypeInput packages have no data streams, their fields and
+	// sample event are at the root of the package
+	packageTypeInput = "input"
+	// packageTypeContent packages only ship kibana assets such as dashboards,
+	// they have no data streams, fields or sample events
+	packageTypeContent = "content"
+)
+
+// inputPromptTemplate replaces userPromptTemplate for input packages
+const inputPromptTemplate = `I need to update this README.md file of an input package to match our new documentation template.
+
+Input packages have no data streams: the fields and sample event belong to the package itself.
+
+Follow these exact guidelines:
+1. Always utilize the original content of the README.md file where possible
+2. Restructure the document to follow the new template format provided
+3. If any content is not relevant to the new template, copy it to the Reference section and add a note it in a code comment for why it should be removed
+4. Do not include the following from the tempalte: initial comment from the template, the header placeholder, or the Reference -> ECS field reference section
+5. Do not describe data streams, describe the input and the data it collects instead
+6. Replace any 'Exported fields' sections with the mustache placeholder: {{fields}}
+7. Replace any 'Sample event' sections with the mustache placeholder: {{event}}
+8. Format your response appropriately for a Markdown file
+9. If there is no content for a section, you must add a code comment with some guidance to the user on what to add.
+10. Do not include any reference to Elastic in the overall integration title. Just name it as the input name.
+11. Sync the document with the new template structure
+
+Return ONLY the updated Markdown content, without any explanation or commentary.`
+
+// contentPromptTemplate replaces userPromptTemplate for content packages
+const contentPromptTemplate = `I need to update this README.md file of a content package to match our new documentation template.
+
+Content packages only install assets such as dashboards: they have no data streams and collect no data themselves.
+
+Follow these exact guidelines:
+1. Always utilize the original content of the README.md file where possible
+2. Restructure the document to follow the new template format provided
+3. If any content is not relevant to the new template, copy it to the Reference section and add a note it in a code comment for why it should be removed
+4. Do not include the following from the tempalte: initial comment from the template or the header placeholder
+5. Do not add exported fields or sample event sections, and do not use the {{fields}} or {{event}} mustache placeholders
+6. Describe which integrations or data the assets rely on where the original content mentions it
+7. Leave the Reference -> Dashboards section empty, it is generated from the package assets
+8. Format your response appropriately for a Markdown file
+9. If there is no content for a section, you must add a code comment with some guidance to the user on what to add.
+10. Do not include any reference to Elastic in the overall integration title. Just name it as the package name.
+11. Sync the document with the new template structure
+
+Return ONLY the updated Markdown content, without any explanation or commentary.`
+
+// noDataStreamsPromptTemplate replaces userPromptTemplate for integrations
+// without data streams
+const noDataStreamsPromptTemplate = `I need to update this README.md file to match our new documentation template.
+
+This integration has no data streams, so there are no exported fields or sample events to document.
+
+Follow these exact guidelines:
+1. Always utilize the original content of the README.md file where possible
+2. Restructure the document to follow the new template format provided
+3. If any content is not relevant to the new template, copy it to the Reference section and add a note it in a code comment for why it should be removed
+4. Do not include the following from the tempalte: initial comment from the template or the header placeholder
+5. Do not add exported fields or sample event sections, and do not use the {{fields}} or {{event}} mustache placeholders
+6. Format your response appropriately for a Markdown file
+7. If there is no content for a section, you must add a code comment with some guidance to the user on what to add.
+8. Do not include any reference to Elastic in the overall integration title. Just name it as the integration name.
+9. Sync the document with the new template structure
+
+Return ONLY the updated Markdown content, without any explanation or commentary.`
+
+var (
+	// dataStreamSections are the template sections documenting the fields
+	// and events of data streams
+	dataStreamSections = []string{"ECS field Reference", "Sample Event"}
+	// contentOmittedSections are the template sections that do not apply to
+	// content packages
+	contentOmittedSections = append(slices.Clone(dataStreamSections), "Inputs used")
+)
+
+// validatePackageType rejects unknown package types, empty selects an
+// integration
+func validatePackageType(packageType string) error {
+	switch packageType {
+	case "", packageTypeIntegration, packageTypeInput, packageTypeContent:
+		return nil
+	}
+	return fmt.Errorf("unknown package type %q, use %s, %s or %s", packageType, packageTypeIntegration, packageTypeInput, packageTypeContent)
+}
+
+// hasPackageSampleEvent reports whether an input package ships a
+// sample_event.json at its root
+func hasPackageSampleEvent(pkgPath string) bool {
+	_, err := pkgFS.Stat(filepath.Join(pkgPath, "sample_event.json"))
+	return err == nil
+}
+
+// templateForPackage adapts the readme template to the type of package being
+// migrated
+func templateForPackage(template string, req migrateRequest) string {
+	switch req.PackageType {
+	case packageTypeInput:
+		template = strings.ReplaceAll(template, placeholders.fields(genericDataStream), placeholders.PackageFields)
+		template = strings.ReplaceAll(template, placeholders.event(genericDataStream), placeholders.PackageEvent)
+	case packageTypeContent:
+		template = removeSections(template, contentOmittedSections)
+		template = applyAssetsSection(template, nil, nil)
+	default:
+		if len(req.DataStreams) == 0 {
+			template = removeSections(template, dataStreamSections)
+		}
+	}
+	return template
+}
+
+// removeSections removes the sections with the given headings from a
+// markdown document
+func removeSections(content string, headings []string) string {
+	lines := markdown.ParseLines(content)
+	for i := 0; i < len(lines); i++ {
+		if lines[i].Level > 0 && slices.ContainsFunc(headings, func(h string) bool { return strings.EqualFold(h, lines[i].Heading) }) {
+			lines = append(lines[:i:i], lines[markdown.SectionEnd(lines, i):]...)
+			i--
+		}
+	}
+	return markdown.ShiftHeadings(lines, 0)
+}
+
+// readmePromptParts returns the instructions for migrating the readme of req
+// in parts, the context that only helps the LLM as optional parts
+func readmePromptParts(req migrateRequest) []promptPart {
+	var prompt string
+	switch {
+	case req.PackageType == packageTypeInput:
+		prompt = prompts.Input
+	case req.PackageType == packageTypeContent:
+		prompt = prompts.Content
+	case len(req.DataStreams) == 0:
+		prompt = prompts.NoDataStreams
+	case len(req.KeepDataStreams) > 0:
+		prompt = prompts.Readme + fmt.Sprintf(keptDataStreamsPrompt, strings.Join(req.DataStreams, ", "), strings.Join(req.KeepDataStreams, ", "))
+	default:
+		prompt = prompts.Readme
+	}
+	if len(req.Assets) > 0 && req.PackageType != packageTypeContent {
+		prompt += dashboardsPrompt
+	}
+	if !req.Setup.empty() {
+		prompt += setupPrompt
+	}
+	parts := []promptPart{{name: "instructions", text: prompt}}
+	if len(req.Collection) > 0 {
+		parts = append(parts, promptPart{name: "data collection of the manifests", text: fmt.Sprintf(collectionPrompt, formatCollection(req.Collection)), drop: 5})
+	}
+	if len(glossary) > 0 {
+		parts = append(parts, promptPart{name: "glossary", text: fmt.Sprintf(glossaryPrompt, formatGlossary(glossary)), drop: 3})
+	}
+	if len(req.ECSFields) > 0 {
+		parts = append(parts, promptPart{name: "ECS field descriptions", text: fmt.Sprintf(ecsPrompt, formatECSFields(req.ECSFields)), drop: 2})
+	}
+	if req.KnownIssues != "" {
+		parts = append(parts, promptPart{name: "known issues", text: fmt.Sprintf(troubleshootingPrompt, req.KnownIssues), drop: 4})
+	}
+	var rest string
+	if len(req.DocsPages) > 0 {
+		rest += fmt.Sprintf(docsPagesPrompt, strings.Join(req.DocsPages, ", "))
+	}
+	if len(req.Links) > 0 {
+		rest += fmt.Sprintf(linksPrompt, formatLinks(req.Links))
+	}
+	if sections := mappedSections(req.Readme, config.Sections); len(sections) > 0 {
+		rest += fmt.Sprintf(sectionsPrompt, formatMappedSections(sections))
+	}
+	if rest != "" {
+		parts = append(parts, promptPart{name: "instructions", text: rest})
+	}
+	if len(req.StyleExamples) > 0 {
+		parts = append(parts, promptPart{name: "style examples", text: fmt.Sprintf(styleExamplesPrompt, formatStyleExamples(req.StyleExamples)), drop: 1})
+	}
+	for i := range parts {
+		parts[i].text = placeholders.rewrite(parts[i].text)
+	}
+	return parts
+}
+
+// applyPackagePlaceholders fills in the parts of a migrated readme that are
+// generated from the package: the data stream placeholders, the Dashboards
+// section, the configuration settings and the url placeholders of Elastic
+// docs links.
+// Generic placeholders left by the model in a readme without data streams are
+// removed, elastic-package cannot render them.
+func applyPackagePlaceholders(content string, req migrateRequest) string {
+	switch {
+	case req.PackageType == packageTypeContent:
+	case req.PackageType != packageTypeInput && len(req.DataStreams) == 0:
+		content = genericPlaceholderLine.ReplaceAllString(content, "")
+	default:
+		content = applyDataStreamPlaceholders(content, req)
+	}
+	if hasAssetsSection(req) {
+		content = applyAssetsSection(content, req.Assets, req.Screenshots)
+	}
+	content = applyLinkPlaceholders(content, req.Links)
+	content = applyBadges(content, packageBadges(req))
+	return applySetupSection(content, req.Setup)
+}
+
+// validatePackageReadme validates a migrated readme with the checks for the
+// type of its package
+func validatePackageReadme(content, template string, req migrateRequest) []string {
+	var findings []string
+	switch req.PackageType {
+	case packageTypeInput:
+		findings = validateInputReadme(content, template, req.SampleEvent)
+	case packageTypeContent:
+		findings = validateContentReadme(content, template)
+	default:
+		eventStreams := req.SampleEvents
+		if eventStreams == nil {
+			eventStreams = req.DataStreams
+		}
+		findings = validateReadme(content, template, req.DataStreams, eventStreams)
+	}
+	if !placeholders.custom() {
+		// Only elastic-package placeholders can be rendered
+		findings = append(findings, renderFindings(content)...)
+	}
+	findings = append(findings, linkFindings(content, req.Links)...)
+	findings = append(findings, licenseHeaderFindings(content, req.LicenseHeader)...)
+	findings = append(findings, screenshotFindings(content, req)...)
+	findings = append(findings, sectionMappingFindings(content, req.Readme, template, config.Sections)...)
+	findings = append(findings, glossaryFindings(content, glossary)...)
+	findings = append(findings, termFindings(content, config.Terms)...)
+	findings = append(findings, readabilityFindings(readability(content))...)
+	if hasAssetsSection(req) && applyAssetsSection(content, req.Assets, req.Screenshots) != content {
+		findings = append(findings, "dashboards section does not match the kibana assets and screenshots of the package")
+	}
+	if applyBadges(content, packageBadges(req)) != content {
+		findings = append(findings, "badges at the top of the readme do not match those of the original readme or manifest.yml")
+	}
+	if applySetupSection(content, req.Setup) != content {
+		findings = append(findings, "configuration settings do not match the policy_templates and vars of manifest.yml")
+	}
+	return findings
+}
+
+// validateInputReadme is validateReadme for input packages, which use the
+// package level fields and event placeholders
+func validateInputReadme(content, template string, sampleEvent bool) []string {
+	findings := missingSections(content, template)
+	if genericPlaceholder.MatchString(content) {
+		findings = append(findings, `generic "data_stream_name" placeholder left in readme`)
+	}
+	if !packageFieldsPattern.MatchString(content) {
+		findings = append(findings, "missing exported fields placeholder "+placeholders.PackageFields)
+	}
+	if sampleEvent && !packageEventPattern.MatchString(content) {
+		findings = append(findings, "missing sample event placeholder "+placeholders.PackageEvent)
+	}
+	return findings
+}
+
+// validateContentReadme is validateReadme for content packages, which have
+// no fields or sample events to document
+func validateContentReadme(content, template string) []string {
+	findings := missingSections(content, template)
+	if packageFieldsPattern.MatchString(content) || packageEventPattern.MatchString(content) || placeholderPattern.MatchString(content) {
+		findings = append(findings, "fields or event placeholder left in the readme of a content package")
+	}
+	return findings
+}
+
+// packageRequest describes a package on disk for a migration, the readme is
+// left for the caller to fill in
+func packageRequest(pkgPath string) (migrateRequest, error) {
+	req := migrateRequest{
+		Target:      outputTarget,
+		PackageType: packageTypeIntegration,
+		name:        filepath.Base(pkgPath),
+	}
+	if m, err := readManifest(pkgPath); err == nil {
+		if m.Type != "" {
+			req.PackageType = m.Type
+		}
+		req.Setup = &m.packageSetup
+		req.Version, req.Subscription = m.Version, m.Conditions.Elastic.Subscription
+		req.Screenshots = readScreenshots(pkgPath, m.Screenshots)
+		req.manifestScreenshots = m.Screenshots
+	}
+	images, err := readImages(pkgPath)
+	if err != nil {
+		return req, fmt.Errorf("failed to read images: %w", err)
+	}
+	req.Images = images
+	assets, err := readKibanaAssets(pkgPath)
+	if err != nil {
+		return req, fmt.Errorf("failed to read kibana assets: %w", err)
+	}
+	req.Assets = assets
+	if req.KnownIssues, err = readKnownIssues(pkgPath); err != nil {
+		return req, fmt.Errorf("failed to read known issues: %w", err)
+	}
+	if req.Links, err = readLinks(pkgPath); err != nil {
+		return req, err
+	}
+
+	if req.PackageType == packageTypeInput {
+		req.SampleEvent = hasPackageSampleEvent(pkgPath)
+		collection, err := readCollection(pkgPath, req)
+		if err != nil {
+			return req, fmt.Errorf("failed to read agent templates: %w", err)
+		}
+		req.Collection = collection
+		return req, nil
+	}
+	if req.PackageType == packageTypeContent {
+		return req, nil
+	}
+
+	dataStreams, err := findDataStreams(pkgPath)
+	if err != nil {
+		return req, fmt.Errorf("failed to find data streams: %w", err)
+	}
+	req.DataStreams = manifestOrder(dataStreams, req.Setup)
+	req.SampleEvents = sampleEventStreams(pkgPath, dataStreams)
+	if req.DataStreamTypes, err = readDataStreamTypes(pkgPath, dataStreams); err != nil {
+		return req, fmt.Errorf("failed to read data stream types: %w", err)
+	}
+	if req.Collection, err = readCollection(pkgPath, req); err != nil {
+		return req, fmt.Errorf("failed to read agent templates: %w", err)
+	}
+	return req, nil
+}
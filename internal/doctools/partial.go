@@ -0,0 +1,144 @@
+package doctools
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// partialPrompt is added to the prompt of a readme whose migration was
+// started by hand, with the sections already following the template and
+// those left to write
+const partialPrompt = `
+
+The readme was already partially migrated to the template. These sections already follow the template, copy them to the output verbatim, heading and content, where the template puts them: %s.
+Only write the other sections of the template, from the rest of the readme: %s.`
+
+// conformantSections returns the top sections of the template, below the
+// title, that the readme already has with content and with all their
+// template subsections, in the order of the template. They are kept as they
+// are by the migration.
+func conformantSections(readme, template string) []string {
+	lines := markdown.ParseLines(readme)
+	gaps := make(map[string]bool)
+	for _, g := range coverageGaps(readme, template) {
+		gaps[strings.ToLower(g.Section)] = true
+	}
+
+	var sections []string
+	required := templateHeadings(template)
+	for i, h := range required {
+		if h.Level != 2 {
+			continue
+		}
+		start := findSection(lines, h.Text, h.Level)
+		if start < 0 || gaps[strings.ToLower(h.Text)] {
+			continue
+		}
+		present := make(map[string]bool)
+		for _, l := range lines[start+1 : markdown.SectionEnd(lines, start)] {
+			if l.Level > 0 {
+				present[strings.ToLower(l.Heading)] = true
+			}
+		}
+		complete := true
+		for _, sub := range required[i+1:] {
+			if sub.Level <= h.Level {
+				break
+			}
+			if !present[strings.ToLower(sub.Text)] {
+				complete = false
+				break
+			}
+		}
+		if complete {
+			sections = append(sections, h.Text)
+		}
+	}
+	return sections
+}
+
+// missingTopSections returns the top sections of the template not in
+// sections
+func missingTopSections(template string, sections []string) []string {
+	var missing []string
+	for _, h := range templateHeadings(template) {
+		if h.Level == 2 && !slices.ContainsFunc(sections, func(s string) bool { return strings.EqualFold(s, h.Text) }) {
+			missing = append(missing, h.Text)
+		}
+	}
+	return missing
+}
+
+// partialReadmePrompt returns the instructions to keep the sections of a
+// partially migrated readme, empty if no section follows the template yet
+func partialReadmePrompt(template string, sections []string) string {
+	if len(sections) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(partialPrompt, quoteSections(sections), quoteSections(missingTopSections(template, sections)))
+}
+
+// quoteSections formats section names for a prompt
+func quoteSections(sections []string) string {
+	if len(sections) == 0 {
+		return "none"
+	}
+	quoted := make([]string, len(sections))
+	for i, s := range sections {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// findSection returns the index of the first heading with the given text and
+// level, or -1 if there is none
+func findSection(lines []markdown.Line, heading string, level int) int {
+	for i, line := range lines {
+		if line.Level == level && strings.EqualFold(line.Heading, heading) {
+			return i
+		}
+	}
+	return -1
+}
+
+// restoreConformantSections puts back the sections of a partially migrated
+// readme as they were in the original, in case the model changed them. A
+// section the model left out is put back before the next template section
+// of the migrated readme, or at its end.
+func restoreConformantSections(original, migrated, template string, sections []string) string {
+	originalLines := markdown.ParseLines(original)
+	var order []string
+	for _, h := range templateHeadings(template) {
+		if h.Level == 2 {
+			order = append(order, h.Text)
+		}
+	}
+
+	for _, name := range sections {
+		start := findSection(originalLines, name, 2)
+		if start < 0 {
+			continue
+		}
+		section := originalLines[start:markdown.SectionEnd(originalLines, start)]
+		lines := markdown.ParseLines(migrated)
+		if mStart := findSection(lines, name, 2); mStart >= 0 {
+			migrated = markdown.ShiftHeadings(slices.Concat(lines[:mStart], section, lines[markdown.SectionEnd(lines, mStart):]), 0)
+			continue
+		}
+
+		at := len(lines)
+		if i := slices.IndexFunc(order, func(s string) bool { return strings.EqualFold(s, name) }); i >= 0 {
+			for _, next := range order[i+1:] {
+				if n := findSection(lines, next, 2); n >= 0 {
+					at = n
+					break
+				}
+			}
+		}
+		migrated = markdown.ShiftHeadings(slices.Concat(lines[:at], section, lines[at:]), 0)
+	}
+	return migrated
+}
@@ -0,0 +1,123 @@
+package doctools
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Default paths of the readmes in a package
+const (
+	defaultSourceReadme = "docs/README.md"
+	defaultTargetReadme = "_dev/build/docs/readme.md"
+)
+
+// pathsConfig is the paths section of the -config file, for packages and
+// forks that keep their readmes elsewhere. The paths are relative to the
+// package, with slashes.
+type pathsConfig struct {
+	// Source is the rendered readme, docs/README.md by default.
+	Source string `yaml:"source"`
+	// Target is the readme template elastic-package renders the source
+	// from, _dev/build/docs/readme.md by default.
+	Target string `yaml:"target"`
+}
+
+var (
+	// sourceReadmeFlag and targetReadmeFlag are the paths of -source-readme
+	// and -target-readme
+	sourceReadmeFlag string
+	targetReadmeFlag string
+	// sourceReadmeRel and targetReadmeRel are the paths in use
+	sourceReadmeRel = defaultSourceReadme
+	targetReadmeRel = defaultTargetReadme
+)
+
+// applyPaths sets the readme paths from the flags, the configuration or the
+// defaults, in this order, and checks them
+func applyPaths(c pathsConfig) error {
+	for _, p := range []struct {
+		value *string
+		flags string
+		conf  string
+		def   string
+		name  string
+	}{
+		{&sourceReadmeRel, sourceReadmeFlag, c.Source, defaultSourceReadme, "source"},
+		{&targetReadmeRel, targetReadmeFlag, c.Target, defaultTargetReadme, "target"},
+	} {
+		value := p.flags
+		if value == "" {
+			value = p.conf
+		}
+		if value == "" {
+			value = p.def
+		}
+		clean := path.Clean(filepath.ToSlash(value))
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("%s readme %s must be relative to the package", p.name, value)
+		}
+		if !strings.EqualFold(path.Ext(clean), ".md") {
+			return fmt.Errorf("%s readme %s is not a markdown file", p.name, value)
+		}
+		*p.value = clean
+	}
+	if strings.EqualFold(sourceReadmeRel, targetReadmeRel) {
+		return fmt.Errorf("the source and target readme are both %s", sourceReadmeRel)
+	}
+	return nil
+}
+
+// sourceReadmePath returns the path of the rendered readme in the package
+func sourceReadmePath(pkgPath string) string {
+	return resolveCase(filepath.Join(pkgPath, filepath.FromSlash(sourceReadmeRel)))
+}
+
+// sourceDocsDir returns the directory of the rendered docs in the package
+func sourceDocsDir(pkgPath string) string {
+	return filepath.Dir(filepath.Join(pkgPath, filepath.FromSlash(sourceReadmeRel)))
+}
+
+// targetReadmePath returns the path of the readme template in the package,
+// or relative to the package when pkgPath is empty
+func targetReadmePath(pkgPath string) string {
+	if pkgPath == "" {
+		return filepath.FromSlash(targetReadmeRel)
+	}
+	return resolveCase(filepath.Join(pkgPath, filepath.FromSlash(targetReadmeRel)))
+}
+
+// caseVariants returns the names a file is looked up with when the file
+// system cannot be listed, such as on GitHub: the name, in lower case and
+// with an upper case stem, like readme.md and README.md
+func caseVariants(name string) []string {
+	ext := path.Ext(name)
+	variants := []string{name}
+	for _, v := range []string{strings.ToLower(name), strings.ToUpper(strings.TrimSuffix(name, ext)) + strings.ToLower(ext)} {
+		if !slices.Contains(variants, v) {
+			variants = append(variants, v)
+		}
+	}
+	return variants
+}
+
+// resolveCase returns the file in the directory of p whose name only differs
+// from the name of p in case, such as README.md for readme.md, when p does
+// not exist. Otherwise p is returned.
+func resolveCase(p string) string {
+	if _, err := pkgFS.Stat(p); err == nil {
+		return p
+	}
+	entries, err := pkgFS.ReadDir(filepath.Dir(p))
+	if err != nil {
+		return p
+	}
+	for _, e := range entries {
+		if !e.IsDir() && strings.EqualFold(e.Name(), filepath.Base(p)) {
+			return filepath.Join(filepath.Dir(p), e.Name())
+		}
+	}
+	return p
+}
@@ -0,0 +1,202 @@
+package doctools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+// Names of the stages run only for packages migrated on disk
+const (
+	stagePrepare = "prepare"
+	stageWrite   = "write"
+)
+
+// stageOrder lists every stage in the order they run
+var stageOrder = []string{stagePrepare, stageFetchTemplate, stageGenerate, stageApplyPlaceholders, stageValidate, stageDiff, stageWrite, stageVerify}
+
+// Stages the configuration cannot skip or replace: without them the
+// following stages have nothing to work on
+var (
+	requiredStages    = []string{stagePrepare, stageFetchTemplate}
+	replaceableStages = []string{stageGenerate, stageApplyPlaceholders}
+)
+
+// stage is a named step of the migration pipeline
+type stage struct {
+	name string
+	run  func(ctx context.Context, s *pipelineState) error
+}
+
+// pipelineState is the state the stages of a migration share
+type pipelineState struct {
+	req      migrateRequest
+	template string
+	// resp is filled in by the stages, its Markdown starts as the readme.
+	resp *migrateResponse
+	// kept are the warnings about the restored sections of kept data
+	// streams.
+	kept []string
+	// preserved are the sections of a partially migrated readme that
+	// already follow the template.
+	preserved []string
+	// dropped are the warnings about the context left out of the prompt
+	// to fit the token budget.
+	dropped []string
+
+	// The package and its files, for packages migrated on disk.
+	pkgPath    string
+	targetPath string
+	readPath   string
+	original   string
+	// dataStreams are all data streams of the package, also those not
+	// selected with -data-streams.
+	dataStreams    []string
+	dataStreamDocs map[string]string
+	consolidated   []string
+	// pages are the extra docs kept as pages with -extra-docs separate.
+	pages []docsPage
+	// samples are the sample events refreshed from the pipeline tests, by
+	// path.
+	samples map[string][]byte
+	// drift are the findings about the fields the hand-written tables of the
+	// readme document and the data streams define.
+	drift []string
+	// backend is the model the readme is generated with instead of the
+	// failover chain, -sensitive-model for a readme likely to trip the
+	// safety filters, nil for the chain.
+	backend *llmclient.Backend
+}
+
+// llmBackend returns the backend the readme is generated with
+func (s *pipelineState) llmBackend() llmclient.Backend {
+	if s.backend != nil {
+		return *s.backend
+	}
+	return currentBackend()
+}
+
+// stageTiming is how long a stage of a migration took
+type stageTiming struct {
+	Stage      string `json:"stage"`
+	DurationMS int64  `json:"duration_ms"`
+	// Skipped reports the stage was skipped by the configuration.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// stageError attributes an error to the stage it happened in
+type stageError struct {
+	stage string
+	err   error
+}
+
+func (e *stageError) Error() string {
+	return fmt.Sprintf("%s stage: %v", e.stage, e.err)
+}
+
+func (e *stageError) Unwrap() error {
+	return e.err
+}
+
+// stageConfig is the stages section of the -config file
+type stageConfig struct {
+	// Skip are the stages not to run.
+	Skip []string `yaml:"skip"`
+	// Replace maps stages to commands run instead of them. The command
+	// reads the readme on stdin and writes the result to stdout.
+	Replace map[string]string `yaml:"replace"`
+}
+
+// validate checks the stages of the configuration exist and can be skipped
+// or replaced
+func (c *stageConfig) validate() error {
+	for _, name := range c.Skip {
+		if !slices.Contains(stageOrder, name) {
+			return fmt.Errorf("unknown stage %q to skip, use one of %s", name, strings.Join(stageOrder, ", "))
+		}
+		if slices.Contains(requiredStages, name) {
+			return fmt.Errorf("stage %q cannot be skipped", name)
+		}
+	}
+	for name, command := range c.Replace {
+		if !slices.Contains(replaceableStages, name) {
+			return fmt.Errorf("stage %q cannot be replaced, only %s can", name, strings.Join(replaceableStages, " and "))
+		}
+		if len(strings.Fields(command)) == 0 {
+			return fmt.Errorf("no command to replace stage %q with", name)
+		}
+	}
+	return nil
+}
+
+// runStages runs stages one after the other, each in its own span, and
+// returns how long they took. Stages are skipped or replaced as configured.
+// If progress is not nil it is called as each stage starts.
+func runStages(ctx context.Context, stages []stage, s *pipelineState, progress func(stage string)) ([]stageTiming, error) {
+	var timings []stageTiming
+	for _, st := range stages {
+		if slices.Contains(config.Stages.Skip, st.name) {
+			timings = append(timings, stageTiming{Stage: st.name, Skipped: true})
+			continue
+		}
+		if command, ok := config.Stages.Replace[st.name]; ok {
+			st.run = commandStage(command)
+		}
+		if progress != nil {
+			progress(st.name)
+		}
+
+		stageCtx, span := tracer.Start(ctx, st.name)
+		started := time.Now()
+		err := st.run(stageCtx, s)
+		timings = append(timings, stageTiming{Stage: st.name, DurationMS: time.Since(started).Milliseconds()})
+		logDebug("Stage %s took %s", st.name, time.Since(started).Round(time.Millisecond))
+		if err != nil {
+			failSpan(span, err)
+			span.End()
+			return timings, &stageError{stage: st.name, err: err}
+		}
+		span.End()
+	}
+	return timings, nil
+}
+
+// commandStage returns a stage running command on the markdown instead of a
+// built-in stage. The command gets the template in the file named by
+// DOCS_TEMPLATE_UPDATE_TEMPLATE.
+func commandStage(command string) func(ctx context.Context, s *pipelineState) error {
+	return func(ctx context.Context, s *pipelineState) error {
+		tmp, err := os.CreateTemp("", "docs-template-update-template-*.md")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		_, err = tmp.WriteString(s.template)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+
+		args := strings.Fields(command)
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(s.resp.Markdown)
+		cmd.Env = append(os.Environ(), "DOCS_TEMPLATE_UPDATE_TEMPLATE="+tmp.Name())
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("failed to run %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		s.resp.Markdown = string(out)
+		return nil
+	}
+}
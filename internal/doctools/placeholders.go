@@ -0,0 +1,147 @@
+package doctools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+const (
+	// dataStreamVar stands for the data stream name in the placeholders of
+	// the -config file
+	dataStreamVar = "{data_stream}"
+	// genericDataStream is the data stream name of the placeholders of the
+	// template
+	genericDataStream = "data_stream_name"
+)
+
+// placeholderConfig is the placeholders section of the -config file, the
+// syntax of the fields and event placeholders of the build pipeline that
+// renders the readmes. The defaults are those of elastic-package.
+type placeholderConfig struct {
+	// Fields is the exported fields placeholder of a data stream, with
+	// {data_stream} for its name.
+	Fields string `yaml:"fields"`
+	// Event is the sample event placeholder of a data stream, with
+	// {data_stream} for its name.
+	Event string `yaml:"event"`
+	// PackageFields is the exported fields placeholder of input packages.
+	PackageFields string `yaml:"package_fields"`
+	// PackageEvent is the sample event placeholder of input packages.
+	PackageEvent string `yaml:"package_event"`
+}
+
+// defaultPlaceholders are the placeholders of elastic-package
+var defaultPlaceholders = placeholderConfig{
+	Fields:        `{{fields "{data_stream}"}}`,
+	Event:         `{{event "{data_stream}"}}`,
+	PackageFields: "{{fields}}",
+	PackageEvent:  "{{event}}",
+}
+
+var (
+	// placeholders are the placeholders in use
+	placeholders = defaultPlaceholders
+
+	// genericFieldsPattern and genericEventPattern match the placeholders
+	// of the template, for the generic data stream
+	genericFieldsPattern *regexp.Regexp
+	genericEventPattern  *regexp.Regexp
+	genericPlaceholder   *regexp.Regexp
+	// genericPlaceholderLine matches a line holding only a generic
+	// placeholder, which cannot be filled in without data streams
+	genericPlaceholderLine *regexp.Regexp
+	packageFieldsPattern   *regexp.Regexp
+	packageEventPattern    *regexp.Regexp
+	// placeholderPattern matches the placeholders of any data stream
+	placeholderPattern *regexp.Regexp
+)
+
+func init() {
+	compilePlaceholderPatterns()
+}
+
+// fields returns the exported fields placeholder of a data stream
+func (c placeholderConfig) fields(dataStream string) string {
+	return strings.ReplaceAll(c.Fields, dataStreamVar, dataStream)
+}
+
+// event returns the sample event placeholder of a data stream
+func (c placeholderConfig) event(dataStream string) string {
+	return strings.ReplaceAll(c.Event, dataStreamVar, dataStream)
+}
+
+// custom reports whether the placeholders are not those of elastic-package
+func (c placeholderConfig) custom() bool {
+	return c != defaultPlaceholders
+}
+
+// rewrite replaces the elastic-package placeholders of a prompt with those
+// in use
+func (c placeholderConfig) rewrite(text string) string {
+	if !c.custom() {
+		return text
+	}
+	return strings.NewReplacer(
+		defaultPlaceholders.fields(genericDataStream), c.fields(genericDataStream),
+		defaultPlaceholders.event(genericDataStream), c.event(genericDataStream),
+		defaultPlaceholders.PackageFields, c.PackageFields,
+		defaultPlaceholders.PackageEvent, c.PackageEvent,
+	).Replace(text)
+}
+
+// applyPlaceholders sets the placeholders from the configuration, with the
+// defaults for those it leaves out, and checks them
+func applyPlaceholders(c placeholderConfig) error {
+	for _, p := range []struct {
+		value *string
+		def   string
+		name  string
+		perDS bool
+	}{
+		{&c.Fields, defaultPlaceholders.Fields, "fields", true},
+		{&c.Event, defaultPlaceholders.Event, "event", true},
+		{&c.PackageFields, defaultPlaceholders.PackageFields, "package_fields", false},
+		{&c.PackageEvent, defaultPlaceholders.PackageEvent, "package_event", false},
+	} {
+		*p.value = strings.TrimSpace(*p.value)
+		if *p.value == "" {
+			*p.value = p.def
+		}
+		if has := strings.Contains(*p.value, dataStreamVar); has != p.perDS {
+			if p.perDS {
+				return fmt.Errorf("%s placeholder %s has no %s", p.name, *p.value, dataStreamVar)
+			}
+			return fmt.Errorf("%s placeholder %s cannot have a %s", p.name, *p.value, dataStreamVar)
+		}
+	}
+	if c.Fields == c.Event || c.PackageFields == c.PackageEvent {
+		return fmt.Errorf("the fields and event placeholders must differ")
+	}
+	placeholders = c
+	compilePlaceholderPatterns()
+	return nil
+}
+
+// compilePlaceholderPatterns compiles the patterns matching the placeholders
+// in use
+func compilePlaceholderPatterns() {
+	fields := markdown.PlaceholderRegexp(placeholders.Fields, dataStreamVar, genericDataStream)
+	event := markdown.PlaceholderRegexp(placeholders.Event, dataStreamVar, genericDataStream)
+	genericFieldsPattern = regexp.MustCompile(fields)
+	genericEventPattern = regexp.MustCompile(event)
+	genericPlaceholder = regexp.MustCompile(fields + "|" + event)
+	genericPlaceholderLine = regexp.MustCompile(`(?m)^[ \t]*(?:` + fields + "|" + event + `)[ \t]*\n?(?:[ \t]*\n)?`)
+	packageFieldsPattern = regexp.MustCompile(markdown.PlaceholderRegexp(placeholders.PackageFields, dataStreamVar, ""))
+	packageEventPattern = regexp.MustCompile(markdown.PlaceholderRegexp(placeholders.PackageEvent, dataStreamVar, ""))
+	placeholderPattern = regexp.MustCompile(markdown.PlaceholderRegexp(placeholders.Fields, dataStreamVar, `[^\s"']*`) + "|" + markdown.PlaceholderRegexp(placeholders.Event, dataStreamVar, `[^\s"']*`))
+
+	// Translations keep the placeholders of other syntaxes too
+	protected := protectedSource
+	if placeholders.custom() {
+		protected += "|" + placeholderPattern.String() + "|" + packageFieldsPattern.String() + "|" + packageEventPattern.String()
+	}
+	protectedPattern = regexp.MustCompile(protected)
+}
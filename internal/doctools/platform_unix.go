@@ -0,0 +1,22 @@
+//go:build !windows
+
+package doctools
+
+import (
+	"os"
+	"syscall"
+)
+
+// shutdownSignals stop a run, the stages in progress are cancelled
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// replaceFile renames from to to, replacing to if it exists
+func replaceFile(from, to string) error {
+	return os.Rename(from, to)
+}
+
+// replaceExecutable replaces the executable at to, which may be running, by
+// from. The running process keeps the file it was started from.
+func replaceExecutable(from, to string) error {
+	return os.Rename(from, to)
+}
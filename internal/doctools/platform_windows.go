@@ -0,0 +1,56 @@
+//go:build windows
+
+package doctools
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// shutdownSignals stop a run, the stages in progress are cancelled. Windows
+// only delivers Ctrl+C and Ctrl+Break, as os.Interrupt.
+var shutdownSignals = []os.Signal{os.Interrupt}
+
+// errorSharingViolation is returned when another process has the file open
+const errorSharingViolation syscall.Errno = 32
+
+// replaceFileAttempts bounds the retries of replaceFile
+const replaceFileAttempts = 10
+
+// replaceFile renames from to to, replacing to if it exists. Unlike on Unix
+// the rename fails while another process, such as an editor, a virus scanner
+// or the search indexer, has it open, so it is retried for about a second.
+func replaceFile(from, to string) error {
+	var err error
+	for attempt := range replaceFileAttempts {
+		if err = os.Rename(from, to); err == nil {
+			return nil
+		}
+		if !errors.Is(err, syscall.ERROR_ACCESS_DENIED) && !errors.Is(err, errorSharingViolation) {
+			return err
+		}
+		time.Sleep(time.Duration(attempt+1) * 20 * time.Millisecond)
+	}
+	return err
+}
+
+// replaceExecutable replaces the executable at to, which may be running, by
+// from. Windows cannot overwrite a running executable but can rename it, so
+// it is moved aside to a .old file, removed by the next replacement.
+func replaceExecutable(from, to string) error {
+	old := to + ".old"
+	if err := os.Remove(old); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(to, old); err != nil {
+		return err
+	}
+	if err := replaceFile(from, to); err != nil {
+		// Put the running executable back in place
+		_ = os.Rename(old, to)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package doctools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// promptPreset are the prompts a readme is migrated with. A -prompts file
+// replaces some of the built-in prompts, those it leaves out are kept.
+type promptPreset struct {
+	// System gives the LLM the original readme and the template, in this
+	// order, as %s verbs.
+	System string `yaml:"system"`
+	// Readme are the instructions for integrations with data streams,
+	// NoDataStreams for those without.
+	Readme        string `yaml:"readme"`
+	NoDataStreams string `yaml:"no_data_streams"`
+	Input         string `yaml:"input"`
+	Content       string `yaml:"content"`
+	DocsV3        string `yaml:"docs_v3"`
+}
+
+var (
+	// promptsPath is the prompt preset file of -prompts
+	promptsPath string
+	// prompts are the prompts in use
+	prompts = builtinPrompts()
+)
+
+// builtinPrompts returns the prompts used without -prompts
+func builtinPrompts() promptPreset {
+	return promptPreset{
+		System:        systemPrompt,
+		Readme:        userPromptTemplate,
+		NoDataStreams: noDataStreamsPromptTemplate,
+		Input:         inputPromptTemplate,
+		Content:       contentPromptTemplate,
+		DocsV3:        docsV3Prompt,
+	}
+}
+
+// registerPromptFlags adds the -prompts flag to fs
+func registerPromptFlags(fs *flag.FlagSet) {
+	fs.StringVar(&promptsPath, "prompts", "", "YAML file replacing some of the built-in prompts, see the README for its settings")
+}
+
+// loadPrompts reads the -prompts file
+func loadPrompts() error {
+	if promptsPath == "" {
+		return nil
+	}
+	p, err := readPromptPreset(promptsPath)
+	if err != nil {
+		return err
+	}
+	prompts = p
+	return nil
+}
+
+// readPromptPreset reads a prompt preset file on top of the built-in
+// prompts
+func readPromptPreset(path string) (promptPreset, error) {
+	p := builtinPrompts()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, fmt.Errorf("failed to read prompts: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("failed to parse prompts %s: %w", path, err)
+	}
+	if n := strings.Count(p.System, "%s"); n != 2 {
+		return p, fmt.Errorf("system prompt of %s needs two %%s verbs, for the readme and the template, found %d", path, n)
+	}
+	return p, nil
+}
+
+// promptVersion identifies the prompts in use by a hash of their text, so
+// runs can be compared across prompt changes. It covers the presets and the
+// instructions added for the features of a package, not those of
+// translations and localized readmes.
+func promptVersion() string {
+	h := sha256.New()
+	for _, p := range []string{
+		prompts.System, prompts.Readme, prompts.NoDataStreams, prompts.Input, prompts.Content, prompts.DocsV3,
+		keptDataStreamsPrompt, dashboardsPrompt, setupPrompt, collectionPrompt, glossaryPrompt, ecsPrompt, troubleshootingPrompt, partialPrompt, docsPagesPrompt, linksPrompt, sectionsPrompt, styleExamplesPrompt, sectionsJSONPrompt, sectionsRetryPrompt, operationsPrompt, degenerateRetryPrompt, sensitiveFramingPrompt,
+	} {
+		h.Write([]byte(p))
+		// Separate the prompts, so moving text from one to the next changes
+		// the hash
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
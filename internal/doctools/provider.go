@@ -0,0 +1,131 @@
+package doctools
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+var (
+	// llmProvider is the provider of -provider
+	llmProvider = llmclient.ProviderGemini
+	// modelName is the model of -model used to restructure the readme
+	modelName = "gemini-2.5-pro"
+	// fakeResponsePath is the file of -fake-response
+	fakeResponsePath string
+	// fakeResponse is the answer of the fake provider
+	fakeResponse string
+	// requestsPerMinute is the rate limit of -llm-rpm
+	requestsPerMinute int
+	// llmLimiter applies -llm-rpm to the requests of every LLM client
+	llmLimiter *llmclient.Limiter
+)
+
+// registerProviderFlags adds the LLM provider flags to fs
+func registerProviderFlags(fs *flag.FlagSet) {
+	fs.StringVar(&llmProvider, "provider", llmProvider, "LLM provider: gemini, or fake to answer every prompt with -fake-response without an API key")
+	fs.StringVar(&modelName, "model", modelName, "Gemini model used to restructure the readme")
+	fs.Float64Var(&temperature, "temperature", temperature, "Temperature of the model from 0 to 2, negative for the default of the model; see the sweep subcommand to pick one")
+	fs.StringVar(&responseFormat, "response-format", responseFormat, "Format of the answer of the model: markdown, json for the content of each template section in structured output mode, assembled into the readme by the tool, operations for function calls editing the original readme, applied by the tool with an edit log, or chunked for a call per top-level template section, run concurrently and assembled in template order. Docs-v3 pages are always markdown")
+	fs.IntVar(&sectionConcurrency, "section-concurrency", sectionConcurrency, "With -response-format chunked, how many sections are generated at the same time")
+	fs.IntVar(&tokenBudget, "token-budget", 0, "Tokens the prompt may use, estimated at four bytes per token: the readme and the template first, then the instructions, then optional context such as style examples and ECS field descriptions, left out when over budget (0 means the context window of the model minus room for the answer)")
+	fs.StringVar(&fallbackModels, "fallback-models", "", "Comma-separated models to fail over to, in order, when the quota of the model in use is exhausted or it fails 3 times in a row, as in benchmark -models, e.g. gemini-2.0-flash")
+	fs.DurationVar(&failoverCooldown, "failover-cooldown", failoverCooldown, "How long after failing over to one of -fallback-models the run goes back to -model (0 means never)")
+	fs.StringVar(&sensitiveModel, "sensitive-model", "", "Gemini model migrating the readmes likely to trip the safety filters of -model, such as those of security integrations describing malware and exploits")
+	fs.IntVar(&candidateCount, "candidates", candidateCount, "How many readmes to generate for a package, scored with the validators and -candidate-judge, the best is kept")
+	fs.StringVar(&candidateJudge, "candidate-judge", "", "Model also scoring the completeness, structure and clarity of the -candidates, as in benchmark -models; empty to only use the validators")
+	fs.IntVar(&requestsPerMinute, "llm-rpm", 0, "Most requests sent to the LLM provider a minute, shared by the concurrent migrations, to stay within the rate limit of the API key (0 means no limit)")
+	fs.StringVar(&fakeResponsePath, "fake-response", "", "With -provider fake, markdown file returned as the answer to every prompt")
+}
+
+// loadProvider checks the provider flags and reads the answer of the fake
+// provider
+func loadProvider() error {
+	if err := validateResponseFormat(responseFormat); err != nil {
+		return err
+	}
+	if sectionConcurrency < 1 {
+		return fmt.Errorf("-section-concurrency must be at least 1")
+	}
+	if temperature > 2 {
+		return fmt.Errorf("-temperature must not be above 2")
+	}
+	if tokenBudget < 0 {
+		return fmt.Errorf("-token-budget must not be negative")
+	}
+	if requestsPerMinute < 0 {
+		return fmt.Errorf("-llm-rpm must not be negative")
+	}
+	llmLimiter = llmclient.NewLimiter(requestsPerMinute)
+	if err := loadCandidates(); err != nil {
+		return err
+	}
+	if err := loadFailover(); err != nil {
+		return err
+	}
+	if sensitiveModel != "" {
+		if err := checkModelAllowed(sensitiveModel); err != nil {
+			return fmt.Errorf("invalid -sensitive-model: %w", err)
+		}
+	}
+	switch llmProvider {
+	case llmclient.ProviderGemini:
+		return checkModelAllowed(modelName)
+	case llmclient.ProviderFake:
+		return readFakeResponse()
+	}
+	return fmt.Errorf("unknown provider %q, use gemini or fake", llmProvider)
+}
+
+// readFakeResponse reads the -fake-response of the fake provider
+func readFakeResponse() error {
+	if fakeResponsePath == "" {
+		return fmt.Errorf("-provider fake needs -fake-response")
+	}
+	data, err := os.ReadFile(fakeResponsePath)
+	if err != nil {
+		return fmt.Errorf("failed to read fake response: %w", err)
+	}
+	fakeResponse = string(data)
+	return nil
+}
+
+// newLLMClient returns a client of the provider and model of b, set up from
+// the flags, to be closed after use
+func newLLMClient(ctx context.Context, b llmclient.Backend) (*llmclient.Client, error) {
+	var transport http.RoundTripper
+	if customTransport {
+		transport = otelhttp.NewTransport(outboundTransport)
+	}
+	return llmclient.New(ctx, llmclient.Config{
+		Provider:     b.Provider,
+		Model:        b.Model,
+		APIKey:       googleAPIKey,
+		Transport:    transport,
+		Temperature:  temperature,
+		FakeResponse: fakeResponse,
+		Limiter:      llmLimiter,
+		Observe:      observeLLMRequest,
+	})
+}
+
+// observeLLMRequest records the duration, tokens and errors of a request to
+// the provider in the metrics
+func observeLLMRequest(model string, elapsed time.Duration, usage llmclient.Usage, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		providerErrors.WithLabelValues(model).Inc()
+	}
+	llmRequestDuration.WithLabelValues(model, outcome).Observe(elapsed.Seconds())
+	llmTokens.WithLabelValues(model, "in").Add(float64(usage.PromptTokens))
+	llmTokens.WithLabelValues(model, "out").Add(float64(usage.ResponseTokens))
+	logDebug("LLM call to %s took %s and used %d prompt and %d response tokens", model, elapsed.Round(time.Millisecond), usage.PromptTokens, usage.ResponseTokens)
+}
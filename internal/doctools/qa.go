@@ -0,0 +1,402 @@
+package doctools
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+const (
+	// defaultDocsIndex is the file of the docs index of the qa subcommand
+	defaultDocsIndex = "docs-index.json"
+	// maxDocsChunk bounds the bytes of a chunk of the docs index
+	maxDocsChunk = 2000
+	// notDocumented is the answer of the model to questions the excerpts do
+	// not answer
+	notDocumented = "NOT DOCUMENTED"
+)
+
+// qaPrompt asks the model to answer a question from excerpts of the docs
+const qaPrompt = `Answer the question about Elastic integrations using only these numbered excerpts of their documentation.
+
+Follow these exact guidelines:
+1. Only use what the excerpts say, do not add what you know about the integrations
+2. Cite the excerpts you used with their number in brackets, e.g. [2]
+3. Keep the answer short, quoting settings, field names and commands as they are written
+4. If the excerpts do not answer the question, answer exactly ` + notDocumented + `
+
+# Excerpts
+%s
+# Question
+%s`
+
+// docsIndexFile is the index of the qa subcommand, the chunks of the built
+// docs of the packages with their embeddings
+type docsIndexFile struct {
+	// Model is the embedding model of the chunks, only questions embedded
+	// with the same model compare.
+	Model  string      `json:"model"`
+	Chunks []docsChunk `json:"chunks"`
+}
+
+// docsChunk is a part of a section of a docs page of a package
+type docsChunk struct {
+	Package string `json:"package"`
+	Page    string `json:"page"`
+	// Heading is the heading of the section, after those of the sections it
+	// is nested in, e.g. Setup > Collecting logs.
+	Heading   string    `json:"heading"`
+	Text      string    `json:"text"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// source names the page and section of a chunk
+func (c docsChunk) source() string {
+	s := c.Package + "/" + c.Page
+	if c.Heading != "" {
+		s += " > " + c.Heading
+	}
+	return s
+}
+
+// RunQA runs the qa command with the arguments args
+func RunQA(args []string) {
+	if len(args) == 0 || (args[0] != "index" && args[0] != "ask") {
+		fmt.Fprintf(os.Stderr, "Usage: %s index|ask [options]\n", os.Args[0])
+		os.Exit(2)
+	}
+	if args[0] == "index" {
+		runQAIndex(args[1:])
+		return
+	}
+	runQAAsk(args[1:])
+}
+
+// registerQAFlags adds the flags of both qa subcommands to fs
+func registerQAFlags(fs *flag.FlagSet) {
+	fs.StringVar(&googleAPIKey, "api-key", "", "Google Gemini API key")
+	fs.StringVar(&apiKeyRef, "api-key-ref", "", secretRefHelp)
+	fs.StringVar(&embeddingModel, "embedding-model", defaultEmbeddingModel, "Gemini model of the embeddings of the docs index")
+	registerVerbosityFlags(fs)
+	registerProviderFlags(fs)
+	registerNetworkFlags(fs)
+	registerTimeoutFlags(fs)
+	registerConfigFlags(fs)
+}
+
+// loadQAFlags checks the flags of both qa subcommands
+func loadQAFlags() {
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateTimeouts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadProvider(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	requireAPIKey()
+}
+
+// runQAIndex builds the docs index of the packages of a directory
+func runQAIndex(args []string) {
+	fs := flag.NewFlagSet("qa index", flag.ExitOnError)
+	dir := fs.String("packages", "", "Directory of the packages whose built docs are indexed (required)")
+	output := fs.String("o", defaultDocsIndex, "File the docs index is written to")
+	registerQAFlags(fs)
+	_ = fs.Parse(args)
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "qa index needs -packages")
+		fs.Usage()
+		os.Exit(2)
+	}
+	loadQAFlags()
+
+	index, err := buildDocsIndex(context.Background(), *dir)
+	if err != nil {
+		log.Fatalf("Error building docs index: %v", err)
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := writeFileAtomic(*output, append(data, '\n'), 0o644); err != nil {
+		log.Fatalf("Error writing docs index: %v", err)
+	}
+	logInfo("Indexed %d chunks in %s", len(index.Chunks), *output)
+}
+
+// buildDocsIndex splits the built docs of the packages of dir, the markdown
+// pages of their docs directory, into chunks and embeds them
+func buildDocsIndex(ctx context.Context, dir string) (*docsIndexFile, error) {
+	pkgs, err := findPackages(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages: %w", err)
+	}
+	index := &docsIndexFile{Model: styleEmbeddingModel(), Chunks: []docsChunk{}}
+	for _, pkgPath := range pkgs {
+		docsDir := sourceDocsDir(pkgPath)
+		entries, err := pkgFS.ReadDir(docsDir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var chunks []docsChunk
+		for _, e := range entries {
+			if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".md") {
+				continue
+			}
+			content, _, err := readMarkdown(filepath.Join(docsDir, e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range docsChunks(content) {
+				c.Package, c.Page = filepath.Base(pkgPath), e.Name()
+				chunks = append(chunks, c)
+			}
+		}
+		if len(chunks) == 0 {
+			continue
+		}
+		texts := make([]string, len(chunks))
+		for i, c := range chunks {
+			texts[i] = c.Heading + "\n\n" + c.Text
+		}
+		embeddings, err := embedTexts(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed the docs of %s: %w", filepath.Base(pkgPath), err)
+		}
+		for i := range chunks {
+			chunks[i].Embedding = embeddings[i]
+		}
+		index.Chunks = append(index.Chunks, chunks...)
+		if verbose {
+			log.Printf("Indexed %d chunks of %s", len(chunks), pkgPath)
+		}
+	}
+	return index, nil
+}
+
+// docsChunks splits a docs page into its sections, without comments, under
+// the headings of the sections they are nested in. Sections longer than
+// maxDocsChunk are split at paragraphs, or lines for long tables.
+func docsChunks(content string) []docsChunk {
+	var chunks []docsChunk
+	var path []markdown.Line
+	var texts []string
+	flush := func() {
+		text := strings.TrimSpace(markdown.CommentPattern.ReplaceAllString(strings.Join(texts, "\n"), ""))
+		texts = nil
+		if text == "" {
+			return
+		}
+		headings := make([]string, len(path))
+		for i, h := range path {
+			headings[i] = h.Heading
+		}
+		for _, part := range splitChunk(text, maxDocsChunk) {
+			chunks = append(chunks, docsChunk{Heading: strings.Join(headings, " > "), Text: part})
+		}
+	}
+	for _, line := range markdown.ParseLines(content) {
+		if line.Level == 0 {
+			texts = append(texts, line.Text)
+			continue
+		}
+		flush()
+		for len(path) > 0 && path[len(path)-1].Level >= line.Level {
+			path = path[:len(path)-1]
+		}
+		path = append(path, line)
+	}
+	flush()
+	return chunks
+}
+
+// splitChunk splits text into parts of at most size bytes, at blank lines,
+// or else at line ends. A single line longer than size is cut.
+func splitChunk(text string, size int) []string {
+	if len(text) <= size {
+		return []string{text}
+	}
+	sep := "\n\n"
+	pieces := strings.Split(text, sep)
+	if len(pieces) == 1 {
+		sep = "\n"
+		if pieces = strings.Split(text, sep); len(pieces) == 1 {
+			cut := strings.ToValidUTF8(text[:size], "")
+			return append([]string{cut}, splitChunk(text[len(cut):], size)...)
+		}
+	}
+	var parts []string
+	var current string
+	for _, p := range pieces {
+		if current != "" && len(current)+len(sep)+len(p) > size {
+			parts = append(parts, splitChunk(current, size)...)
+			current = ""
+		}
+		if current != "" {
+			current += sep
+		}
+		current += p
+	}
+	return append(parts, splitChunk(current, size)...)
+}
+
+// loadDocsIndex reads a docs index built with the embedding model in use
+func loadDocsIndex(path string) (*docsIndexFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read docs index: %w", err)
+	}
+	var index docsIndexFile
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse docs index: %w", err)
+	}
+	if model := styleEmbeddingModel(); index.Model != model {
+		return nil, fmt.Errorf("docs index %s was built with %s, not %s", path, index.Model, model)
+	}
+	return &index, nil
+}
+
+// runQAAsk answers questions from the docs index, given as arguments, in a
+// file, or else read from standard input one per line
+func runQAAsk(args []string) {
+	fs := flag.NewFlagSet("qa ask", flag.ExitOnError)
+	indexPath := fs.String("index", defaultDocsIndex, "Docs index built with qa index")
+	top := fs.Int("top", 6, "Number of chunks of the docs given to the model to answer from")
+	pkg := fs.String("package", "", "Only answer from the docs of this package")
+	questionsFile := fs.String("questions", "", "File of questions, one per line, to check the docs answer them; exits with 1 if some are not documented")
+	registerQAFlags(fs)
+	_ = fs.Parse(args)
+	if *top < 1 {
+		log.Fatalf("Error: -top must be at least 1")
+	}
+	loadQAFlags()
+
+	index, err := loadDocsIndex(*indexPath)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *pkg != "" {
+		index.Chunks = slices.DeleteFunc(index.Chunks, func(c docsChunk) bool { return c.Package != *pkg })
+		if len(index.Chunks) == 0 {
+			log.Fatalf("Error: no docs of package %s in %s", *pkg, *indexPath)
+		}
+	}
+	ctx := context.Background()
+
+	if *questionsFile != "" {
+		data, err := os.ReadFile(*questionsFile)
+		if err != nil {
+			log.Fatalf("Error reading -questions: %v", err)
+		}
+		var missing []string
+		for _, q := range strings.Split(string(data), "\n") {
+			if q = strings.TrimSpace(q); q == "" || strings.HasPrefix(q, "#") {
+				continue
+			}
+			answer, _, err := answerQuestion(ctx, index, q, *top)
+			if err != nil {
+				log.Fatalf("Error answering %q: %v", q, err)
+			}
+			status := "documented"
+			if answer == notDocumented {
+				status = "NOT documented"
+				missing = append(missing, q)
+			}
+			fmt.Printf("%s: %s\n", status, q)
+		}
+		if len(missing) > 0 {
+			fmt.Printf("\n%d questions are not answered by the docs\n", len(missing))
+			os.Exit(1)
+		}
+		return
+	}
+
+	if fs.NArg() > 0 {
+		printAnswer(ctx, index, strings.Join(fs.Args(), " "), *top)
+		return
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for fmt.Fprint(os.Stderr, "> "); scanner.Scan(); fmt.Fprint(os.Stderr, "> ") {
+		if q := strings.TrimSpace(scanner.Text()); q != "" {
+			printAnswer(ctx, index, q, *top)
+			fmt.Println()
+		}
+	}
+}
+
+// printAnswer prints the answer to a question with the sections it cites
+func printAnswer(ctx context.Context, index *docsIndexFile, question string, top int) {
+	answer, chunks, err := answerQuestion(ctx, index, question, top)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return
+	}
+	fmt.Println(answer)
+	if answer == notDocumented {
+		return
+	}
+	fmt.Println()
+	for i, c := range chunks {
+		if strings.Contains(answer, fmt.Sprintf("[%d]", i+1)) {
+			fmt.Printf("[%d] %s\n", i+1, c.source())
+		}
+	}
+}
+
+// answerQuestion asks the model to answer a question from the top chunks of
+// the docs index closest to it, and returns its answer with the chunks, in
+// the order they were numbered in the prompt
+func answerQuestion(ctx context.Context, index *docsIndexFile, question string, top int) (string, []docsChunk, error) {
+	query, err := embedText(ctx, question)
+	if err != nil {
+		return "", nil, err
+	}
+	type scored struct {
+		chunk docsChunk
+		score float64
+	}
+	ranked := make([]scored, len(index.Chunks))
+	for i, c := range index.Chunks {
+		ranked[i] = scored{c, cosineSimilarity(query, c.Embedding)}
+	}
+	slices.SortStableFunc(ranked, func(a, b scored) int { return cmp.Compare(b.score, a.score) })
+	chunks := make([]docsChunk, 0, top)
+	var b strings.Builder
+	for i, r := range ranked[:min(top, len(ranked))] {
+		chunks = append(chunks, r.chunk)
+		fmt.Fprintf(&b, "[%d] %s\n%s\n\n", i+1, r.chunk.source(), r.chunk.Text)
+	}
+	answer, _, _, err := generateWithFailover(ctx, func(backend llmclient.Backend) (string, llmclient.Usage, error) {
+		return generateText(ctx, backend, fmt.Sprintf(qaPrompt, b.String(), question))
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	answer = strings.TrimSpace(answer)
+	if strings.Trim(answer, ".* ") == notDocumented {
+		answer = notDocumented
+	}
+	return answer, chunks, nil
+}
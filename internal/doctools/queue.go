@@ -0,0 +1,377 @@
+package doctools
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// workerTokenEnv is the environment variable holding the token shared by the
+// coordinator and its workers
+const workerTokenEnv = "DOCS_TEMPLATE_UPDATE_WORKER_TOKEN"
+
+const queueSchema = `
+CREATE TABLE IF NOT EXISTS queue_jobs (
+	id          TEXT PRIMARY KEY,
+	created_at  TIMESTAMP NOT NULL,
+	finished_at TIMESTAMP,
+	cancelled   INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS queue_items (
+	job_id           TEXT NOT NULL REFERENCES queue_jobs(id) ON DELETE CASCADE,
+	package          TEXT NOT NULL,
+	position         INTEGER NOT NULL,
+	request          TEXT NOT NULL,
+	status           TEXT NOT NULL,
+	worker           TEXT NOT NULL DEFAULT '',
+	lease_id         TEXT NOT NULL DEFAULT '',
+	lease_expires_at TIMESTAMP,
+	attempts         INTEGER NOT NULL DEFAULT 0,
+	result           TEXT NOT NULL DEFAULT '',
+	error            TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (job_id, package)
+);
+
+CREATE INDEX IF NOT EXISTS queue_items_status ON queue_items(status, lease_expires_at);
+`
+
+// errLeaseNotFound is returned when completing work with an unknown or
+// expired lease
+var errLeaseNotFound = errors.New("lease not found or expired")
+
+// errLeaseExpired is the error recorded for packages whose lease expired on
+// every attempt
+const errLeaseExpired = "lease expired on every attempt, the package may be crashing workers"
+
+// workQueue is a SQLite backed queue of packages to migrate. It lets a
+// coordinator hand out work to remote workers and survives restarts.
+type workQueue struct {
+	db *sql.DB
+	// maxAttempts is how many times a package is leased before it is marked
+	// as failed, so a package that crashes workers does not loop forever.
+	maxAttempts int
+}
+
+// workItem is a package leased to a worker
+type workItem struct {
+	LeaseID        string         `json:"lease_id"`
+	LeaseExpiresAt time.Time      `json:"lease_expires_at"`
+	JobID          string         `json:"job_id"`
+	Package        string         `json:"package"`
+	Request        migrateRequest `json:"request"`
+}
+
+// workResult is what a worker reports back for a leased package
+type workResult struct {
+	Result     *migrateResponse `json:"result,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	DurationMS int64            `json:"duration_ms"`
+}
+
+// openQueue opens, and creates if needed, the queue database at path
+func openQueue(path string) (*workQueue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(queueSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create queue schema: %w", err)
+	}
+	return &workQueue{db: db, maxAttempts: 3}, nil
+}
+
+func (q *workQueue) Close() error {
+	return q.db.Close()
+}
+
+// enqueue adds all packages of a job to the queue
+func (q *workQueue) enqueue(jobID string, created time.Time, pkgs []jobPackage) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // Rollback after Commit is a no-op.
+
+	if _, err := tx.Exec(`INSERT INTO queue_jobs (id, created_at) VALUES (?, ?)`, jobID, created.UTC()); err != nil {
+		return err
+	}
+	for i, p := range pkgs {
+		req, err := json.Marshal(p.migrateRequest)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO queue_items (job_id, package, position, request, status) VALUES (?, ?, ?, ?, ?)`,
+			jobID, p.Name, i, string(req), statusQueued); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// workRef identifies a package of a job in the queue
+type workRef struct {
+	JobID   string
+	Package string
+}
+
+// expireLeases queues packages whose lease expired again, presumably their
+// worker died. Packages that already used up their attempts are marked as
+// failed and returned.
+func (q *workQueue) expireLeases() ([]workRef, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck // Rollback after Commit is a no-op.
+
+	now := time.Now().UTC()
+	rows, err := tx.Query(`SELECT job_id, package FROM queue_items WHERE status = ? AND lease_expires_at < ? AND attempts >= ?`,
+		statusRunning, now, q.maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	var failed []workRef
+	for rows.Next() {
+		var ref workRef
+		if err := rows.Scan(&ref.JobID, &ref.Package); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		failed = append(failed, ref)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`UPDATE queue_items SET status = ?, error = ?, lease_id = ''
+		WHERE status = ? AND lease_expires_at < ? AND attempts >= ?`,
+		statusFailed, errLeaseExpired, statusRunning, now, q.maxAttempts); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE queue_items SET status = ?, worker = '', lease_id = ''
+		WHERE status = ? AND lease_expires_at < ?`, statusQueued, statusRunning, now); err != nil {
+		return nil, err
+	}
+	return failed, tx.Commit()
+}
+
+// lease hands the oldest queued package to worker for ttl. It returns nil if
+// there is no work.
+func (q *workQueue) lease(worker string, ttl time.Duration) (*workItem, error) {
+	leaseID, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := q.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck // Rollback after Commit is a no-op.
+
+	now := time.Now().UTC()
+	item := workItem{LeaseID: leaseID, LeaseExpiresAt: now.Add(ttl)}
+	var req string
+	err = tx.QueryRow(`SELECT i.job_id, i.package, i.request FROM queue_items i
+		JOIN queue_jobs j ON j.id = i.job_id
+		WHERE i.status = ? AND j.cancelled = 0
+		ORDER BY j.created_at, i.position
+		LIMIT 1`, statusQueued).Scan(&item.JobID, &item.Package, &req)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, tx.Commit()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(req), &item.Request); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`UPDATE queue_items SET status = ?, worker = ?, lease_id = ?, lease_expires_at = ?, attempts = attempts + 1
+		WHERE job_id = ? AND package = ?`, statusRunning, worker, leaseID, item.LeaseExpiresAt, item.JobID, item.Package); err != nil {
+		return nil, err
+	}
+	return &item, tx.Commit()
+}
+
+// complete stores the result of a leased package and returns which package
+// it was
+func (q *workQueue) complete(leaseID string, res workResult) (jobID, pkg string, err error) {
+	status := statusSucceeded
+	if res.Error != "" {
+		status = statusFailed
+	}
+	// A failed package has a result only for the usage of its calls
+	var result []byte
+	if res.Result != nil {
+		if result, err = json.Marshal(res.Result); err != nil {
+			return "", "", err
+		}
+	}
+
+	tx, err := q.db.Begin()
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback() //nolint:errcheck // Rollback after Commit is a no-op.
+
+	err = tx.QueryRow(`SELECT job_id, package FROM queue_items WHERE lease_id = ? AND status = ?`, leaseID, statusRunning).Scan(&jobID, &pkg)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", errLeaseNotFound
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := tx.Exec(`UPDATE queue_items SET status = ?, result = ?, error = ?, lease_id = '' WHERE job_id = ? AND package = ?`,
+		status, string(result), res.Error, jobID, pkg); err != nil {
+		return "", "", err
+	}
+	return jobID, pkg, tx.Commit()
+}
+
+// cancel stops handing out the remaining packages of a job
+func (q *workQueue) cancel(jobID string) error {
+	_, err := q.db.Exec(`UPDATE queue_jobs SET cancelled = 1 WHERE id = ?`, jobID)
+	if err != nil {
+		return err
+	}
+	_, err = q.db.Exec(`UPDATE queue_items SET status = ? WHERE job_id = ? AND status = ?`, statusCancelled, jobID, statusQueued)
+	return err
+}
+
+// finish records that a job has no outstanding packages anymore
+func (q *workQueue) finish(jobID string, finished time.Time) error {
+	_, err := q.db.Exec(`UPDATE queue_jobs SET finished_at = ? WHERE id = ?`, finished.UTC(), jobID)
+	return err
+}
+
+// load rebuilds the jobs stored in the queue, used to restore the
+// coordinator state after a restart
+func (q *workQueue) load() ([]*job, error) {
+	rows, err := q.db.Query(`SELECT j.id, j.created_at, j.finished_at, j.cancelled, i.package, i.status, i.result, i.error
+		FROM queue_jobs j JOIN queue_items i ON i.job_id = j.id
+		ORDER BY j.created_at, i.position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*job
+	var current *job
+	for rows.Next() {
+		var (
+			id, pkg, status, result, errMsg string
+			created                         time.Time
+			finished                        sql.NullTime
+			cancelled                       bool
+		)
+		if err := rows.Scan(&id, &created, &finished, &cancelled, &pkg, &status, &result, &errMsg); err != nil {
+			return nil, err
+		}
+		if current == nil || current.ID != id {
+			current = &job{ID: id, Status: statusRunning, CreatedAt: created, cancel: func() {}}
+			if finished.Valid {
+				t := finished.Time
+				current.FinishedAt = &t
+				current.Status = statusCompleted
+				if cancelled {
+					current.Status = statusCancelled
+				}
+			}
+			jobs = append(jobs, current)
+		}
+
+		p := &packageResult{Name: pkg, Status: status, Error: errMsg}
+		if result != "" {
+			var resp migrateResponse
+			if err := json.Unmarshal([]byte(result), &resp); err != nil {
+				return nil, err
+			}
+			p.Warnings, p.Findings, p.Style, p.Readability, p.Gaps, p.Todos = resp.Warnings, resp.Findings, resp.Style, resp.Readability, resp.Gaps, resp.Todos
+			p.Usage, p.markdown, p.patch = resp.Usage, resp.Markdown, resp.Patch
+		}
+		current.Packages = append(current.Packages, p)
+	}
+	return jobs, rows.Err()
+}
+
+// registerWorkRoutes adds the endpoints workers use to lease packages and
+// report results. Workers must send token as a bearer token, which is only
+// empty with -insecure-no-auth.
+func registerWorkRoutes(mux *http.ServeMux, store *jobStore, token string) {
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if token == "" {
+			return true
+		}
+		got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid worker token"})
+			return false
+		}
+		return true
+	}
+
+	mux.HandleFunc("POST /v1/work/lease", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		var req struct {
+			Worker string `json:"worker"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+			return
+		}
+		if req.Worker == "" {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "worker is required"})
+			return
+		}
+
+		item, err := store.leaseWork(req.Worker)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+			return
+		}
+		if item == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+
+	mux.HandleFunc("POST /v1/work/{lease}/complete", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		var res workResult
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&res); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+			return
+		}
+
+		err := store.completeWork(r.PathValue("lease"), res)
+		switch {
+		case errors.Is(err, errLeaseNotFound):
+			writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
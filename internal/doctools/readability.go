@@ -0,0 +1,156 @@
+package doctools
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// readabilitySections are the sections of a migrated readme whose
+// readability is scored: the overview and the setup instructions
+var readabilitySections = []string{"Overview", "How do I deploy this integration?"}
+
+var (
+	// maxGrade, maxSentenceWords and maxPassive are the readability
+	// thresholds of -max-grade, -max-sentence-words and -max-passive, 0
+	// disables them
+	maxGrade         float64
+	maxSentenceWords float64
+	maxPassive       float64
+
+	sentenceEndPattern = regexp.MustCompile(`[.!?]+(?:\s|$)`)
+	wordPattern        = regexp.MustCompile(`[A-Za-z][A-Za-z'’-]*`)
+	vowelGroupPattern  = regexp.MustCompile(`[aeiouy]+`)
+	// passivePattern matches a form of "to be" followed by a past
+	// participle, optionally with an adverb in between
+	passivePattern = regexp.MustCompile(`(?i)\b(?:am|is|are|was|were|be|been|being)\s+(?:\w+ly\s+)?(?:\w+ed|known|shown|given|taken|written|done|made|seen|sent|built|set|found|kept|run|held|read|put|chosen|driven|hidden|shipped)\b`)
+)
+
+// sectionReadability are the readability metrics of a readme section
+type sectionReadability struct {
+	Section   string `json:"section"`
+	Words     int    `json:"words"`
+	Sentences int    `json:"sentences"`
+	// Grade is the Flesch-Kincaid grade level.
+	Grade float64 `json:"grade"`
+	// SentenceWords is the average number of words per sentence.
+	SentenceWords float64 `json:"sentence_words"`
+	// Passive is the percentage of sentences in the passive voice.
+	Passive float64 `json:"passive"`
+}
+
+// registerReadabilityFlags adds the readability threshold flags to fs
+func registerReadabilityFlags(fs *flag.FlagSet) {
+	fs.Float64Var(&maxGrade, "max-grade", 0, "Warn when the Flesch-Kincaid grade level of the overview or setup section is above this (0 means no limit)")
+	fs.Float64Var(&maxSentenceWords, "max-sentence-words", 0, "Warn when the average sentence length of the overview or setup section is above this many words (0 means no limit)")
+	fs.Float64Var(&maxPassive, "max-passive", 0, "Warn when more than this percentage of the sentences of the overview or setup section are passive (0 means no limit)")
+}
+
+// sectionProse returns the prose of a section: the text of its paragraphs and
+// list items, without headings, tables, code, comments or placeholders
+func sectionProse(lines []markdown.Line) string {
+	var b strings.Builder
+	for _, line := range strings.Split(proseOnly(markdown.ShiftHeadings(lines, 0)), "\n") {
+		text := strings.TrimSpace(line)
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, "|") {
+			continue
+		}
+		text = strings.TrimLeft(text, "-*+> ")
+		b.WriteString(text)
+		// List items and paragraphs without a full stop still end a
+		// sentence.
+		if !sentenceEndPattern.MatchString(text + " ") {
+			b.WriteString(".")
+		}
+		b.WriteString(" ")
+	}
+	return b.String()
+}
+
+// countSyllables estimates the syllables of an English word
+func countSyllables(word string) int {
+	word = strings.ToLower(word)
+	n := len(vowelGroupPattern.FindAllString(word, -1))
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && n > 1 {
+		n--
+	}
+	return max(n, 1)
+}
+
+// scoreReadability computes the readability metrics of prose, nil when it
+// has no words
+func scoreReadability(section, prose string) *sectionReadability {
+	words := wordPattern.FindAllString(prose, -1)
+	if len(words) == 0 {
+		return nil
+	}
+	var sentences []string
+	last := 0
+	for _, loc := range sentenceEndPattern.FindAllStringIndex(prose, -1) {
+		if s := strings.TrimSpace(prose[last:loc[1]]); s != "" {
+			sentences = append(sentences, s)
+		}
+		last = loc[1]
+	}
+	if s := strings.TrimSpace(prose[last:]); s != "" {
+		sentences = append(sentences, s)
+	}
+
+	syllables, passive := 0, 0
+	for _, w := range words {
+		syllables += countSyllables(w)
+	}
+	for _, s := range sentences {
+		if passivePattern.MatchString(s) {
+			passive++
+		}
+	}
+	wordsPerSentence := float64(len(words)) / float64(len(sentences))
+	round := func(v float64) float64 { return math.Round(v*10) / 10 }
+	return &sectionReadability{
+		Section:       section,
+		Words:         len(words),
+		Sentences:     len(sentences),
+		Grade:         round(0.39*wordsPerSentence + 11.8*float64(syllables)/float64(len(words)) - 15.59),
+		SentenceWords: round(wordsPerSentence),
+		Passive:       round(100 * float64(passive) / float64(len(sentences))),
+	}
+}
+
+// readability scores the overview and setup sections of a migrated readme,
+// sections that are missing or empty are left out
+func readability(content string) []sectionReadability {
+	lines := markdown.ParseLines(content)
+	var scores []sectionReadability
+	for _, heading := range readabilitySections {
+		i := markdown.FindHeading(lines, heading)
+		if i < 0 {
+			continue
+		}
+		if s := scoreReadability(lines[i].Heading, sectionProse(lines[i+1:markdown.SectionEnd(lines, i)])); s != nil {
+			scores = append(scores, *s)
+		}
+	}
+	return scores
+}
+
+// readabilityFindings reports the sections above the readability thresholds
+func readabilityFindings(scores []sectionReadability) []string {
+	var findings []string
+	for _, s := range scores {
+		if maxGrade > 0 && s.Grade > maxGrade {
+			findings = append(findings, fmt.Sprintf("%s section has grade level %.1f, above -max-grade %g", s.Section, s.Grade, maxGrade))
+		}
+		if maxSentenceWords > 0 && s.SentenceWords > maxSentenceWords {
+			findings = append(findings, fmt.Sprintf("%s section has %.1f words per sentence, above -max-sentence-words %g", s.Section, s.SentenceWords, maxSentenceWords))
+		}
+		if maxPassive > 0 && s.Passive > maxPassive {
+			findings = append(findings, fmt.Sprintf("%s section has %.1f%% passive sentences, above -max-passive %g", s.Section, s.Passive, maxPassive))
+		}
+	}
+	return findings
+}
@@ -0,0 +1,78 @@
+package doctools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// regenerateSection is the template section selected with -section, the
+// rest of the migrated readme is kept as it is
+var regenerateSection string
+
+// sectionPrompt is added to the readme prompt when only one section of a
+// migrated readme is regenerated
+const sectionPrompt = `
+
+The README is already migrated to the template. Only write its section %q again, with its subsections, following this part of the template:
+
+%s
+
+Answer with this section only, starting with its heading. Do not write the other sections of the README.`
+
+// sectionAnswer returns the answer of the model for a section starting with
+// the heading of the section, at its level in the readme, adding the heading
+// if the model left it out
+func sectionAnswer(text, readme, heading string) string {
+	lines := markdown.ParseLines(readme)
+	original := lines[markdown.FindHeading(lines, heading)]
+	answer := markdown.ParseLines(strings.TrimSpace(text))
+	if len(answer) == 0 || answer[0].Level == 0 || !strings.EqualFold(answer[0].Heading, heading) {
+		return original.Text + "\n\n" + strings.TrimSpace(text)
+	}
+	return markdown.ShiftHeadings(answer, original.Level-answer[0].Level)
+}
+
+// generateSectionStage asks the LLM for the section of -section of a
+// migrated readme only, and puts it in place of the section in the readme
+func generateSectionStage(ctx context.Context, s *pipelineState) error {
+	heading := s.req.Section
+	templateSection := markdown.ExtractSection(s.template, heading)
+	if templateSection == "" {
+		return fmt.Errorf("the template has no section %q", heading)
+	}
+	if markdown.FindHeading(markdown.ParseLines(s.req.Readme), heading) < 0 {
+		return fmt.Errorf("the readme has no section %q, migrate the whole package first", heading)
+	}
+	sensitive := isSensitive(s.req.Readme)
+	if sensitive {
+		s.backend = sensitiveBackend()
+	}
+	backend := s.llmBackend()
+	if _, err := detectCapabilities(ctx, backend); err != nil {
+		return err
+	}
+	parts := append(readmePromptParts(s.req), promptPart{name: "instructions", text: fmt.Sprintf(sectionPrompt, heading, templateSection)})
+	if sensitive {
+		parts = append(parts, promptPart{name: "instructions", text: sensitiveFramingPrompt})
+	}
+	system := systemInstruction(s.req.Readme, s.template)
+	prompt, dropped, err := budgetPrompt(backend, system, parts)
+	if err != nil {
+		return err
+	}
+	s.dropped = droppedContextWarnings(backend, dropped)
+	text, usage, answered, err := generateFramed(ctx, prompt, sensitive, s.backend, func(b llmclient.Backend, prompt string) (string, llmclient.Usage, error) {
+		// The length of the section is not compared with the readme
+		return generateReadmeText(ctx, b, system, prompt, "")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to regenerate section %q: %w", heading, err)
+	}
+	section := normalizeGenerated(sectionAnswer(text, s.req.Readme, heading), s.req.Readme, s.template)
+	s.resp.Markdown, s.resp.Usage, s.resp.Backend = markdown.ReplaceSection(s.req.Readme, heading, section), usage, answered
+	return nil
+}
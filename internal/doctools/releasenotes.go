@@ -0,0 +1,271 @@
+package doctools
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// whatsNewHeading is the heading of the section of the release notes
+const whatsNewHeading = "What's new"
+
+// whatsNewPrompt asks for the release notes of a package from its changes
+const whatsNewPrompt = `I need a "What's new" section for the README.md of the Elastic integration package %q, summarizing these changes of %s for its users.
+
+Follow these exact guidelines:
+1. Start with the heading "## What's new", followed by one sentence naming the versions it covers
+2. Summarize the changes in a short bulleted list grouped by theme, breaking changes first, each saying what users have to do
+3. Write for the users of the integration: leave out internal changes such as CI, tests and refactoring
+4. Keep the links to the pull requests as Markdown links
+5. Do not mention changes that are not in the list
+
+Return ONLY the Markdown section, without any explanation or commentary.
+
+# Changes
+%s`
+
+// whatsNewStart and whatsNewEnd delimit the release notes in a pull request
+// body, so they are replaced when injected again
+const (
+	whatsNewStart = "<!-- docs-template-update:whats-new -->"
+	whatsNewEnd   = "<!-- /docs-template-update:whats-new -->"
+)
+
+// pullRequestRefPattern matches pull requests of -pr, such as
+// elastic/integrations#12345
+var pullRequestRefPattern = regexp.MustCompile(`^([\w.-]+/[\w.-]+)#(\d+)$`)
+
+// changelogVersion is an entry of a changelog.yml
+type changelogVersion struct {
+	Version string            `yaml:"version"`
+	Changes []changelogChange `yaml:"changes"`
+}
+
+// RunReleaseNotes runs the release-notes command with the arguments args
+func RunReleaseNotes(args []string) {
+	fs := flag.NewFlagSet("release-notes", flag.ExitOnError)
+	pkgPath := fs.String("path", "", "Path to the package to summarize the changes of (required)")
+	since := fs.String("since-version", "", "Summarize the changelog.yml entries after this version (default only the latest version)")
+	gitFrom := fs.String("git-from", "", "Summarize the commits of the package since this git tag or ref instead of changelog.yml")
+	gitTo := fs.String("git-to", "HEAD", "With -git-from, the last git tag or ref of the commits")
+	inject := fs.Bool("inject", false, "Add the section to the readme of the package, or replace its What's new section")
+	pr := fs.String("pr", "", "Add the section to the body of this pull request, e.g. elastic/integrations#12345, using GITHUB_TOKEN")
+	fs.StringVar(&googleAPIKey, "api-key", "", "Google Gemini API key")
+	fs.StringVar(&apiKeyRef, "api-key-ref", "", secretRefHelp)
+	registerVerbosityFlags(fs)
+	registerProviderFlags(fs)
+	registerNetworkFlags(fs)
+	registerTimeoutFlags(fs)
+	registerConfigFlags(fs)
+	_ = fs.Parse(args)
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *pkgPath == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *gitFrom != "" && *since != "" {
+		log.Fatalf("Error: -since-version and -git-from cannot be used together")
+	}
+	var repo string
+	var number int
+	if *pr != "" {
+		m := pullRequestRefPattern.FindStringSubmatch(*pr)
+		if m == nil {
+			log.Fatalf("Error: invalid -pr %q, use owner/repo#number", *pr)
+		}
+		repo = m[1]
+		number, _ = strconv.Atoi(m[2])
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateTimeouts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadProvider(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	requireAPIKey()
+
+	var changes, versions string
+	var err error
+	if *gitFrom != "" {
+		changes, err = gitChanges(*pkgPath, *gitFrom, *gitTo)
+		versions = *gitFrom + ".." + *gitTo
+	} else {
+		changes, versions, err = changelogChanges(*pkgPath, *since)
+	}
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	ctx := context.Background()
+	section, err := generateReleaseNotes(ctx, packageName(*pkgPath), versions, changes)
+	if err != nil {
+		log.Fatalf("Error generating the release notes: %v", err)
+	}
+	if !*inject && *pr == "" {
+		fmt.Println(section)
+		return
+	}
+	if *inject {
+		if err := injectReleaseNotes(targetReadmePath(*pkgPath), section); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		logInfo("Added the changes of %s to %s", versions, targetReadmePath(*pkgPath))
+	}
+	if *pr != "" {
+		if err := envGitHubClient().injectPullRequestBody(ctx, repo, number, section); err != nil {
+			log.Fatalf("Error updating %s: %v", *pr, err)
+		}
+		logInfo("Added the changes of %s to %s", versions, *pr)
+	}
+}
+
+// changelogChanges returns the changes of the changelog.yml of a package
+// after the version since, or of its latest version when since is empty,
+// one per line, with the versions they cover
+func changelogChanges(pkgPath, since string) (string, string, error) {
+	data, err := pkgFS.ReadFile(filepath.Join(pkgPath, "changelog.yml"))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read changelog.yml: %w", err)
+	}
+	var entries []changelogVersion
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return "", "", fmt.Errorf("failed to parse changelog.yml: %w", err)
+	}
+	if len(entries) == 0 {
+		return "", "", errors.New("changelog.yml has no versions")
+	}
+	selected := entries[:1]
+	if since != "" {
+		i := 0
+		for i < len(entries) && entries[i].Version != since {
+			i++
+		}
+		if i == len(entries) {
+			return "", "", fmt.Errorf("version %s is not in changelog.yml", since)
+		}
+		if i == 0 {
+			return "", "", fmt.Errorf("%s is the latest version of changelog.yml, there are no changes after it", since)
+		}
+		selected = entries[:i]
+	}
+
+	var b strings.Builder
+	for _, e := range selected {
+		for _, c := range e.Changes {
+			fmt.Fprintf(&b, "- %s (%s, %s): %s\n", e.Version, c.Type, c.Link, c.Description)
+		}
+	}
+	versions := "version " + selected[0].Version
+	if len(selected) > 1 {
+		versions = fmt.Sprintf("versions %s to %s", selected[len(selected)-1].Version, selected[0].Version)
+	}
+	return b.String(), versions, nil
+}
+
+// gitChanges returns the commits changing a package between two git refs,
+// one per line with their body, merge commits left out
+func gitChanges(pkgPath, from, to string) (string, error) {
+	out, err := gitOutput(pkgPath, "log", "--no-merges", "--format=- %s (%h)%n%w(0,2,2)%b", from+".."+to, "--", ".")
+	if err != nil {
+		return "", fmt.Errorf("failed to list the commits between %s and %s: %w", from, to, err)
+	}
+	if strings.TrimSpace(out) == "" {
+		return "", fmt.Errorf("no commits changed the package between %s and %s", from, to)
+	}
+	return out, nil
+}
+
+// generateReleaseNotes asks the model for the What's new section of the
+// changes of a package, as a level 2 heading
+func generateReleaseNotes(ctx context.Context, pkgName, versions, changes string) (string, error) {
+	text, _, _, err := generateWithFailover(ctx, func(b llmclient.Backend) (string, llmclient.Usage, error) {
+		return generateText(ctx, b, fmt.Sprintf(whatsNewPrompt, pkgName, versions, changes))
+	})
+	if err != nil {
+		return "", err
+	}
+	answer := markdown.ParseLines(strings.TrimSpace(normalizeGenerated(text, changes)))
+	if len(answer) == 0 || answer[0].Level == 0 {
+		return "## " + whatsNewHeading + "\n\n" + markdown.JoinLines(answer), nil
+	}
+	answer[0].Heading = whatsNewHeading
+	return markdown.ShiftHeadings(answer, 2-answer[0].Level), nil
+}
+
+// injectReleaseNotes replaces the What's new section of a readme with
+// section, or adds it after the overview, or before the first level 2
+// heading of a readme without one
+func injectReleaseNotes(path, section string) error {
+	content, enc, err := readMarkdown(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the readme: %w", err)
+	}
+	lines := markdown.ParseLines(content)
+	if markdown.FindHeading(lines, whatsNewHeading) >= 0 {
+		content = markdown.ReplaceSection(content, whatsNewHeading, section)
+	} else {
+		at := len(lines)
+		if i := markdown.FindHeading(lines, "Overview"); i >= 0 {
+			at = markdown.SectionEnd(lines, i)
+		} else {
+			for i, line := range lines {
+				if line.Level == 2 {
+					at = i
+					break
+				}
+			}
+		}
+		before := strings.TrimRight(markdown.JoinLines(lines[:at]), "\n")
+		after := markdown.JoinLines(lines[at:])
+		content = before + "\n\n" + section + "\n"
+		if after != "" {
+			content += "\n" + after
+		}
+	}
+	return pkgFS.WriteFile(path, enc.encode(content), 0o644)
+}
+
+// injectPullRequestBody adds section to the body of a pull request between
+// markers, replacing what is between them if the body has them already
+func (c *githubClient) injectPullRequestBody(ctx context.Context, repo string, number int, section string) error {
+	var pr struct {
+		Body string `json:"body"`
+	}
+	path := fmt.Sprintf("/repos/%s/pulls/%d", repo, number)
+	if err := c.do(ctx, http.MethodGet, path, nil, &pr); err != nil {
+		return err
+	}
+	notes := whatsNewStart + "\n" + section + "\n" + whatsNewEnd
+	body := notes + "\n"
+	if pr.Body != "" {
+		body = strings.TrimRight(pr.Body, "\n") + "\n\n" + body
+	}
+	if start := strings.Index(pr.Body, whatsNewStart); start >= 0 {
+		if end := strings.Index(pr.Body[start:], whatsNewEnd); end >= 0 {
+			body = pr.Body[:start] + notes + pr.Body[start+end+len(whatsNewEnd):]
+		}
+	}
+	return c.do(ctx, http.MethodPatch, path, map[string]string{"body": body}, nil)
+}
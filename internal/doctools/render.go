@@ -0,0 +1,92 @@
+package doctools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+var (
+	// strayBracePattern matches what is left of a placeholder after rendering
+	// when one of its braces is missing, such as {fields "logs"}} or
+	// {{event "logs"}
+	strayBracePattern = regexp.MustCompile(`\{\{|\}\}|\{\s*(?:fields|event|url|inputDocs)\b`)
+	inlineCodePattern = regexp.MustCompile("`[^`]*`")
+)
+
+// renderFuncs are the functions elastic-package renders the placeholders
+// of a readme template with, stubbed. Only their arguments are checked, which
+// data streams they name is left to the other checks.
+var renderFuncs = template.FuncMap{
+	"fields": stubDataStreamFunc("fields"),
+	"event":  stubDataStreamFunc("event"),
+	"url": func(key string, caption ...string) (string, error) {
+		if key == "" || len(caption) > 1 {
+			return "", fmt.Errorf("url takes a link key and an optional caption")
+		}
+		return "url", nil
+	},
+	"inputDocs": func() string { return "inputDocs" },
+}
+
+// stubDataStreamFunc returns a stub of the fields or event function, which
+// take the data stream for integration packages and nothing for input
+// packages
+func stubDataStreamFunc(name string) func(...string) (string, error) {
+	return func(args ...string) (string, error) {
+		if len(args) > 1 {
+			return "", fmt.Errorf("%s takes at most one data stream, got %d arguments", name, len(args))
+		}
+		return name, nil
+	}
+}
+
+// renderFindings renders a migrated readme the way elastic-package does, with
+// stubbed fields, event and url placeholders, and returns a finding for a
+// placeholder that cannot be rendered, such as one with unbalanced braces or
+// single quotes. Models emit these now and then and elastic-package only
+// notices them when the package is built.
+func renderFindings(content string) []string {
+	tmpl, err := template.New("readme").Funcs(renderFuncs).Parse(content)
+	if err == nil {
+		var b strings.Builder
+		err = tmpl.Execute(&b, nil)
+		if err == nil {
+			return strayBraceFindings(b.String())
+		}
+	}
+	return []string{"placeholder cannot be rendered: " + renderError(err)}
+}
+
+// strayBraceFindings returns a finding for every line of a rendered readme
+// with the braces of a broken placeholder left in it. Code, such as JSON with
+// nested objects, is skipped.
+func strayBraceFindings(rendered string) []string {
+	var findings []string
+	inCode := false
+	for i, line := range strings.Split(rendered, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		if strayBracePattern.MatchString(inlineCodePattern.ReplaceAllString(line, "")) {
+			findings = append(findings, fmt.Sprintf("placeholder with unbalanced braces on line %d of the rendered readme: %s", i+1, strings.TrimSpace(line)))
+		}
+	}
+	return findings
+}
+
+// renderError returns a text/template error without its template name, with
+// the line of the readme it is about
+func renderError(err error) string {
+	msg := err.Error()
+	msg = strings.TrimPrefix(msg, "template: ")
+	if rest, ok := strings.CutPrefix(msg, "readme:"); ok {
+		msg = "line " + rest
+	}
+	return msg
+}
@@ -0,0 +1,138 @@
+package doctools
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// runReport implements the report subcommand
+func runReport(args []string) {
+	if len(args) == 0 || (args[0] != "history" && args[0] != "compare") {
+		fmt.Fprintf(os.Stderr, "Usage: %s report history|compare [options]\n", os.Args[0])
+		os.Exit(2)
+	}
+	if args[0] == "compare" {
+		runReportCompare(args[1:])
+		return
+	}
+
+	fs := flag.NewFlagSet("report history", flag.ExitOnError)
+	dbPath := fs.String("db", os.Getenv(historyEnv), "Path to the history database (defaults to "+historyEnv+")")
+	limit := fs.Int("limit", 20, "Maximum number of runs to show")
+	pkg := fs.String("package", "", "Show the history of a single package instead of the runs")
+	owners := fs.Bool("owners", false, "Show the token usage and estimated cost per owning team instead of the runs")
+	since := fs.Duration("since", 30*24*time.Hour, "With -owners, only include runs started within this period")
+	_ = fs.Parse(args[1:])
+
+	history := openReportHistory(*dbPath)
+	defer history.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	if *owners {
+		usage, err := history.usageByOwner(time.Now().Add(-*since))
+		if err != nil {
+			log.Fatalf("Error reading history: %v", err)
+		}
+		fmt.Fprintln(w, "OWNER\tPACKAGES\tTOKENS IN\tTOKENS OUT\tCOST (USD)")
+		for _, o := range usage {
+			owner := o.Owner
+			if owner == "" {
+				owner = "(unknown)"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.2f\n", owner, o.Packages, o.Usage.PromptTokens, o.Usage.ResponseTokens, o.CostUSD)
+		}
+		return
+	}
+
+	if *pkg != "" {
+		entries, err := history.packageHistory(*pkg, *limit)
+		if err != nil {
+			log.Fatalf("Error reading history: %v", err)
+		}
+		fmt.Fprintln(w, "RUN\tSTARTED\tMODEL\tSTATUS\tFINDINGS\tTOKENS IN\tTOKENS OUT\tCOST (USD)\tDURATION\tERROR")
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%.4f\t%s\t%s\n",
+				e.RunID, e.StartedAt.Local().Format("2006-01-02 15:04"), e.Model, e.Status, e.Findings,
+				e.Usage.PromptTokens, e.Usage.ResponseTokens, e.CostUSD, e.Duration.Round(100*time.Millisecond), e.Error)
+		}
+		return
+	}
+
+	runs, err := history.runs(*limit)
+	if err != nil {
+		log.Fatalf("Error reading history: %v", err)
+	}
+	fmt.Fprintln(w, "RUN\tSTARTED\tMODE\tMODEL\tPROMPTS\tPACKAGES\tSUCCEEDED\tFAILED\tFINDINGS\tTOKENS IN\tTOKENS OUT\tCOST (USD)")
+	for _, r := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%.2f\n",
+			r.ID, r.StartedAt.Local().Format("2006-01-02 15:04"), r.Mode, r.Model, promptVersionLabel(r.PromptVersion), r.Packages, r.Succeeded, r.Failed,
+			r.Findings, r.Usage.PromptTokens, r.Usage.ResponseTokens, r.CostUSD)
+	}
+}
+
+// runReportCompare implements report compare, the quality of the migrations
+// per prompt version in the order the versions were first used
+func runReportCompare(args []string) {
+	fs := flag.NewFlagSet("report compare", flag.ExitOnError)
+	dbPath := fs.String("db", os.Getenv(historyEnv), "Path to the history database (defaults to "+historyEnv+")")
+	since := fs.Duration("since", 90*24*time.Hour, "Only include runs started within this period")
+	_ = fs.Parse(args)
+
+	history := openReportHistory(*dbPath)
+	defer history.Close()
+
+	versions, err := history.promptVersions(time.Now().Add(-*since))
+	if err != nil {
+		log.Fatalf("Error reading history: %v", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "PROMPTS\tFIRST RUN\tLAST RUN\tRUNS\tPACKAGES\tSUCCEEDED\tFINDINGS/PKG\tTOKENS/PKG\tDURATION/PKG\tCOST/PKG (USD)")
+	for _, v := range versions {
+		succeeded := "-"
+		var findings, tokens, cost float64
+		var duration time.Duration
+		if v.Packages > 0 {
+			succeeded = fmt.Sprintf("%.0f%%", 100*float64(v.Succeeded)/float64(v.Packages))
+			findings = float64(v.Findings) / float64(v.Packages)
+			tokens = float64(v.Usage.PromptTokens+v.Usage.ResponseTokens) / float64(v.Packages)
+			cost = v.CostUSD / float64(v.Packages)
+			duration = v.Duration / time.Duration(v.Packages)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%.1f\t%.0f\t%s\t%.4f\n",
+			promptVersionLabel(v.PromptVersion), v.FirstRun.Local().Format("2006-01-02 15:04"), v.LastRun.Local().Format("2006-01-02 15:04"),
+			v.Runs, v.Packages, succeeded, findings, tokens, duration.Round(100*time.Millisecond), cost)
+	}
+}
+
+// openReportHistory opens the history database of a report, exiting when
+// there is none
+func openReportHistory(dbPath string) *historyStore {
+	if dbPath == "" {
+		log.Fatalf("History database is required. Set it using the -db flag or %s environment variable", historyEnv)
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		log.Fatalf("Error opening history: %v", err)
+	}
+
+	history, err := openHistory(dbPath)
+	if err != nil {
+		log.Fatalf("Error opening history: %v", err)
+	}
+	return history
+}
+
+// promptVersionLabel shows the runs recorded before prompt versions were
+// stored
+func promptVersionLabel(version string) string {
+	if version == "" {
+		return "(unknown)"
+	}
+	return version
+}
@@ -0,0 +1,221 @@
+package doctools
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// When the rules of the -rules file are applied
+const (
+	// ruleBefore rules fix the readme given to the LLM.
+	ruleBefore = "before"
+	// ruleAfter rules fix the migrated readme.
+	ruleAfter = "after"
+)
+
+// Kinds of markdown a transformation rule matches
+const (
+	ruleText    = "text"
+	ruleLink    = "link"
+	ruleImage   = "image"
+	ruleHeading = "heading"
+)
+
+// transformRule is a rule of the -rules file: a mechanical fix applied to
+// every readme without the LLM. Fenced code blocks are never changed.
+type transformRule struct {
+	// Name optionally identifies the rule in the logs.
+	Name string `yaml:"name"`
+	// When is before, to fix the readme given to the LLM, or after (the
+	// default), to fix the migrated readme.
+	When string `yaml:"when"`
+	// Kind is what Pattern matches: text (the default), the target of
+	// links, the address of images or the text of headings.
+	Kind    string `yaml:"kind"`
+	Pattern string `yaml:"pattern"`
+	// Replacement replaces the matched text, link target or heading, and
+	// may refer to submatches as $1 or ${name}. Without it, matched text is
+	// deleted, links are replaced by their text and the sections of
+	// headings are removed. Image rules remove the matching images, with
+	// the link around them.
+	Replacement string `yaml:"replacement"`
+
+	re *regexp.Regexp
+}
+
+var (
+	// rulesPath is the rules file selected with -rules
+	rulesPath string
+	// transformRules are the rules loaded from rulesPath
+	transformRules []transformRule
+
+	// inlineLinkPattern matches the links that are not images and have no
+	// brackets in their text, with the character before them
+	inlineLinkPattern = regexp.MustCompile(`(^|[^!\]])\[([^\[\]\n]*)\]\(([^)\s]+)((?:\s+"[^"]*")?)\)`)
+	// imagePattern matches images, with the link around them if there is
+	// one, such as the badges of a readme
+	imagePattern = regexp.MustCompile(`\[!\[[^\]\n]*\]\(([^)\s]+)(?:\s+"[^"]*")?\)\]\([^)\s]+(?:\s+"[^"]*")?\)|!\[[^\]\n]*\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+)
+
+// compile validates a rule and compiles its pattern
+func (r *transformRule) compile() error {
+	if r.Pattern == "" {
+		return errors.New("no pattern")
+	}
+	switch r.When {
+	case "":
+		r.When = ruleAfter
+	case ruleBefore, ruleAfter:
+	default:
+		return fmt.Errorf("unknown when %q, use %s or %s", r.When, ruleBefore, ruleAfter)
+	}
+	switch r.Kind {
+	case "":
+		r.Kind = ruleText
+	case ruleText, ruleLink, ruleImage, ruleHeading:
+	default:
+		return fmt.Errorf("unknown kind %q, use %s, %s, %s or %s", r.Kind, ruleText, ruleLink, ruleImage, ruleHeading)
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return err
+	}
+	r.re = re
+	return nil
+}
+
+// String identifies the rule in the logs
+func (r *transformRule) String() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("%s rule %q", r.Kind, r.Pattern)
+}
+
+// loadRules reads the rules of -rules
+func loadRules() error {
+	if rulesPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(rulesPath)
+	if err != nil {
+		return fmt.Errorf("failed to read rules: %w", err)
+	}
+	var rules []transformRule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("failed to parse rules: %w", err)
+	}
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return fmt.Errorf("invalid rule %d in %s: %w", i+1, rulesPath, err)
+		}
+	}
+	transformRules = rules
+	return nil
+}
+
+// applyRules applies the rules of the -rules file to be applied when, in
+// order, each on the result of the previous ones
+func applyRules(content, when string) string {
+	for i := range transformRules {
+		r := &transformRules[i]
+		if r.When != when {
+			continue
+		}
+		var fixed string
+		if r.Kind == ruleHeading {
+			fixed = r.applyHeadings(content)
+		} else {
+			fixed = outsideCode(content, r.apply)
+		}
+		if verbose && fixed != content {
+			log.Printf("Applied %s", r)
+		}
+		content = fixed
+	}
+	return content
+}
+
+// outsideCode applies fix to the parts of content outside fenced code blocks
+func outsideCode(content string, fix func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, span := range markdown.CodeFencePattern.FindAllStringIndex(content, -1) {
+		b.WriteString(fix(content[last:span[0]]))
+		b.WriteString(content[span[0]:span[1]])
+		last = span[1]
+	}
+	b.WriteString(fix(content[last:]))
+	return b.String()
+}
+
+// apply applies a text, link or image rule to text without code blocks
+func (r *transformRule) apply(text string) string {
+	switch r.Kind {
+	case ruleLink:
+		return inlineLinkPattern.ReplaceAllStringFunc(text, func(link string) string {
+			m := inlineLinkPattern.FindStringSubmatch(link)
+			if !r.re.MatchString(m[3]) {
+				return link
+			}
+			if r.Replacement == "" {
+				return m[1] + m[2]
+			}
+			return m[1] + "[" + m[2] + "](" + r.re.ReplaceAllString(m[3], r.Replacement) + m[4] + ")"
+		})
+	case ruleImage:
+		var lines []string
+		for _, line := range strings.Split(text, "\n") {
+			fixed := imagePattern.ReplaceAllStringFunc(line, func(image string) string {
+				m := imagePattern.FindStringSubmatch(image)
+				if r.re.MatchString(m[1] + m[2]) {
+					return ""
+				}
+				return image
+			})
+			if fixed == line {
+				lines = append(lines, line)
+				continue
+			}
+			fixed = strings.TrimRight(fixed, " \t")
+			if strings.TrimSpace(fixed) != "" {
+				lines = append(lines, fixed)
+			} else if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+				// The line held only removed images, drop it and the
+				// blank line before it
+				lines = lines[:len(lines)-1]
+			}
+		}
+		return strings.Join(lines, "\n")
+	default:
+		return r.re.ReplaceAllString(text, r.Replacement)
+	}
+}
+
+// applyHeadings applies a heading rule: it renames the matching headings, or
+// removes their sections without a replacement
+func (r *transformRule) applyHeadings(content string) string {
+	lines := markdown.ParseLines(content)
+	var texts []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if line.Level == 0 || !r.re.MatchString(line.Heading) {
+			texts = append(texts, line.Text)
+			continue
+		}
+		if r.Replacement != "" {
+			texts = append(texts, strings.Repeat("#", line.Level)+" "+r.re.ReplaceAllString(line.Heading, r.Replacement))
+			continue
+		}
+		i = markdown.SectionEnd(lines, i) - 1
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,152 @@
+package doctools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// refreshSamples regenerates the sample events from the pipeline tests,
+// selected with -refresh-samples
+var refreshSamples bool
+
+// agentFields are the fields Elastic Agent adds to the events, ingest
+// pipelines do not produce them so they are kept from the previous sample
+// event
+var agentFields = []string{"agent", "elastic_agent", "data_stream", "input"}
+
+// pipelineTestExpected is a -expected.json file of the pipeline tests of a
+// data stream, the documents its ingest pipeline produces for a test log
+type pipelineTestExpected struct {
+	Expected []map[string]any `json:"expected"`
+}
+
+// refreshSampleEvents returns the sample events of the data streams with
+// pipeline tests, regenerated from the expected documents of their latest
+// test, by path. The document with the most fields is taken, with the agent
+// fields of the previous sample event.
+func refreshSampleEvents(pkgPath string, dataStreams []string) (map[string][]byte, error) {
+	samples := make(map[string][]byte)
+	for _, ds := range dataStreams {
+		doc, source, err := latestExpectedDocument(filepath.Join(pkgPath, "data_stream", ds, "_dev", "test", "pipeline"))
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			continue
+		}
+
+		path := filepath.Join(pkgPath, "data_stream", ds, "sample_event.json")
+		if previous, err := pkgFS.ReadFile(path); err == nil {
+			var event map[string]any
+			dec := json.NewDecoder(bytes.NewReader(previous))
+			dec.UseNumber()
+			if err := dec.Decode(&event); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			for _, f := range agentFields {
+				if _, ok := doc[f]; !ok && event[f] != nil {
+					doc[f] = event[f]
+				}
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		var b bytes.Buffer
+		enc := json.NewEncoder(&b)
+		// The indentation of elastic-package
+		enc.SetIndent("", "    ")
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+		samples[path] = b.Bytes()
+		if verbose {
+			log.Printf("Refreshing %s from %s", path, source)
+		}
+	}
+	return samples, nil
+}
+
+// latestExpectedDocument returns the document with the most fields of the
+// most recently changed -expected.json file in dir and the file, or nil if
+// there is none
+func latestExpectedDocument(dir string) (map[string]any, string, error) {
+	entries, err := pkgFS.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list pipeline tests: %w", err)
+	}
+	var latest string
+	var latestInfo os.FileInfo
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), "-expected.json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, "", err
+		}
+		// Ties, e.g. in a fresh checkout, go to the last file by name
+		if latestInfo == nil || !info.ModTime().Before(latestInfo.ModTime()) {
+			latest, latestInfo = e.Name(), info
+		}
+	}
+	if latest == "" {
+		return nil, "", nil
+	}
+
+	path := filepath.Join(dir, latest)
+	data, err := pkgFS.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	var expected pipelineTestExpected
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Keep large numbers, such as byte counts, as they are
+	dec.UseNumber()
+	if err := dec.Decode(&expected); err != nil {
+		return nil, "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	var doc map[string]any
+	most := -1
+	for _, d := range expected.Expected {
+		if n := countFields(d); n > most {
+			doc, most = d, n
+		}
+	}
+	return doc, path, nil
+}
+
+// countFields returns the number of leaf fields of a document
+func countFields(v any) int {
+	switch v := v.(type) {
+	case map[string]any:
+		n := 0
+		for _, child := range v {
+			n += countFields(child)
+		}
+		return n
+	default:
+		return 1
+	}
+}
+
+// refreshedSampleStreams returns the data streams with a sample event once
+// the refreshed ones are written, in the order of dataStreams
+func refreshedSampleStreams(pkgPath string, dataStreams, sampleEvents []string, refreshed map[string][]byte) []string {
+	var streams []string
+	for _, ds := range dataStreams {
+		if _, ok := refreshed[filepath.Join(pkgPath, "data_stream", ds, "sample_event.json")]; ok || slices.Contains(sampleEvents, ds) {
+			streams = append(streams, ds)
+		}
+	}
+	return streams
+}
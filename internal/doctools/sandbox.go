@@ -0,0 +1,215 @@
+package doctools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// stageVerify is the stage running -verify on the migrated package in the
+// sandbox
+const stageVerify = "verify"
+
+var (
+	// sandboxMode is set by -sandbox
+	sandboxMode bool
+	// verifyCommand is the command -verify runs in a copy of the migrated
+	// package, e.g. elastic-package build
+	verifyCommand string
+)
+
+// apply writes the files written to m to fsys and removes those removed from
+// m, with the permissions they were written with. On disk every file is
+// first written next to the one it replaces and only renamed into place once
+// all of them are, and the files replaced or removed are kept in memory
+// until then: if a rename fails, those already moved into place are put
+// back, so a failed apply leaves the package as it was.
+func (m *memFS) apply(fsys packageFS) error {
+	paths := m.changed()
+	if _, ok := fsys.(osFS); !ok {
+		// Writes to memory, with -dry-run, do not fail half way
+		for _, path := range paths {
+			f, ok := m.file(path)
+			if !ok {
+				if err := fsys.Remove(path); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				continue
+			}
+			if err := fsys.WriteFile(path, f.data, f.perm); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	staged := make(map[string]string)
+	defer func() {
+		for _, tmp := range staged {
+			os.Remove(tmp)
+		}
+	}()
+	for _, path := range paths {
+		f, ok := m.file(path)
+		if !ok {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		tmp, err := stageFile(path, f.data, f.perm)
+		if err != nil {
+			return err
+		}
+		staged[path] = tmp
+	}
+
+	var done []fileBackup
+	for _, path := range paths {
+		b, err := backupFile(path)
+		if err != nil {
+			return errors.Join(err, restoreFiles(done))
+		}
+		if tmp, ok := staged[path]; ok {
+			err = replaceFile(tmp, path)
+			delete(staged, path)
+		} else if err = os.Remove(path); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			return errors.Join(err, restoreFiles(done))
+		}
+		done = append(done, b)
+	}
+	return nil
+}
+
+// file returns a file written to m, false if it was removed
+func (m *memFS) file(name string) (memFile, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[name]
+	return f, ok
+}
+
+// fileBackup is a file on disk as it was before apply replaced or removed it
+type fileBackup struct {
+	path    string
+	data    []byte
+	perm    os.FileMode
+	existed bool
+}
+
+// backupFile reads the file at path, if any, for restoreFiles
+func backupFile(path string) (fileBackup, error) {
+	b := fileBackup{path: path}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return b, err
+	}
+	if b.data, err = os.ReadFile(path); err != nil {
+		return b, err
+	}
+	b.perm, b.existed = info.Mode().Perm(), true
+	return b, nil
+}
+
+// restoreFiles puts back the files of backups, removing those that did not
+// exist, in the reverse order they were replaced
+func restoreFiles(backups []fileBackup) error {
+	var errs []error
+	for _, b := range slices.Backward(backups) {
+		var err error
+		if b.existed {
+			err = writeFileAtomic(b.path, b.data, b.perm)
+		} else if err = os.Remove(b.path); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore %s: %w", b.path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// copyTree copies the directory src of fsys to dst on disk, keeping the
+// permissions of the files and directories, such as those of scripts
+func copyTree(fsys packageFS, src, dst string) error {
+	entries, err := fsys.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	perm := os.FileMode(0o755)
+	if info, err := fsys.Stat(src); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(dst, perm); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		from, to := filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())
+		if e.IsDir() {
+			if err := copyTree(fsys, from, to); err != nil {
+				return err
+			}
+			continue
+		}
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		data, err := fsys.ReadFile(from)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(to, data, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyStage copies the migrated package from the sandbox to a temporary
+// directory and runs -verify in it
+func verifyStage(ctx context.Context, s *pipelineState) error {
+	if !sandboxMode || verifyCommand == "" {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "docs-template-update-sandbox-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	// The command may expect the package in a directory named after it
+	pkgDir := filepath.Join(dir, filepath.Base(s.pkgPath))
+	if err := copyTree(pkgFS, s.pkgPath, pkgDir); err != nil {
+		return fmt.Errorf("failed to copy package to the sandbox: %w", err)
+	}
+
+	args := strings.Fields(verifyCommand)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = pkgDir
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to verify package with %s: %w: %s", args[0], err, strings.TrimSpace(output.String()))
+	}
+	if verbose {
+		log.Printf("Verified %s with %s", s.pkgPath, verifyCommand)
+	}
+	return nil
+}
@@ -0,0 +1,487 @@
+package doctools
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Actions of the -sample-policy file for each kind of value
+const (
+	// sanitizeRandomize replaces a value by one derived from a hash of it,
+	// the same value gets the same replacement in every event and run
+	sanitizeRandomize = "randomize"
+	// sanitizeRedact replaces all values of a kind by the same one
+	sanitizeRedact = "redact"
+	sanitizeKeep   = "keep"
+)
+
+// samplePolicy is the -sample-policy file, how the real IP addresses,
+// hostnames and account IDs in the sample events of a package are replaced
+type samplePolicy struct {
+	// IPs, Hostnames and AccountIDs are the actions for each kind of value:
+	// randomize, the default, redact or keep.
+	IPs        string `yaml:"ips"`
+	Hostnames  string `yaml:"hostnames"`
+	AccountIDs string `yaml:"account_ids"`
+	// HostnameFields and AccountIDFields are the fields, as dotted paths,
+	// holding hostnames and account IDs, besides the default ones.
+	HostnameFields  []string `yaml:"hostname_fields"`
+	AccountIDFields []string `yaml:"account_id_fields"`
+	// Keep are values never replaced, such as public DNS resolvers.
+	Keep []string `yaml:"keep"`
+	// Seed changes the randomized values.
+	Seed string `yaml:"seed"`
+}
+
+var (
+	// samplePolicyPath is the policy file selected with -sample-policy
+	samplePolicyPath string
+	// sampleSanitizer is the policy loaded from samplePolicyPath, nil
+	// without -sample-policy
+	sampleSanitizer *samplePolicy
+
+	// defaultHostnameFields hold hostnames in ECS
+	defaultHostnameFields = []string{
+		"host.name", "host.hostname", "agent.name", "agent.hostname", "observer.name", "observer.hostname",
+		"client.domain", "server.domain", "source.domain", "destination.domain", "url.domain", "dns.question.name",
+	}
+	// defaultAccountIDFields hold cloud account IDs in ECS
+	defaultAccountIDFields = []string{"cloud.account.id", "cloud.project.id"}
+
+	// documentationPrefixes are the address ranges reserved for
+	// documentation, randomized addresses are taken from them
+	documentationPrefixes = []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("198.51.100.0/24"),
+		netip.MustParsePrefix("203.0.113.0/24"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+	// reservedDomains are the domains reserved for documentation and
+	// testing, randomized hostnames are below example.com
+	reservedDomains = []string{"example.com", "example.net", "example.org", "example", "test", "invalid", "localhost"}
+
+	ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Pattern = regexp.MustCompile(`[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`)
+	// arnAccountPattern captures the account ID of an AWS ARN
+	arnAccountPattern = regexp.MustCompile(`\barn:aws[a-z-]*:[a-z0-9-]*:[a-z0-9-]*:(\d{12}):`)
+	// sanitizedHostnamePattern matches the hostnames without a domain that
+	// were randomized or redacted
+	sanitizedHostnamePattern = regexp.MustCompile(`^host(-[0-9a-f]{6})?$`)
+	// jsonStringPattern matches the strings of a JSON document, with the
+	// colon following keys
+	jsonStringPattern = regexp.MustCompile(`"(?:[^"\\]|\\.)*"(\s*:)?`)
+)
+
+// loadSamplePolicy reads the policy of -sample-policy
+func loadSamplePolicy() error {
+	if samplePolicyPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(samplePolicyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read sample policy: %w", err)
+	}
+	var p samplePolicy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("failed to parse sample policy %s: %w", samplePolicyPath, err)
+	}
+	for _, action := range []*string{&p.IPs, &p.Hostnames, &p.AccountIDs} {
+		if *action == "" {
+			*action = sanitizeRandomize
+		}
+		if *action != sanitizeRandomize && *action != sanitizeRedact && *action != sanitizeKeep {
+			return fmt.Errorf("unknown action %q in sample policy %s, use %s, %s or %s", *action, samplePolicyPath, sanitizeRandomize, sanitizeRedact, sanitizeKeep)
+		}
+	}
+	sampleSanitizer = &p
+	return nil
+}
+
+// sampleEventPaths returns the sample events of a package: the one of an
+// input package and those of its data streams
+func sampleEventPaths(pkgPath string, dataStreams []string) []string {
+	var paths []string
+	if hasPackageSampleEvent(pkgPath) {
+		paths = append(paths, filepath.Join(pkgPath, "sample_event.json"))
+	}
+	for _, ds := range sampleEventStreams(pkgPath, dataStreams) {
+		paths = append(paths, filepath.Join(pkgPath, "data_stream", ds, "sample_event.json"))
+	}
+	return paths
+}
+
+// writeSampleEvents writes the sample events of a package refreshed from its
+// pipeline tests, sanitizes them with the -sample-policy and adds their
+// patches to result
+func writeSampleEvents(pkgPath string, dataStreams []string, refreshed map[string][]byte, result *migrateResponse) error {
+	paths := sampleEventPaths(pkgPath, dataStreams)
+	for path := range refreshed {
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		data, err := pkgFS.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		content := data
+		if r, ok := refreshed[path]; ok {
+			content = r
+		}
+		if sampleSanitizer != nil {
+			sanitized, replaced, err := sampleSanitizer.sanitize(content)
+			if err != nil {
+				return fmt.Errorf("failed to sanitize %s: %w", path, err)
+			}
+			if replaced > 0 {
+				logInfo("Sanitized %d values in %s", replaced, path)
+			}
+			content = sanitized
+		}
+		if bytes.Equal(content, data) {
+			continue
+		}
+		rel, err := filepath.Rel(pkgPath, path)
+		if err != nil {
+			rel = path
+		}
+		patch, err := generatePatch(rel, string(data), string(content))
+		if err != nil {
+			return fmt.Errorf("failed to generate patch: %w", err)
+		}
+		if err := pkgFS.WriteFile(path, content, 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		result.Patch += patch
+	}
+	return nil
+}
+
+// sampleIdentifiers are the hostnames and account IDs found in sample
+// events, with their replacements
+type sampleIdentifiers struct {
+	hostnames map[string]string
+	accounts  map[string]string
+}
+
+func newSampleIdentifiers() *sampleIdentifiers {
+	return &sampleIdentifiers{hostnames: make(map[string]string), accounts: make(map[string]string)}
+}
+
+// sanitize returns a sample event with its IP addresses, hostnames and
+// account IDs replaced following the policy, and the number of values that
+// changed. Only the changed strings are rewritten, the formatting and the
+// order of the fields are kept.
+func (p *samplePolicy) sanitize(data []byte) ([]byte, int, error) {
+	var event any
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, 0, err
+	}
+	ids := newSampleIdentifiers()
+	p.collect(event, ids)
+	out, replaced := p.rewrite(data, event, ids)
+	return out, replaced, nil
+}
+
+// collect adds the hostnames and account IDs of event to ids. Hostnames and
+// account IDs are recognized by their fields, and then replaced wherever
+// they appear, e.g. in messages and ARNs.
+func (p *samplePolicy) collect(event any, ids *sampleIdentifiers) {
+	walkStrings(event, "", func(field, value string) {
+		switch {
+		case p.Hostnames != sanitizeKeep && p.isField(field, defaultHostnameFields, p.HostnameFields):
+			if !p.kept(value) && !isSanitizedHostname(value) {
+				ids.hostnames[value] = p.hostname(value)
+			}
+		case p.AccountIDs != sanitizeKeep && p.isField(field, defaultAccountIDFields, p.AccountIDFields):
+			if !p.kept(value) && !isSanitizedAccountID(value) {
+				ids.accounts[value] = p.accountID(value)
+			}
+		}
+		p.collectText(value, ids)
+	})
+}
+
+// collectText adds the account IDs of the ARNs in s to ids
+func (p *samplePolicy) collectText(s string, ids *sampleIdentifiers) {
+	if p.AccountIDs == sanitizeKeep {
+		return
+	}
+	for _, m := range arnAccountPattern.FindAllStringSubmatch(s, -1) {
+		if !p.kept(m[1]) && !isSanitizedAccountID(m[1]) {
+			ids.accounts[m[1]] = p.accountID(m[1])
+		}
+	}
+}
+
+// replaceString returns value with its IP addresses and the identifiers of
+// ids replaced. The IP addresses of version fields are kept, field is empty
+// for text outside JSON documents.
+func (p *samplePolicy) replaceString(field, value string, ids *sampleIdentifiers) string {
+	if p.IPs != sanitizeKeep && !strings.Contains(strings.ToLower(field), "version") {
+		value = p.replaceIPs(value)
+	}
+	value = replaceWords(value, ids.hostnames)
+	return replaceWords(value, ids.accounts)
+}
+
+// rewrite returns data, the JSON document event was parsed from, with its
+// strings replaced following the policy and ids, and the number of strings
+// replaced
+func (p *samplePolicy) rewrite(data []byte, event any, ids *sampleIdentifiers) ([]byte, int) {
+	replacements := make(map[string]string)
+	walkStrings(event, "", func(field, value string) {
+		if sanitized := p.replaceString(field, value, ids); sanitized != value {
+			replacements[value] = sanitized
+		}
+	})
+	if len(replacements) == 0 {
+		return data, 0
+	}
+
+	// Strings are escaped like in the file, elastic-package escapes HTML
+	escapeHTML := bytes.Contains(data, []byte(`\u003c`)) || bytes.Contains(data, []byte(`\u003e`)) || bytes.Contains(data, []byte(`\u0026`))
+	replaced := 0
+	out := jsonStringPattern.ReplaceAllFunc(data, func(lit []byte) []byte {
+		if strings.HasSuffix(string(lit), ":") {
+			return lit
+		}
+		var value string
+		if err := json.Unmarshal(lit, &value); err != nil {
+			return lit
+		}
+		sanitized, ok := replacements[value]
+		if !ok {
+			return lit
+		}
+		var encoded bytes.Buffer
+		enc := json.NewEncoder(&encoded)
+		enc.SetEscapeHTML(escapeHTML)
+		if err := enc.Encode(sanitized); err != nil {
+			return lit
+		}
+		replaced++
+		return bytes.TrimSuffix(encoded.Bytes(), []byte("\n"))
+	})
+	return out, replaced
+}
+
+// walkStrings calls fn with the dotted field and the value of every string in
+// v, array elements have the field of their array
+func walkStrings(v any, field string, fn func(field, value string)) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			name := k
+			if field != "" {
+				name = field + "." + k
+			}
+			walkStrings(child, name, fn)
+		}
+	case []any:
+		for _, child := range v {
+			walkStrings(child, field, fn)
+		}
+	case string:
+		fn(field, v)
+	}
+}
+
+// isField reports whether field is among the default fields or those of the
+// policy
+func (p *samplePolicy) isField(field string, defaults, extra []string) bool {
+	return slices.Contains(defaults, field) || slices.Contains(extra, field)
+}
+
+// kept reports whether the policy keeps value
+func (p *samplePolicy) kept(value string) bool {
+	return slices.Contains(p.Keep, value)
+}
+
+// hash returns a hash of value of the given kind, salted with the seed
+func (p *samplePolicy) hash(kind, value string) []byte {
+	h := sha256.Sum256([]byte(p.Seed + "\x00" + kind + "\x00" + value))
+	return h[:]
+}
+
+// replaceIPs replaces the IP addresses in s that are not loopback,
+// unspecified or documentation addresses
+func (p *samplePolicy) replaceIPs(s string) string {
+	replace := func(token string) string {
+		addr, err := netip.ParseAddr(token)
+		if err != nil || p.kept(token) || addr.IsLoopback() || addr.IsUnspecified() || isDocumentationAddr(addr) {
+			return token
+		}
+		return p.ip(addr).String()
+	}
+	s = ipv4Pattern.ReplaceAllStringFunc(s, replace)
+	// IPv6 addresses must not be part of a longer token, such as the
+	// account of an ARN
+	var b strings.Builder
+	last := 0
+	for _, m := range ipv6Pattern.FindAllStringIndex(s, -1) {
+		if (m[0] > 0 && isTokenByte(s[m[0]-1])) || (m[1] < len(s) && isTokenByte(s[m[1]])) {
+			continue
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(replace(s[m[0]:m[1]]))
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// isTokenByte reports whether c continues a token around an IPv6 address
+func isTokenByte(c byte) bool {
+	return c == ':' || c == '.' || c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// ip returns the replacement of addr, a documentation address of the same
+// family
+func (p *samplePolicy) ip(addr netip.Addr) netip.Addr {
+	if addr.Is4() || addr.Is4In6() {
+		if p.IPs == sanitizeRedact {
+			return netip.MustParseAddr("192.0.2.1")
+		}
+		h := p.hash("ip", addr.String())
+		prefix := documentationPrefixes[int(h[0])%3].Addr().As4()
+		prefix[3] = 1 + h[1]%254
+		return netip.AddrFrom4(prefix)
+	}
+	if p.IPs == sanitizeRedact {
+		return netip.MustParseAddr("2001:db8::1")
+	}
+	h := p.hash("ip", addr.String())
+	b := documentationPrefixes[3].Addr().As16()
+	copy(b[8:], h[:8])
+	return netip.AddrFrom16(b)
+}
+
+// isDocumentationAddr reports whether addr is reserved for documentation
+func isDocumentationAddr(addr netip.Addr) bool {
+	for _, prefix := range documentationPrefixes {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostname returns the replacement of a hostname, below example.com when it
+// has a domain
+func (p *samplePolicy) hostname(name string) string {
+	host := "host"
+	if p.Hostnames == sanitizeRandomize {
+		host += "-" + hex.EncodeToString(p.hash("hostname", strings.ToLower(name)))[:6]
+	}
+	if strings.Contains(name, ".") {
+		return host + ".example.com"
+	}
+	return host
+}
+
+// isSanitizedHostname reports whether a hostname is reserved for
+// documentation or was already replaced
+func isSanitizedHostname(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, domain := range reservedDomains {
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+	}
+	return sanitizedHostnamePattern.MatchString(name)
+}
+
+// accountID returns the replacement of an account ID with the same shape:
+// digits are replaced by digits, hexadecimal letters by hexadecimal letters,
+// other letters by letters of the same case and the rest is kept. The first
+// three digits or letters, all of them when redacting, are 0, or x for
+// letters that are not hexadecimal, so replaced IDs are recognized.
+func (p *samplePolicy) accountID(id string) string {
+	h := p.hash("account", id)
+	var b strings.Builder
+	marked := 0
+	for i, r := range []rune(id) {
+		if !unicode.IsDigit(r) && !unicode.IsLetter(r) {
+			b.WriteRune(r)
+			continue
+		}
+		c := h[i%len(h)]
+		var replacement rune
+		switch {
+		case marked < 3 || p.AccountIDs == sanitizeRedact:
+			marked++
+			replacement = 'x'
+			if unicode.IsDigit(r) || isHexLetter(r) {
+				replacement = '0'
+			}
+		case unicode.IsDigit(r):
+			replacement = rune('0' + c%10)
+		case isHexLetter(r):
+			replacement = rune('a' + c%6)
+		default:
+			replacement = rune('a' + c%26)
+		}
+		if unicode.IsUpper(r) {
+			replacement = unicode.ToUpper(replacement)
+		}
+		b.WriteRune(replacement)
+	}
+	return b.String()
+}
+
+// isHexLetter reports whether r is a hexadecimal letter, they are replaced by
+// hexadecimal letters to keep IDs such as GUIDs valid
+func isHexLetter(r rune) bool {
+	return strings.ContainsRune("abcdefABCDEF", r)
+}
+
+// isSanitizedAccountID reports whether an account ID was already replaced,
+// its first three digits or letters are 0 or x
+func isSanitizedAccountID(id string) bool {
+	marked := 0
+	for _, r := range id {
+		switch {
+		case r == '0' || r == 'x' || r == 'X':
+			marked++
+			if marked == 3 {
+				return true
+			}
+		case unicode.IsDigit(r) || unicode.IsLetter(r):
+			return false
+		}
+	}
+	return false
+}
+
+// replaceWords replaces the keys of replacements in s by their values where
+// they are not part of a longer word
+func replaceWords(s string, replacements map[string]string) string {
+	// Longer values first, so a value inside another is not replaced in it
+	olds := slices.Collect(maps.Keys(replacements))
+	slices.SortFunc(olds, func(a, b string) int { return cmp.Or(len(b)-len(a), strings.Compare(a, b)) })
+	for _, old := range olds {
+		if !strings.Contains(s, old) {
+			continue
+		}
+		pattern := regexp.MustCompile(`(^|[^\w.-])` + regexp.QuoteMeta(old) + `\b`)
+		s = pattern.ReplaceAllString(s, "${1}"+strings.ReplaceAll(replacements[old], "$", "$$"))
+	}
+	return s
+}
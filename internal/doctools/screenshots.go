@@ -0,0 +1,111 @@
+package doctools
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// imagesDir is the directory of a package the integrations UI serves its
+// images and screenshots from
+const imagesDir = "img"
+
+// htmlImagePattern matches HTML images and captures their address
+var htmlImagePattern = regexp.MustCompile(`(?i)<img\s[^>]*\bsrc\s*=\s*["']([^"']+)["']`)
+
+// readImages returns the images of the img/ directory of a package, by their
+// path in the package such as /img/overview.png. A package without one has
+// none.
+func readImages(pkgPath string) ([]string, error) {
+	images := []string{}
+	var walk func(dir, rel string) error
+	walk = func(dir, rel string) error {
+		entries, err := pkgFS.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				if err := walk(filepath.Join(dir, e.Name()), path.Join(rel, e.Name())); err != nil {
+					return err
+				}
+				continue
+			}
+			images = append(images, path.Join(rel, e.Name()))
+		}
+		return nil
+	}
+	if err := walk(filepath.Join(pkgPath, imagesDir), "/"+imagesDir); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return images, nil
+}
+
+// imageReferences returns the images of the package a readme shows, by their
+// path in the package, in order and without duplicates. Addresses are
+// relative to the rendered readme, images of other sites and in code are
+// left out.
+func imageReferences(content string) []string {
+	content = markdown.CodeFencePattern.ReplaceAllString(content, "")
+	content = inlineCodePattern.ReplaceAllString(content, "")
+	content = markdown.CommentPattern.ReplaceAllString(content, "")
+
+	var addresses []string
+	for _, m := range imagePattern.FindAllStringSubmatch(content, -1) {
+		addresses = append(addresses, m[1]+m[2])
+	}
+	for _, m := range htmlImagePattern.FindAllStringSubmatch(content, -1) {
+		addresses = append(addresses, m[1])
+	}
+
+	base := "/" + path.Dir(sourceReadmeRel)
+	var refs []string
+	for _, address := range addresses {
+		u, err := url.Parse(address)
+		if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || strings.Contains(address, "{{") {
+			continue
+		}
+		ref := u.Path
+		if !strings.HasPrefix(ref, "/") {
+			ref = path.Join(base, ref)
+		}
+		if !slices.Contains(refs, ref) {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// screenshotFindings cross-checks the images a readme shows with the img/
+// directory and the manifest screenshots of its package: images that are
+// not in img/, screenshots whose image is missing and screenshots the readme
+// does not show. Without the images of the package nothing is checked.
+func screenshotFindings(content string, req migrateRequest) []string {
+	if req.Images == nil {
+		return nil
+	}
+	var findings []string
+	refs := imageReferences(content)
+	for _, ref := range refs {
+		if !slices.Contains(req.Images, ref) {
+			findings = append(findings, fmt.Sprintf("image %s referenced by the readme is not in the %s/ directory of the package", ref, imagesDir))
+		}
+	}
+	for _, s := range req.manifestScreenshots {
+		src := path.Join("/", s.Src)
+		switch {
+		case !slices.Contains(req.Images, src):
+			findings = append(findings, fmt.Sprintf("screenshot %s of manifest.yml is not in the package", src))
+		case !slices.Contains(refs, src):
+			findings = append(findings, fmt.Sprintf("screenshot %s of manifest.yml is not referenced by the readme", src))
+		}
+	}
+	return findings
+}
@@ -0,0 +1,185 @@
+package doctools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"google.golang.org/api/option"
+	gcpsecretmanager "google.golang.org/api/secretmanager/v1"
+)
+
+// apiKeyRef points to a secret holding the API key, see resolveSecretRef
+var apiKeyRef string
+
+// secretRefHelp documents the supported secret references for flag usages
+const secretRefHelp = "Reference to a secret holding the Google Gemini API key: vault://<path>#<field>, aws-sm://<name or ARN>[#<field>] or gcp-sm://projects/<project>/secrets/<secret>[/versions/<version>][#<field>]"
+
+// resolveSecretRef fetches a secret from a secret manager. The fragment of
+// the reference selects a field of a structured secret:
+//
+//   - vault://secret/gemini#key reads from HashiCorp Vault at VAULT_ADDR
+//     with VAULT_TOKEN. Both KV version 1 and 2 are supported, the field can
+//     be omitted if the secret has a single one.
+//   - aws-sm://gemini-api-key reads from AWS Secrets Manager with the default
+//     AWS credential chain. With a fragment the secret is parsed as JSON.
+//   - gcp-sm://projects/p/secrets/gemini reads the latest version from GCP
+//     Secret Manager with application default credentials. With a fragment
+//     the secret is parsed as JSON.
+func resolveSecretRef(ctx context.Context, ref string) (string, error) {
+	// Not parsed as a URL, AWS ARNs contain colons.
+	scheme, rest, ok := strings.Cut(ref, "://")
+	rest, fragment, _ := strings.Cut(rest, "#")
+	path := strings.Trim(rest, "/")
+	if !ok || path == "" {
+		return "", fmt.Errorf("invalid secret reference %q, expected <scheme>://<path>[#<field>]", ref)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var (
+		secret string
+		err    error
+	)
+	switch scheme {
+	case "vault":
+		return vaultSecret(ctx, path, fragment)
+	case "aws-sm":
+		secret, err = awsSecret(ctx, path)
+	case "gcp-sm":
+		secret, err = gcpSecret(ctx, path)
+	default:
+		return "", fmt.Errorf("unsupported secret reference scheme %q, use vault, aws-sm or gcp-sm", scheme)
+	}
+	if err != nil {
+		return "", err
+	}
+	if fragment == "" {
+		return strings.TrimSpace(secret), nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object, cannot select field %q", path, fragment)
+	}
+	return secretField(fields, path, fragment)
+}
+
+// secretField returns a string field of a structured secret. An empty field
+// name selects the only field of the secret.
+func secretField(fields map[string]any, path, field string) (string, error) {
+	if field == "" {
+		if len(fields) != 1 {
+			names := make([]string, 0, len(fields))
+			for name := range fields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return "", fmt.Errorf("secret %s has fields %s, select one with #<field>", path, strings.Join(names, ", "))
+		}
+		for name := range fields {
+			field = name
+		}
+	}
+
+	v, ok := fields[field].(string)
+	if !ok {
+		return "", fmt.Errorf("secret %s has no string field %q", path, field)
+	}
+	return v, nil
+}
+
+// vaultSecret reads a field of a KV secret from Vault
+func vaultSecret(ctx context.Context, path, field string) (string, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	token := os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return "", errors.New("VAULT_ADDR and VAULT_TOKEN must be set to read secrets from Vault")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	resp, err := (&http.Client{Transport: outboundTransport}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from Vault: %w", path, err)
+	}
+	defer resp.Body.Close()
+	// KV version 2 serves secrets below <mount>/data/, allow referring to
+	// them by their logical path like the vault CLI does.
+	if mount, rest, ok := strings.Cut(path, "/"); resp.StatusCode == http.StatusNotFound && ok && !strings.HasPrefix(rest, "data/") {
+		return vaultSecret(ctx, mount+"/data/"+rest, field)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to read %s from Vault: %s", path, resp.Status)
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode Vault response: %w", err)
+	}
+	// KV version 2 nests the secret in data.data next to its metadata.
+	fields := body.Data
+	if nested, ok := fields["data"].(map[string]any); ok {
+		if _, hasMetadata := fields["metadata"]; hasMetadata {
+			fields = nested
+		}
+	}
+	return secretField(fields, path, field)
+}
+
+// awsSecret reads the string value of a secret from AWS Secrets Manager
+func awsSecret(ctx context.Context, id string) (string, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithHTTPClient(&http.Client{Transport: outboundTransport}))
+	if err != nil {
+		return "", fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	out, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(id)})
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from AWS Secrets Manager: %w", id, err)
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("secret %s has no string value", id)
+	}
+	return *out.SecretString, nil
+}
+
+// gcpSecret reads a secret version from GCP Secret Manager, the latest one
+// unless the path names a version
+func gcpSecret(ctx context.Context, name string) (string, error) {
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	svc, err := gcpsecretmanager.NewService(ctx, option.WithScopes(gcpsecretmanager.CloudPlatformScope))
+	if err != nil {
+		return "", fmt.Errorf("failed to create GCP Secret Manager client: %w", err)
+	}
+	resp, err := svc.Projects.Secrets.Versions.Access(name).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from GCP Secret Manager: %w", name, err)
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret %s: %w", name, err)
+	}
+	return string(data), nil
+}
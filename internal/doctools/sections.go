@@ -0,0 +1,134 @@
+package doctools
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// sectionsPrompt is added to the prompt of a readme that has sections the
+// section mappings of -config move to a template section
+const sectionsPrompt = `
+
+Move the content of these sections of the original README to the template section given, and do not keep their headings:
+%s`
+
+// sectionMapping is a section mapping of the configuration: the content of
+// legacy readme sections whose heading matches Heading or Pattern belongs
+// in the template section Section
+type sectionMapping struct {
+	// Heading is the heading of the legacy section, compared ignoring case.
+	Heading string `yaml:"heading"`
+	// Pattern is a regular expression matching the headings of legacy
+	// sections, instead of Heading. It ignores case.
+	Pattern string `yaml:"pattern"`
+	// Section is the heading of the template section.
+	Section string `yaml:"section"`
+
+	re *regexp.Regexp
+}
+
+// compile validates a mapping and compiles its heading or pattern
+func (m *sectionMapping) compile() error {
+	if (m.Heading == "") == (m.Pattern == "") {
+		return errors.New("needs either a heading or a pattern")
+	}
+	if strings.TrimSpace(m.Section) == "" {
+		return errors.New("no section")
+	}
+	pattern := "(?i)" + m.Pattern
+	if m.Heading != "" {
+		pattern = `(?i)^\s*` + regexp.QuoteMeta(strings.TrimSpace(m.Heading)) + `\s*$`
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	m.re = re
+	return nil
+}
+
+// mappedSection is a section of a readme a mapping moves
+type mappedSection struct {
+	Heading string
+	Section string
+	// HasContent reports whether the section has text besides comments.
+	HasContent bool
+}
+
+// mappedSections returns the sections of content the mappings move, the
+// first matching mapping applies. A heading that is the section of its
+// mapping already is not moved.
+func mappedSections(content string, mappings []sectionMapping) []mappedSection {
+	if len(mappings) == 0 {
+		return nil
+	}
+	lines := markdown.ParseLines(content)
+	var sections []mappedSection
+	for i, line := range lines {
+		if line.Level == 0 {
+			continue
+		}
+		for _, m := range mappings {
+			if !m.re.MatchString(line.Heading) {
+				continue
+			}
+			if !strings.EqualFold(line.Heading, m.Section) {
+				sections = append(sections, mappedSection{Heading: line.Heading, Section: m.Section, HasContent: sectionHasProse(lines, i)})
+			}
+			break
+		}
+	}
+	return sections
+}
+
+// sectionHasProse reports whether the section started by the heading at
+// start, with its subsections, has text besides comments
+func sectionHasProse(lines []markdown.Line, start int) bool {
+	var text []string
+	for _, l := range lines[start+1 : markdown.SectionEnd(lines, start)] {
+		if l.Level == 0 {
+			text = append(text, l.Text)
+		}
+	}
+	return markdown.HasProse(strings.Join(text, "\n"))
+}
+
+// formatMappedSections formats the moved sections for the prompt
+func formatMappedSections(sections []mappedSection) string {
+	var b strings.Builder
+	for _, s := range sections {
+		fmt.Fprintf(&b, "- %q goes to %q\n", s.Heading, s.Section)
+	}
+	return b.String()
+}
+
+// sectionMappingFindings checks that the sections of the original readme the
+// mappings move were moved in the migrated readme: their heading is gone and
+// their template section is there, with content if they had some. Template
+// sections that are missing are left to missingSections.
+func sectionMappingFindings(migrated, original, template string, mappings []sectionMapping) []string {
+	var findings []string
+	for _, s := range mappedSections(migrated, mappings) {
+		findings = append(findings, fmt.Sprintf("legacy section %q was kept, its content belongs in section %q", s.Heading, s.Section))
+	}
+
+	inTemplate := make(map[string]bool)
+	for _, h := range templateHeadings(template) {
+		inTemplate[strings.ToLower(h.Text)] = true
+	}
+	lines := markdown.ParseLines(migrated)
+	for _, s := range mappedSections(original, mappings) {
+		i := markdown.FindHeading(lines, s.Section)
+		switch {
+		case i < 0 && !inTemplate[strings.ToLower(s.Section)]:
+			findings = append(findings, fmt.Sprintf("missing section %q for the content of legacy section %q", s.Section, s.Heading))
+		case i >= 0 && s.HasContent && !sectionHasProse(lines, i):
+			findings = append(findings, fmt.Sprintf("section %q is empty, the content of legacy section %q belongs in it", s.Section, s.Heading))
+		}
+	}
+	return findings
+}
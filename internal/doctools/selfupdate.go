@@ -0,0 +1,302 @@
+package doctools
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+const (
+	// defaultReleaseRepo is the repository self-update takes the releases
+	// of
+	defaultReleaseRepo = "kgeller/go-examples"
+	// checksumsAsset lists the SHA-256 checksums of the binaries of a
+	// release, in the format of sha256sum, and signatureAsset is its
+	// base64 ed25519 signature
+	checksumsAsset = "checksums.txt"
+	signatureAsset = "checksums.txt.sig"
+	// maxBinarySize bounds the download of a binary
+	maxBinarySize = 512 << 20
+	// maxChecksumsSize bounds the download of the checksums and signature
+	maxChecksumsSize = 1 << 20
+)
+
+var (
+	// pseudoVersionPattern matches the pseudo-versions of builds of a commit
+	// rather than a release, such as v0.0.0-20240101120000-0123456789ab
+	pseudoVersionPattern = regexp.MustCompile(`\d{14}-[0-9a-f]{12}|\+dirty$`)
+
+	// version is the release of the binary, set by make with
+	// -ldflags "-X github.com/kgeller/go-examples/internal/doctools.version=v1.2.3". Binaries installed with go install
+	// take the version of their module instead.
+	version string
+	// releasePublicKey is the base64 ed25519 public key the checksums of the
+	// releases are signed with, set by make from RELEASE_PUBLIC_KEY
+	releasePublicKey string
+)
+
+// binaryVersion returns the release of the running binary, or dev for
+// builds from a checkout, which Go stamps with a pseudo-version
+func binaryVersion() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" || info.Main.Version == "(devel)" || pseudoVersionPattern.MatchString(info.Main.Version) {
+		return "dev"
+	}
+	return info.Main.Version
+}
+
+// releaseAssetName returns the name of the binary of a release for the
+// platform the tool runs on, as built by make dist
+func releaseAssetName() string {
+	name := fmt.Sprintf("docs-template-update_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// runSelfUpdate implements the self-update subcommand
+func runSelfUpdate(args []string) {
+	fs := flag.NewFlagSet("self-update", flag.ExitOnError)
+	repo := fs.String("repo", defaultReleaseRepo, "Repository whose releases are installed (uses GITHUB_TOKEN and GITHUB_API_URL)")
+	tag := fs.String("version", "", "Release to install, e.g. v1.4.0, instead of the latest one")
+	check := fs.Bool("check", false, "Only report whether a newer release is available, exit with 1 if there is one")
+	force := fs.Bool("force", false, "Install the release even if it is not newer than the running binary, or the binary is not a release")
+	publicKey := fs.String("public-key", releasePublicKey, "Base64 ed25519 public key the checksums of the releases are signed with (defaults to the key the binary was released with)")
+	skipSignature := fs.Bool("insecure-skip-signature", false, "Install a release without a public key, only checking the checksum of the binary")
+	timeout := fs.Duration("timeout", 5*time.Minute, "Timeout for checking and downloading the release")
+	registerVerbosityFlags(fs)
+	registerNetworkFlags(fs)
+	_ = fs.Parse(args)
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	client := envGitHubClient()
+	release, err := client.release(ctx, *repo, *tag)
+	if errors.Is(err, errNotFound) {
+		if *tag != "" {
+			log.Fatalf("Error: %s has no release %s", *repo, *tag)
+		}
+		log.Fatalf("Error: %s has no release", *repo)
+	}
+	if err != nil {
+		log.Fatalf("Error checking the releases of %s: %v", *repo, err)
+	}
+
+	// A binary that is not a release is never up to date, but only
+	// replaced with -force
+	current := binaryVersion()
+	_, isRelease := releaseVersion(current)
+	newer := newerVersion(release.TagName, current)
+	switch {
+	case *check && (newer || !isRelease):
+		fmt.Printf("docs-template-update %s is available, this is %s: %s\n", release.TagName, current, release.HTMLURL)
+		os.Exit(1)
+	case *check, isRelease && !newer && !*force:
+		logInfo("docs-template-update %s is up to date", current)
+		return
+	case !isRelease && !*force:
+		log.Fatalf("Error: docs-template-update %s is not a release, use -force to replace it with %s", current, release.TagName)
+	}
+
+	if *publicKey == "" && !*skipSignature {
+		log.Fatalf("Error: no release public key to verify %s with, pass -public-key, or -insecure-skip-signature to only check its checksum", release.TagName)
+	}
+	binary, err := downloadRelease(ctx, client, release, *publicKey)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		log.Fatalf("Error finding the running binary: %v", err)
+	}
+	if err := installBinary(exe, binary); err != nil {
+		log.Fatalf("Error replacing %s: %v", exe, err)
+	}
+	logInfo("Updated %s from %s to %s", exe, current, release.TagName)
+}
+
+// downloadRelease downloads the binary of a release for the running
+// platform and checks it against the checksums of the release. The
+// checksums must carry the signature of the public key, which is only
+// empty with -insecure-skip-signature.
+func downloadRelease(ctx context.Context, client *githubClient, release githubRelease, publicKey string) ([]byte, error) {
+	name := releaseAssetName()
+	assets := make(map[string]githubAsset)
+	for _, a := range release.Assets {
+		assets[a.Name] = a
+	}
+	asset, ok := assets[name]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no binary %s for this platform", release.TagName, name)
+	}
+	sums, ok := assets[checksumsAsset]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s, its binaries cannot be verified", release.TagName, checksumsAsset)
+	}
+	checksums, err := client.downloadAsset(ctx, sums, maxChecksumsSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if publicKey == "" {
+		log.Printf("Warning: -insecure-skip-signature, only the checksum of the binary is verified")
+	} else {
+		sigAsset, ok := assets[signatureAsset]
+		if !ok {
+			return nil, fmt.Errorf("release %s has no %s, it is not signed", release.TagName, signatureAsset)
+		}
+		sig, err := client.downloadAsset(ctx, sigAsset, maxChecksumsSize)
+		if err != nil {
+			return nil, err
+		}
+		if err := verifyReleaseSignature(checksums, sig, publicKey); err != nil {
+			return nil, err
+		}
+	}
+	want, err := assetChecksum(checksums, name)
+	if err != nil {
+		return nil, err
+	}
+
+	logInfo("Downloading %s of %s", name, release.TagName)
+	binary, err := client.downloadAsset(ctx, asset, maxBinarySize)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(binary)
+	if !bytes.Equal(sum[:], want) {
+		return nil, fmt.Errorf("the checksum of %s does not match %s, the download is corrupt or was tampered with", name, checksumsAsset)
+	}
+	return binary, nil
+}
+
+// verifyReleaseSignature checks the base64 ed25519 signature of data with a
+// base64 public key
+func verifyReleaseSignature(data, sig []byte, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid release public key, want a base64 ed25519 public key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", signatureAsset, err)
+	}
+	if !ed25519.Verify(key, data, signature) {
+		return fmt.Errorf("the signature of %s does not match the release public key", checksumsAsset)
+	}
+	return nil
+}
+
+// assetChecksum returns the SHA-256 checksum of an asset from the lines of
+// a checksums file, "<hex>  <name>" with an optional * before binary names
+func assetChecksum(checksums []byte, name string) ([]byte, error) {
+	for _, line := range strings.Split(string(checksums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		sum, err := hex.DecodeString(fields[0])
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid checksum of %s in %s", name, checksumsAsset)
+		}
+		return sum, nil
+	}
+	return nil, fmt.Errorf("%s has no checksum of %s", checksumsAsset, name)
+}
+
+// installBinary replaces the executable at exe with binary, keeping its
+// permissions. The new binary is written next to it first so the
+// replacement is a rename.
+func installBinary(exe string, binary []byte) error {
+	perm := os.FileMode(0o755)
+	if info, err := os.Stat(exe); err == nil {
+		perm = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return replaceExecutable(tmp.Name(), exe)
+}
+
+// describePattern matches the suffixes git describe adds to the tag a build
+// is counted from: the commits after it and the abbreviated hash, and dirty
+// for uncommitted changes
+var describePattern = regexp.MustCompile(`^(.+?)(-\d+-g[0-9a-f]+)?(-dirty)?$`)
+
+// releaseVersion returns the release a version is built from: the version
+// itself for a release tag such as v1.4.0 or v1.4.0-rc.1, or the tag git
+// describe counted from for a build after it, such as v1.4.0 for
+// v1.4.0-3-gabc1234 or v1.4.0-dirty. It returns false if that is not a
+// semantic version with its three numbers.
+func releaseVersion(v string) (string, bool) {
+	m := describePattern.FindStringSubmatch(v)
+	if m == nil {
+		return "", false
+	}
+	base := m[1]
+	if !semver.IsValid(base) || semver.Canonical(base) != strings.SplitN(base, "+", 2)[0] {
+		return "", false
+	}
+	return base, true
+}
+
+// newerVersion reports whether the release tag is newer than the version
+// of the running binary, false if either is not a release. A build git
+// describe counted from a tag is newer than the tag, and a pre-release older
+// than its release.
+func newerVersion(tag, current string) bool {
+	if base, ok := releaseVersion(tag); !ok || base != tag {
+		return false
+	}
+	c, ok := releaseVersion(current)
+	if !ok {
+		return false
+	}
+	return semver.Compare(tag, c) > 0
+}
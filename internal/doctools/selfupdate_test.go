@@ -0,0 +1,183 @@
+package doctools
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+func TestAssetChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("binary"))
+	hexSum := hex.EncodeToString(sum[:])
+	tests := []struct {
+		name      string
+		checksums string
+		asset     string
+		want      []byte
+		wantErr   bool
+	}{
+		{
+			name:      "text mode",
+			checksums: hexSum + "  docs-template-update_linux_amd64\n",
+			asset:     "docs-template-update_linux_amd64",
+			want:      sum[:],
+		},
+		{
+			name:      "binary mode",
+			checksums: hexSum + " *docs-template-update_windows_amd64.exe\n",
+			asset:     "docs-template-update_windows_amd64.exe",
+			want:      sum[:],
+		},
+		{
+			name:      "among others",
+			checksums: "00  other\n\n" + hexSum + "  docs-template-update_darwin_arm64\n",
+			asset:     "docs-template-update_darwin_arm64",
+			want:      sum[:],
+		},
+		{
+			name:      "missing",
+			checksums: hexSum + "  docs-template-update_linux_amd64\n",
+			asset:     "docs-template-update_linux_arm64",
+			wantErr:   true,
+		},
+		{
+			name:      "prefix of another name",
+			checksums: hexSum + "  docs-template-update_linux_amd64.exe\n",
+			asset:     "docs-template-update_linux_amd64",
+			wantErr:   true,
+		},
+		{
+			name:      "invalid hex",
+			checksums: "zz  docs-template-update_linux_amd64\n",
+			asset:     "docs-template-update_linux_amd64",
+			wantErr:   true,
+		},
+		{
+			name:      "short checksum",
+			checksums: hexSum[:32] + "  docs-template-update_linux_amd64\n",
+			asset:     "docs-template-update_linux_amd64",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := assetChecksum([]byte(tt.checksums), tt.asset)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("assetChecksum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("assetChecksum() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyReleaseSignature(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("0123  docs-template-update_linux_amd64\n")
+	sig := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(private, data)) + "\n")
+	key := base64.StdEncoding.EncodeToString(public)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		sig       []byte
+		publicKey string
+		wantErr   bool
+	}{
+		{name: "valid", data: data, sig: sig, publicKey: key},
+		{name: "key with newline", data: data, sig: sig, publicKey: key + "\n"},
+		{name: "tampered data", data: append([]byte("1"), data...), sig: sig, publicKey: key, wantErr: true},
+		{name: "other key", data: data, sig: sig, publicKey: base64.StdEncoding.EncodeToString(otherPublic), wantErr: true},
+		{name: "invalid key", data: data, sig: sig, publicKey: "not a key", wantErr: true},
+		{name: "short key", data: data, sig: sig, publicKey: base64.StdEncoding.EncodeToString(public[:16]), wantErr: true},
+		{name: "invalid signature", data: data, sig: []byte("not a signature"), publicKey: key, wantErr: true},
+		{name: "empty signature", data: data, sig: nil, publicKey: key, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyReleaseSignature(tt.data, tt.sig, tt.publicKey)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyReleaseSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReleaseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+		wantOK  bool
+	}{
+		{version: "v1.4.0", want: "v1.4.0", wantOK: true},
+		{version: "v0.10.2", want: "v0.10.2", wantOK: true},
+		{version: "v1.4.0-rc.1", want: "v1.4.0-rc.1", wantOK: true},
+		{version: "v1.4.0+build", want: "v1.4.0+build", wantOK: true},
+		{version: "v1.4.0-3-gabc1234", want: "v1.4.0", wantOK: true},
+		{version: "v1.4.0-3-gabc1234-dirty", want: "v1.4.0", wantOK: true},
+		{version: "v1.4.0-dirty", want: "v1.4.0", wantOK: true},
+		{version: "v1.4.0-rc.1-2-gabc1234", want: "v1.4.0-rc.1", wantOK: true},
+		{version: "abc1234"},
+		{version: "1.4.0"},
+		{version: "v1.4"},
+		{version: "v1.4.0.1"},
+		{version: "v1.x.0"},
+		{version: "v1.-4.0"},
+		{version: "dev"},
+		{version: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, ok := releaseVersion(tt.version)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("releaseVersion(%q) = %q, %v, want %q, %v", tt.version, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewerVersion(t *testing.T) {
+	tests := []struct {
+		tag     string
+		current string
+		want    bool
+	}{
+		{tag: "v1.4.1", current: "v1.4.0", want: true},
+		{tag: "v1.5.0", current: "v1.4.9", want: true},
+		{tag: "v2.0.0", current: "v1.10.10", want: true},
+		{tag: "v1.10.0", current: "v1.9.0", want: true},
+		{tag: "v1.4.0", current: "v1.4.0"},
+		{tag: "v1.3.9", current: "v1.4.0"},
+		{tag: "v1.4.0", current: "v1.4.0-rc.1", want: true},
+		{tag: "v1.4.0-rc.2", current: "v1.4.0-rc.1", want: true},
+		{tag: "v1.4.0-rc.1", current: "v1.3.0", want: true},
+		{tag: "v1.4.0-rc.1", current: "v1.4.0"},
+		{tag: "v1.4.0", current: "v1.4.0-3-gabc1234"},
+		{tag: "v1.4.0", current: "v1.4.0-dirty"},
+		{tag: "v1.4.1", current: "v1.4.0-3-gabc1234", want: true},
+		{tag: "v1.4.0", current: "v1.4.0-rc.1-2-gabc1234", want: true},
+		{tag: "v1.4.1-3-gabc1234", current: "v1.4.0"},
+		{tag: "v1.4.0", current: "abc1234"},
+		{tag: "v1.4.0", current: "dev"},
+		{tag: "latest", current: "v1.4.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag+" over "+tt.current, func(t *testing.T) {
+			if got := newerVersion(tt.tag, tt.current); got != tt.want {
+				t.Errorf("newerVersion(%q, %q) = %v, want %v", tt.tag, tt.current, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package doctools
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	// maxRequestBytes bounds the size of a migrate request body
+	maxRequestBytes = 10 << 20
+	// maxJobRequestBytes bounds the size of a batch job request body
+	maxJobRequestBytes = 100 << 20
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// runServe implements the serve subcommand
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	grpcAddr := fs.String("grpc-addr", "", "Address to serve the gRPC API on (disabled when empty)")
+	webhookSecret := fs.String("github-webhook-secret", os.Getenv("GITHUB_WEBHOOK_SECRET"), "Secret used to verify GitHub webhook deliveries; enables the webhook bot (defaults to GITHUB_WEBHOOK_SECRET)")
+	githubToken := fs.String("github-token", os.Getenv("GITHUB_TOKEN"), "GitHub token the webhook bot uses to comment and open pull requests (defaults to GITHUB_TOKEN)")
+	githubAPIURL := fs.String("github-api-url", "https://api.github.com", "GitHub API base URL")
+	historyPath := fs.String("history", os.Getenv(historyEnv), "Path to a SQLite database recording finished jobs (defaults to "+historyEnv+")")
+	queuePath := fs.String("queue", "", "Path to a SQLite work queue; jobs are migrated by remote workers instead of this process")
+	leaseTTL := fs.Duration("lease-ttl", 20*time.Minute, "How long a worker may take for a package before it is handed to another worker, keep it above the workers' -package-timeout")
+	workerToken := fs.String("worker-token", os.Getenv(workerTokenEnv), "Token workers must present to lease work, required with -queue (defaults to "+workerTokenEnv+")")
+	apiTokens := fs.String("api-tokens", os.Getenv(apiTokensEnv), "Path to a file of \"<name> <token>\" lines, the bearer tokens of the API, the web UI and gRPC (defaults to "+apiTokensEnv+")")
+	identityHeader := fs.String("identity-header", "", "Header set to the authenticated user by a trusted proxy in front of the server, e.g. X-Forwarded-User; only use it when the server cannot be reached except through the proxy")
+	insecureNoAuth := fs.Bool("insecure-no-auth", false, "Serve the API, the web UI, gRPC and the work routes without authentication")
+	requireApproval := fs.Bool("require-approval", false, "Hold the pull requests the webhook bot opens for pushes until a reviewer accepts them through /v1/approvals or /ui/approvals, needs -history")
+	pprof := fs.String("pprof", "", "Serve pprof profiles on this address, e.g. localhost:6060")
+	fs.BoolVar(&debugRuntime, "debug-runtime", false, "Log memory and goroutine statistics after every job package")
+	fs.StringVar(&apiKeyRef, "api-key-ref", "", secretRefHelp)
+	fs.StringVar(&googleAPIKey, "api-key", "", "Google Gemini API key (can also be set via GOOGLE_API_KEY environment variable)")
+	registerVerbosityFlags(fs)
+	registerNetworkFlags(fs)
+	registerTimeoutFlags(fs)
+	registerECSFlags(fs)
+	registerStyleIndexFlags(fs)
+	registerGlossaryFlags(fs)
+	registerConfigFlags(fs)
+	registerValeFlags(fs)
+	registerReadabilityFlags(fs)
+	registerProviderFlags(fs)
+	registerPromptFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s serve [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "serve exposes the migration over a REST API and, optionally, gRPC.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateTimeouts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadGlossary(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadProvider(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadPrompts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// A coordinator leaves the generation to the workers, the key is only
+	// needed for the synchronous endpoints then.
+	if *queuePath == "" {
+		requireAPIKey()
+	} else if err := loadAPIKey(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// Anyone reaching the server could otherwise spend the API key and
+	// lease or complete work
+	auth, err := newAPIAuth(*apiTokens, *identityHeader, *insecureNoAuth)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if !auth.enabled() && !*insecureNoAuth {
+		log.Fatalf("Error: serve needs -api-tokens or -identity-header to authenticate requests, or -insecure-no-auth")
+	}
+	if *queuePath != "" && *workerToken == "" && !*insecureNoAuth {
+		log.Fatalf("Error: -queue needs -worker-token to authenticate workers, or -insecure-no-auth")
+	}
+	// The approver of a decision is the authenticated user, and the held
+	// patches are kept in the history database
+	if *requireApproval && !auth.enabled() {
+		log.Fatalf("Error: -require-approval needs -api-tokens or -identity-header to identify the approvers")
+	}
+	if *requireApproval && *historyPath == "" {
+		log.Fatalf("Error: -require-approval needs -history to keep the held patches")
+	}
+
+	if *pprof != "" {
+		startPprof(*pprof)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+
+	if *grpcAddr != "" {
+		go func() {
+			if err := serveGRPC(ctx, *grpcAddr, auth); err != nil {
+				log.Fatalf("Error serving gRPC: %v", err)
+			}
+		}()
+	}
+
+	var history *historyStore
+	if *historyPath != "" {
+		if history, err = openHistory(*historyPath); err != nil {
+			log.Fatalf("Error opening history: %v", err)
+		}
+		defer history.Close()
+	}
+
+	store := newJobStore(ctx, history)
+	mux := newServeMux(store, auth)
+	if *queuePath != "" {
+		queue, err := openQueue(*queuePath)
+		if err != nil {
+			log.Fatalf("Error opening work queue: %v", err)
+		}
+		defer queue.Close()
+		if err := store.useQueue(queue, *leaseTTL); err != nil {
+			log.Fatalf("Error restoring jobs: %v", err)
+		}
+		registerWorkRoutes(mux, store, *workerToken)
+	}
+	if *webhookSecret != "" {
+		bot := &webhookBot{
+			ctx:    ctx,
+			secret: []byte(*webhookSecret),
+			gh:     newGitHubClient(*githubAPIURL, *githubToken),
+		}
+		if bot.login, err = bot.gh.authenticatedUser(ctx); err != nil {
+			log.Fatalf("Error looking up the GitHub account of the token: %v", err)
+		}
+		if *requireApproval {
+			if bot.approvals, err = newApprovalQueue(history); err != nil {
+				log.Fatalf("Error restoring held patches: %v", err)
+			}
+			bot.approvals.open = func(p *heldPatch) (string, error) {
+				return bot.openPullRequest(p.Repo, p.Base, p.SHA, p.migration)
+			}
+			registerApprovalRoutes(mux, bot.approvals, auth)
+		}
+		mux.Handle("POST /v1/webhooks/github", bot)
+	} else if *requireApproval {
+		log.Fatalf("Error: -require-approval needs -github-webhook-secret")
+	}
+
+	if err := serve(ctx, *addr, mux); err != nil {
+		log.Fatalf("Error serving: %v", err)
+	}
+}
+
+// serve runs an HTTP server until ctx is cancelled, then shuts it down
+// gracefully
+func serve(ctx context.Context, addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           otelhttp.NewHandler(handler, "docs-template-update"),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s", addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newServeMux returns the routes of serve. The API and the web UI need auth,
+// the health check, the spec and the metrics do not.
+func newServeMux(store *jobStore, auth *apiAuth) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("GET /openapi.yaml", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write(openAPISpec)
+	})
+	mux.Handle("GET /metrics", promhttp.Handler())
+
+	// The more specific routes added to mux later, such as those of the
+	// workers and the webhook, take precedence and check their own auth
+	api := http.NewServeMux()
+	api.HandleFunc("POST /v1/migrate", handleMigrate)
+	registerJobRoutes(api, store, auth)
+	registerUIRoutes(api, store, auth)
+	mux.Handle("/v1/", auth.require(api))
+	mux.Handle("/ui/", auth.require(api))
+	mux.Handle("GET /{$}", api)
+	return mux
+}
+
+func handleMigrate(w http.ResponseWriter, r *http.Request) {
+	var req migrateRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	if req.Readme == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "readme is required"})
+		return
+	}
+	if err := validateTarget(req.Target); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	if err := validatePackageType(req.PackageType); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	resp, err := migrateContent(r.Context(), req, nil)
+	if err != nil {
+		log.Printf("Error migrating readme: %v", err)
+		writeJSON(w, http.StatusBadGateway, errorResponse{Error: redactSecrets(err.Error())})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
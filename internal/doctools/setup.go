@@ -0,0 +1,151 @@
+package doctools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// setupPrompt is added to the readme prompt when the configuration settings
+// are generated from the manifest
+const setupPrompt = `
+
+Do not list the configuration settings of the integration in the Onboard / configure section, a "Configuration settings" subsection is generated from the package manifest and added after your response. Keep any other setup instructions of the original README.md there.`
+
+// setupNote marks the generated settings, so they are not edited by hand
+const setupNote = "<!-- Generated from the policy_templates and vars of manifest.yml, changes to this section are overwritten -->"
+
+// empty reports whether the package has no settings to document
+func (s *packageSetup) empty() bool {
+	return s == nil || (len(s.Vars) == 0 && len(s.PolicyTemplates) == 0)
+}
+
+// formatDefault formats the default value of a setting for a table cell
+func formatDefault(v manifestVar) string {
+	if v.Secret || v.Default == nil {
+		return ""
+	}
+	switch d := v.Default.(type) {
+	case string:
+		if strings.Contains(strings.TrimSpace(d), "\n") {
+			return "multi-line value"
+		}
+		return "`" + d + "`"
+	case []any:
+		values := make([]string, len(d))
+		for i, item := range d {
+			values[i] = formatDefault(manifestVar{Default: item})
+		}
+		return strings.Join(values, ", ")
+	}
+	return fmt.Sprintf("`%v`", v.Default)
+}
+
+// firstSentence returns the first sentence of a description on one line
+func firstSentence(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if i := strings.Index(text, ". "); i >= 0 {
+		text = text[:i+1]
+	}
+	return text
+}
+
+// settingsTable writes the settings as a markdown table
+func settingsTable(b *strings.Builder, vars []manifestVar) {
+	if len(vars) == 0 {
+		b.WriteString("There are no settings.\n")
+		return
+	}
+	b.WriteString("| Setting | Required | Default | Description |\n")
+	b.WriteString("|---------|----------|---------|-------------|\n")
+	cell := strings.NewReplacer("|", `\|`)
+	for _, v := range vars {
+		name := fmt.Sprintf("`%s`", v.Name)
+		if v.Title != "" {
+			name = fmt.Sprintf("%s (`%s`)", v.Title, v.Name)
+		}
+		if !v.ShowUser {
+			name += ", advanced"
+		}
+		required := "No"
+		if v.Required {
+			required = "Yes"
+		}
+		fmt.Fprintf(b, "| %s | %s | %s | %s |\n", cell.Replace(name), required, cell.Replace(formatDefault(v)), cell.Replace(firstSentence(v.Description)))
+	}
+}
+
+// setupSection documents the settings of a package, as a section with a
+// heading of the given level
+func setupSection(setup *packageSetup, level int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s Configuration settings\n\n%s\n\n", strings.Repeat("#", level), setupNote)
+	b.WriteString("These settings are available when adding the integration to an agent policy. Advanced settings are hidden under the advanced options.\n")
+
+	if len(setup.Vars) > 0 {
+		b.WriteString("\nThese settings apply to all inputs:\n\n")
+		settingsTable(&b, setup.Vars)
+	}
+	for _, pt := range setup.PolicyTemplates {
+		if len(setup.PolicyTemplates) > 1 {
+			title := pt.Title
+			if title == "" {
+				title = pt.Name
+			}
+			fmt.Fprintf(&b, "\n**%s**\n", title)
+		}
+		if pt.Input != "" {
+			fmt.Fprintf(&b, "\nThe `%s` input has these settings:\n\n", pt.Input)
+			settingsTable(&b, pt.Vars)
+		}
+		for _, in := range pt.Inputs {
+			title := in.Title
+			if title == "" {
+				title = in.Type
+			}
+			fmt.Fprintf(&b, "\n%s (`%s` input):\n\n", title, in.Type)
+			settingsTable(&b, in.Vars)
+		}
+	}
+	return b.String()
+}
+
+// applySetupSection replaces the Configuration settings subsection of the
+// Onboard / configure section with one generated from the manifest. Readmes
+// without the section get it at the end of the deployment section, readmes
+// without either are left as they are.
+func applySetupSection(content string, setup *packageSetup) string {
+	if setup.empty() {
+		return content
+	}
+
+	lines := markdown.ParseLines(content)
+	var before, after, section string
+	if onboard := markdown.FindHeading(lines, "Onboard / configure"); onboard >= 0 {
+		end := markdown.SectionEnd(lines, onboard)
+		for i := onboard + 1; i < end; i++ {
+			if lines[i].Level > 0 && strings.EqualFold(lines[i].Heading, "configuration settings") {
+				sub := min(markdown.SectionEnd(lines, i), end)
+				lines = append(lines[:i:i], lines[sub:]...)
+				end -= sub - i
+				i--
+			}
+		}
+		before, after = markdown.ShiftHeadings(lines[:end], 0), markdown.ShiftHeadings(lines[end:], 0)
+		section = setupSection(setup, min(lines[onboard].Level+1, 6))
+	} else if deploy := markdown.FindHeading(lines, "How do I deploy this integration?"); deploy >= 0 {
+		end := markdown.SectionEnd(lines, deploy)
+		level := min(lines[deploy].Level+1, 6)
+		before, after = markdown.ShiftHeadings(lines[:end], 0), markdown.ShiftHeadings(lines[end:], 0)
+		section = fmt.Sprintf("%s Onboard / configure\n\n%s", strings.Repeat("#", level), setupSection(setup, min(level+1, 6)))
+	} else {
+		return content
+	}
+
+	before = strings.TrimRight(before, "\n")
+	if after == "" {
+		return before + "\n\n" + section
+	}
+	return before + "\n\n" + section + "\n" + after
+}
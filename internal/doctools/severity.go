@@ -0,0 +1,164 @@
+package doctools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Severities of findings, from the least to the most severe. Terminology
+// rules and style alerts use the same names.
+const (
+	severityInfo    = "info"
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+// severityRanks orders the severities
+var severityRanks = map[string]int{
+	severityInfo:    1,
+	severityWarning: 2,
+	severityError:   3,
+}
+
+// Sources of findings
+const (
+	sourceValidation   = "validation"
+	sourceTerminology  = "terminology"
+	sourceGlossary     = "glossary"
+	sourceReadability  = "readability"
+	sourcePreservation = "preservation"
+	sourceTranslation  = "translation"
+	sourceLint         = "lint"
+	sourceCoverage     = "coverage"
+	sourceTodo         = "todo"
+	sourceFields       = "fields"
+)
+
+var (
+	// strictMode fails packages with findings of warning severity or above,
+	// selected with -strict
+	strictMode bool
+	// failOn is the severity of -fail-on, packages with findings of this
+	// severity or above fail
+	failOn string
+)
+
+// finding is a finding of a migration classified by severity
+type finding struct {
+	Severity string `json:"severity"`
+	// Source is the check the finding comes from: validation, terminology,
+	// glossary, readability, preservation, translation, lint, coverage, todo
+	// or fields.
+	Source  string `json:"source"`
+	Message string `json:"message"`
+}
+
+func (f finding) String() string {
+	return fmt.Sprintf("%s (%s): %s", f.Severity, f.Source, f.Message)
+}
+
+// warningRule classifies the warnings matching pattern
+type warningRule struct {
+	pattern  *regexp.Regexp
+	source   string
+	severity string
+}
+
+// warningRules classify the warnings of a migration, the first matching rule
+// applies. Warnings no rule matches are validation warnings.
+var warningRules = []warningRule{
+	{regexp.MustCompile(`^error: line \d+:`), sourceTerminology, severityError},
+	{regexp.MustCompile(`^warning: line \d+:`), sourceTerminology, severityWarning},
+	{regexp.MustCompile(`^line \d+: use ".*" instead of`), sourceGlossary, severityWarning},
+	{regexp.MustCompile(`, above -max-`), sourceReadability, severityWarning},
+	{regexp.MustCompile(`^\S+ readme (is missing|has) placeholder`), sourceTranslation, severityError},
+	{regexp.MustCompile(`^\S+ (readme has \d+ headings|heading ".*" is level)`), sourceTranslation, severityWarning},
+	{regexp.MustCompile(`readme has not been migrated$`), sourceTranslation, severityInfo},
+	// Placeholders that are missing or cannot be rendered break the docs
+	// elastic-package or docs-builder build.
+	{regexp.MustCompile(`placeholder`), sourceValidation, severityError},
+	{regexp.MustCompile(`^(missing|invalid) frontmatter|directive`), sourceValidation, severityError},
+	{regexp.MustCompile(`^section of kept data stream .* missing`), sourcePreservation, severityError},
+	// License headers are legal text, they must be kept to the character
+	{regexp.MustCompile(`^license header`), sourcePreservation, severityError},
+	{regexp.MustCompile(`^no section found for data stream`), sourcePreservation, severityWarning},
+	{regexp.MustCompile(`^page .* exists already, .* was not copied`), sourcePreservation, severityWarning},
+	{regexp.MustCompile(`^link to .* was rewritten to `), sourcePreservation, severityInfo},
+	{regexp.MustCompile(`^screenshot .* is not referenced by the readme$`), sourceValidation, severityInfo},
+	{regexp.MustCompile(`out of the prompt to fit the token budget`), sourceValidation, severityInfo},
+	{regexp.MustCompile(`^field \S+ is documented in the readme but not defined`), sourceFields, severityWarning},
+	{regexp.MustCompile(`^data stream \S+ defines \d+ fields the readme did not document`), sourceFields, severityInfo},
+}
+
+// classifyWarning returns the source and severity of a warning
+func classifyWarning(msg string) (string, string) {
+	for _, r := range warningRules {
+		if r.pattern.MatchString(msg) {
+			return r.source, r.severity
+		}
+	}
+	return sourceValidation, severityWarning
+}
+
+// classifyWarnings classifies warnings by source and severity
+func classifyWarnings(warnings []string) []finding {
+	var findings []finding
+	for _, w := range warnings {
+		source, severity := classifyWarning(w)
+		findings = append(findings, finding{Severity: severity, Source: source, Message: w})
+	}
+	return findings
+}
+
+// classifyFindings classifies the warnings, style alerts, docs gaps and
+// TODOs of a migration
+func classifyFindings(resp *migrateResponse) []finding {
+	findings := classifyWarnings(resp.Warnings)
+	for _, a := range resp.Style {
+		severity := strings.ToLower(a.Severity)
+		if _, ok := severityRanks[severity]; !ok {
+			// Vale suggestions
+			severity = severityInfo
+		}
+		findings = append(findings, finding{Severity: severity, Source: sourceLint, Message: fmt.Sprintf("line %d, %s: %s", a.Line, a.Check, a.Message)})
+	}
+	for _, g := range resp.Gaps {
+		findings = append(findings, finding{Severity: severityInfo, Source: sourceCoverage, Message: fmt.Sprintf("section %q is %s", g.Section, g.Kind)})
+	}
+	for _, t := range resp.Todos {
+		findings = append(findings, finding{Severity: severityInfo, Source: sourceTodo, Message: fmt.Sprintf("%s in %q, line %d: %s", t.Kind, t.Section, t.Line, t.Text)})
+	}
+	return findings
+}
+
+// validateFailOn checks -fail-on and sets it from -strict
+func validateFailOn() error {
+	if strictMode {
+		if failOn != "" && failOn != severityWarning {
+			return fmt.Errorf("-strict fails on warnings, it cannot be used with -fail-on %s", failOn)
+		}
+		failOn = severityWarning
+	}
+	if failOn == "" {
+		return nil
+	}
+	if _, ok := severityRanks[failOn]; !ok {
+		return fmt.Errorf("unknown -fail-on severity %q, use %s, %s or %s", failOn, severityInfo, severityWarning, severityError)
+	}
+	return nil
+}
+
+// failingFindings returns the findings at or above the -fail-on severity
+func failingFindings(findings []finding) []finding {
+	if failOn == "" {
+		return nil
+	}
+	var failing []finding
+	for _, f := range findings {
+		if severityRanks[f.Severity] >= severityRanks[failOn] {
+			failing = append(failing, f)
+		}
+	}
+	return failing
+}
@@ -0,0 +1,202 @@
+package doctools
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// sinceRef is the git ref of -since, only the data streams changed since it
+// are regenerated
+var sinceRef string
+
+// changedDataStreams returns the data streams of the package at pkgPath with
+// files changed since the git ref, committed or not, including new files
+// git does not track yet, in order of their names
+func changedDataStreams(pkgPath, ref string) ([]string, error) {
+	changed, err := gitOutput(pkgPath, "diff", "--name-only", "--relative", ref, "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := gitOutput(pkgPath, "ls-files", "--others", "--exclude-standard", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	var dataStreams []string
+	for _, file := range strings.Split(changed+"\n"+untracked, "\n") {
+		dir, rest, ok := strings.Cut(path.Clean(strings.TrimSpace(file)), "/")
+		if !ok || dir != "data_stream" {
+			continue
+		}
+		name, _, _ := strings.Cut(rest, "/")
+		if !slices.Contains(dataStreams, name) {
+			dataStreams = append(dataStreams, name)
+		}
+	}
+	slices.Sort(dataStreams)
+	return dataStreams, nil
+}
+
+// dataStreamSectionsPrompt is added to the readme prompt when only the
+// Reference sections of the data streams changed since -since are
+// regenerated
+const dataStreamSectionsPrompt = `
+
+The README is already migrated to the template. Only write the sections of the data streams %s under its Reference section again, following this part of the template:
+
+%s
+
+Answer with these sections only, each starting with a level %d heading naming its data stream as the README does. Do not write the other sections of the README.`
+
+// selectChangedDataStreams restricts a migration to the Reference sections
+// of the data streams of the package changed since -since, the rest of the
+// readme is kept byte for byte. Data streams that were removed since are
+// left to the full migration.
+func selectChangedDataStreams(req *migrateRequest, pkgPath string) error {
+	changed, err := changedDataStreams(pkgPath, sinceRef)
+	if err != nil {
+		return fmt.Errorf("failed to list the changes since %s: %w", sinceRef, err)
+	}
+	var selected []string
+	for _, ds := range changed {
+		if slices.Contains(req.DataStreams, ds) {
+			selected = append(selected, ds)
+		}
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("no data stream changed since %s, the readme is up to date", sinceRef)
+	}
+	logInfo("Data streams changed since %s: %s", sinceRef, strings.Join(selected, ", "))
+	req.sinceDataStreams = selected
+	return nil
+}
+
+// dataStreamSectionLevel returns the heading level of the sections of the
+// data streams in the lines of a readme: that of the first section found,
+// or the level below the Reference section
+func dataStreamSectionLevel(lines []markdown.Line, dataStreams []string) int {
+	for _, ds := range dataStreams {
+		if start, _ := dataStreamSection(lines, ds); start >= 0 {
+			return lines[start].Level
+		}
+	}
+	return min(lines[markdown.FindHeading(lines, "reference")].Level+1, 6)
+}
+
+// extractDataStreamSections returns the Reference sections of the data
+// streams of content, without the blank lines around them, by data stream
+func extractDataStreamSections(content string, dataStreams []string) map[string]string {
+	lines := markdown.ParseLines(content)
+	sections := make(map[string]string)
+	for _, ds := range dataStreams {
+		if start, end := dataStreamSection(lines, ds); start >= 0 {
+			sections[ds] = strings.TrimSpace(markdown.JoinLines(lines[start:end]))
+		}
+	}
+	return sections
+}
+
+// dataStreamAnswers splits the answer of the model into the section of each
+// data stream, at the heading level of the sections in the readme. It fails
+// if a section is missing.
+func dataStreamAnswers(text string, dataStreams []string, level int) (map[string]string, error) {
+	lines := markdown.ParseLines(strings.TrimSpace(text))
+	sections := make(map[string]string)
+	for i, line := range lines {
+		if line.Level == 0 {
+			continue
+		}
+		for _, ds := range dataStreams {
+			if _, ok := sections[ds]; !ok && normalizeHeading(line.Heading) == normalizeHeading(ds) {
+				sections[ds] = strings.TrimSpace(markdown.ShiftHeadings(lines[i:markdown.SectionEnd(lines, i)], level-line.Level))
+			}
+		}
+	}
+	for _, ds := range dataStreams {
+		if _, ok := sections[ds]; !ok {
+			return nil, fmt.Errorf("the answer has no section for data stream %q", ds)
+		}
+	}
+	return sections, nil
+}
+
+// spliceDataStreamSections puts the sections of the data streams in place
+// of theirs in the Reference section of the readme, and those of data
+// streams without one at its end. The rest of the readme is left byte for
+// byte.
+func spliceDataStreamSections(readme string, sections map[string]string, dataStreams []string) string {
+	for _, ds := range dataStreams {
+		section, ok := sections[ds]
+		if !ok {
+			continue
+		}
+		lines := markdown.ParseLines(readme)
+		if start, end := dataStreamSection(lines, ds); start >= 0 {
+			readme = markdown.ReplaceLines(lines, start, end, section)
+			continue
+		}
+		ref := markdown.FindHeading(lines, "reference")
+		at := markdown.SectionEnd(lines, ref)
+		for at > ref+1 && strings.TrimSpace(lines[at-1].Text) == "" {
+			at--
+		}
+		readme = markdown.ReplaceLines(lines, at, at, "\n"+section)
+	}
+	return readme
+}
+
+// generateDataStreamSectionsStage asks the LLM for the Reference sections
+// of the data streams changed since -since only, and puts them in place of
+// theirs in the readme
+func generateDataStreamSectionsStage(ctx context.Context, s *pipelineState) error {
+	dataStreams := s.req.sinceDataStreams
+	templateSection := markdown.ExtractSection(s.template, "reference")
+	if templateSection == "" {
+		return fmt.Errorf("the template has no Reference section")
+	}
+	lines := markdown.ParseLines(s.req.Readme)
+	if markdown.FindHeading(lines, "reference") < 0 {
+		return fmt.Errorf("the readme has no Reference section, migrate the whole package first")
+	}
+	level := dataStreamSectionLevel(lines, dataStreams)
+	sensitive := isSensitive(s.req.Readme)
+	if sensitive {
+		s.backend = sensitiveBackend()
+	}
+	backend := s.llmBackend()
+	if _, err := detectCapabilities(ctx, backend); err != nil {
+		return err
+	}
+	parts := append(readmePromptParts(s.req), promptPart{name: "instructions", text: fmt.Sprintf(dataStreamSectionsPrompt, strings.Join(dataStreams, ", "), templateSection, level)})
+	if sensitive {
+		parts = append(parts, promptPart{name: "instructions", text: sensitiveFramingPrompt})
+	}
+	system := systemInstruction(s.req.Readme, s.template)
+	prompt, dropped, err := budgetPrompt(backend, system, parts)
+	if err != nil {
+		return err
+	}
+	s.dropped = droppedContextWarnings(backend, dropped)
+	text, usage, answered, err := generateFramed(ctx, prompt, sensitive, s.backend, func(b llmclient.Backend, prompt string) (string, llmclient.Usage, error) {
+		// The length of the sections is not compared with the readme
+		return generateReadmeText(ctx, b, system, prompt, "")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to regenerate the sections of %s: %w", strings.Join(dataStreams, ", "), err)
+	}
+	s.resp.Usage, s.resp.Backend = usage, answered
+	sections, err := dataStreamAnswers(text, dataStreams, level)
+	if err != nil {
+		return fmt.Errorf("failed to regenerate the sections of %s: %w", strings.Join(dataStreams, ", "), err)
+	}
+	for ds, section := range sections {
+		sections[ds] = normalizeGenerated(section, s.req.Readme, s.template)
+	}
+	s.resp.Markdown = spliceDataStreamSections(s.req.Readme, sections, dataStreams)
+	return nil
+}
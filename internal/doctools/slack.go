@@ -0,0 +1,118 @@
+package doctools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxSlackPackages bounds the packages listed by name in a Slack summary
+const maxSlackPackages = 20
+
+// slackEscaper escapes the control characters of Slack mrkdwn
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+var (
+	// slackWebhook is the Slack incoming webhook URL of -slack-webhook
+	slackWebhook string
+	// reportURL is the URL the -report is published at, from -report-url
+	reportURL string
+)
+
+// slackSummary formats the results of a batch run as a Slack message in
+// mrkdwn
+func slackSummary(dir string, report *batchReport) string {
+	counts := make(map[string]int)
+	warnings := 0
+	var failed, followUps []string
+	for _, p := range report.Packages {
+		counts[p.Status]++
+		if len(p.Warnings) > 0 {
+			warnings++
+		}
+		if p.Status == statusFailed {
+			failed = append(failed, fmt.Sprintf("• `%s`: %s", p.Name, slackEscaper.Replace(p.Error)))
+		}
+		if p.FollowUpIssue != "" {
+			followUps = append(followUps, fmt.Sprintf("• <%s|%s>", p.FollowUpIssue, p.Name))
+		} else if p.FollowUpTicket != "" {
+			followUps = append(followUps, fmt.Sprintf("• <%s|%s>", p.FollowUpTicket, p.Name))
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*Docs template migration of %s*", dir)
+	if report.incomplete() {
+		b.WriteString(" (stopped early)")
+	}
+	fmt.Fprintf(&b, "\n%d packages: %d succeeded, %d failed", len(report.Packages), counts[statusSucceeded], counts[statusFailed])
+	if n := counts[statusCancelled] + counts[statusSkipped]; n > 0 {
+		fmt.Fprintf(&b, ", %d not migrated", n)
+	}
+	fmt.Fprintf(&b, ", %d with warnings\n", warnings)
+	fmt.Fprintf(&b, "Took %s, %d tokens in, %d tokens out, about $%.2f\n",
+		report.FinishedAt.Sub(report.StartedAt).Round(time.Second), report.Usage.PromptTokens, report.Usage.ResponseTokens, report.CostUSD)
+	if reportURL != "" {
+		fmt.Fprintf(&b, "<%s|Report>\n", reportURL)
+	}
+
+	if len(failed) > 0 {
+		b.WriteString("\n*Failed*\n")
+		writeSlackList(&b, failed)
+	}
+	if len(followUps) > 0 {
+		b.WriteString("\n*Follow-up*\n")
+		writeSlackList(&b, followUps)
+	}
+	return b.String()
+}
+
+// writeSlackList writes at most maxSlackPackages lines of a list
+func writeSlackList(b *strings.Builder, lines []string) {
+	for i, line := range lines {
+		if i == maxSlackPackages {
+			fmt.Fprintf(b, "… and %d more\n", len(lines)-i)
+			break
+		}
+		b.WriteString(line + "\n")
+	}
+}
+
+// notifySlack posts the summary of a batch run to -slack-webhook
+func notifySlack(ctx context.Context, dir string, report *batchReport) error {
+	if slackWebhook == "" {
+		return nil
+	}
+	data, err := json.Marshal(map[string]string{"text": slackSummary(dir, report)})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, slackWebhook, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: 30 * time.Second, Transport: outboundTransport}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The webhook URL is a secret, keep it out of the error.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to notify Slack: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to notify Slack: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package doctools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// Readme layouts, selected with -layout
+const (
+	// layoutSingle keeps the whole documentation in the readme
+	layoutSingle = "single"
+	// layoutSplit moves the reference of every data stream to its own page
+	// next to the readme
+	layoutSplit = "split"
+)
+
+// docsLayout is the layout selected with -layout
+var docsLayout string
+
+// validateLayout rejects unknown layouts
+func validateLayout(layout string) error {
+	switch layout {
+	case layoutSingle, layoutSplit:
+		return nil
+	}
+	return fmt.Errorf("unknown layout %q, use %s or %s", layout, layoutSingle, layoutSplit)
+}
+
+// dataStreamDocPath returns the path of the page of a data stream in the
+// split layout
+func dataStreamDocPath(pkgPath, dataStream string) string {
+	return filepath.Join(filepath.Dir(targetReadmePath(pkgPath)), dataStream+".md")
+}
+
+// normalizeHeading reduces a heading to compare it with data stream names,
+// so that "Malware Bazaar" or "`malware_bazaar` data stream" match
+// malware_bazaar
+func normalizeHeading(text string) string {
+	text = strings.ToLower(strings.ReplaceAll(text, "`", ""))
+	text = strings.TrimSuffix(strings.TrimSpace(text), "data stream")
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(text)
+}
+
+// splitDataStreams moves the sections about each data stream found under the
+// Reference section of a readme to a page per data stream. The readme links
+// to the pages where the first section was. Data streams without a section
+// are left in the readme and reported.
+func splitDataStreams(content string, dataStreams []string) (string, map[string]string, []string) {
+	lines := markdown.ParseLines(content)
+	ref := -1
+	for i, line := range lines {
+		if line.Level > 0 && strings.EqualFold(line.Heading, "reference") {
+			ref = i
+			break
+		}
+	}
+	if ref < 0 {
+		return content, nil, []string{"no Reference section found, the readme was not split"}
+	}
+	refEnd := markdown.SectionEnd(lines, ref)
+
+	byName := make(map[string]string, len(dataStreams))
+	for _, ds := range dataStreams {
+		byName[normalizeHeading(ds)] = ds
+	}
+
+	// Collect the data stream sections, a heading nested in a group such as
+	// "Exported fields" keeps the group name in the data stream page.
+	pages := make(map[string]*strings.Builder)
+	removed := make([]bool, len(lines))
+	insertAt := -1
+	var parents []int
+	for i := ref + 1; i < refEnd; i++ {
+		if lines[i].Level == 0 || removed[i] {
+			continue
+		}
+		for len(parents) > 0 && lines[parents[len(parents)-1]].Level >= lines[i].Level {
+			parents = parents[:len(parents)-1]
+		}
+		if strings.EqualFold(lines[i].Heading, "data streams") {
+			// The links of an earlier split are written again below.
+			for j := i; j < min(markdown.SectionEnd(lines, i), refEnd); j++ {
+				removed[j] = true
+			}
+			continue
+		}
+		ds, ok := byName[normalizeHeading(lines[i].Heading)]
+		if !ok {
+			parents = append(parents, i)
+			continue
+		}
+
+		end := min(markdown.SectionEnd(lines, i), refEnd)
+		page, ok := pages[ds]
+		if !ok {
+			page = &strings.Builder{}
+			fmt.Fprintf(page, "# %s\n", lines[i].Heading)
+			pages[ds] = page
+		}
+		body := lines[i+1 : end]
+		if len(parents) > 0 {
+			// Nested in a group, the group becomes a section of the page.
+			fmt.Fprintf(page, "\n## %s\n", lines[parents[len(parents)-1]].Heading)
+			page.WriteString(markdown.ShiftHeadings(body, 2-lines[i].Level))
+		} else {
+			page.WriteString(markdown.ShiftHeadings(body, 1-lines[i].Level))
+		}
+		for j := i; j < end; j++ {
+			removed[j] = true
+		}
+		if insertAt < 0 {
+			insertAt = i
+		}
+	}
+
+	var warnings []string
+	for _, ds := range dataStreams {
+		if pages[ds] == nil {
+			warnings = append(warnings, fmt.Sprintf("no section found for data stream %q, it was left in the readme", ds))
+		}
+	}
+	if insertAt < 0 {
+		return content, nil, warnings
+	}
+
+	// Drop the groups left empty by the move.
+	for i := ref + 1; i < refEnd; i++ {
+		if lines[i].Level == 0 || removed[i] {
+			continue
+		}
+		empty := true
+		for j := i + 1; j < markdown.SectionEnd(lines, i); j++ {
+			if !removed[j] && strings.TrimSpace(lines[j].Text) != "" {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			for j := i; j < markdown.SectionEnd(lines, i); j++ {
+				removed[j] = true
+			}
+			insertAt = min(insertAt, i)
+		}
+	}
+
+	var links strings.Builder
+	fmt.Fprintf(&links, "%s Data streams\n\nEach data stream is documented on its own page:\n\n", strings.Repeat("#", min(lines[ref].Level+1, 6)))
+	for _, ds := range dataStreams {
+		if pages[ds] != nil {
+			fmt.Fprintf(&links, "* [%s](%s.md)\n", ds, ds)
+		}
+	}
+
+	var readme []string
+	for i, line := range lines {
+		if i == insertAt {
+			readme = append(readme, links.String())
+		}
+		if !removed[i] {
+			readme = append(readme, line.Text)
+		}
+	}
+
+	docs := make(map[string]string, len(pages))
+	for ds, page := range pages {
+		docs[ds] = strings.TrimRight(page.String(), "\n") + "\n"
+	}
+	return strings.Join(readme, "\n"), docs, warnings
+}
+
+// readDataStreamDocs returns the pages of the data streams of a split readme,
+// data streams without a page are left out
+func readDataStreamDocs(pkgPath string, dataStreams []string) (map[string]string, error) {
+	docs := make(map[string]string)
+	for _, ds := range dataStreams {
+		data, _, err := readMarkdown(dataStreamDocPath(pkgPath, ds))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs[ds] = data
+	}
+	return docs, nil
+}
+
+// joinDataStreamDocs appends the data stream pages of a split readme to it,
+// as subsections of its Reference section, so it can be validated or migrated
+// as a whole
+func joinDataStreamDocs(content string, dataStreams []string, docs map[string]string) string {
+	if len(docs) == 0 {
+		return content
+	}
+
+	lines := markdown.ParseLines(content)
+	level, end := 2, len(lines)
+	for i, line := range lines {
+		if line.Level > 0 && strings.EqualFold(line.Heading, "reference") {
+			level, end = line.Level, markdown.SectionEnd(lines, i)
+			break
+		}
+	}
+
+	var pages []string
+	for _, ds := range dataStreams {
+		if doc, ok := docs[ds]; ok {
+			pages = append(pages, markdown.ShiftHeadings(markdown.ParseLines(strings.TrimRight(doc, "\n")), level))
+		}
+	}
+	before := strings.TrimRight(markdown.ShiftHeadings(lines[:end], 0), "\n")
+	after := markdown.ShiftHeadings(lines[end:], 0)
+	joined := before + "\n\n" + strings.Join(pages, "\n\n") + "\n"
+	if after != "" {
+		joined += "\n" + after
+	}
+	return joined
+}
+
+// applyLayout lays out the migrated readme in result as selected with
+// -layout. The split layout writes a page per data stream, the single layout
+// removes the pages of a readme that was split before. original is the readme
+// as read and previous the data stream pages it had; the markdown and patch
+// of result are updated to what is written.
+func applyLayout(pkgPath, original string, dataStreams []string, previous map[string]string, result *migrateResponse) error {
+	readme := result.Markdown
+	var pages map[string]string
+	if docsLayout == layoutSplit {
+		var warnings []string
+		readme, pages, warnings = splitDataStreams(readme, dataStreams)
+		result.Warnings = append(result.Warnings, warnings...)
+	}
+
+	patch, err := generatePatch(targetReadmePath(""), original, readme)
+	if err != nil {
+		return err
+	}
+	for _, ds := range dataStreams {
+		page, split := pages[ds]
+		prev, wasSplit := previous[ds]
+		if !split && !wasSplit {
+			continue
+		}
+		pagePatch, err := generatePatch(dataStreamDocPath("", ds), prev, page)
+		if err != nil {
+			return err
+		}
+		patch += pagePatch
+
+		path := dataStreamDocPath(pkgPath, ds)
+		if split {
+			err = pkgFS.WriteFile(path, []byte(page), 0o644)
+		} else {
+			err = pkgFS.Remove(path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	result.Markdown = readme
+	result.Patch = patch
+	return nil
+}
@@ -0,0 +1,220 @@
+package doctools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// Formats of the answer of the LLM, selected with -response-format
+const (
+	// responseMarkdown asks for the migrated readme as markdown
+	responseMarkdown = "markdown"
+	// responseJSON asks for a JSON object of the content of each template
+	// section, the readme is assembled from it
+	responseJSON = "json"
+)
+
+const (
+	// titleKey is the key of the title of the readme in a JSON answer
+	titleKey = "title"
+	// sectionsAttempts is how often the LLM is asked for an answer matching
+	// the schema of the template sections before the migration fails
+	sectionsAttempts = 3
+)
+
+// sectionsJSONPrompt is added to the readme prompt with -response-format json
+const sectionsJSONPrompt = `
+
+Answer with a JSON object instead of markdown: the title of the document under "title", and the markdown content of each section of the template under its heading, without the heading itself. Subsections have their own keys, do not repeat their content in their parent section. Put additional subsections you need, such as those of data streams in Reference, in the content of the section they belong to, with headings below the level of that section.`
+
+// sectionsRetryPrompt asks the LLM again after an answer that does not match
+// the schema, with the validation errors and the answer
+const sectionsRetryPrompt = `
+
+Your previous answer does not match the JSON schema of the template sections:
+%s
+
+Previous answer:
+%s
+
+Answer again with a JSON object fixing these errors.`
+
+// responseFormat is the format of -response-format
+var responseFormat = responseMarkdown
+
+// validateResponseFormat rejects unknown response formats
+func validateResponseFormat(format string) error {
+	switch format {
+	case responseMarkdown, responseJSON, responseOperations, responseChunked:
+		return nil
+	}
+	return fmt.Errorf("unknown response format %q, use %s, %s, %s or %s", format, responseMarkdown, responseJSON, responseOperations, responseChunked)
+}
+
+// sectionsSchema returns the schema of the JSON answer for a template: the
+// title and the content of every template section, all required
+func sectionsSchema(template string) *genai.Schema {
+	schema := &genai.Schema{
+		Type:       genai.TypeObject,
+		Properties: map[string]*genai.Schema{titleKey: {Type: genai.TypeString, Description: "Title of the document, without the leading #"}},
+		Required:   []string{titleKey},
+	}
+	for _, h := range templateHeadings(template) {
+		if _, ok := schema.Properties[h.Text]; ok {
+			continue
+		}
+		schema.Properties[h.Text] = &genai.Schema{Type: genai.TypeString, Description: fmt.Sprintf("Markdown content of the %q section, without its heading", h.Text)}
+		schema.Required = append(schema.Required, h.Text)
+	}
+	return schema
+}
+
+// generateSectionsReadme asks the LLM for the content of each section of the
+// template as JSON, in the structured output mode of the model, and
+// assembles the readme from it
+func generateSectionsReadme(ctx context.Context, b llmclient.Backend, readmeContent, templateContent, userPrompt string) (string, llmclient.Usage, error) {
+	system := systemInstruction(readmeContent, templateContent)
+	prompt := userPrompt + sectionsJSONPrompt
+	schema := sectionsSchema(templateContent)
+	var usage llmclient.Usage
+	retryPrompt := ""
+	for attempt := 1; ; attempt++ {
+		text, u, err := generateContent(ctx, b, system, prompt+retryPrompt, retryConfiguration(b, attempt, func(m *genai.GenerativeModel) {
+			m.ResponseMIMEType = "application/json"
+			m.ResponseSchema = schema
+		}))
+		usage.PromptTokens += u.PromptTokens
+		usage.ResponseTokens += u.ResponseTokens
+		var de degenerateError
+		var sections map[string]string
+		var errs []string
+		switch {
+		case errors.As(err, &de):
+			// A truncated answer does not parse, it is asked for again
+			// like one that does not match
+			errs = []string{"the answer was " + de.reason}
+		case err != nil:
+			return "", usage, err
+		default:
+			sections, errs = validateSections(text, schema)
+		}
+		var readme string
+		if len(errs) == 0 {
+			readme = assembleSections(templateContent, sections)
+			if reason := degenerateReason(readme, readmeContent); reason != "" {
+				errs = append(errs, "the README assembled from the sections is "+reason)
+			}
+		}
+		if len(errs) == 0 {
+			return normalizeGenerated(readme, readmeContent, templateContent), usage, nil
+		}
+		if attempt == sectionsAttempts {
+			return "", usage, fmt.Errorf("answer of the model does not match the schema of the template sections after %d attempts: %s", attempt, strings.Join(errs, "; "))
+		}
+		log.Printf("Answer of the model does not match the schema of the template sections (attempt %d of %d), asking again: %s", attempt, sectionsAttempts, strings.Join(errs, "; "))
+		retryPrompt = fmt.Sprintf(sectionsRetryPrompt, "- "+strings.Join(errs, "\n- "), text)
+	}
+}
+
+// validateSections checks a JSON answer against the schema of the template
+// sections and returns the content of its sections by heading, or the
+// validation errors: an answer that is not an object, missing or empty
+// required properties, properties that are not in the schema and values
+// that are not strings
+func validateSections(text string, schema *genai.Schema) (map[string]string, []string) {
+	var answer map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &answer); err != nil {
+		return nil, []string{fmt.Sprintf("the answer is not a JSON object: %v", err)}
+	}
+	var errs []string
+	for _, key := range schema.Required {
+		if _, ok := answer[key]; !ok {
+			errs = append(errs, fmt.Sprintf("required property %q is missing", key))
+		}
+	}
+	sections := make(map[string]string)
+	for _, key := range slices.Sorted(maps.Keys(answer)) {
+		property, ok := schema.Properties[key]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("property %q is not a section of the template", key))
+			continue
+		}
+		value, ok := answer[key].(string)
+		if property.Type == genai.TypeString && !ok {
+			errs = append(errs, fmt.Sprintf("property %q must be a string, not %s", key, jsonType(answer[key])))
+			continue
+		}
+		sections[key] = value
+	}
+	if title, ok := answer[titleKey].(string); ok && strings.TrimSpace(strings.TrimLeft(title, "# ")) == "" {
+		errs = append(errs, fmt.Sprintf("property %q must not be empty", titleKey))
+	}
+	return sections, errs
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+	return "a string"
+}
+
+// assembleSections writes the readme of a JSON answer: the title, then the
+// headings of the template in order, each followed by its content. Headings
+// in the content at or above the level of their section are moved below
+// it, so the structure is always the one of the template.
+func assembleSections(template string, sections map[string]string) string {
+	var b strings.Builder
+	if title := strings.TrimSpace(strings.TrimLeft(sections[titleKey], "# ")); title != "" {
+		fmt.Fprintf(&b, "# %s\n\n", title)
+	}
+	known := map[string]bool{titleKey: true}
+	for _, h := range templateHeadings(template) {
+		if known[h.Text] {
+			continue
+		}
+		known[h.Text] = true
+		fmt.Fprintf(&b, "%s %s\n\n", strings.Repeat("#", h.Level), h.Text)
+		if content := sectionContent(sections[h.Text], h); content != "" {
+			b.WriteString(content + "\n\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// sectionContent returns the content of a section of a JSON answer without
+// a repeated heading and with its headings below the level of the section
+func sectionContent(content string, h markdown.Heading) string {
+	lines := markdown.ParseLines(strings.TrimSpace(content))
+	if len(lines) > 0 && lines[0].Level > 0 && strings.EqualFold(lines[0].Heading, h.Text) {
+		lines = lines[1:]
+	}
+	shift := 0
+	for _, l := range lines {
+		if l.Level > 0 && l.Level <= h.Level {
+			shift = max(shift, h.Level+1-l.Level)
+		}
+	}
+	return strings.TrimSpace(markdown.ShiftHeadings(lines, shift))
+}
@@ -0,0 +1,345 @@
+package doctools
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+const (
+	// defaultEmbeddingModel is the Gemini model the style index is built
+	// and queried with
+	defaultEmbeddingModel = "text-embedding-004"
+	// maxStyleSection bounds the bytes of a section kept in the index
+	maxStyleSection = 3000
+	// maxStyleQuery bounds the bytes of the readme its examples are looked
+	// up with
+	maxStyleQuery = 4000
+	// embedBatchSize is the most texts Gemini embeds in a single request
+	embedBatchSize = 100
+)
+
+// styleExamplesPrompt is added to the readme prompt with the sections of
+// other migrated readmes closest to the readme
+const styleExamplesPrompt = `
+
+These sections come from already migrated READMEs of other packages. Match their tone, voice and level of detail, but do not copy their content:
+%s`
+
+var (
+	// styleIndexPath is the index of -style-index
+	styleIndexPath string
+	// styleExampleCount is the number of sections of each kind of
+	// -style-examples
+	styleExampleCount int
+	// embeddingModel is the model of -embedding-model
+	embeddingModel string
+
+	styleIndexMu sync.Mutex
+	styleIndex   *styleIndexFile
+
+	// styleSectionPatterns match the headings of the sections the index
+	// holds, by kind
+	styleSectionPatterns = []struct {
+		kind    string
+		pattern *regexp.Regexp
+	}{
+		{"overview", regexp.MustCompile(`(?i)overview`)},
+		{"setup", regexp.MustCompile(`(?i)set ?up|deploy|install|configur`)},
+	}
+)
+
+// styleIndexFile is the style index, the overview and setup sections of
+// migrated readmes with their embeddings
+type styleIndexFile struct {
+	// Model is the embedding model of the sections, only queries with the
+	// same model compare.
+	Model    string         `json:"model"`
+	Sections []styleSection `json:"sections"`
+}
+
+// styleSection is a section of a migrated readme in the style index
+type styleSection struct {
+	Package   string    `json:"package"`
+	Kind      string    `json:"kind"`
+	Heading   string    `json:"heading"`
+	Text      string    `json:"text"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// styleExample is a section of the readme of another package given to the
+// LLM as an example of the style of the catalog
+type styleExample struct {
+	Package string `json:"package"`
+	Kind    string `json:"kind"`
+	Text    string `json:"text"`
+}
+
+// registerStyleIndexFlags adds the style index flags to fs
+func registerStyleIndexFlags(fs *flag.FlagSet) {
+	fs.StringVar(&styleIndexPath, "style-index", "", "Style index built with the index command, to give the LLM the overview and setup sections of the migrated readmes closest to the readme as examples of style")
+	fs.IntVar(&styleExampleCount, "style-examples", 2, "With -style-index, number of overview and of setup sections given as examples")
+	fs.StringVar(&embeddingModel, "embedding-model", defaultEmbeddingModel, "Gemini model of the embeddings of the style index")
+}
+
+// runIndex builds the style index of the migrated readmes of a packages
+// directory
+func runIndex(args []string) {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	dir := fs.String("packages", "", "Directory of the packages whose migrated readmes are indexed (required)")
+	output := fs.String("o", "style-index.json", "File the style index is written to")
+	fs.StringVar(&googleAPIKey, "api-key", "", "Google Gemini API key")
+	fs.StringVar(&apiKeyRef, "api-key-ref", "", secretRefHelp)
+	fs.StringVar(&embeddingModel, "embedding-model", defaultEmbeddingModel, "Gemini model of the embeddings of the style index")
+	registerVerbosityFlags(fs)
+	registerProviderFlags(fs)
+	registerNetworkFlags(fs)
+	registerTimeoutFlags(fs)
+	registerConfigFlags(fs)
+	_ = fs.Parse(args)
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "index needs -packages")
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadProvider(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	requireAPIKey()
+
+	index, err := buildStyleIndex(context.Background(), *dir)
+	if err != nil {
+		log.Fatalf("Error building style index: %v", err)
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := writeFileAtomic(*output, append(data, '\n'), 0o644); err != nil {
+		log.Fatalf("Error writing style index: %v", err)
+	}
+	logInfo("Indexed %d sections in %s", len(index.Sections), *output)
+}
+
+// buildStyleIndex embeds the overview and setup sections of the migrated
+// readmes of the packages of dir. Packages that are not migrated yet are
+// left out.
+func buildStyleIndex(ctx context.Context, dir string) (*styleIndexFile, error) {
+	pkgs, err := findPackages(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages: %w", err)
+	}
+	index := &styleIndexFile{Model: styleEmbeddingModel()}
+	for _, pkgPath := range pkgs {
+		content, _, err := readMarkdown(targetReadmePath(pkgPath))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range styleSections(content) {
+			s.Package = filepath.Base(pkgPath)
+			if s.Embedding, err = embedText(ctx, s.Text); err != nil {
+				return nil, fmt.Errorf("failed to embed %s of %s: %w", s.Heading, s.Package, err)
+			}
+			index.Sections = append(index.Sections, s)
+		}
+		if verbose {
+			log.Printf("Indexed %s", pkgPath)
+		}
+	}
+	return index, nil
+}
+
+// styleSections returns the first section of a readme of each kind, with
+// its subsections and without comments
+func styleSections(content string) []styleSection {
+	lines := markdown.ParseLines(content)
+	var sections []styleSection
+	for i, line := range lines {
+		if line.Level != 2 {
+			continue
+		}
+		for _, p := range styleSectionPatterns {
+			if !p.pattern.MatchString(line.Heading) || slices.ContainsFunc(sections, func(s styleSection) bool { return s.Kind == p.kind }) {
+				continue
+			}
+			var texts []string
+			for _, l := range lines[i:markdown.SectionEnd(lines, i)] {
+				texts = append(texts, l.Text)
+			}
+			text := strings.TrimSpace(markdown.CommentPattern.ReplaceAllString(strings.Join(texts, "\n"), ""))
+			if len(text) > maxStyleSection {
+				text = strings.ToValidUTF8(text[:maxStyleSection], "")
+			}
+			if sectionHasProse(lines, i) {
+				sections = append(sections, styleSection{Kind: p.kind, Heading: line.Heading, Text: text})
+			}
+			break
+		}
+	}
+	return sections
+}
+
+// styleEmbeddingModel returns the embedding model in use
+func styleEmbeddingModel() string {
+	if llmProvider == llmclient.ProviderFake {
+		return llmclient.HashEmbeddingModel
+	}
+	return embeddingModel
+}
+
+// embedText returns the embedding of text with the embedding model in use
+func embedText(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := embedTexts(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// embedTexts is embedText for many texts, sent to the model in batches of
+// up to embedBatchSize
+func embedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	client, err := newLLMClient(ctx, currentBackend())
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	embeddings := make([][]float32, 0, len(texts))
+	for batch := range slices.Chunk(texts, embedBatchSize) {
+		callCtx, cancel := withTimeout(ctx, llmTimeout, "llm-timeout")
+		e, err := client.Embed(callCtx, embeddingModel, batch)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, e...)
+	}
+	return embeddings, nil
+}
+
+// cosineSimilarity returns the cosine similarity of two embeddings, zero if
+// their sizes differ
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// loadStyleIndex reads the index of -style-index, it is cached after the
+// first successful read
+func loadStyleIndex() (*styleIndexFile, error) {
+	styleIndexMu.Lock()
+	defer styleIndexMu.Unlock()
+	if styleIndex != nil {
+		return styleIndex, nil
+	}
+	data, err := os.ReadFile(styleIndexPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read style index: %w", err)
+	}
+	var index styleIndexFile
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse style index: %w", err)
+	}
+	if model := styleEmbeddingModel(); index.Model != model {
+		return nil, fmt.Errorf("style index %s was built with %s, not %s", styleIndexPath, index.Model, model)
+	}
+	styleIndex = &index
+	return styleIndex, nil
+}
+
+// similarStyleSections returns the sections of the style index closest to a
+// readme, -style-examples of each kind, leaving out those of the package
+// itself. None without -style-index.
+func similarStyleSections(ctx context.Context, readme, pkg string) ([]styleExample, error) {
+	if styleIndexPath == "" || styleExampleCount <= 0 {
+		return nil, nil
+	}
+	index, err := loadStyleIndex()
+	if err != nil {
+		return nil, err
+	}
+	if len(readme) > maxStyleQuery {
+		readme = strings.ToValidUTF8(readme[:maxStyleQuery], "")
+	}
+	query, err := embedText(ctx, readme)
+	if err != nil {
+		return nil, err
+	}
+
+	type scored struct {
+		section styleSection
+		score   float64
+	}
+	var candidates []scored
+	for _, s := range index.Sections {
+		if s.Package != pkg {
+			candidates = append(candidates, scored{s, cosineSimilarity(query, s.Embedding)})
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b scored) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		}
+		return 0
+	})
+
+	examples := []styleExample{}
+	counts := make(map[string]int)
+	for _, c := range candidates {
+		if counts[c.section.Kind] < styleExampleCount {
+			counts[c.section.Kind]++
+			examples = append(examples, styleExample{Package: c.section.Package, Kind: c.section.Kind, Text: c.section.Text})
+		}
+	}
+	if verbose {
+		log.Printf("Using %d sections of the style index as style examples", len(examples))
+	}
+	return examples, nil
+}
+
+// formatStyleExamples formats the style examples for the prompt
+func formatStyleExamples(examples []styleExample) string {
+	var b strings.Builder
+	for _, e := range examples {
+		fmt.Fprintf(&b, "\n--- %s section of the %s package ---\n%s\n", e.Kind, e.Package, e.Text)
+	}
+	return b.String()
+}
@@ -0,0 +1,258 @@
+package doctools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+)
+
+// defaultSweepTemperatures are the temperatures the sweep subcommand tries
+// without -temperatures
+const defaultSweepTemperatures = "0,0.5,1,1.5"
+
+// temperature is the temperature of -temperature, negative for the default
+// of the model
+var temperature = -1.0
+
+// readmeSizes are the sizes of readmes the sweep subcommand summarizes by,
+// from the smallest, each up to its number of words
+var readmeSizes = []struct {
+	Name  string
+	Words int
+}{
+	{"small", 500},
+	{"medium", 2000},
+	{"large", 0},
+}
+
+// sweepResult is the result of a package at a temperature
+type sweepResult struct {
+	Package     string  `json:"package"`
+	Words       int     `json:"words"`
+	Size        string  `json:"size"`
+	Temperature float64 `json:"temperature"`
+	// Score is the score of -candidates, with -candidate-judge if given.
+	Score     float64 `json:"score"`
+	Retention float64 `json:"retention"`
+	Findings  int     `json:"findings"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// sweepSummary is the mean score of the packages of a size at a temperature
+type sweepSummary struct {
+	Size        string  `json:"size"`
+	Temperature float64 `json:"temperature"`
+	Packages    int     `json:"packages"`
+	Failed      int     `json:"failed"`
+	Score       float64 `json:"score"`
+	// Best marks the temperature with the highest mean score of the size.
+	Best bool `json:"best,omitempty"`
+}
+
+// sweepReport is the JSON report of the sweep subcommand
+type sweepReport struct {
+	StartedAt    time.Time      `json:"started_at"`
+	Model        string         `json:"model"`
+	Temperatures []float64      `json:"temperatures"`
+	Summary      []sweepSummary `json:"summary"`
+	Results      []sweepResult  `json:"results"`
+}
+
+// runSweep implements the sweep subcommand
+func runSweep(args []string) {
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	dir := fs.String("packages", "", "Directory of the packages to migrate at every temperature (required)")
+	temperaturesFlag := fs.String("temperatures", defaultSweepTemperatures, "Comma separated temperatures to try")
+	reportFile := fs.String("report", "", "Write the results of every package to this JSON file")
+	fs.StringVar(&googleAPIKey, "api-key", "", "Google Gemini API key")
+	fs.StringVar(&apiKeyRef, "api-key-ref", "", secretRefHelp)
+	registerVerbosityFlags(fs)
+	registerProviderFlags(fs)
+	registerNetworkFlags(fs)
+	registerTimeoutFlags(fs)
+	registerGlossaryFlags(fs)
+	registerConfigFlags(fs)
+	_ = fs.Parse(args)
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *dir == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	temperatures, err := parseTemperatures(*temperaturesFlag)
+	if err != nil {
+		log.Fatalf("Error: invalid -temperatures: %v", err)
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateTimeouts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadGlossary(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadProvider(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	requireAPIKey()
+
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+
+	pkgs, err := findPackages(*dir)
+	if err != nil {
+		log.Fatalf("Error listing packages: %v", err)
+	}
+	report := &sweepReport{StartedAt: time.Now().UTC(), Temperatures: temperatures}
+	report.Model = llmclient.Backend{Provider: llmProvider, Model: modelName}.String()
+	for _, pkgPath := range pkgs {
+		for _, t := range temperatures {
+			if ctx.Err() != nil {
+				break
+			}
+			if verbose {
+				log.Printf("Migrating %s at temperature %g", pkgPath, t)
+			}
+			temperature = t
+			report.Results = append(report.Results, sweepPackage(ctx, pkgPath, t))
+		}
+	}
+	report.Summary = summarizeSweep(report.Results, temperatures)
+
+	printSweep(report)
+	if *reportFile != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = writeFileAtomic(*reportFile, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			log.Printf("Failed to write report: %v", err)
+		}
+	}
+	if ctx.Err() != nil {
+		os.Exit(1)
+	}
+}
+
+// parseTemperatures parses the comma separated temperatures of
+// -temperatures
+func parseTemperatures(value string) ([]float64, error) {
+	var temperatures []float64
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		t, err := strconv.ParseFloat(s, 64)
+		if err != nil || t < 0 || t > 2 {
+			return nil, fmt.Errorf("temperature %q is not a number from 0 to 2", s)
+		}
+		temperatures = append(temperatures, t)
+	}
+	if len(temperatures) == 0 {
+		return nil, errors.New("no temperatures given")
+	}
+	return temperatures, nil
+}
+
+// readmeSize names the size of a readme of the given number of words
+func readmeSize(words int) string {
+	for _, s := range readmeSizes {
+		if s.Words == 0 || words <= s.Words {
+			return s.Name
+		}
+	}
+	return readmeSizes[len(readmeSizes)-1].Name
+}
+
+// sweepPackage migrates a package at a temperature, without writing it, and
+// scores the migrated readme like the -candidates
+func sweepPackage(ctx context.Context, pkgPath string, t float64) sweepResult {
+	r := sweepResult{Package: packageName(pkgPath), Temperature: t}
+	s := &pipelineState{pkgPath: pkgPath}
+	if err := prepareStage(ctx, s); err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Words = len(wordPattern.FindAllString(s.req.Readme, -1))
+	r.Size = readmeSize(r.Words)
+	result, err := migrateContent(ctx, s.req, nil)
+	if err != nil {
+		r.Error = redactSecrets(err.Error())
+		log.Printf("Error processing %s at temperature %g: %v", pkgPath, t, err)
+		return r
+	}
+	score, _ := scoreCandidate(ctx, s.req, result)
+	r.Score, r.Retention, r.Findings = score.Score, score.Retention, score.Findings
+	return r
+}
+
+// summarizeSweep averages the scores of the packages of each size at each
+// temperature and marks the best temperature of each size. Failed packages
+// are left out of the mean.
+func summarizeSweep(results []sweepResult, temperatures []float64) []sweepSummary {
+	var summary []sweepSummary
+	for _, size := range readmeSizes {
+		best := -1
+		for _, t := range temperatures {
+			s := sweepSummary{Size: size.Name, Temperature: t}
+			for _, r := range results {
+				if r.Size != size.Name || r.Temperature != t {
+					continue
+				}
+				s.Packages++
+				if r.Error != "" {
+					s.Failed++
+					continue
+				}
+				s.Score += r.Score
+			}
+			if s.Packages == 0 {
+				continue
+			}
+			if ok := s.Packages - s.Failed; ok > 0 {
+				s.Score /= float64(ok)
+				if best < 0 || s.Score > summary[best].Score {
+					best = len(summary)
+				}
+			}
+			summary = append(summary, s)
+		}
+		if best >= 0 {
+			summary[best].Best = true
+		}
+	}
+	return summary
+}
+
+// printSweep prints the mean score of every readme size at every
+// temperature, the best marked
+func printSweep(report *sweepReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintf(w, "Model: %s\n\n", report.Model)
+	fmt.Fprintln(w, "SIZE\tTEMPERATURE\tPACKAGES\tFAILED\tSCORE\tBEST")
+	for _, s := range report.Summary {
+		best := ""
+		if s.Best {
+			best = "*"
+		}
+		fmt.Fprintf(w, "%s\t%g\t%d\t%d\t%.1f\t%s\n", s.Size, s.Temperature, s.Packages, s.Failed, s.Score, best)
+	}
+}
@@ -0,0 +1,161 @@
+package doctools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	// templateAttempts is how often the template download is tried before
+	// giving up
+	templateAttempts = 4
+	// templateAttemptTimeout bounds a single download attempt, so a stalled
+	// connection is retried instead of using up -template-timeout
+	templateAttemptTimeout = 30 * time.Second
+	// templateMaxBackoff caps the wait between attempts, including waits
+	// asked for by Retry-After
+	templateMaxBackoff = time.Minute
+)
+
+var (
+	templateMu     sync.Mutex
+	cachedTemplate string
+
+	templateClientOnce sync.Once
+	templateClient     *http.Client
+)
+
+// templateStatusError is returned for a template download answered with an
+// unexpected status
+type templateStatusError struct {
+	status     string
+	code       int
+	retryAfter time.Duration
+}
+
+func (e *templateStatusError) Error() string {
+	return "unexpected status " + e.status
+}
+
+// retryable reports whether the request may succeed when tried again
+func (e *templateStatusError) retryable() bool {
+	return e.code >= 500 || e.code == http.StatusTooManyRequests
+}
+
+// fetchTemplate downloads the readme template. The result is cached so long
+// running modes such as watch only download it once. Network errors, server
+// errors and rate limiting are retried with backoff, honoring Retry-After,
+// until -template-timeout expires.
+func fetchTemplate(ctx context.Context) (string, error) {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+
+	if cachedTemplate != "" {
+		return cachedTemplate, nil
+	}
+
+	ctx, cancel := withTimeout(ctx, templateTimeout, "template-timeout")
+	defer cancel()
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		template, err := downloadTemplate(ctx)
+		if err == nil {
+			cachedTemplate = template
+			return cachedTemplate, nil
+		}
+		if ctx.Err() != nil {
+			return "", timeoutError(ctx, err)
+		}
+
+		var statusErr *templateStatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			return "", err
+		}
+		if attempt == templateAttempts {
+			return "", fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		// Jitter spreads the retries of parallel workers.
+		wait := backoff + rand.N(backoff/2)
+		if statusErr != nil && statusErr.retryAfter > 0 {
+			wait = statusErr.retryAfter
+		}
+		wait = min(wait, templateMaxBackoff)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return "", fmt.Errorf("not retrying, -template-timeout expires before the retry in %s: %w", wait, err)
+		}
+		log.Printf("Failed to download template (attempt %d of %d), retrying in %s: %v", attempt, templateAttempts, wait.Round(time.Millisecond), err)
+
+		select {
+		case <-ctx.Done():
+			return "", timeoutError(ctx, err)
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// downloadTemplate makes a single attempt at downloading the template
+func downloadTemplate(ctx context.Context) (string, error) {
+	templateClientOnce.Do(func() {
+		// Built on first use, configureNetwork runs after the flags are
+		// parsed.
+		templateClient = &http.Client{
+			Timeout:   templateAttemptTimeout,
+			Transport: otelhttp.NewTransport(outboundTransport),
+		}
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, templateURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := templateClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Drain a little of the body so the connection can be reused.
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return "", &templateStatusError{
+			status:     resp.Status,
+			code:       resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// parseRetryAfter returns the wait asked for by a Retry-After header, given
+// in seconds or as an HTTP date. It returns zero if the header is missing or
+// invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
@@ -0,0 +1,126 @@
+package doctools
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// fixTerms applies the replacements of the terminology rules to migrated
+// readmes, selected with -fix-terms
+var fixTerms bool
+
+// termRule is a terminology rule of the configuration: prose matching
+// Pattern is reported, and replaced with Replacement by -fix-terms
+type termRule struct {
+	// Name optionally identifies the rule.
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+	// Replacement may refer to submatches of the pattern as $1 or ${name}.
+	Replacement string `yaml:"replacement"`
+	// Severity is warning (the default) or error, see -fail-on.
+	Severity string `yaml:"severity"`
+	Message  string `yaml:"message"`
+
+	re *regexp.Regexp
+}
+
+// compile validates a rule and compiles its pattern
+func (r *termRule) compile() error {
+	if r.Pattern == "" {
+		return errors.New("no pattern")
+	}
+	switch r.Severity {
+	case "":
+		r.Severity = severityWarning
+	case severityWarning, severityError:
+	default:
+		return fmt.Errorf("unknown severity %q, use %s or %s", r.Severity, severityWarning, severityError)
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return err
+	}
+	r.re = re
+	return nil
+}
+
+// termViolation is prose matching a terminology rule
+type termViolation struct {
+	rule  *termRule
+	start int
+	end   int
+	text  string
+	line  int
+}
+
+// String formats the violation as a finding
+func (v termViolation) String() string {
+	msg := fmt.Sprintf("%s: line %d: %q", v.rule.Severity, v.line, v.text)
+	if v.rule.Message != "" {
+		msg += " " + v.rule.Message
+	} else {
+		msg += " is a banned term"
+	}
+	if v.rule.Replacement != "" {
+		msg += fmt.Sprintf(", use %q", v.rule.re.ReplaceAllString(v.text, v.rule.Replacement))
+	}
+	return msg
+}
+
+// findTermViolations returns the prose of content matching the rules, code,
+// placeholders, comments and URLs are not checked
+func findTermViolations(content string, rules []termRule) []termViolation {
+	prose := proseOnly(content)
+	var violations []termViolation
+	for i := range rules {
+		for _, loc := range rules[i].re.FindAllStringIndex(prose, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			violations = append(violations, termViolation{
+				rule:  &rules[i],
+				start: loc[0],
+				end:   loc[1],
+				text:  content[loc[0]:loc[1]],
+				line:  lineAt(prose, loc[0]),
+			})
+		}
+	}
+	return violations
+}
+
+// termFindings reports the terminology violations of content
+func termFindings(content string, rules []termRule) []string {
+	var findings []string
+	for _, v := range findTermViolations(content, rules) {
+		findings = append(findings, v.String())
+	}
+	return findings
+}
+
+// applyTermFixes replaces the prose matching rules that have a replacement.
+// Rules are applied in order, each on the result of the previous ones.
+func applyTermFixes(content string, rules []termRule) string {
+	for i := range rules {
+		r := &rules[i]
+		if r.Replacement == "" {
+			continue
+		}
+		prose := proseOnly(content)
+		var b strings.Builder
+		last := 0
+		for _, m := range r.re.FindAllStringSubmatchIndex(prose, -1) {
+			if m[0] == m[1] {
+				continue
+			}
+			b.WriteString(content[last:m[0]])
+			b.Write(r.re.ExpandString(nil, r.Replacement, content, m))
+			last = m[1]
+		}
+		b.WriteString(content[last:])
+		content = b.String()
+	}
+	return content
+}
@@ -0,0 +1,54 @@
+package doctools
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// Timeouts of the migration steps, registered by registerTimeoutFlags. Zero
+// disables a timeout.
+var (
+	templateTimeout = 2 * time.Minute
+	llmTimeout      = 10 * time.Minute
+	packageTimeout  = 15 * time.Minute
+)
+
+// registerTimeoutFlags adds the timeout flags to fs
+func registerTimeoutFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&templateTimeout, "template-timeout", templateTimeout, "Timeout for downloading the readme template, including retries (0 means no timeout)")
+	fs.DurationVar(&llmTimeout, "llm-timeout", llmTimeout, "Timeout for a single LLM call (0 means no timeout)")
+	fs.DurationVar(&packageTimeout, "package-timeout", packageTimeout, "Timeout for migrating a whole package, including the template download and LLM calls (0 means no timeout)")
+}
+
+// validateTimeouts rejects negative timeouts
+func validateTimeouts() error {
+	if templateTimeout < 0 || llmTimeout < 0 || packageTimeout < 0 {
+		return errors.New("timeouts must not be negative")
+	}
+	return nil
+}
+
+// withTimeout is context.WithTimeout for the timeout set by flagName. When
+// the timeout expires the context's cause names the flag, see timeoutError.
+// A zero timeout only adds cancellation.
+func withTimeout(ctx context.Context, timeout time.Duration, flagName string) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w: took longer than -%s %s", context.DeadlineExceeded, flagName, timeout))
+}
+
+// timeoutError replaces err with the cause of ctx if ctx timed out, so the
+// error tells which timeout to raise
+func timeoutError(ctx context.Context, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	if cause := context.Cause(ctx); cause != nil {
+		return cause
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package doctools
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// Kinds of TODOs
+const (
+	// todoGuidance is guidance on what to write in a section the LLM had no
+	// content for
+	todoGuidance = "guidance"
+	// todoRemoval is a note on content the LLM kept but flagged for removal
+	todoRemoval = "removal"
+)
+
+// removalPattern matches the notes the LLM adds to content it flags for
+// removal
+var removalPattern = regexp.MustCompile(`(?i)\b(?:remov(?:e|ed|al)|delet(?:e|ed)|not relevant|obsolete|outdated)\b`)
+
+// todo is a comment the LLM added to a migrated readme for follow-up work
+type todo struct {
+	Section string `json:"section,omitempty"`
+	Kind    string `json:"kind"`
+	Line    int    `json:"line"`
+	Text    string `json:"text"`
+}
+
+// commentText returns the text of an HTML comment on one line
+func commentText(comment string) string {
+	return strings.Join(strings.Fields(strings.TrimSuffix(strings.TrimPrefix(comment, "<!--"), "-->")), " ")
+}
+
+// extractTodos returns the comments of a migrated readme that are neither in
+// the original readme nor in the template, nor added by this tool: the
+// guidance and removal notes the prompt asks the LLM for
+func extractTodos(content, original, template string) []todo {
+	known := make(map[string]bool)
+	for _, c := range markdown.CommentPattern.FindAllString(original+"\n"+template+"\n"+setupNote, -1) {
+		known[commentText(c)] = true
+	}
+	fences := markdown.CodeFencePattern.FindAllStringIndex(content, -1)
+	inFence := func(offset int) bool {
+		for _, f := range fences {
+			if offset >= f[0] && offset < f[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var todos []todo
+	for _, loc := range markdown.CommentPattern.FindAllStringIndex(content, -1) {
+		text := commentText(content[loc[0]:loc[1]])
+		if text == "" || known[text] || inFence(loc[0]) {
+			continue
+		}
+		t := todo{Kind: todoGuidance, Line: lineAt(content, loc[0]), Text: text}
+		for _, line := range markdown.ParseLines(content[:loc[0]]) {
+			if line.Level > 0 {
+				t.Section = line.Heading
+			}
+		}
+		if removalPattern.MatchString(text) {
+			t.Kind = todoRemoval
+		}
+		todos = append(todos, t)
+	}
+	return todos
+}
@@ -0,0 +1,64 @@
+package doctools
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the pipeline. It is a no-op until tracing is
+// set up.
+var tracer = otel.Tracer("github.com/kgeller/go-examples/docs-template-update")
+
+// SetupTracing exports the spans of the tool service over OTLP/HTTP when an
+// OTLP endpoint is configured with the standard OTEL_EXPORTER_OTLP_*
+// environment variables. The returned function flushes pending spans and
+// must be called before exiting.
+func SetupTracing(service string) func() {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func() {}
+	}
+
+	ctx := context.Background()
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		log.Printf("Error setting up tracing, continuing without it: %v", err)
+		return func() {}
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence.
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName(service)),
+		resource.Environment(),
+	)
+	if err != nil {
+		log.Printf("Error reading tracing resource attributes: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return func() {
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Printf("Error flushing traces: %v", err)
+		}
+	}
+}
+
+// failSpan marks span as failed. The error is redacted as it may carry the
+// API key.
+func failSpan(span trace.Span, err error) {
+	msg := redactSecrets(err.Error())
+	span.RecordError(errors.New(msg))
+	span.SetStatus(codes.Error, msg)
+}
@@ -0,0 +1,131 @@
+package doctools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// translateLanguages are the languages selected with -translate
+var translateLanguages []string
+
+// translatePrompt asks for the translation of a document whose code and
+// placeholders were replaced with keep tokens by protectSpans
+const translatePrompt = `Translate the following Markdown document from English to the language with the BCP 47 code %q.
+
+Follow these exact guidelines:
+1. Keep the Markdown structure: the same headings, lists, tables and links, in the same order
+2. Keep every token of the form @@KEEP_<number>@@ exactly as it is and where it is, they stand for code and placeholders that must not be translated
+3. Do not translate product names, field names, data stream names and URLs
+4. Keep the keys of the YAML frontmatter, if any, and only translate their values
+5. Translate the text of HTML comments, keeping the comment markers
+
+Return ONLY the translated Markdown, without any explanation or commentary.
+
+%s`
+
+// protectedSource is the regular expression of protectedPattern without the
+// placeholders of -config
+const protectedSource = "(?ms)^```.*?^```|`[^`\n]+`|\\{\\{.*?\\}\\}|^:{3,}.*?$"
+
+var (
+	languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+	// protectedPattern matches the spans a translation must not touch:
+	// fenced code blocks, inline code, mustache placeholders and directive
+	// fences, and the placeholders of -config
+	protectedPattern = regexp.MustCompile(protectedSource)
+)
+
+// parseLanguages parses the comma separated language codes of -translate
+func parseLanguages(value string) ([]string, error) {
+	var langs []string
+	seen := make(map[string]bool)
+	for _, lang := range strings.Split(value, ",") {
+		lang = strings.TrimSpace(lang)
+		if lang == "" || seen[lang] {
+			continue
+		}
+		if !languagePattern.MatchString(lang) {
+			return nil, fmt.Errorf("invalid language code %q, use codes such as ja, fr or pt-BR", lang)
+		}
+		if lang == "en" {
+			return nil, errors.New("the readme is written in English, en cannot be a translation")
+		}
+		seen[lang] = true
+		langs = append(langs, lang)
+	}
+	return langs, nil
+}
+
+// translatedPath returns the path of the lang variant of a document, e.g.
+// readme.ja.md for readme.md
+func translatedPath(path, lang string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + lang + ext
+}
+
+// translateMarkdown translates a document to lang, leaving its code blocks
+// and placeholders untouched. The warnings report structural differences to
+// the source.
+func translateMarkdown(ctx context.Context, content, lang string) (string, []string, llmclient.Usage, error) {
+	ctx, span := tracer.Start(ctx, "translate", trace.WithAttributes(attribute.String("language", lang)))
+	defer span.End()
+
+	masked, spans := markdown.Protect(content, protectedPattern)
+	translated, usage, err := generateText(ctx, currentBackend(), fmt.Sprintf(translatePrompt, lang, masked))
+	if err != nil {
+		failSpan(span, err)
+		return "", nil, usage, err
+	}
+	// Only normalized to NFC, other languages use typographic quotes
+	translated, err = markdown.Restore(normalizeText(translated), spans)
+	if err != nil {
+		failSpan(span, err)
+		return "", nil, usage, fmt.Errorf("translation to %s did not keep the code and placeholders: %w", lang, err)
+	}
+
+	return translated, structureFindings(content, translated, lang), usage, nil
+}
+
+// writeTranslations writes the -translate variants of the migrated document
+// at path next to it. Their patches, usage and warnings are added to result.
+func writeTranslations(ctx context.Context, path string, result *migrateResponse) error {
+	for _, lang := range translateLanguages {
+		translated, warnings, usage, err := translateMarkdown(ctx, result.Markdown, lang)
+		result.Usage.PromptTokens += usage.PromptTokens
+		result.Usage.ResponseTokens += usage.ResponseTokens
+		if err != nil {
+			return fmt.Errorf("failed to translate to %s: %w", lang, err)
+		}
+		result.Warnings = append(result.Warnings, warnings...)
+
+		langPath := translatedPath(path, lang)
+		previous, err := pkgFS.ReadFile(langPath)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %w", langPath, err)
+		}
+		patch, err := generatePatch(langPath, string(previous), translated)
+		if err != nil {
+			return fmt.Errorf("failed to generate patch: %w", err)
+		}
+		if err := pkgFS.WriteFile(langPath, []byte(translated), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", langPath, err)
+		}
+		result.Patch += patch
+		if verbose {
+			log.Printf("Translation to %s written to %s", lang, langPath)
+		}
+	}
+	return nil
+}
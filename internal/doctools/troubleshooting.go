@@ -0,0 +1,82 @@
+package doctools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// knownIssuesFile is the optional file of a package listing its known
+// issues, it is not rendered by elastic-package
+const knownIssuesFile = "_dev/known-issues.md"
+
+// maxKnownIssues bounds the issues read from GitHub for a package
+const maxKnownIssues = 20
+
+// troubleshootingPrompt is added to the readme prompt with the known issues
+// of the package
+const troubleshootingPrompt = `
+
+Write the Troubleshooting section from these known issues of the package: for each issue describe the symptom and how to resolve or work around it, and link the GitHub issue where there is one. Keep the generic troubleshooting links of the template.
+%s`
+
+// issuesQuery is the GitHub search query selected with -issues-query, with
+// {package} standing for the package name
+var issuesQuery string
+
+// readKnownIssues returns the known issues file of a package, empty if it has
+// none
+func readKnownIssues(pkgPath string) (string, error) {
+	data, err := pkgFS.ReadFile(filepath.Join(pkgPath, filepath.FromSlash(knownIssuesFile)))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// formatIssues formats GitHub issues for the prompt, with the start of their
+// description
+func formatIssues(issues []githubIssue) string {
+	var b strings.Builder
+	for _, issue := range issues {
+		fmt.Fprintf(&b, "- #%d %s (%s, %s)\n", issue.Number, issue.Title, issue.State, issue.HTMLURL)
+		body := strings.Join(strings.Fields(issue.Body), " ")
+		if r := []rune(body); len(r) > 500 {
+			body = string(r[:500]) + "..."
+		}
+		if body != "" {
+			fmt.Fprintf(&b, "  %s\n", body)
+		}
+	}
+	return b.String()
+}
+
+// searchKnownIssues returns the issues of a package matching -issues-query,
+// formatted for the prompt
+func searchKnownIssues(ctx context.Context, pkgName string) (string, error) {
+	if issuesQuery == "" {
+		return "", nil
+	}
+	issues, err := envGitHubClient().searchIssues(ctx, strings.ReplaceAll(issuesQuery, "{package}", pkgName), maxKnownIssues)
+	if err != nil {
+		return "", fmt.Errorf("failed to search known issues: %w", err)
+	}
+	return formatIssues(issues), nil
+}
+
+// joinKnownIssues joins the known issues file of a package with the issues
+// found on GitHub
+func joinKnownIssues(file, issues string) string {
+	switch {
+	case file == "":
+		return issues
+	case issues == "":
+		return file
+	}
+	return file + "\n\nIssues on GitHub:\n" + issues
+}
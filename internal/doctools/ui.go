@@ -0,0 +1,135 @@
+package doctools
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"strings"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+var uiTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"countStatus": func(pkgs []*packageResult, status string) int {
+		n := 0
+		for _, p := range pkgs {
+			if p.Status == status {
+				n++
+			}
+		}
+		return n
+	},
+	"countDecision": func(pkgs []*packageResult, decision string) int {
+		n := 0
+		for _, p := range pkgs {
+			if p.Decision == decision {
+				n++
+			}
+		}
+		return n
+	},
+}).ParseFS(uiFiles, "ui/templates/*.html"))
+
+// diffLine is a line of a patch with the CSS class used to highlight it
+type diffLine struct {
+	Class string
+	Text  string
+}
+
+// diffLines splits a unified diff into lines classified for highlighting
+func diffLines(patch string) []diffLine {
+	var lines []diffLine
+	for _, l := range strings.Split(strings.TrimSuffix(patch, "\n"), "\n") {
+		class := ""
+		switch {
+		case strings.HasPrefix(l, "+++"), strings.HasPrefix(l, "---"):
+			class = "file"
+		case strings.HasPrefix(l, "@@"):
+			class = "hunk"
+		case strings.HasPrefix(l, "+"):
+			class = "add"
+		case strings.HasPrefix(l, "-"):
+			class = "del"
+		}
+		lines = append(lines, diffLine{Class: class, Text: l})
+	}
+	return lines
+}
+
+// registerUIRoutes adds the review web UI for batch jobs to mux. Its forms
+// carry the CSRF token of the user auth authenticated.
+func registerUIRoutes(mux *http.ServeMux, store *jobStore, auth *apiAuth) {
+	static, err := fs.Sub(uiFiles, "ui/static")
+	if err != nil {
+		panic(err)
+	}
+	mux.Handle("GET /ui/static/", http.StripPrefix("/ui/static/", http.FileServer(http.FS(static))))
+
+	mux.HandleFunc("GET /ui/{$}", func(w http.ResponseWriter, _ *http.Request) {
+		renderUI(w, "jobs.html", map[string]any{
+			"Title": "Jobs",
+			"Jobs":  store.snapshots(),
+		})
+	})
+
+	mux.HandleFunc("GET /ui/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		j, ok := store.snapshot(r.PathValue("id"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		renderUI(w, "job.html", map[string]any{
+			"Title": "Job " + j.ID,
+			"Job":   j,
+		})
+	})
+
+	mux.HandleFunc("GET /ui/jobs/{id}/packages/{name}", func(w http.ResponseWriter, r *http.Request) {
+		j, ok := store.snapshot(r.PathValue("id"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		for _, p := range j.Packages {
+			if p.Name != r.PathValue("name") || p.Status != statusSucceeded {
+				continue
+			}
+			reviewer, _ := auth.identity(r)
+			renderUI(w, "package.html", map[string]any{
+				"Title":     p.Name,
+				"Job":       j,
+				"Package":   p,
+				"Diff":      diffLines(p.patch),
+				"CSRFToken": auth.csrfToken(reviewer),
+			})
+			return
+		}
+		http.NotFound(w, r)
+	})
+
+	mux.HandleFunc("POST /ui/jobs/{id}/packages/{name}/decision", func(w http.ResponseWriter, r *http.Request) {
+		id, name := r.PathValue("id"), r.PathValue("name")
+		reviewer, _ := auth.identity(r)
+		if !auth.validCSRFToken(r, reviewer) {
+			http.Error(w, "invalid CSRF token, reload the page", http.StatusForbidden)
+			return
+		}
+		if status, err := store.decide(id, name, reviewer, r.FormValue("decision")); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		http.Redirect(w, r, "/ui/jobs/"+id, http.StatusSeeOther)
+	})
+
+	mux.Handle("GET /{$}", http.RedirectHandler("/ui/", http.StatusFound))
+}
+
+func renderUI(w http.ResponseWriter, name string, data map[string]any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := uiTemplates.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Error rendering %s: %v", name, err)
+	}
+}
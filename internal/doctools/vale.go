@@ -0,0 +1,134 @@
+package doctools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	// valeLint lints migrated readmes with Vale, selected with -vale
+	valeLint bool
+	// valeConfig is the .vale.ini selected with -vale-config
+	valeConfig string
+)
+
+// styleAlert is a style issue found in a migrated readme
+type styleAlert struct {
+	Check    string `json:"check"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+	Line     int    `json:"line"`
+	Match    string `json:"match,omitempty"`
+}
+
+// builtinStyleRules are a subset of the Elastic style guide rules for Vale,
+// used when vale is not installed
+var builtinStyleRules = []termRule{
+	{Name: "Elastic.Latinisms", Pattern: `\b(?:e\.g\.|i\.e\.|etc\.|via)(?:\s|,|$)`, Severity: severityWarning, Message: "Avoid Latinisms such as e.g., i.e., etc. and via, use plain English."},
+	{Name: "Elastic.Simple", Pattern: `(?i)\b(?:simply|just|easily|obviously)\b`, Severity: severityWarning, Message: "Avoid words that assume the task is easy for the reader."},
+	{Name: "Elastic.Please", Pattern: `(?i)\bplease\b`, Severity: severityWarning, Message: "Avoid 'please' in instructions."},
+	{Name: "Elastic.InclusiveLanguage", Pattern: `(?i)\b(?:whitelist|blacklist|master|slave)(?:ed|s)?\b`, Severity: severityError, Message: "Use inclusive language, for example allowlist, denylist, primary or replica."},
+	{Name: "Elastic.WordChoice", Pattern: `(?i)\b(?:click on|in order to|is able to)\b`, Severity: severityWarning, Message: "Use shorter wording, for example click, to or can."},
+	{Name: "Elastic.Exclamation", Pattern: `!(?:\s|$)`, Severity: severityWarning, Message: "Avoid exclamation points in technical content."},
+	{Name: "Elastic.Gender", Pattern: `(?i)\b(?:he|she|his|her|him)\b`, Severity: severityWarning, Message: "Use gender neutral pronouns such as they and their."},
+}
+
+func init() {
+	for i := range builtinStyleRules {
+		if err := builtinStyleRules[i].compile(); err != nil {
+			panic(fmt.Sprintf("invalid built-in style rule %s: %v", builtinStyleRules[i].Name, err))
+		}
+	}
+}
+
+// registerValeFlags adds the style lint flags to fs
+func registerValeFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&valeLint, "vale", false, "Lint the migrated readme with Vale and the Elastic style guide, or a built-in subset of its rules when vale is not installed, and report the style issues")
+	fs.StringVar(&valeConfig, "vale-config", "", "Vale configuration (.vale.ini) with the Elastic style package, defaults to the one Vale finds itself")
+}
+
+// lintStyle reports the style issues of a migrated readme, none without
+// -vale
+func lintStyle(ctx context.Context, content string) ([]styleAlert, error) {
+	if !valeLint {
+		return nil, nil
+	}
+	bin, err := exec.LookPath("vale")
+	if err != nil {
+		return builtinStyleAlerts(content), nil
+	}
+	return runVale(ctx, bin, content)
+}
+
+// builtinStyleAlerts checks content with the built-in style rules
+func builtinStyleAlerts(content string) []styleAlert {
+	var alerts []styleAlert
+	for _, v := range findTermViolations(content, builtinStyleRules) {
+		alerts = append(alerts, styleAlert{
+			Check:    v.rule.Name,
+			Message:  v.rule.Message,
+			Severity: v.rule.Severity,
+			Line:     v.line,
+			Match:    strings.TrimSpace(v.text),
+		})
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].Line < alerts[j].Line })
+	return alerts
+}
+
+// valeAlert is an alert of the Vale JSON output
+type valeAlert struct {
+	Check    string
+	Message  string
+	Severity string
+	Line     int
+	Match    string
+}
+
+// runVale lints content with the vale binary at bin
+func runVale(ctx context.Context, bin, content string) ([]styleAlert, error) {
+	dir, err := os.MkdirTemp("", "docs-template-update-vale")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "README.md")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return nil, err
+	}
+
+	args := []string{"--output=JSON", "--no-exit"}
+	if valeConfig != "" {
+		args = append(args, "--config="+valeConfig)
+	}
+	cmd := exec.CommandContext(ctx, bin, append(args, path)...)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("failed to run vale: %w: %s", err, exitErr.Stderr)
+		}
+		return nil, fmt.Errorf("failed to run vale: %w", err)
+	}
+
+	var files map[string][]valeAlert
+	if err := json.Unmarshal(out, &files); err != nil {
+		return nil, fmt.Errorf("failed to parse vale output: %w", err)
+	}
+	var alerts []styleAlert
+	for _, list := range files {
+		for _, a := range list {
+			alerts = append(alerts, styleAlert(a))
+		}
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].Line < alerts[j].Line })
+	return alerts, nil
+}
@@ -0,0 +1,198 @@
+package doctools
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kgeller/go-examples/internal/llmclient"
+	"github.com/kgeller/go-examples/internal/markdown"
+)
+
+// variantPrompt asks to restructure a localized readme like the migrated
+// English readme
+const variantPrompt = `I need to restructure a localized README.md file written in the language with the BCP 47 code %q so that it matches the English README.md, which was already restructured.
+
+Follow these exact guidelines:
+1. Keep the language of the localized README, do not translate it to English
+2. Use exactly the same sections as the English README: the same number of headings, at the same levels, in the same order, with headings translated to the language of the localized README
+3. Always utilize the original content of the localized README where possible, do not remove content
+4. Where the English README has content the localized README lacks, translate it from the English README
+5. Copy code blocks and mustache placeholders such as {{fields "data_stream_name"}} and {{event "data_stream_name"}} exactly as they are in the English README
+6. Format your response appropriately for a Markdown file
+
+Return ONLY the updated localized Markdown content, without any explanation or commentary.
+
+# English README:
+%s
+
+# Localized README:
+%s`
+
+var (
+	// variantNamePattern matches the file names of localized readmes, e.g.
+	// README.es.md or readme.pt-BR.md
+	variantNamePattern = regexp.MustCompile(`^(?i:readme)\.([a-z]{2,3}(?:-[A-Za-z0-9]{2,8})*)\.md$`)
+)
+
+// readmeVariant is a localized readme of a package
+type readmeVariant struct {
+	Lang string
+	// Source is the readme the migration starts from, the migrated variant
+	// if there is one, the rendered one otherwise.
+	Source string
+	// Target is where the migrated variant is written.
+	Target string
+}
+
+// findReadmeVariants returns the localized readmes of a package, rendered in
+// docs/ or already migrated in _dev/build/docs, sorted by language
+func findReadmeVariants(pkgPath string) ([]readmeVariant, error) {
+	targetDir := filepath.Dir(targetReadmePath(pkgPath))
+	variants := make(map[string]*readmeVariant)
+	for _, dir := range []string{sourceDocsDir(pkgPath), targetDir} {
+		entries, err := pkgFS.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			m := variantNamePattern.FindStringSubmatch(e.Name())
+			if e.IsDir() || m == nil || m[1] == "en" {
+				continue
+			}
+			// The migrated variant takes precedence, it is read after the
+			// rendered one.
+			variants[m[1]] = &readmeVariant{
+				Lang:   m[1],
+				Source: filepath.Join(dir, e.Name()),
+				Target: translatedPath(targetReadmePath(pkgPath), m[1]),
+			}
+		}
+	}
+
+	result := make([]readmeVariant, 0, len(variants))
+	for _, v := range variants {
+		result = append(result, *v)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Lang < result[j].Lang })
+	return result, nil
+}
+
+// structureFindings compares the structure of a localized readme with the
+// English one: the heading levels and the placeholders must be the same
+func structureFindings(english, variant, lang string) []string {
+	var findings []string
+
+	want, got := markdown.ParseHeadings(english), markdown.ParseHeadings(variant)
+	if len(got) != len(want) {
+		findings = append(findings, fmt.Sprintf("%s readme has %d headings, the English readme has %d", lang, len(got), len(want)))
+	} else {
+		for i := range want {
+			if got[i].Level != want[i].Level {
+				findings = append(findings, fmt.Sprintf("%s heading %q is level %d, the English heading %q is level %d", lang, got[i].Text, got[i].Level, want[i].Text, want[i].Level))
+			}
+		}
+	}
+
+	wantPlaceholders := placeholderPattern.FindAllString(english, -1)
+	gotPlaceholders := placeholderPattern.FindAllString(variant, -1)
+	for _, p := range wantPlaceholders {
+		if !slices.Contains(gotPlaceholders, p) {
+			findings = append(findings, fmt.Sprintf("%s readme is missing placeholder %s", lang, p))
+		}
+	}
+	for _, p := range gotPlaceholders {
+		if !slices.Contains(wantPlaceholders, p) {
+			findings = append(findings, fmt.Sprintf("%s readme has placeholder %s, which is not in the English readme", lang, p))
+		}
+	}
+	return findings
+}
+
+// migrateVariant restructures a localized readme like the migrated English
+// readme
+func migrateVariant(ctx context.Context, english, variant, lang string) (string, []string, llmclient.Usage, error) {
+	ctx, span := tracer.Start(ctx, "migrate-variant", trace.WithAttributes(attribute.String("language", lang)))
+	defer span.End()
+
+	migrated, usage, err := generateText(ctx, currentBackend(), fmt.Sprintf(placeholders.rewrite(variantPrompt), lang, english, variant))
+	if err != nil {
+		failSpan(span, err)
+		return "", nil, usage, err
+	}
+	migrated = normalizeText(migrated)
+	return migrated, structureFindings(english, migrated, lang), usage, nil
+}
+
+// writeVariants migrates the localized readmes of a package after its English
+// readme was migrated to result. Languages produced by -translate are left
+// to the translation. Their patches, usage and warnings are added to result.
+func writeVariants(ctx context.Context, pkgPath string, result *migrateResponse) error {
+	variants, err := findReadmeVariants(pkgPath)
+	if err != nil {
+		return fmt.Errorf("failed to find localized readmes: %w", err)
+	}
+	for _, v := range variants {
+		if slices.Contains(translateLanguages, v.Lang) {
+			continue
+		}
+		if verbose {
+			log.Printf("Migrating %s readme %s", v.Lang, v.Source)
+		}
+
+		content, enc, err := readMarkdown(v.Source)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", v.Source, err)
+		}
+		migrated, warnings, usage, err := migrateVariant(ctx, result.Markdown, content, v.Lang)
+		result.Usage.PromptTokens += usage.PromptTokens
+		result.Usage.ResponseTokens += usage.ResponseTokens
+		if err != nil {
+			return fmt.Errorf("failed to migrate %s readme: %w", v.Lang, err)
+		}
+		result.Warnings = append(result.Warnings, warnings...)
+
+		patch, err := generatePatch(v.Target, content, migrated)
+		if err != nil {
+			return fmt.Errorf("failed to generate patch: %w", err)
+		}
+		if err := pkgFS.WriteFile(v.Target, enc.encode(migrated), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", v.Target, err)
+		}
+		result.Patch += patch
+	}
+	return nil
+}
+
+// checkVariants reports localized readmes in _dev/build/docs whose structure
+// drifted from the English readme
+func checkVariants(pkgPath, english string) ([]string, error) {
+	variants, err := findReadmeVariants(pkgPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find localized readmes: %w", err)
+	}
+	var findings []string
+	for _, v := range variants {
+		if filepath.Dir(v.Source) != filepath.Dir(v.Target) {
+			findings = append(findings, fmt.Sprintf("%s not found, %s readme has not been migrated", v.Target, v.Lang))
+			continue
+		}
+		content, _, err := readMarkdown(v.Source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", v.Source, err)
+		}
+		findings = append(findings, structureFindings(english, content, v.Lang)...)
+	}
+	return findings, nil
+}
@@ -0,0 +1,166 @@
+package doctools
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileState is the part of a file's metadata used to detect changes
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchedPaths returns the package paths that trigger a re-run when changed.
+// The readme template is only watched when it is not being regenerated by
+// the watcher itself, otherwise every run would trigger the next one, and the
+// sample events it writes are taken in after each run by takeOwnWrites.
+func watchedPaths(pkgPath string, regenerate bool) []string {
+	paths := []string{
+		sourceDocsDir(pkgPath),
+		filepath.Join(pkgPath, "data_stream"),
+		filepath.Join(pkgPath, "manifest.yml"),
+	}
+	if !regenerate {
+		paths = append(paths, filepath.Dir(targetReadmePath(pkgPath)))
+	}
+	return paths
+}
+
+// snapshot records the state of every file below the given paths. Paths that
+// do not exist are ignored.
+func snapshot(paths []string) (map[string]fileState, error) {
+	files := make(map[string]fileState)
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// takeOwnWrites returns last with the state of the sample events in current.
+// Regeneration writes them with -sample-policy and -refresh-samples, taking
+// them in after each run keeps those writes from triggering the next one,
+// while changes made between runs still do.
+func takeOwnWrites(last, current map[string]fileState) map[string]fileState {
+	taken := make(map[string]fileState, len(last))
+	for path, state := range last {
+		if filepath.Base(path) != "sample_event.json" {
+			taken[path] = state
+		}
+	}
+	for path, state := range current {
+		if filepath.Base(path) == "sample_event.json" {
+			taken[path] = state
+		}
+	}
+	return taken
+}
+
+// changedFiles returns the files that were added, removed or modified
+// between two snapshots
+func changedFiles(before, after map[string]fileState) []string {
+	var changed []string
+	for path, state := range after {
+		if prev, ok := before[path]; !ok || prev != state {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// watchPackage polls the package for changes and re-runs validation, and
+// optionally regeneration, every time something changes. It runs once at
+// startup and returns when ctx is cancelled.
+func watchPackage(ctx context.Context, pkgPath string, interval time.Duration, regenerate bool) error {
+	paths := watchedPaths(pkgPath, regenerate)
+	var last map[string]fileState
+
+	run := func() {
+		if regenerate {
+			result, err := processPackage(ctx, pkgPath)
+			if err != nil {
+				log.Printf("Error processing package: %v", err)
+				return
+			}
+			fmt.Println(result.Patch)
+			// The sample events just written are not changes to act on
+			if current, err := snapshot(paths); err == nil {
+				last = takeOwnWrites(last, current)
+			}
+		}
+
+		findings, err := checkPackage(ctx, pkgPath)
+		if err != nil {
+			log.Printf("Error checking package: %v", err)
+			return
+		}
+		for _, f := range findings {
+			fmt.Printf("%s: %s\n", pkgPath, f)
+		}
+		if len(findings) == 0 {
+			log.Printf("%s conforms to the template", pkgPath)
+		}
+	}
+
+	var err error
+	if last, err = snapshot(paths); err != nil {
+		return fmt.Errorf("failed to scan package: %w", err)
+	}
+	run()
+	log.Printf("Watching %s for changes (press Ctrl+C to stop)", pkgPath)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := snapshot(paths)
+		if err != nil {
+			log.Printf("Error scanning package: %v", err)
+			continue
+		}
+		changed := changedFiles(last, current)
+		last = current
+		if len(changed) == 0 {
+			continue
+		}
+
+		if verbose {
+			log.Printf("Changed files: %v", changed)
+		}
+		run()
+	}
+}
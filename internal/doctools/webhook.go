@@ -0,0 +1,576 @@
+package doctools
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// maxWebhookBytes bounds the size of a webhook payload, GitHub caps
+	// them at 25MB
+	maxWebhookBytes = 25 << 20
+	// maxCommentPatchBytes keeps comments below GitHub's 65536 character limit
+	maxCommentPatchBytes = 60000
+	// botBranchPrefix is the prefix of the branches the bot opens pull
+	// requests from
+	botBranchPrefix = "docs-template-update/"
+)
+
+// readmeChangePattern matches a package's rendered README in any case,
+// capturing the package directory
+func readmeChangePattern() *regexp.Regexp {
+	return regexp.MustCompile(`^(?:(.*)/)?(?i:` + regexp.QuoteMeta(sourceReadmeRel) + `)$`)
+}
+
+// webhookBot reacts to GitHub push and pull request events touching package
+// READMEs by proposing the template conformant version
+type webhookBot struct {
+	ctx    context.Context
+	secret []byte
+	gh     *githubClient
+	// login is the GitHub account of the bot, only its own comments are
+	// updated
+	login string
+	// approvals holds the pull requests of pushes for approval with
+	// -require-approval, nil to open them right away
+	approvals *approvalQueue
+}
+
+type webhookRepository struct {
+	FullName      string `json:"full_name"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+type pullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Head struct {
+			SHA  string            `json:"sha"`
+			Repo webhookRepository `json:"repo"`
+		} `json:"head"`
+	} `json:"pull_request"`
+	Repository webhookRepository `json:"repository"`
+}
+
+type pushEvent struct {
+	Ref     string `json:"ref"`
+	After   string `json:"after"`
+	Commits []struct {
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+	} `json:"commits"`
+	Repository webhookRepository `json:"repository"`
+}
+
+// verifySignature checks the X-Hub-Signature-256 header against the payload
+func verifySignature(secret, payload []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func (b *webhookBot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBytes))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	if !verifySignature(b.secret, payload, r.Header.Get("X-Hub-Signature-256")) {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid signature"})
+		return
+	}
+
+	switch event := r.Header.Get("X-GitHub-Event"); event {
+	case "ping":
+		w.WriteHeader(http.StatusOK)
+	case "pull_request":
+		var ev pullRequestEvent
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		switch ev.Action {
+		case "opened", "reopened", "synchronize":
+			go b.handlePullRequest(ev)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case "push":
+		var ev pushEvent
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		go b.handlePush(ev)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// changedPackageDirs returns the package directories whose README is among
+// the changed files
+func changedPackageDirs(files []string) []string {
+	pattern := readmeChangePattern()
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, f := range files {
+		m := pattern.FindStringSubmatch(f)
+		if m == nil || seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		dirs = append(dirs, m[1])
+	}
+	return dirs
+}
+
+// remoteMigration is the result of migrating a package read from GitHub
+type remoteMigration struct {
+	pkgDir     string
+	targetPath string
+	// targetExists reports whether targetPath already exists at the ref.
+	targetExists bool
+	original     string
+	result       *migrateResponse
+}
+
+// changed reports whether the migration produced any changes
+func (m *remoteMigration) changed() bool {
+	return !m.targetExists || m.result.Markdown != m.original
+}
+
+// migrateRemotePackage migrates a package read from a repository at ref.
+// It returns nil if pkgDir is not a package.
+func (b *webhookBot) migrateRemotePackage(ctx context.Context, repo, ref, pkgDir string) (*remoteMigration, error) {
+	manifestContent, err := b.gh.fileContent(ctx, repo, path.Join(pkgDir, "manifest.yml"), ref)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	manifest, err := parseManifest([]byte(manifestContent))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &remoteMigration{pkgDir: pkgDir, targetPath: path.Join(pkgDir, targetReadmeRel)}
+	for _, name := range caseVariants(path.Base(targetReadmeRel)) {
+		p := path.Join(pkgDir, path.Dir(targetReadmeRel), name)
+		content, err := b.gh.fileContent(ctx, repo, p, ref)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		m.targetPath, m.targetExists, m.original = p, true, content
+		break
+	}
+	if !m.targetExists {
+		found := false
+		for _, name := range caseVariants(path.Base(sourceReadmeRel)) {
+			content, err := b.gh.fileContent(ctx, repo, path.Join(pkgDir, path.Dir(sourceReadmeRel), name), ref)
+			if errors.Is(err, errNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read README: %w", err)
+			}
+			m.original, found = content, true
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("failed to read README: %s not found", path.Join(pkgDir, sourceReadmeRel))
+		}
+	}
+
+	req := migrateRequest{Readme: m.original, SampleEvents: []string{}, PackageType: manifest.Type, Setup: &manifest.packageSetup}
+	if req.Assets, err = b.remoteKibanaAssets(ctx, repo, ref, pkgDir); err != nil {
+		return nil, err
+	}
+	if req.Screenshots, err = b.remoteScreenshots(ctx, repo, ref, pkgDir, manifest.Screenshots); err != nil {
+		return nil, err
+	}
+	knownIssues, err := b.gh.fileContent(ctx, repo, path.Join(pkgDir, knownIssuesFile), ref)
+	if err != nil && !errors.Is(err, errNotFound) {
+		return nil, fmt.Errorf("failed to read known issues: %w", err)
+	}
+	req.KnownIssues = strings.TrimSpace(knownIssues)
+	if req.PackageType == packageTypeInput {
+		_, err := b.gh.fileContent(ctx, repo, path.Join(pkgDir, "sample_event.json"), ref)
+		if err != nil && !errors.Is(err, errNotFound) {
+			return nil, err
+		}
+		req.SampleEvent = err == nil
+		if req.Collection, err = b.remoteCollection(ctx, repo, ref, pkgDir, req); err != nil {
+			return nil, err
+		}
+		if m.result, err = migrateContent(ctx, req, nil); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	if req.PackageType == packageTypeContent {
+		if m.result, err = migrateContent(ctx, req, nil); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+
+	entries, err := b.gh.listDir(ctx, repo, path.Join(pkgDir, "data_stream"), ref)
+	if err != nil && !errors.Is(err, errNotFound) {
+		return nil, fmt.Errorf("failed to list data streams: %w", err)
+	}
+	for _, e := range entries {
+		if e.Type != "dir" {
+			continue
+		}
+		req.DataStreams = append(req.DataStreams, e.Name)
+
+		files, err := b.gh.listDir(ctx, repo, e.Path, ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list data stream %s: %w", e.Name, err)
+		}
+		for _, f := range files {
+			if f.Name == "sample_event.json" {
+				req.SampleEvents = append(req.SampleEvents, e.Name)
+			}
+		}
+	}
+
+	if req.Collection, err = b.remoteCollection(ctx, repo, ref, pkgDir, req); err != nil {
+		return nil, err
+	}
+
+	m.result, err = migrateContent(ctx, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// remoteCollection is readCollection for a package read from a repository at
+// ref
+func (b *webhookBot) remoteCollection(ctx context.Context, repo, ref, pkgDir string, req migrateRequest) ([]streamCollection, error) {
+	defaults := setupDefaults(req.Setup)
+	if req.PackageType == packageTypeInput {
+		collection, err := b.remoteTemplates(ctx, repo, ref, pkgDir, "", path.Join(pkgDir, "agent", "input"), nil, defaults)
+		if err != nil {
+			return nil, err
+		}
+		setPackageInput(collection, req.Setup)
+		return collection, nil
+	}
+
+	var collection []streamCollection
+	for _, ds := range req.DataStreams {
+		dsDir := path.Join(pkgDir, "data_stream", ds)
+		var manifest dataStreamManifest
+		content, err := b.gh.fileContent(ctx, repo, path.Join(dsDir, "manifest.yml"), ref)
+		if err != nil && !errors.Is(err, errNotFound) {
+			return nil, err
+		}
+		if err := yaml.Unmarshal([]byte(content), &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse %s manifest: %w", ds, err)
+		}
+		templates, err := b.remoteTemplates(ctx, repo, ref, pkgDir, ds, path.Join(dsDir, "agent", "stream"), &manifest, defaults)
+		if err != nil {
+			return nil, err
+		}
+		collection = append(collection, templates...)
+	}
+	return collection, nil
+}
+
+// remoteTemplates is readTemplates for a package read from a repository at
+// ref
+func (b *webhookBot) remoteTemplates(ctx context.Context, repo, ref, pkgDir, dataStream, dir string, manifest *dataStreamManifest, defaults map[string]string) ([]streamCollection, error) {
+	files, err := b.gh.listDir(ctx, repo, dir, ref)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to list agent templates: %w", err)
+	}
+	var collection []streamCollection
+	for _, f := range files {
+		if f.Type != "file" || !strings.HasSuffix(f.Name, ".yml.hbs") {
+			continue
+		}
+		data, err := b.gh.fileContent(ctx, repo, f.Path, ref)
+		if err != nil {
+			return nil, err
+		}
+		templatePath := strings.TrimPrefix(f.Path, pkgDir+"/")
+		collection = append(collection, streamTemplateCollection(dataStream, templatePath, []byte(data), manifest, defaults))
+	}
+	return collection, nil
+}
+
+// remoteScreenshots is readScreenshots for a package read from a repository
+// at ref
+func (b *webhookBot) remoteScreenshots(ctx context.Context, repo, ref, pkgDir string, screenshots []screenshot) ([]screenshot, error) {
+	if len(screenshots) == 0 {
+		return nil, nil
+	}
+	files, err := b.gh.listDir(ctx, repo, path.Join(pkgDir, "img"), ref)
+	if err != nil && !errors.Is(err, errNotFound) {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	images := make(map[string]bool, len(files))
+	for _, f := range files {
+		images["/img/"+f.Name] = true
+	}
+	return presentScreenshots(screenshots, func(src string) bool { return images[src] }), nil
+}
+
+// remoteKibanaAssets is readKibanaAssets for a package read from a repository
+// at ref
+func (b *webhookBot) remoteKibanaAssets(ctx context.Context, repo, ref, pkgDir string) ([]kibanaAsset, error) {
+	types, err := b.gh.listDir(ctx, repo, path.Join(pkgDir, "kibana"), ref)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to list kibana assets: %w", err)
+	}
+
+	var assets []kibanaAsset
+	for _, t := range types {
+		if t.Type != "dir" {
+			continue
+		}
+		files, err := b.gh.listDir(ctx, repo, t.Path, ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list kibana assets %s: %w", t.Name, err)
+		}
+		for _, f := range files {
+			if f.Type != "file" || path.Ext(f.Name) != ".json" {
+				continue
+			}
+			var data []byte
+			if t.Name == "dashboard" {
+				content, err := b.gh.fileContent(ctx, repo, f.Path, ref)
+				if err != nil {
+					return nil, err
+				}
+				data = []byte(content)
+			}
+			asset, err := parseKibanaAsset(t.Name, f.Name, data)
+			if err != nil {
+				return nil, err
+			}
+			assets = append(assets, asset)
+		}
+	}
+	sortAssets(assets)
+	return assets, nil
+}
+
+// handlePullRequest comments the proposed changes on a pull request
+func (b *webhookBot) handlePullRequest(ev pullRequestEvent) {
+	repo := ev.Repository.FullName
+	headRepo := ev.PullRequest.Head.Repo.FullName
+	if headRepo == "" {
+		headRepo = repo
+	}
+
+	files, err := b.gh.pullRequestFiles(b.ctx, repo, ev.Number)
+	if err != nil {
+		log.Printf("Webhook: failed to list files of %s#%d: %v", repo, ev.Number, err)
+		return
+	}
+
+	// Every push to the pull request updates the comments of the previous
+	// ones rather than adding new ones
+	var comments []githubComment
+	listed := false
+	for _, pkgDir := range changedPackageDirs(files) {
+		m, err := b.migrateRemotePackage(b.ctx, headRepo, ev.PullRequest.Head.SHA, pkgDir)
+		if err != nil {
+			log.Printf("Webhook: failed to migrate %s in %s#%d: %v", pkgDir, repo, ev.Number, err)
+			continue
+		}
+		if m == nil || !m.changed() {
+			continue
+		}
+		if !listed {
+			if comments, err = b.gh.issueComments(b.ctx, repo, ev.Number); err != nil {
+				log.Printf("Webhook: failed to list comments of %s#%d: %v", repo, ev.Number, err)
+				return
+			}
+			listed = true
+		}
+		body := migrationComment(m)
+		if id, ok := findMigrationComment(comments, b.login, m.targetPath); ok {
+			err = b.gh.updateComment(b.ctx, repo, id, body)
+		} else {
+			err = b.gh.createComment(b.ctx, repo, ev.Number, body)
+		}
+		if err != nil {
+			log.Printf("Webhook: failed to comment on %s#%d: %v", repo, ev.Number, err)
+		}
+	}
+}
+
+// commentMarker identifies the comment of the bot for a readme, hidden in
+// the rendered markdown
+func commentMarker(targetPath string) string {
+	return fmt.Sprintf("<!-- docs-template-update: %s -->", targetPath)
+}
+
+// findMigrationComment returns the ID of the comment the bot, of GitHub
+// account login, left for a readme, if any. Comments of other users quoting
+// the marker are not the bot's.
+func findMigrationComment(comments []githubComment, login, targetPath string) (int64, bool) {
+	marker := commentMarker(targetPath)
+	for _, c := range comments {
+		if c.User.Login == login && strings.HasPrefix(c.Body, marker+"\n") {
+			return c.ID, true
+		}
+	}
+	return 0, false
+}
+
+// handlePush opens a follow-up pull request per package for pushes to the
+// default branch, or holds it for approval
+func (b *webhookBot) handlePush(ev pushEvent) {
+	repo := ev.Repository.FullName
+	base := ev.Repository.DefaultBranch
+	if ev.Ref != "refs/heads/"+base {
+		return
+	}
+
+	var files []string
+	for _, c := range ev.Commits {
+		files = append(files, c.Added...)
+		files = append(files, c.Modified...)
+	}
+
+	for _, pkgDir := range changedPackageDirs(files) {
+		m, err := b.migrateRemotePackage(b.ctx, repo, ev.After, pkgDir)
+		if err != nil {
+			log.Printf("Webhook: failed to migrate %s in %s@%s: %v", pkgDir, repo, ev.After, err)
+			continue
+		}
+		if m == nil || !m.changed() {
+			continue
+		}
+		if b.approvals != nil {
+			p, err := b.approvals.hold(repo, base, ev.After, m)
+			if err != nil {
+				log.Printf("Webhook: failed to hold %s in %s for approval: %v", pkgDir, repo, err)
+				continue
+			}
+			log.Printf("Webhook: holding %s in %s for approval as %s", pkgDir, repo, p.ID)
+			continue
+		}
+
+		url, err := b.openPullRequest(repo, base, ev.After, m)
+		if err != nil {
+			log.Printf("Webhook: failed to open pull request for %s in %s: %v", pkgDir, repo, err)
+			continue
+		}
+		log.Printf("Webhook: opened %s", url)
+	}
+}
+
+// openPullRequest commits the migrated readme to a new branch and opens a
+// pull request for it
+func (b *webhookBot) openPullRequest(repo, base, sha string, m *remoteMigration) (string, error) {
+	name := path.Base(m.pkgDir)
+	branch := fmt.Sprintf("%s%s-%.7s", botBranchPrefix, name, sha)
+	if err := b.gh.createBranch(b.ctx, repo, branch, sha); err != nil {
+		return "", err
+	}
+
+	var blobSHA string
+	if m.targetExists {
+		var err error
+		if blobSHA, err = b.gh.fileSHA(b.ctx, repo, m.targetPath, sha); err != nil {
+			return "", err
+		}
+	}
+	message := fmt.Sprintf("[%s] Update docs to the new template", name)
+	if err := b.gh.putFile(b.ctx, repo, m.targetPath, branch, message, m.result.Markdown, blobSHA); err != nil {
+		return "", err
+	}
+
+	return b.gh.createPullRequest(b.ctx, repo, branch, base, message, migrationComment(m))
+}
+
+// migrationComment renders the proposed changes as markdown for a pull
+// request comment or body
+func migrationComment(m *remoteMigration) string {
+	var sb strings.Builder
+	sb.WriteString(commentMarker(m.targetPath) + "\n")
+	fmt.Fprintf(&sb, "### docs-template-update: `%s`\n\n", m.targetPath)
+	sb.WriteString("The README of this package does not match the docs template yet. ")
+	sb.WriteString("This is the proposed template conformant version.\n\n")
+
+	patch := m.result.Patch
+	if len(patch) > maxCommentPatchBytes {
+		patch = strings.ToValidUTF8(patch[:maxCommentPatchBytes], "") + "\n... (truncated)"
+	}
+	fence := codeFence(patch)
+	fmt.Fprintf(&sb, "<details><summary>Patch</summary>\n\n%sdiff\n", fence)
+	sb.WriteString(patch)
+	fmt.Fprintf(&sb, "\n%s\n\n</details>\n", fence)
+
+	if len(m.result.Warnings) > 0 {
+		sb.WriteString("\n**Warnings**\n\n")
+		for _, w := range m.result.Warnings {
+			fmt.Fprintf(&sb, "- %s\n", w)
+		}
+	}
+	if len(m.result.Todos) > 0 {
+		sb.WriteString("\n**TODO**\n\n")
+		for _, t := range m.result.Todos {
+			fmt.Fprintf(&sb, "- [ ] %s, line %d (%s): %s\n", t.Section, t.Line, t.Kind, t.Text)
+		}
+	}
+	if len(m.result.Style) > 0 {
+		sb.WriteString("\n**Style**\n\n")
+		for _, a := range m.result.Style {
+			fmt.Fprintf(&sb, "- line %d, %s (%s): %s\n", a.Line, a.Check, a.Severity, a.Message)
+		}
+	}
+	return sb.String()
+}
+
+// codeFence returns a backtick fence longer than any run of backticks in
+// content, so that fenced code in a patch does not close the block
+func codeFence(content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r != '`' {
+			run = 0
+			continue
+		}
+		run++
+		longest = max(longest, run)
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
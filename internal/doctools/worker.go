@@ -0,0 +1,205 @@
+package doctools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// workClient talks to the work endpoints of a coordinator
+type workClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// post sends v as JSON and decodes a JSON response into out, if not nil. It
+// returns the response status.
+func (c *workClient) post(ctx context.Context, path string, v, out any) (int, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e errorResponse
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if json.Unmarshal(data, &e) != nil || e.Error == "" {
+			e.Error = strings.TrimSpace(string(data))
+		}
+		return resp.StatusCode, fmt.Errorf("%s %s: %s: %s", req.Method, path, resp.Status, e.Error)
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// lease asks the coordinator for the next package, it returns nil if there
+// is no work
+func (c *workClient) lease(ctx context.Context, worker string) (*workItem, error) {
+	var item workItem
+	status, err := c.post(ctx, "/v1/work/lease", map[string]string{"worker": worker}, &item)
+	if err != nil || status == http.StatusNoContent {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// complete reports the result of a leased package
+func (c *workClient) complete(ctx context.Context, leaseID string, res workResult) error {
+	_, err := c.post(ctx, "/v1/work/"+leaseID+"/complete", res, nil)
+	return err
+}
+
+// runWorker implements the worker subcommand
+func runWorker(args []string) {
+	hostname, _ := os.Hostname()
+
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	coordinator := fs.String("coordinator", "", "Base URL of the coordinator, a serve instance started with -queue (required)")
+	name := fs.String("name", hostname, "Name reported to the coordinator, defaults to the hostname")
+	token := fs.String("worker-token", os.Getenv(workerTokenEnv), "Token presented to the coordinator (defaults to "+workerTokenEnv+")")
+	metricsAddr := fs.String("metrics-addr", "", "Address to serve Prometheus metrics on (disabled when empty)")
+	pollInterval := fs.Duration("poll-interval", 10*time.Second, "How long to wait before asking for work again when the queue is empty")
+	fs.StringVar(&apiKeyRef, "api-key-ref", "", secretRefHelp)
+	fs.StringVar(&googleAPIKey, "api-key", "", "Google Gemini API key (can also be set via GOOGLE_API_KEY environment variable)")
+	registerVerbosityFlags(fs)
+	registerNetworkFlags(fs)
+	registerTimeoutFlags(fs)
+	registerECSFlags(fs)
+	registerStyleIndexFlags(fs)
+	registerGlossaryFlags(fs)
+	registerConfigFlags(fs)
+	registerValeFlags(fs)
+	registerReadabilityFlags(fs)
+	registerProviderFlags(fs)
+	registerPromptFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s worker -coordinator URL [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "worker migrates packages leased from a coordinator with its own API key.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if err := applyVerbosity(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := configureNetwork(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := validateTimeouts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadGlossary(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadProvider(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := loadPrompts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *coordinator == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	requireAPIKey()
+
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", promhttp.Handler())
+		go func() {
+			if err := serve(ctx, *metricsAddr, mux); err != nil {
+				log.Fatalf("Error serving metrics: %v", err)
+			}
+		}()
+	}
+
+	client := &workClient{
+		baseURL: strings.TrimSuffix(*coordinator, "/"),
+		token:   *token,
+		http:    &http.Client{Timeout: time.Minute, Transport: outboundTransport},
+	}
+	workLoop(ctx, client, *name, *pollInterval)
+}
+
+// workLoop leases and migrates packages until ctx is cancelled. A package
+// being migrated when ctx is cancelled is not reported, the coordinator hands
+// it to another worker once the lease expires.
+func workLoop(ctx context.Context, client *workClient, worker string, pollInterval time.Duration) {
+	log.Printf("Worker %s pulling work from %s", worker, client.baseURL)
+	for {
+		item, err := client.lease(ctx, worker)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("Error leasing work: %v", err)
+		}
+		if item == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		log.Printf("Migrating %s of job %s", item.Package, item.JobID)
+		started := time.Now()
+		resp, err := migrateContent(ctx, item.Request, nil)
+		if ctx.Err() != nil {
+			return
+		}
+		res := workResult{Result: resp, DurationMS: time.Since(started).Milliseconds()}
+		if err != nil {
+			log.Printf("Error migrating %s: %v", item.Package, err)
+			res = workResult{Error: redactSecrets(err.Error()), DurationMS: res.DurationMS}
+			// The coordinator still accounts for the tokens used
+			if resp != nil {
+				res.Result = &migrateResponse{Usage: resp.Usage}
+			}
+		}
+
+		// If this fails the lease expires and another worker migrates the
+		// package again.
+		if err := client.complete(ctx, item.LeaseID, res); err != nil {
+			log.Printf("Error reporting %s: %v", item.Package, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package llmclient
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+const (
+	// HashEmbeddingModel stands for the word hashing embeddings of the fake
+	// provider, which need no API key
+	HashEmbeddingModel = "hash"
+	// hashEmbeddingSize is the size of the word hashing embeddings
+	hashEmbeddingSize = 256
+)
+
+// embeddingWordPattern matches the words hashed into the embeddings of the
+// fake provider
+var embeddingWordPattern = regexp.MustCompile(`[A-Za-z][A-Za-z'’-]*`)
+
+// Embed returns the embeddings of texts with an embedding model, in a single
+// request. The fake provider embeds them with HashEmbedding.
+func (c *Client) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	if c.cfg.Provider == ProviderFake {
+		for _, t := range texts {
+			embeddings = append(embeddings, HashEmbedding(t))
+		}
+		return embeddings, nil
+	}
+	if err := c.cfg.Limiter.Wait(ctx); err != nil {
+		return nil, contextError(ctx, err)
+	}
+	em := c.client.EmbeddingModel(model)
+	if len(texts) == 1 {
+		resp, err := em.EmbedContent(ctx, genai.Text(texts[0]))
+		if err != nil {
+			return nil, fmt.Errorf("error embedding with %s: %w", model, c.redact(contextError(ctx, err)))
+		}
+		if resp.Embedding == nil {
+			return nil, fmt.Errorf("no embedding received from Gemini")
+		}
+		return append(embeddings, resp.Embedding.Values), nil
+	}
+	b := em.NewBatch()
+	for _, t := range texts {
+		b.AddContent(genai.Text(t))
+	}
+	resp, err := em.BatchEmbedContents(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("error embedding with %s: %w", model, c.redact(contextError(ctx, err)))
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("%d embeddings received from Gemini for %d texts", len(resp.Embeddings), len(texts))
+	}
+	for _, e := range resp.Embeddings {
+		embeddings = append(embeddings, e.Values)
+	}
+	return embeddings, nil
+}
+
+// HashEmbedding embeds text as its words hashed into a fixed size vector,
+// so the fake provider can build and query an index offline
+func HashEmbedding(text string) []float32 {
+	v := make([]float32, hashEmbeddingSize)
+	for _, w := range embeddingWordPattern.FindAllString(strings.ToLower(text), -1) {
+		h := fnv.New32a()
+		h.Write([]byte(w))
+		v[h.Sum32()%hashEmbeddingSize]++
+	}
+	return v
+}
@@ -0,0 +1,180 @@
+package llmclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/grpc/codes"
+)
+
+// FailoverErrors is how many provider errors in a row make the backend in
+// use fail over to the next one of the chain
+const FailoverErrors = 3
+
+// ProviderError marks the errors of the LLM provider, as opposed to those of
+// its answer
+type ProviderError struct {
+	Model string
+	Err   error
+}
+
+func (e ProviderError) Error() string {
+	return fmt.Sprintf("error generating content with %s: %v", e.Model, e.Err)
+}
+
+func (e ProviderError) Unwrap() error { return e.Err }
+
+// IsQuotaError reports whether err is the provider refusing requests over
+// the quota of the API key
+func IsQuotaError(err error) bool {
+	var apiErr *apierror.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if s := apiErr.GRPCStatus(); s != nil && s.Code() == codes.ResourceExhausted {
+		return true
+	}
+	return apiErr.HTTPCode() == http.StatusTooManyRequests
+}
+
+// Backend is a provider and one of its models
+type Backend struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+func (b Backend) String() string {
+	if b.Provider == ProviderFake {
+		return ProviderFake
+	}
+	return b.Provider + ":" + b.Model
+}
+
+// ParseBackends parses comma separated backends, prefixed with their
+// provider and a colon unless they are Gemini models, and fake for the fake
+// provider
+func ParseBackends(value string) ([]Backend, error) {
+	var backends []Backend
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		b := Backend{Provider: ProviderGemini, Model: name}
+		if provider, model, ok := strings.Cut(name, ":"); ok {
+			b = Backend{Provider: provider, Model: model}
+		} else if name == ProviderFake {
+			b.Provider = ProviderFake
+		}
+		// The clients are built on the Gemini SDK, other providers such as
+		// OpenAI are not supported
+		if b.Provider != ProviderGemini && b.Provider != ProviderFake {
+			return nil, fmt.Errorf("unsupported provider %q in %q, only gemini and fake are supported", b.Provider, name)
+		}
+		backends = append(backends, b)
+	}
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no models given")
+	}
+	return backends, nil
+}
+
+// Failover is a chain of backends a run fails over along when the one in
+// use keeps failing, safe for concurrent use. After its cooldown it goes
+// back to the first backend of the chain, a quota or an outage of the
+// primary model not lasting for the whole run. The zero value has no chain
+// and never fails over.
+type Failover struct {
+	mu       sync.Mutex
+	chain    []Backend
+	cooldown time.Duration
+	current  int
+	errors   int
+	// switched is when the chain last failed over.
+	switched time.Time
+}
+
+// FailoverDecision is what to do after a provider error
+type FailoverDecision struct {
+	// Retry reports whether the call should be made again, with Backend.
+	Retry   bool
+	Backend Backend
+	// Switched reports Backend is the next one of the chain, Errors is the
+	// count of errors in a row of the backend otherwise.
+	Switched bool
+	Errors   int
+}
+
+// NewFailover returns a failover along chain, starting with its first
+// backend and going back to it cooldown after failing over, never if
+// cooldown is zero
+func NewFailover(chain []Backend, cooldown time.Duration) *Failover {
+	return &Failover{chain: chain, cooldown: cooldown}
+}
+
+// Current returns the backend in use, false for the zero value
+func (f *Failover) Current() (Backend, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.chain) == 0 {
+		return Backend{}, false
+	}
+	f.restorePrimary()
+	return f.chain[f.current], true
+}
+
+// restorePrimary goes back to the first backend once the cooldown has
+// passed since the chain failed over. The caller holds f.mu.
+func (f *Failover) restorePrimary() {
+	if f.current > 0 && f.cooldown > 0 && time.Since(f.switched) >= f.cooldown {
+		f.current, f.errors = 0, 0
+	}
+}
+
+// Fail counts a provider error of backend b and switches to the next one of
+// the chain on quota exhaustion or after FailoverErrors errors in a row.
+// Errors other than provider errors, and those of the last backend, are not
+// retried. An error of a backend the chain already failed over from, in a
+// call made concurrently with the one that switched, is retried with the
+// backend in use without being counted.
+func (f *Failover) Fail(b Backend, err error) FailoverDecision {
+	var pe ProviderError
+	if !errors.As(err, &pe) {
+		return FailoverDecision{}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.chain) == 0 {
+		return FailoverDecision{}
+	}
+	f.restorePrimary()
+	if b != f.chain[f.current] {
+		return FailoverDecision{Retry: true, Backend: f.chain[f.current], Switched: true}
+	}
+	if f.current >= len(f.chain)-1 {
+		return FailoverDecision{}
+	}
+	f.errors++
+	if !IsQuotaError(err) && f.errors < FailoverErrors {
+		return FailoverDecision{Retry: true, Backend: f.chain[f.current], Errors: f.errors}
+	}
+	f.current++
+	f.errors = 0
+	f.switched = time.Now()
+	return FailoverDecision{Retry: true, Backend: f.chain[f.current], Switched: true}
+}
+
+// Succeed resets the count of errors in a row of backend b, if it is still
+// the one in use
+func (f *Failover) Succeed(b Backend) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.chain) > 0 && b == f.chain[f.current] {
+		f.errors = 0
+	}
+}
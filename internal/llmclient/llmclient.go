@@ -0,0 +1,275 @@
+// Package llmclient is the client of the LLM providers shared by the doc
+// tools: the setup of the Gemini client, the fake provider of offline runs,
+// rate limiting, failover between models and token accounting.
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// LLM providers
+const (
+	ProviderGemini = "gemini"
+	// ProviderFake answers every prompt with Config.FakeResponse, for
+	// offline runs such as golden tests
+	ProviderFake = "fake"
+)
+
+// ErrMaxTokens is returned for an answer cut off at the output token limit
+var ErrMaxTokens = errors.New("cut off at the output token limit")
+
+// Config is the provider and model of a Client
+type Config struct {
+	Provider string
+	Model    string
+	APIKey   string
+	// Transport sends the requests to the provider, nil for the default one
+	// of the SDK.
+	Transport http.RoundTripper
+	// Temperature is the temperature of the model, negative for its default.
+	Temperature float64
+	// FakeResponse is the answer of the fake provider to every prompt.
+	FakeResponse string
+	// Limiter bounds the requests sent, nil for no limit. It is shared by
+	// the clients of a run.
+	Limiter *Limiter
+	// Observe, if not nil, is called after every request with its duration,
+	// its usage and the provider error it failed with, for metrics.
+	Observe func(model string, elapsed time.Duration, usage Usage, err error)
+}
+
+// Client sends prompts to the model of its Config
+type Client struct {
+	cfg    Config
+	client *genai.Client
+}
+
+// New returns a client of the provider of cfg, to be closed after use
+func New(ctx context.Context, cfg Config) (*Client, error) {
+	c := &Client{cfg: cfg}
+	switch cfg.Provider {
+	case ProviderFake:
+		return c, nil
+	case ProviderGemini:
+	default:
+		return nil, fmt.Errorf("unknown provider %q, use gemini or fake", cfg.Provider)
+	}
+	client, err := genai.NewClient(ctx, c.clientOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Gemini client: %w", c.redact(err))
+	}
+	c.client = client
+	return c, nil
+}
+
+// Close releases the connections of the client
+func (c *Client) Close() error {
+	if c.client == nil {
+		return nil
+	}
+	return c.client.Close()
+}
+
+// apiKeyTransport authenticates Gemini API requests with a key header
+type apiKeyTransport struct {
+	key  string
+	base http.RoundTripper
+}
+
+func (t apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("x-goog-api-key", t.key)
+	return t.base.RoundTrip(req)
+}
+
+// clientOptions returns the client options for the Gemini API. With a
+// custom transport the SDK ignores the API key option for its REST clients,
+// so the key is sent by the transport instead. The option is still needed by
+// the SDK's gRPC cache client, which is never used.
+func (c *Client) clientOptions() []option.ClientOption {
+	opts := []option.ClientOption{option.WithAPIKey(c.cfg.APIKey)}
+	if c.cfg.Transport != nil {
+		opts = append(opts, option.WithHTTPClient(&http.Client{
+			Transport: apiKeyTransport{key: c.cfg.APIKey, base: c.cfg.Transport},
+		}))
+	}
+	return opts
+}
+
+// Model returns the model of the client with the safety settings of the doc
+// tools and system as its system instruction, if not empty
+func (c *Client) Model(system string) *genai.GenerativeModel {
+	model := c.client.GenerativeModel(c.cfg.Model)
+	if system != "" {
+		model.SystemInstruction = genai.NewUserContent(genai.Text(system))
+	}
+	if c.cfg.Temperature >= 0 {
+		model.SetTemperature(float32(c.cfg.Temperature))
+	}
+
+	// Set safety settings to allow content generation
+	model.SafetySettings = []*genai.SafetySetting{
+		{
+			Category:  genai.HarmCategoryHarassment,
+			Threshold: genai.HarmBlockNone,
+		},
+		{
+			Category:  genai.HarmCategoryHateSpeech,
+			Threshold: genai.HarmBlockNone,
+		},
+		{
+			Category:  genai.HarmCategoryDangerousContent,
+			Threshold: genai.HarmBlockNone,
+		},
+		{
+			Category:  genai.HarmCategorySexuallyExplicit,
+			Threshold: genai.HarmBlockNone,
+		},
+	}
+	return model
+}
+
+// Generate sends prompt to the model, with system, if not empty, as its
+// system instruction, and with the model set up by configure, such as for
+// structured output, if it is not nil. It returns the text of the answer
+// and the tokens used, priced at the model of the client, also with
+// ErrMaxTokens. The fake provider answers with its response, the usage
+// estimated at four bytes per token so runs are reproducible.
+func (c *Client) Generate(ctx context.Context, system, prompt string, configure func(*genai.GenerativeModel)) (string, Usage, error) {
+	if c.cfg.Provider == ProviderFake {
+		return c.cfg.FakeResponse, FakeUsage(JoinPrompt(system, prompt), c.cfg.FakeResponse).Priced(c.cfg.Model), nil
+	}
+	model := c.Model(system)
+	if configure != nil {
+		configure(model)
+	}
+	resp, usage, err := c.send(ctx, func() (*genai.GenerateContentResponse, error) {
+		return model.GenerateContent(ctx, genai.Text(prompt))
+	})
+	if err != nil {
+		return "", usage, err
+	}
+	if len(resp.Candidates) > 0 && resp.Candidates[0].FinishReason == genai.FinishReasonMaxTokens {
+		return "", usage, ErrMaxTokens
+	}
+	text, ok := resp.Candidates[0].Content.Parts[0].(genai.Text)
+	if !ok {
+		return "", Usage{}, errors.New("unexpected response type from Gemini")
+	}
+	return string(text), usage, nil
+}
+
+// SendMessage sends parts to a chat of a model of the client, for
+// conversations such as function calls, and returns the answer with the
+// tokens used
+func (c *Client) SendMessage(ctx context.Context, chat *genai.ChatSession, parts ...genai.Part) (*genai.GenerateContentResponse, Usage, error) {
+	return c.send(ctx, func() (*genai.GenerateContentResponse, error) {
+		return chat.SendMessage(ctx, parts...)
+	})
+}
+
+// send makes a request within the rate limit and returns its answer, an
+// answer without content is an error
+func (c *Client) send(ctx context.Context, request func() (*genai.GenerateContentResponse, error)) (*genai.GenerateContentResponse, Usage, error) {
+	if err := c.cfg.Limiter.Wait(ctx); err != nil {
+		return nil, Usage{}, contextError(ctx, err)
+	}
+	started := time.Now()
+	resp, err := request()
+	if err != nil {
+		err = ProviderError{Model: c.cfg.Model, Err: c.redact(contextError(ctx, err))}
+		c.observe(started, Usage{}, err)
+		return nil, Usage{}, err
+	}
+	usage := ResponseUsage(resp).Priced(c.cfg.Model)
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		// A cut off answer can have no content, it is not the provider
+		// failing
+		if len(resp.Candidates) > 0 && resp.Candidates[0].FinishReason == genai.FinishReasonMaxTokens {
+			c.observe(started, usage, nil)
+			return resp, usage, ErrMaxTokens
+		}
+		err := ProviderError{Model: c.cfg.Model, Err: errors.New("no response received from Gemini")}
+		c.observe(started, usage, err)
+		return nil, Usage{}, err
+	}
+	c.observe(started, usage, nil)
+	return resp, usage, nil
+}
+
+// observe reports a request to Config.Observe
+func (c *Client) observe(started time.Time, usage Usage, err error) {
+	if c.cfg.Observe != nil {
+		c.cfg.Observe(c.cfg.Model, time.Since(started), usage, err)
+	}
+}
+
+// Models lists the models available to the API key
+func (c *Client) Models(ctx context.Context) ([]*genai.ModelInfo, error) {
+	if c.client == nil {
+		return nil, nil
+	}
+	var models []*genai.ModelInfo
+	iter := c.client.ListModels(ctx)
+	for {
+		info, err := iter.Next()
+		if err == iterator.Done {
+			return models, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing models: %w", c.redact(contextError(ctx, err)))
+		}
+		models = append(models, info)
+	}
+}
+
+// redactedError hides the API key in the message of err while keeping it
+// inspectable with errors.Is and errors.As
+type redactedError struct {
+	err error
+	key string
+}
+
+func (e redactedError) Error() string {
+	if e.key == "" {
+		return e.err.Error()
+	}
+	return strings.ReplaceAll(e.err.Error(), e.key, "REDACTED")
+}
+
+func (e redactedError) Unwrap() error { return e.err }
+
+// redact hides the API key of the client in err
+func (c *Client) redact(err error) error {
+	return redactedError{err: err, key: c.cfg.APIKey}
+}
+
+// contextError replaces err with the cause of ctx if ctx timed out, so the
+// error tells which timeout to raise
+func contextError(ctx context.Context, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	if cause := context.Cause(ctx); cause != nil {
+		return cause
+	}
+	return err
+}
+
+// JoinPrompt returns the system instruction and the prompt as a single text,
+// for providers and models without system instructions
+func JoinPrompt(system, prompt string) string {
+	if system == "" {
+		return prompt
+	}
+	return system + "\n\n" + prompt
+}
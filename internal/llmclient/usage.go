@@ -0,0 +1,78 @@
+package llmclient
+
+import "github.com/google/generative-ai-go/genai"
+
+// Usage counts the tokens consumed by LLM calls and their estimated cost.
+// The cost is priced per call, so a sum of calls to several models or of
+// calls below the long context threshold is still priced right.
+type Usage struct {
+	PromptTokens   int     `json:"prompt_tokens"`
+	ResponseTokens int     `json:"response_tokens"`
+	CostUSD        float64 `json:"cost_usd,omitempty"`
+}
+
+// Add adds the tokens and the cost of other to u
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.ResponseTokens += other.ResponseTokens
+	u.CostUSD += other.CostUSD
+}
+
+// Priced returns the usage of a single call to model with its cost set
+func (u Usage) Priced(model string) Usage {
+	u.CostUSD = EstimateCost(model, u)
+	return u
+}
+
+// ResponseUsage returns the tokens used by a response of Gemini
+func ResponseUsage(resp *genai.GenerateContentResponse) Usage {
+	if resp.UsageMetadata == nil {
+		return Usage{}
+	}
+	return Usage{
+		PromptTokens:   int(resp.UsageMetadata.PromptTokenCount),
+		ResponseTokens: int(resp.UsageMetadata.CandidatesTokenCount),
+	}
+}
+
+// FakeUsage estimates the tokens of a prompt and its answer at four bytes
+// per token, for the fake provider
+func FakeUsage(prompt, response string) Usage {
+	return Usage{PromptTokens: len(prompt) / 4, ResponseTokens: len(response) / 4}
+}
+
+// Price is the list price of a model in USD per million tokens
+type Price struct {
+	Input  float64
+	Output float64
+	// LongContextThreshold is the prompt size above which the long context
+	// prices apply, zero if the model has a single tier.
+	LongContextThreshold int
+	LongInput            float64
+	LongOutput           float64
+}
+
+// Prices are the Gemini API list prices used to estimate costs. They are
+// estimates, negotiated prices and free tiers are not taken into account.
+var Prices = map[string]Price{
+	"gemini-2.5-pro": {
+		Input: 1.25, Output: 10,
+		LongContextThreshold: 200_000, LongInput: 2.5, LongOutput: 15,
+	},
+	"gemini-2.5-flash": {Input: 0.3, Output: 2.5},
+}
+
+// EstimateCost returns the estimated cost in USD of a single call to model,
+// zero for models without a known price. The long context tier is chosen by
+// the prompt size, so it must not be given the usage summed over calls.
+func EstimateCost(model string, usage Usage) float64 {
+	p, ok := Prices[model]
+	if !ok {
+		return 0
+	}
+	in, out := p.Input, p.Output
+	if p.LongContextThreshold > 0 && usage.PromptTokens > p.LongContextThreshold {
+		in, out = p.LongInput, p.LongOutput
+	}
+	return (float64(usage.PromptTokens)*in + float64(usage.ResponseTokens)*out) / 1e6
+}
@@ -0,0 +1,41 @@
+package markdown
+
+import "strings"
+
+// Format serializes a document generated by the doc tools so that its
+// patches only hold changes of content: lines of spaces are emptied, runs of
+// blank lines collapsed to one, headings set apart by blank lines and the
+// document ends with a single newline. Trailing spaces after text, which
+// can be hard line breaks, and fenced code blocks are kept as they are.
+func Format(content string) string {
+	var out []string
+	blank := func() bool { return len(out) == 0 || out[len(out)-1] == "" }
+	inCode, afterHeading := false, false
+	for _, line := range ParseLines(content) {
+		text := line.Text
+		if strings.HasPrefix(text, "```") {
+			inCode = !inCode
+		} else if inCode {
+			out = append(out, text)
+			continue
+		}
+		if strings.TrimSpace(text) == "" {
+			if !blank() {
+				out = append(out, "")
+			}
+			continue
+		}
+		if (line.Level > 0 || afterHeading) && !blank() {
+			out = append(out, "")
+		}
+		out = append(out, text)
+		afterHeading = line.Level > 0
+	}
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
@@ -0,0 +1,113 @@
+// Package markdown holds the markdown utilities shared by the doc tools:
+// parsing documents into lines, headings and an outline of sections,
+// extracting and replacing sections by heading, matching and protecting
+// placeholders, and serializing documents so their patches stay small.
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// HeadingPattern matches an ATX heading, with its level marks and text
+	HeadingPattern = regexp.MustCompile(`(?m)^(#{1,6})\s+(.+?)\s*#*\s*$`)
+	// CommentPattern matches an HTML comment
+	CommentPattern = regexp.MustCompile(`(?s)<!--.*?-->`)
+	// CodeFencePattern matches a fenced code block
+	CodeFencePattern = regexp.MustCompile("(?ms)^```.*?^```")
+
+	// anchorPunctuation matches what headings lose in their anchor
+	anchorPunctuation = regexp.MustCompile(`[^\p{L}\p{N}\s_-]`)
+)
+
+// Line is a line of a markdown document, Level is the heading level or zero
+// for lines that are not headings
+type Line struct {
+	Text    string
+	Level   int
+	Heading string
+}
+
+// ParseLines splits content into lines and marks the headings, ignoring
+// lines in fenced code blocks
+func ParseLines(content string) []Line {
+	var lines []Line
+	inCode := false
+	for _, text := range strings.Split(content, "\n") {
+		line := Line{Text: text}
+		if strings.HasPrefix(text, "```") {
+			inCode = !inCode
+		} else if !inCode {
+			if m := HeadingPattern.FindStringSubmatch(text); m != nil {
+				line.Level, line.Heading = len(m[1]), m[2]
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// JoinLines returns the text of lines as it was parsed
+func JoinLines(lines []Line) string {
+	texts := make([]string, len(lines))
+	for i, l := range lines {
+		texts[i] = l.Text
+	}
+	return strings.Join(texts, "\n")
+}
+
+// ShiftHeadings changes the level of every heading by delta, keeping them
+// between 1 and 6
+func ShiftHeadings(lines []Line, delta int) string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.Text
+		if line.Level > 0 {
+			level := min(max(line.Level+delta, 1), 6)
+			texts[i] = strings.Repeat("#", level) + " " + line.Heading
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// Heading is a markdown heading with its level (number of leading '#')
+type Heading struct {
+	Level int
+	Text  string
+}
+
+// ParseHeadings returns the markdown headings in content, ignoring anything
+// inside HTML comments and fenced code blocks
+func ParseHeadings(content string) []Heading {
+	content = CommentPattern.ReplaceAllString(content, "")
+	content = CodeFencePattern.ReplaceAllString(content, "")
+
+	var headings []Heading
+	for _, m := range HeadingPattern.FindAllStringSubmatch(content, -1) {
+		headings = append(headings, Heading{Level: len(m[1]), Text: m[2]})
+	}
+	return headings
+}
+
+// Anchor returns the anchor of a heading on GitHub and the Elastic docs
+// sites
+func Anchor(text string) string {
+	text = strings.ToLower(strings.TrimSpace(anchorPunctuation.ReplaceAllString(text, "")))
+	return strings.Join(strings.Fields(text), "-")
+}
+
+// Anchors returns the anchors of the headings of content with the text of
+// their heading. Repeated headings get numbered anchors, as on GitHub.
+func Anchors(content string) map[string]string {
+	anchors := make(map[string]string)
+	for _, h := range ParseHeadings(content) {
+		anchor := Anchor(h.Text)
+		for i := 1; anchors[anchor] != ""; i++ {
+			anchor = fmt.Sprintf("%s-%d", Anchor(h.Text), i)
+		}
+		anchors[anchor] = h.Text
+	}
+	return anchors
+}
@@ -0,0 +1,102 @@
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// keepTokenPattern matches the tokens Protect puts in place of the spans
+var keepTokenPattern = regexp.MustCompile(`@@KEEP_(\d+)@@`)
+
+// PlaceholderRegexp returns the regular expression of a placeholder format
+// such as {{fields "{data_stream}"}}, with value, a regular expression, in
+// place of variable. Spaces inside the delimiters of the placeholder, such
+// as {{ fields }} for {{fields}}, match.
+func PlaceholderRegexp(format, variable, value string) string {
+	parts := []string{format}
+	if variable != "" {
+		parts = strings.Split(format, variable)
+	}
+	for i, part := range parts {
+		parts[i] = flexibleSpaces(part)
+	}
+	return strings.Join(parts, value)
+}
+
+// flexibleSpaces quotes s for a regular expression in which a run of spaces
+// matches any run of spaces, and delimiters may be separated from the rest
+// by spaces or not
+func flexibleSpaces(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == ' ' || r == '\t' {
+			j := i
+			for j+1 < len(runes) && (runes[j+1] == ' ' || runes[j+1] == '\t') {
+				j++
+			}
+			if (i > 0 && isDelimiter(runes[i-1])) || (j+1 < len(runes) && isDelimiter(runes[j+1])) {
+				b.WriteString(`\s*`)
+			} else {
+				b.WriteString(`\s+`)
+			}
+			i = j
+			continue
+		}
+		if i > 0 && runes[i-1] != ' ' && runes[i-1] != '\t' && isDelimiter(runes[i-1]) != isDelimiter(r) {
+			b.WriteString(`\s*`)
+		}
+		b.WriteString(regexp.QuoteMeta(string(r)))
+	}
+	return b.String()
+}
+
+// isDelimiter reports whether r is part of the delimiters of a placeholder
+// rather than its name or arguments
+func isDelimiter(r rune) bool {
+	return strings.ContainsRune("{}[]()<>%#$@!|~*", r)
+}
+
+// Protect replaces the spans of content matched by pattern, such as code
+// and placeholders, with numbered keep tokens and returns them in order, so
+// a rewrite of the text cannot touch them
+func Protect(content string, pattern *regexp.Regexp) (string, []string) {
+	var spans []string
+	masked := pattern.ReplaceAllStringFunc(content, func(span string) string {
+		spans = append(spans, span)
+		return "@@KEEP_" + strconv.Itoa(len(spans)-1) + "@@"
+	})
+	return masked, spans
+}
+
+// Restore puts the spans of Protect back in place of their keep tokens. It
+// fails if the rewrite dropped, duplicated or made up a token.
+func Restore(content string, spans []string) (string, error) {
+	seen := make([]bool, len(spans))
+	var restoreErr error
+	restored := keepTokenPattern.ReplaceAllStringFunc(content, func(token string) string {
+		i, _ := strconv.Atoi(keepTokenPattern.FindStringSubmatch(token)[1])
+		switch {
+		case i >= len(spans):
+			restoreErr = fmt.Errorf("unknown token %s", token)
+			return token
+		case seen[i]:
+			restoreErr = fmt.Errorf("token %s was duplicated", token)
+			return token
+		}
+		seen[i] = true
+		return spans[i]
+	})
+	if restoreErr != nil {
+		return "", restoreErr
+	}
+	for i, ok := range seen {
+		if !ok {
+			return "", fmt.Errorf("code or placeholder %q was lost", spans[i])
+		}
+	}
+	return restored, nil
+}
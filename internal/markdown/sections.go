@@ -0,0 +1,121 @@
+package markdown
+
+import "strings"
+
+// Section is a node of the outline of a document: a heading with the lines
+// up to the next heading of the same or a higher level, and the sections of
+// its subheadings
+type Section struct {
+	Heading string
+	Level   int
+	// Start is the index of the line of the heading, End that of the first
+	// line after the section, its subsections included.
+	Start    int
+	End      int
+	Children []*Section
+}
+
+// Outline returns the sections of the headings of lines as a tree, a heading
+// nested in the closest heading of a lower level before it
+func Outline(lines []Line) []*Section {
+	var roots, open []*Section
+	for i, line := range lines {
+		if line.Level == 0 {
+			continue
+		}
+		s := &Section{Heading: line.Heading, Level: line.Level, Start: i, End: SectionEnd(lines, i)}
+		for len(open) > 0 && open[len(open)-1].Level >= line.Level {
+			open = open[:len(open)-1]
+		}
+		if len(open) == 0 {
+			roots = append(roots, s)
+		} else {
+			parent := open[len(open)-1]
+			parent.Children = append(parent.Children, s)
+		}
+		open = append(open, s)
+	}
+	return roots
+}
+
+// SectionEnd returns the index of the first line after the section started
+// by the heading at start
+func SectionEnd(lines []Line, start int) int {
+	for i := start + 1; i < len(lines); i++ {
+		if lines[i].Level > 0 && lines[i].Level <= lines[start].Level {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+// FindHeading returns the index of the first heading with the given text,
+// or -1 if there is none
+func FindHeading(lines []Line, heading string) int {
+	for i, line := range lines {
+		if line.Level > 0 && strings.EqualFold(line.Heading, heading) {
+			return i
+		}
+	}
+	return -1
+}
+
+// SectionLines returns the lines of the section with the given heading, its
+// subsections included, or -1 if there is none
+func SectionLines(lines []Line, heading string) (int, int) {
+	start := FindHeading(lines, heading)
+	if start < 0 {
+		return -1, -1
+	}
+	return start, SectionEnd(lines, start)
+}
+
+// ExtractSection returns the section of content with the given heading,
+// without the blank lines around it, or an empty string if there is none
+func ExtractSection(content, heading string) string {
+	lines := ParseLines(content)
+	start, end := SectionLines(lines, heading)
+	if start < 0 {
+		return ""
+	}
+	return strings.TrimSpace(JoinLines(lines[start:end]))
+}
+
+// ReplaceSection replaces the section of content with the given heading by
+// section. The rest of content, including the blank lines after the
+// section, is left byte for byte.
+func ReplaceSection(content, heading, section string) string {
+	lines := ParseLines(content)
+	start, end := SectionLines(lines, heading)
+	if start < 0 {
+		return content
+	}
+	return ReplaceLines(lines, start, end, strings.TrimSpace(section))
+}
+
+// ReplaceLines replaces lines[start:end] by text, inserting it before
+// lines[start] if start == end. The blank lines at the end of the replaced
+// lines and the other lines are left byte for byte.
+func ReplaceLines(lines []Line, start, end int, text string) string {
+	blank := 0
+	for i := end - 1; i > start && strings.TrimSpace(lines[i].Text) == ""; i-- {
+		blank++
+	}
+	replaced := strings.Split(text, "\n")
+	replaced = append(replaced, make([]string, blank)...)
+	before := JoinLines(lines[:start])
+	if start > 0 {
+		before += "\n"
+	}
+	after := JoinLines(lines[end:])
+	if end < len(lines) {
+		after = "\n" + after
+	}
+	return before + strings.Join(replaced, "\n") + after
+}
+
+// HasProse reports whether text holds more than HTML comments and blank
+// lines
+func HasProse(text string) bool {
+	return strings.TrimSpace(CommentPattern.ReplaceAllString(text, "")) != ""
+}
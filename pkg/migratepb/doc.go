@@ -0,0 +1,5 @@
+// Package migratepb is the gRPC API of the docs-template-update server,
+// generated from migrate.proto, for the clients of the MigrationService.
+package migratepb
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative ../migratepb/migrate.proto
@@ -0,0 +1,140 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: migratepb/migrate.proto
+
+package migratepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	MigrationService_Migrate_FullMethodName = "/docstemplateupdate.v1.MigrationService/Migrate"
+)
+
+// MigrationServiceClient is the client API for MigrationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type MigrationServiceClient interface {
+	// Migrate runs the migration pipeline on a README. Progress events are
+	// streamed as each stage starts, followed by a single result.
+	Migrate(ctx context.Context, in *MigrateRequest, opts ...grpc.CallOption) (MigrationService_MigrateClient, error)
+}
+
+type migrationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMigrationServiceClient(cc grpc.ClientConnInterface) MigrationServiceClient {
+	return &migrationServiceClient{cc}
+}
+
+func (c *migrationServiceClient) Migrate(ctx context.Context, in *MigrateRequest, opts ...grpc.CallOption) (MigrationService_MigrateClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MigrationService_ServiceDesc.Streams[0], MigrationService_Migrate_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &migrationServiceMigrateClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MigrationService_MigrateClient interface {
+	Recv() (*MigrateResponse, error)
+	grpc.ClientStream
+}
+
+type migrationServiceMigrateClient struct {
+	grpc.ClientStream
+}
+
+func (x *migrationServiceMigrateClient) Recv() (*MigrateResponse, error) {
+	m := new(MigrateResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MigrationServiceServer is the server API for MigrationService service.
+// All implementations must embed UnimplementedMigrationServiceServer
+// for forward compatibility
+type MigrationServiceServer interface {
+	// Migrate runs the migration pipeline on a README. Progress events are
+	// streamed as each stage starts, followed by a single result.
+	Migrate(*MigrateRequest, MigrationService_MigrateServer) error
+	mustEmbedUnimplementedMigrationServiceServer()
+}
+
+// UnimplementedMigrationServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedMigrationServiceServer struct {
+}
+
+func (UnimplementedMigrationServiceServer) Migrate(*MigrateRequest, MigrationService_MigrateServer) error {
+	return status.Errorf(codes.Unimplemented, "method Migrate not implemented")
+}
+func (UnimplementedMigrationServiceServer) mustEmbedUnimplementedMigrationServiceServer() {}
+
+// UnsafeMigrationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MigrationServiceServer will
+// result in compilation errors.
+type UnsafeMigrationServiceServer interface {
+	mustEmbedUnimplementedMigrationServiceServer()
+}
+
+func RegisterMigrationServiceServer(s grpc.ServiceRegistrar, srv MigrationServiceServer) {
+	s.RegisterService(&MigrationService_ServiceDesc, srv)
+}
+
+func _MigrationService_Migrate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(MigrateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MigrationServiceServer).Migrate(m, &migrationServiceMigrateServer{stream})
+}
+
+type MigrationService_MigrateServer interface {
+	Send(*MigrateResponse) error
+	grpc.ServerStream
+}
+
+type migrationServiceMigrateServer struct {
+	grpc.ServerStream
+}
+
+func (x *migrationServiceMigrateServer) Send(m *MigrateResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// MigrationService_ServiceDesc is the grpc.ServiceDesc for MigrationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MigrationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "docstemplateupdate.v1.MigrationService",
+	HandlerType: (*MigrationServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Migrate",
+			Handler:       _MigrationService_Migrate_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "migratepb/migrate.proto",
+}